@@ -37,15 +37,81 @@ CREATE TABLE user_customers (
     FOREIGN KEY (customer_id) REFERENCES customers(id) 
 ); 
  
+CREATE TABLE customer_addresses ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    customer_id INT NOT NULL, 
+    label VARCHAR(50) NOT NULL, 
+    recipient_name VARCHAR(100) NOT NULL, 
+    phone_number VARCHAR(20) NOT NULL, 
+    address TEXT NOT NULL, 
+    city VARCHAR(100) NOT NULL, 
+    postal_code VARCHAR(10) NOT NULL, 
+    zone VARCHAR(50) NOT NULL, 
+    is_default BOOLEAN NOT NULL DEFAULT FALSE, 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL, 
+    updated_by INT, 
+    FOREIGN KEY (created_by) REFERENCES users(id), 
+    FOREIGN KEY (updated_by) REFERENCES users(id), 
+    FOREIGN KEY (customer_id) REFERENCES customers(id), 
+    INDEX idx_customer_id (customer_id) 
+); 
+ 
+CREATE TABLE shipping_rates ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    name VARCHAR(100) NOT NULL, 
+    type ENUM('flat', 'weight', 'zone') NOT NULL, 
+    zone VARCHAR(50), -- hanya untuk tipe zone
+    min_weight INT, -- gram, NULL berarti tanpa batas bawah
+    max_weight INT, -- gram, NULL berarti tanpa batas atas
+    fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fee >= 0), 
+    is_active BOOLEAN NOT NULL DEFAULT TRUE, 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL, 
+    updated_by INT, 
+    FOREIGN KEY (created_by) REFERENCES users(id), 
+    FOREIGN KEY (updated_by) REFERENCES users(id), 
+    INDEX idx_type_active (type, is_active) 
+); 
+ 
+CREATE TABLE tax_classes ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    code VARCHAR(20) NOT NULL UNIQUE, -- contoh: standard, reduced, exempt
+    name VARCHAR(100) NOT NULL, 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL, 
+    updated_by INT,  
+    FOREIGN KEY (created_by) REFERENCES users(id), 
+    FOREIGN KEY (updated_by) REFERENCES users(id)
+); 
+
+CREATE TABLE tax_rates ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    tax_class VARCHAR(20) NOT NULL, 
+    rate DECIMAL(5,2) NOT NULL CHECK (rate >= 0 AND rate <= 100), -- persen
+    effective_from DATE NOT NULL, 
+    effective_to DATE, -- NULL berarti berlaku sampai ada tarif baru
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    FOREIGN KEY (created_by) REFERENCES users(id), 
+    FOREIGN KEY (tax_class) REFERENCES tax_classes(code), 
+    UNIQUE INDEX idx_tax_class_effective_from (tax_class, effective_from)
+); 
+
 CREATE TABLE categories ( 
     id INT PRIMARY KEY AUTO_INCREMENT, 
     name VARCHAR(100) NOT NULL, 
+    tax_class VARCHAR(20) NOT NULL DEFAULT 'standard', 
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
     updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     created_by INT NOT NULL, 
     updated_by INT,  
     FOREIGN KEY (created_by) REFERENCES users(id), 
     FOREIGN KEY (updated_by) REFERENCES users(id), 
+    FOREIGN KEY (tax_class) REFERENCES tax_classes(code), 
     UNIQUE INDEX idx_name (name) 
 ); 
  
@@ -56,6 +122,9 @@ CREATE TABLE products (
     description TEXT, 
     category_id INT NOT NULL, 
     price DECIMAL(10,2) DEFAULT 0 NOT NULL, 
+    tax_class VARCHAR(20), -- NULL berarti mengikuti kelas pajak kategori
+    price_includes_tax BOOLEAN NOT NULL DEFAULT FALSE, -- TRUE jika harga sudah termasuk pajak
+    weight INT NOT NULL DEFAULT 0 CHECK (weight >= 0), -- gram
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
     updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, 
     created_by INT NOT NULL, 
@@ -63,6 +132,7 @@ CREATE TABLE products (
     FOREIGN KEY (created_by) REFERENCES users(id), 
     FOREIGN KEY (updated_by) REFERENCES users(id), 
     FOREIGN KEY (category_id) REFERENCES categories(id), 
+    FOREIGN KEY (tax_class) REFERENCES tax_classes(code), 
     INDEX idx_category_id (category_id), 
     INDEX idx_price (price), 
     INDEX idx_name (name) 
@@ -75,6 +145,10 @@ CREATE TABLE orders (
     date DATE NOT NULL, 
     status ENUM('processing', 'completed', 'cancel') NOT NULL DEFAULT 'processing',
     total DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (total >= 0),
+    shipping_address_id INT,
+    shipping_address TEXT, -- snapshot alamat pengiriman saat checkout
+    shipping_zone VARCHAR(50), -- snapshot zona alamat, dipakai menghitung ulang ongkos kirim
+    shipping_fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (shipping_fee >= 0),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
     updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     created_by INT NOT NULL,
@@ -82,6 +156,7 @@ CREATE TABLE orders (
     FOREIGN KEY (created_by) REFERENCES users(id),
     FOREIGN KEY (updated_by) REFERENCES users(id),
     FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (shipping_address_id) REFERENCES customer_addresses(id),
     INDEX idx_order_number_display (number_display),
     INDEX idx_date (date), 
     INDEX idx_status (status)
@@ -92,6 +167,12 @@ CREATE TABLE order_details (
     order_id INT NOT NULL, 
     product_id INT NOT NULL,
     qty INT NOT NULL DEFAULT 1,
+    -- Snapshot data produk saat order dibuat agar perubahan harga tidak mengubah histori
+    product_name VARCHAR(100) NOT NULL,
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_class VARCHAR(20) NOT NULL DEFAULT 'standard',
+    price_includes_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    weight INT NOT NULL DEFAULT 0 CHECK (weight >= 0), -- gram per unit
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
     updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     created_by INT NOT NULL, 
@@ -109,8 +190,11 @@ CREATE TABLE billings (
     issue_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     due_date TIMESTAMP,
     tax DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax >= 0), 
+    shipping_fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (shipping_fee >= 0), 
     total DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (total >= 0), 
     status ENUM('unpaid', 'paid', 'lesspaid', 'cancelled', 'refunded') NOT NULL DEFAULT 'unpaid', 
+    -- Berisi order_id hanya selama billing masih aktif (unpaid/lesspaid), NULL selain itu
+    active_order_id INT GENERATED ALWAYS AS (CASE WHEN status IN ('unpaid', 'lesspaid') THEN order_id END) STORED,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
     updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     created_by INT NOT NULL,
@@ -118,16 +202,36 @@ CREATE TABLE billings (
     FOREIGN KEY (created_by) REFERENCES users(id),
     FOREIGN KEY (updated_by) REFERENCES users(id),
     FOREIGN KEY (order_id) REFERENCES orders(id),
+    UNIQUE INDEX uq_billing_active_order (active_order_id), -- maksimal satu billing aktif per order
     INDEX idx_billing_number_display (number_display),
     INDEX idx_status (status)
 );
  
+CREATE TABLE payment_methods ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    code VARCHAR(20) NOT NULL UNIQUE, -- sama dengan entity.Method, contoh 'qris'
+    name VARCHAR(50) NOT NULL, 
+    enabled BOOLEAN NOT NULL DEFAULT TRUE, 
+    fee_flat DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fee_flat >= 0), -- biaya tetap per transaksi
+    fee_percent DECIMAL(5,2) NOT NULL DEFAULT 0 CHECK (fee_percent >= 0 AND fee_percent <= 100), -- biaya persen dari nominal
+    min_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_amount >= 0), -- 0 berarti tanpa batas minimal
+    max_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (max_amount >= 0), -- 0 berarti tanpa batas maksimal
+    display_order INT NOT NULL DEFAULT 0, 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL,
+    updated_by INT, 
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    INDEX idx_enabled_display_order (enabled, display_order)
+); 
+
 CREATE TABLE payments ( 
     id INT PRIMARY KEY AUTO_INCREMENT, 
     billing_id INT NOT NULL, 
     date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (amount >= 0), 
-    method ENUM('credit_card', 'va', 'transfer') NOT NULL, 
+    method VARCHAR(20) NOT NULL, 
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
     updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     created_by INT NOT NULL,
@@ -135,10 +239,321 @@ CREATE TABLE payments (
     FOREIGN KEY (created_by) REFERENCES users(id),
     FOREIGN KEY (updated_by) REFERENCES users(id),
     FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (method) REFERENCES payment_methods(code),
     INDEX idx_date (date),
     INDEX idx_method (method)
 ); 
 
+CREATE TABLE cart_items ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    customer_id INT NOT NULL, 
+    product_id INT NOT NULL,
+    qty INT NOT NULL DEFAULT 1 CHECK (qty > 0),
+    -- Harga produk saat item ditambahkan ke cart, dipakai untuk mendeteksi perubahan harga
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL,
+    updated_by INT,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE INDEX idx_cart_customer_product (customer_id, product_id)
+); 
+
+CREATE TABLE shipments ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    order_id INT NOT NULL UNIQUE, 
+    status ENUM('picking', 'packing', 'shipped', 'delivered') NOT NULL DEFAULT 'picking', 
+    courier VARCHAR(50), 
+    tracking_number VARCHAR(100), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT, -- NULL jika dibuat otomatis oleh sistem
+    updated_by INT,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    INDEX idx_status (status),
+    INDEX idx_tracking_number (tracking_number)
+); 
+
+CREATE TABLE shipment_events ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    shipment_id INT NOT NULL, 
+    status ENUM('picking', 'packing', 'shipped', 'delivered') NOT NULL, 
+    note VARCHAR(255), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (shipment_id) REFERENCES shipments(id),
+    INDEX idx_shipment_id (shipment_id)
+); 
+
+CREATE TABLE returns ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    number_display VARCHAR(50) UNIQUE, 
+    order_id INT NOT NULL, 
+    billing_id INT NOT NULL, -- billing lunas yang menjadi dasar refund
+    customer_id INT NOT NULL, 
+    status ENUM('requested', 'approved', 'rejected', 'received') NOT NULL DEFAULT 'requested', 
+    reason VARCHAR(255) NOT NULL, 
+    admin_note VARCHAR(255), 
+    refund_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (refund_amount >= 0), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL,
+    updated_by INT,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    INDEX idx_status (status)
+); 
+
+CREATE TABLE return_items ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    return_id INT NOT NULL, 
+    order_detail_id INT NOT NULL, 
+    product_id INT NOT NULL, 
+    qty INT NOT NULL CHECK (qty > 0), 
+    price DECIMAL(10,2) NOT NULL DEFAULT 0, -- harga dari snapshot order_details
+    item_condition ENUM('pending', 'restock', 'damaged') NOT NULL DEFAULT 'pending', 
+    FOREIGN KEY (return_id) REFERENCES returns(id),
+    FOREIGN KEY (order_detail_id) REFERENCES order_details(id),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+); 
+
+CREATE TABLE refunds ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    payment_id INT NOT NULL, -- pembayaran yang dananya dikembalikan
+    return_id INT, -- NULL jika refund manual tanpa retur
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0), 
+    reason VARCHAR(255) NOT NULL, 
+    status ENUM('pending', 'completed', 'failed') NOT NULL DEFAULT 'completed', -- pending selama menunggu payment gateway
+    charge_ref VARCHAR(64) NULL, -- charge gateway yang direfund, NULL jika pembayaran tanpa charge
+    gateway_ref VARCHAR(64) NULL, -- ID refund dari payment gateway
+    message VARCHAR(255), -- alasan penolakan dari gateway
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (return_id) REFERENCES returns(id),
+    INDEX idx_created_at (created_at),
+    INDEX idx_status (status)
+); 
+
+CREATE TABLE billing_tax_lines ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    order_detail_id INT NOT NULL, 
+    tax_class VARCHAR(20) NOT NULL, 
+    rate DECIMAL(5,2) NOT NULL, -- tarif yang berlaku saat billing dibuat
+    price_includes_tax BOOLEAN NOT NULL DEFAULT FALSE, 
+    taxable_amount DECIMAL(10,2) NOT NULL DEFAULT 0, -- dasar pengenaan pajak (tanpa pajak)
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0, 
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (order_detail_id) REFERENCES order_details(id),
+    INDEX idx_billing_id (billing_id)
+); 
+
+CREATE TABLE stock_reservations ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    product_id INT NOT NULL, 
+    qty INT NOT NULL CHECK (qty > 0), 
+    status ENUM('reserved', 'committed', 'released') NOT NULL DEFAULT 'reserved', -- committed saat lunas, released saat billing kedaluwarsa
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    INDEX idx_billing_id (billing_id),
+    INDEX idx_status (status)
+); 
+
+CREATE TABLE billing_audits ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    order_id INT NOT NULL, 
+    action ENUM('superseded', 'expired') NOT NULL, 
+    replaced_by INT, -- billing pengganti jika action = superseded
+    note VARCHAR(255), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT, -- NULL jika dijalankan worker
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    FOREIGN KEY (replaced_by) REFERENCES billings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_order_id (order_id)
+); 
+
+CREATE TABLE billing_installments ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    seq INT NOT NULL CHECK (seq > 0), -- urutan cicilan, dibayar dari yang terkecil
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0), 
+    paid_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (paid_amount >= 0), 
+    due_date DATE NOT NULL, 
+    status ENUM('open', 'partial', 'paid') NOT NULL DEFAULT 'open', 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by INT NOT NULL, 
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    UNIQUE INDEX uq_billing_installment_seq (billing_id, seq)
+); 
+
+CREATE TABLE payment_allocations ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    payment_id INT NOT NULL, 
+    installment_id INT NOT NULL, 
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (installment_id) REFERENCES billing_installments(id),
+    INDEX idx_payment_id (payment_id)
+); 
+
+CREATE TABLE customer_credits ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    customer_id INT NOT NULL, 
+    billing_id INT, 
+    payment_id INT, 
+    type ENUM('overpayment', 'applied', 'credit_note', 'expired_billing') NOT NULL, 
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0), -- positif menambah saldo, negatif memakai saldo
+    note VARCHAR(255), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_customer_id (customer_id)
+); 
+
+CREATE TABLE billing_adjustments ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    number_display VARCHAR(20) NOT NULL UNIQUE, -- CN-YYYYMM-NNN untuk credit note, DN-YYYYMM-NNN untuk debit note
+    billing_id INT NOT NULL, 
+    type ENUM('credit', 'debit') NOT NULL, 
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0), 
+    reason VARCHAR(255) NOT NULL, 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_billing_id (billing_id)
+); 
+
+CREATE TABLE card_tokens ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    customer_id INT NOT NULL, 
+    token VARCHAR(64) NOT NULL UNIQUE, -- token dari payment gateway; nomor kartu dan CVV tidak pernah disimpan
+    brand ENUM('visa', 'mastercard', 'amex', 'jcb') NOT NULL, 
+    last4 CHAR(4) NOT NULL, 
+    exp_month TINYINT NOT NULL CHECK (exp_month BETWEEN 1 AND 12), 
+    exp_year SMALLINT NOT NULL, 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+); 
+
+CREATE TABLE payment_charges ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    charge_ref VARCHAR(64) NOT NULL UNIQUE, -- ID transaksi dari payment gateway
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0), -- nominal yang dibayarkan ke billing
+    fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fee >= 0), -- biaya metode, ditagih gateway di atas amount
+    method VARCHAR(20) NOT NULL, 
+    status ENUM('pending', 'approved', 'declined') NOT NULL DEFAULT 'pending', 
+    message VARCHAR(255), 
+    card_token_id INT NULL, -- kartu yang di-charge, hanya untuk credit_card
+    qr_payload VARCHAR(512) NULL, -- payload QR EMVCo yang ditampilkan ke customer, hanya untuk qris
+    payment_id INT NULL, -- terisi setelah charge approved dan payment dicatat
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (card_token_id) REFERENCES card_tokens(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (method) REFERENCES payment_methods(code),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_status (status)
+); 
+
+CREATE TABLE virtual_accounts ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    billing_id INT NOT NULL, 
+    customer_id INT NOT NULL, 
+    bank VARCHAR(20) NOT NULL, -- kode bank: bca, bni, bri, mandiri
+    va_number VARCHAR(16) NOT NULL UNIQUE, -- prefix bank (5) + customer (5) + billing (5) + check digit Luhn (1)
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    UNIQUE KEY uq_billing_bank (billing_id, bank),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+); 
+
+CREATE TABLE bank_statement_lines ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    source VARCHAR(255) NOT NULL, -- nama file statement asal
+    fingerprint CHAR(64) NOT NULL UNIQUE, -- sha256 tanggal|nominal|referensi|keterangan, mencegah impor ganda
+    booked_at DATE NOT NULL, 
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0), 
+    reference VARCHAR(100), 
+    description VARCHAR(255), 
+    status ENUM('matched', 'review', 'resolved', 'ignored') NOT NULL DEFAULT 'review', 
+    billing_id INT NULL, -- billing yang dibayar, atau saran billing saat review
+    payment_id INT NULL, 
+    note VARCHAR(255), 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    created_by INT NOT NULL, 
+    resolved_at TIMESTAMP NULL, 
+    resolved_by INT NULL, 
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id),
+    INDEX idx_status (status)
+); 
+
+CREATE TABLE webhook_events ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    event_id VARCHAR(64) NOT NULL UNIQUE, -- ID event dari payment gateway, mencegah event diproses dua kali
+    type VARCHAR(50) NOT NULL, 
+    charge_ref VARCHAR(64) NOT NULL, 
+    payload TEXT NOT NULL, 
+    status ENUM('received', 'processed', 'failed') NOT NULL DEFAULT 'received', 
+    error VARCHAR(255), 
+    attempts INT NOT NULL DEFAULT 1, 
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    processed_at TIMESTAMP NULL, 
+    INDEX idx_charge_ref (charge_ref)
+); 
+
+CREATE TABLE idempotency_keys ( 
+    id INT PRIMARY KEY AUTO_INCREMENT, 
+    idem_key VARCHAR(64) NOT NULL, 
+    user_id INT NOT NULL, 
+    operation VARCHAR(32) NOT NULL, -- create_order, create_payment, checkout
+    request_hash CHAR(64) NOT NULL, -- sha256 isi permintaan
+    resource_id INT NULL, -- ID order atau charge yang dibuat
+    response TEXT, -- hasil permintaan pertama (JSON)
+    status ENUM('pending', 'completed') NOT NULL DEFAULT 'pending', 
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
+    expires_at DATETIME NOT NULL, 
+    FOREIGN KEY (user_id) REFERENCES users(id), 
+    UNIQUE KEY uq_idempotency (user_id, operation, idem_key), 
+    INDEX idx_expires_at (expires_at)
+); 
+
 -- Store Procedure
 
 DELIMITER $$
@@ -147,10 +562,10 @@ CREATE PROCEDURE sp_update_order_total(IN p_order_id INT)
 BEGIN
     DECLARE v_total DECIMAL(10,2);
 
-    SELECT IFNULL(SUM(od.qty * p.price), 0)
+    -- Gunakan harga snapshot di order_details, bukan harga produk saat ini
+    SELECT IFNULL(SUM(od.qty * od.price), 0)
     INTO v_total
     FROM order_details od
-    JOIN products p ON od.product_id = p.id
     WHERE od.order_id = p_order_id;
 
     UPDATE orders
@@ -173,8 +588,9 @@ BEGIN
     DECLARE current_payment_total DECIMAL(10,2) DEFAULT 0;
     DECLARE billing_total         DECIMAL(10,2) DEFAULT 0;
 
-    -- 1. Hitung total pembayaran untuk billing ini, KECUALI baris pembayaran yang sedang di-update
+    -- 1. Hitung dana bersih billing ini: total pembayaran dikurangi dana yang sudah direfund (refund gagal tidak dihitung)
     SELECT IFNULL(SUM(amount), 0)
+        - IFNULL((SELECT SUM(rf.amount) FROM refunds rf WHERE rf.billing_id = p_billing_id AND rf.status <> 'failed'), 0)
     INTO current_payment_total
     FROM payments
     WHERE billing_id = p_billing_id;
@@ -183,11 +599,15 @@ BEGIN
     --    atau negatif untuk “mengurangi” jumlah lama saat billing_id lama)
     SET current_payment_total = current_payment_total + adjustment;
 
-    -- 3. Ambil total tagihan (billing) dari tabel billings
-    SELECT total
+    -- 3. Ambil total tagihan (billing) setelah credit note / debit note
+    SELECT b.total + IFNULL((
+        SELECT SUM(CASE WHEN ba.type = 'debit' THEN ba.amount ELSE -ba.amount END)
+        FROM billing_adjustments ba
+        WHERE ba.billing_id = b.id
+    ), 0)
     INTO billing_total
-    FROM billings
-    WHERE id = p_billing_id;
+    FROM billings b
+    WHERE b.id = p_billing_id;
 
     -- 4. Jika billing_id tidak ditemukan, is_valid = FALSE
     IF billing_total IS NULL THEN
@@ -283,11 +703,36 @@ INSERT INTO user_customers (user_id, customer_id) VALUES
 (4, 2),
 (5, 3);
 
+-- CUSTOMER_ADDRESSES
+INSERT INTO customer_addresses (customer_id, label, recipient_name, phone_number, address, city, postal_code, zone, is_default, created_by) VALUES
+(1, 'Rumah', 'Andi Nugroho', '0811111111', 'Jl. Mawar No.1', 'Jakarta', '10110', 'jabodetabek', TRUE, 3),
+(2, 'Rumah', 'Budi Santoso', '0822222222', 'Jl. Melati No.2', 'Bandung', '40111', 'jawa', TRUE, 4),
+(3, 'Rumah', 'Citra Ayu', '0833333333', 'Jl. Kenanga No.3', 'Surabaya', '60111', 'jawa', TRUE, 5);
+
+-- SHIPPING_RATES
+INSERT INTO shipping_rates (name, type, zone, min_weight, max_weight, fee, created_by) VALUES
+('Flat Nasional', 'flat', NULL, NULL, NULL, 50000, 1),
+('Ringan s/d 5kg', 'weight', NULL, 0, 5000, 20000, 1),
+('Berat 5-20kg', 'weight', NULL, 5001, 20000, 45000, 1),
+('Jabodetabek', 'zone', 'jabodetabek', NULL, NULL, 15000, 1);
+
+-- TAX CLASSES
+INSERT INTO tax_classes (code, name, created_by) VALUES
+('standard', 'PPN Standar', 1),
+('reduced', 'PPN Tarif Khusus', 1),
+('exempt', 'Bebas PPN', 1);
+
+-- TAX RATES
+INSERT INTO tax_rates (tax_class, rate, effective_from, created_by) VALUES
+('standard', 10.00, '2020-01-01', 1),
+('reduced', 5.00, '2020-01-01', 1),
+('exempt', 0.00, '2020-01-01', 1);
+
 -- CATEGORIES
-INSERT INTO categories (name, created_by, updated_by) VALUES
-('Gym Equipment', 1, 1),
-('Outdoor & Hiking', 1, 1),
-('Supplements', 2, 2);
+INSERT INTO categories (name, tax_class, created_by, updated_by) VALUES
+('Gym Equipment', 'standard', 1, 1),
+('Outdoor & Hiking', 'standard', 1, 1),
+('Supplements', 'reduced', 2, 2);
 
 -- PRODUCTS
 INSERT INTO products (name, stock, description, category_id, price, created_by, updated_by) VALUES
@@ -307,15 +752,15 @@ INSERT INTO orders (customer_id, number_display, date, status, total, created_by
 (2, 'ORD-202506-105', '2025-06-05', 'completed', 820000, 1, 1); -- additional for paid
 
 -- ORDER_DETAILS
-INSERT INTO order_details (order_id, product_id, qty, created_by, updated_by) VALUES
-(1, 1, 1, 1, 1),  -- Barbell
-(1, 2, 1, 1, 1),  -- Resistance Band
-(2, 3, 1, 1, 1),  -- Backpack
-(2, 6, 1, 1, 1),  -- Vitamin D
-(4, 4, 1, 2, 2),  -- Climbing Rope
-(4, 6, 1, 2, 2),  -- Vitamin D
-(5, 5, 2, 1, 1),  -- Creatine Powder
-(5, 2, 1, 1, 1);  -- Resistance Band
+INSERT INTO order_details (order_id, product_id, qty, product_name, price, tax_class, created_by, updated_by) VALUES
+(1, 1, 1, 'Barbell 15kg', 750000, 'standard', 1, 1),  -- Barbell
+(1, 2, 1, 'Resistance Band Set', 200000, 'standard', 1, 1),  -- Resistance Band
+(2, 3, 1, 'Hiking Backpack 30L', 450000, 'standard', 1, 1),  -- Backpack
+(2, 6, 1, 'Vitamin D3 1000IU', 120000, 'standard', 1, 1),  -- Vitamin D
+(4, 4, 1, 'Climbing Rope 10m', 350000, 'standard', 2, 2),  -- Climbing Rope
+(4, 6, 1, 'Vitamin D3 1000IU', 120000, 'standard', 2, 2),  -- Vitamin D
+(5, 5, 2, 'Creatine Powder 300g', 220000, 'standard', 1, 1),  -- Creatine Powder
+(5, 2, 1, 'Resistance Band Set', 200000, 'standard', 1, 1);  -- Resistance Band
 
 -- BILLINGS
 INSERT INTO billings (order_id, number_display, tax, total, status, created_by, updated_by) VALUES
@@ -325,6 +770,14 @@ INSERT INTO billings (order_id, number_display, tax, total, status, created_by,
 (4, 'BIL-202506-204', 27000, 570000, 'unpaid', 2, 2), -- new unpaid
 (5, 'BIL-202506-205', 40000, 820000, 'paid', 1, 1);    -- new paid
 
+-- PAYMENT_METHODS
+INSERT INTO payment_methods (code, name, enabled, fee_flat, fee_percent, min_amount, max_amount, display_order, created_by) VALUES
+('credit_card', 'Kartu Kredit', TRUE, 0, 2.00, 10000, 0, 1, 1),
+('va', 'Virtual Account', TRUE, 0, 0, 10000, 0, 2, 1),
+('transfer', 'Transfer Bank', TRUE, 0, 0, 0, 0, 3, 1),
+('qris', 'QRIS', TRUE, 0, 0.70, 1000, 10000000, 4, 1),
+('store_credit', 'Saldo Kredit', TRUE, 0, 0, 0, 0, 5, 1);
+
 -- PAYMENTS
 INSERT INTO payments (billing_id, date, amount, method, created_by, updated_by) VALUES
 (1, '2025-06-03 14:10:00', 950000, 'transfer', 1, 1),
@@ -4,13 +4,19 @@ import (
 	"bufio"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"pairproject/entity"
 	"pairproject/handler"
+	"pairproject/invoice"
+	"pairproject/qris"
+	"pairproject/statement"
 	"pairproject/utils"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Variabel global products (contoh daftar produk statis, bisa dihapus jika menggunakan database)
@@ -160,7 +166,17 @@ AdminMenuLabel: for {
 		fmt.Println("3. Report Most Sold Items")
 		fmt.Println("4. Report Unpaid Bills")
 		fmt.Println("5. Detail Revenue")
-		fmt.Println("6. Logout")
+		fmt.Println("6. Shipping Rates")
+		fmt.Println("7. Fulfilment")
+		fmt.Println("8. Returns")
+		fmt.Println("9. Refunds")
+		fmt.Println("10. Tax Settings")
+		fmt.Println("11. Payment Plans")
+		fmt.Println("12. Export Invoice")
+		fmt.Println("13. Billing Adjustments")
+		fmt.Println("14. Bank Transfers")
+		fmt.Println("15. Payment Methods")
+		fmt.Println("16. Logout")
 		fmt.Print("Choose option: ")
 		choice := readInput()
 
@@ -203,12 +219,25 @@ AdminMenuLabel: for {
 
 			fmt.Print("Enter product price: ")
 			priceInput := readInput()
-			_, err = fmt.Sscanf(priceInput, "%f", &product.Price)
+			product.Price, err = entity.ParseMoney(priceInput)
 			if err != nil {
 				fmt.Println("Invalid price.")
 				break
 			}
 
+			fmt.Print("Enter product weight (gram): ")
+			weightInput := readInput()
+			_, err = fmt.Sscanf(weightInput, "%d", &product.Weight)
+			if err != nil {
+				fmt.Println("Invalid weight.")
+				break
+			}
+
+			fmt.Print("Enter tax class (kosongkan untuk mengikuti kategori): ")
+			product.TaxClass = readInput()
+			fmt.Print("Harga sudah termasuk pajak? (y/n): ")
+			product.PriceIncludesTax = readInput() == "y"
+
 			// Simpan produk baru via handler
 			err = productHandler.CreateProduct(product)
 			if err != nil {
@@ -242,13 +271,13 @@ AdminMenuLabel: for {
 			}
 
 			fmt.Println("=== Unpaid Bills Report ===")
-			fmt.Printf("%-5s | %-17s | %-16s | %-20s | %-10s | %-10s | %-10s | %-20s\n",
-				"ID", "Bill No", "Order No", "Customer", "Tax", "Total", "Status", "Created At")
-			fmt.Println(strings.Repeat("-", 110))
+			fmt.Printf("%-5s | %-17s | %-16s | %-20s | %-16s | %-16s | %-16s | %-10s | %-20s\n",
+				"ID", "Bill No", "Order No", "Customer", "Tax", "Total", "Penyesuaian", "Status", "Created At")
+			fmt.Println(strings.Repeat("-", 143))
 			for _, bill := range unpaidBills {
-				fmt.Printf("%-5d | %-15s | %-15s | %-20s | %-10.2f | %-10.2f | %-10s | %-20s\n",
+				fmt.Printf("%-5d | %-15s | %-15s | %-20s | %-16s | %-16s | %-16s | %-10s | %-20s\n",
 					bill.ID, bill.BillNumber, bill.OrderNumber, bill.CustomerName,
-					bill.Tax, bill.Total, bill.Status, bill.CreatedAt)
+					bill.Tax.Rupiah(), bill.Total.Rupiah(), bill.Adjustment.Rupiah(), bill.Status, bill.CreatedAt)
 			}
 		case "5":
 			// Tampilkan laporan detail revenue/pemasukan
@@ -264,14 +293,44 @@ AdminMenuLabel: for {
 			}
 
 			fmt.Println("=== Revenue Detail Report ===")
-			fmt.Printf("%-17s | %-20s | %-10s | %-12s | %-20s | %-15s\n",
+			fmt.Printf("%-17s | %-20s | %-16s | %-12s | %-20s | %-15s\n",
 				"Bill No", "Payment Date", "Amount", "Method", "Customer", "Order No")
-			fmt.Println(strings.Repeat("-", 100))
+			fmt.Println(strings.Repeat("-", 106))
 			for _, r := range revenueList {
-				fmt.Printf("%-15s | %-20s | %-10.2f | %-12s | %-20s | %-15s\n",
-					r.BillNumber, r.PaymentDate, r.Amount, r.Method, r.CustomerName, r.OrderNumber)
+				fmt.Printf("%-15s | %-20s | %-16s | %-12s | %-20s | %-15s\n",
+					r.BillNumber, r.PaymentDate, r.Amount.Rupiah(), r.Method, r.CustomerName, r.OrderNumber)
 			}
 		case "6":
+			// Kelola tarif pengiriman
+			c.shippingRateMenu()
+		case "7":
+			// Proses pengiriman order yang sudah lunas
+			c.fulfilmentMenu()
+		case "8":
+			// Review dan penerimaan barang retur
+			c.adminReturnMenu()
+		case "9":
+			// Refund manual atas pembayaran billing
+			c.refundMenu()
+		case "10":
+			// Kelola kelas pajak, tarif, dan penetapan pajak kategori/produk
+			c.taxMenu()
+		case "11":
+			// Atur rencana cicilan untuk billing
+			c.paymentPlanMenu()
+		case "12":
+			// Cetak invoice / kuitansi billing mana pun ke file
+			c.exportInvoice()
+		case "13":
+			// Credit note / debit note untuk mengoreksi billing yang sudah terbit
+			c.adjustmentMenu(reportHandler)
+		case "14":
+			// Catat transfer masuk ke virtual account dan rekonsiliasi statement bank
+			c.bankTransferMenu()
+		case "15":
+			// Aktif/nonaktif, biaya, batas nominal, dan urutan metode pembayaran
+			c.paymentMethodMenu()
+		case "16":
 			// Logout user dan kembali ke menu utama
 			fmt.Println("User Logout...")
 			c.ctx = utils.ClearUser(c.ctx)
@@ -290,69 +349,26 @@ func (c *cliHandler) customerMenu(){
 
 	CustomerMenuLabel: for {
 		fmt.Println("\n\n=== Customer Menu ===")
-		fmt.Println("1. Add Order")       // Tambah order baru
+		fmt.Println("1. Cart & Checkout") // Kelola cart dan buat order
 		fmt.Println("2. Update Order")    // Update detail order (kuantitas)
 		fmt.Println("3. Create Billing")  // Generate tagihan dari order
 		fmt.Println("4. Add Payment")     // Tambah pembayaran untuk tagihan
-		fmt.Println("5. Log Out")         // Logout user
+		fmt.Println("5. Order History")   // Riwayat order beserta billing & pembayaran
+		fmt.Println("6. Address Book")    // Kelola alamat pengiriman
+		fmt.Println("7. Track My Order")  // Lacak status pengiriman order
+		fmt.Println("8. Returns")         // Ajukan dan lihat retur
+		fmt.Println("9. Store Credit")    // Saldo kredit dari kelebihan bayar
+		fmt.Println("10. Export Invoice") // Cetak invoice / kuitansi ke file
+		fmt.Println("11. Payment Status") // Status pembayaran di payment gateway
+		fmt.Println("12. My Payments")    // Riwayat pembayaran dan unduh kuitansi
+		fmt.Println("13. Log Out")        // Logout user
 		fmt.Print("Choose option: ")
 		option := readInput()
 
 		switch option {
 		case "1":
-			// Mendapatkan daftar produk dari database
-			productHandler := handler.ProductHandler{DB: c.db}
-			products, err := productHandler.GetProducts()
-			if err != nil {
-				// Jika error, set produk kosong agar tidak crash
-				var p []entity.Product
-				products = p
-			}
-
-			// Tampilkan daftar produk
-			fmt.Println("List Product:")
-			for _, p := range products {
-				fmt.Printf("Id: %d, Name: %s, Category: %s, Stock: %d, Price: %.2f, Description: %s\n",
-					p.ID, p.Name, p.Category.Name, p.Stock, p.Price, p.Description)
-			}
-			fmt.Println("")
-
-			// Input order secara berulang sampai user tidak ingin menambah lagi
-			var orders []entity.OrderProduct
-			for {
-				fmt.Print("Masukkan ProductId: ")
-				productIdStr := readInput()
-				productId, err := strconv.Atoi(productIdStr)
-				if err != nil {
-					fmt.Println("Invalid ProductId. Please enter a number.")
-					continue
-				}
-
-				fmt.Print("Masukkan Qty: ")
-				qtyStr := readInput()
-				qty, err := strconv.Atoi(qtyStr)
-				if err != nil {
-					fmt.Println("Invalid Qty. Please enter a number.")
-					continue
-				}
-
-				orders = append(orders, entity.OrderProduct{ProductId: productId, Qty: qty})
-
-				fmt.Print("Masih ingin memesan (y/n): ")
-				isStillOrder := readInput()
-				if isStillOrder != "y" {
-					break
-				}
-			}
-
-			// Kirim data order ke handler untuk dibuatkan order baru
-			_, err = orderHandler.CreateOrder(orders)
-			if err != nil {
-				fmt.Printf("%v\n\n", err)
-				continue CustomerMenuLabel
-			}
-
-			fmt.Println("Order berhasil dibuat.")
+			// Kelola cart lalu checkout menjadi order
+			c.cartMenu()
 
 		case "3":
 			// Ambil daftar order customer dari DB
@@ -394,8 +410,15 @@ func (c *cliHandler) customerMenu(){
 			}
 
 			// Tampilkan info tagihan dan instruksi pembayaran
-			fmt.Printf("Silahkan melakukan pembayaran atas tagihan: %s dengan nominal: %.2f maksimal di pukul: %s\n\n",
-				billing.NumberDisplay, billing.Total, billing.DueDate)
+			fmt.Printf("Silahkan melakukan pembayaran atas tagihan: %s dengan nominal: %s maksimal di pukul: %s\n",
+				billing.NumberDisplay, billing.Total.Rupiah(), billing.DueDate)
+			fmt.Printf("Tagihan otomatis dibatalkan jika belum dibayar sampai batas waktu, silahkan buat tagihan baru jika itu terjadi.\n\n")
+
+			// Tampilkan invoice lengkap; bisa diekspor ke file lewat menu Export Invoice
+			invoiceHandler := handler.InvoiceHandler{DB: c.db, Ctx: &c.ctx}
+			if inv, err := invoiceHandler.GetInvoice(billing.NumberDisplay); err == nil {
+				invoice.RenderText(os.Stdout, invoice.DefaultStore(), inv)
+			}
 
 		case "2":
 			// Ambil daftar order customer untuk update detail order
@@ -443,6 +466,8 @@ func (c *cliHandler) customerMenu(){
 			var paymentMethod entity.Method
 			var isOkPay bool
 			paymentHandler := handler.PaymentHandler{DB: c.db, Ctx: &c.ctx}
+			creditHandler := handler.CreditHandler{DB: c.db, Ctx: &c.ctx}
+			methodHandler := handler.PaymentMethodHandler{DB: c.db, Ctx: &c.ctx}
 
 			for {
 				// Input nomor tagihan yang ingin dibayar
@@ -454,24 +479,54 @@ func (c *cliHandler) customerMenu(){
 					continue CustomerMenuLabel
 				}
 
-				// Tampilkan opsi metode pembayaran yang tersedia
+				// Billing dengan rencana cicilan: tampilkan jadwal dan cicilan yang harus dibayar berikutnya
+				if len(billing.Installments) > 0 {
+					printInstallments(billing.Installments)
+					for _, installment := range billing.Installments {
+						if installment.Status != entity.InstallmentPaid {
+							fmt.Printf("Cicilan berikutnya: #%d sebesar %s\n", installment.Seq, installment.Remaining().Rupiah())
+							break
+						}
+					}
+				}
+
+				// Credit note / debit note mengubah total yang harus dibayar
+				if len(billing.Adjustments) > 0 {
+					printAdjustments(billing.Adjustments)
+					fmt.Printf("Total setelah penyesuaian: %s\n", billing.AdjustedTotal().Rupiah())
+				}
+
+				// Tampilkan metode pembayaran yang sedang aktif, urut sesuai pengaturan admin
+				methods, err := methodHandler.GetAvailablePaymentMethods()
+				if err != nil {
+					fmt.Println(err)
+					continue CustomerMenuLabel
+				}
+				creditBalance, err := creditHandler.GetMyCreditBalance()
+				if err != nil {
+					creditBalance = 0
+				}
+
 				fmt.Println("===== List Jenis Pembayaran =====")
-				fmt.Printf("- %s\n", entity.MethodCredit)
-				fmt.Printf("- %s\n", entity.MethodVA)
-				fmt.Printf("- %s\n", entity.MethodTransfer)
+				for _, method := range methods {
+					// Saldo kredit hanya ditawarkan jika customer masih punya saldo
+					if method.Code == entity.MethodStoreCredit {
+						if creditBalance > 0 {
+							fmt.Printf("- %s: %s (saldo %s)\n", method.Code, method.Name, creditBalance.Rupiah())
+						}
+						continue
+					}
+					fmt.Printf("- %s: %s%s\n", method.Code, method.Name, paymentMethodTerms(method))
+				}
 				fmt.Print("Silahkan masukan jenis pembayaran: ")
 				paymentMethodInput := readInput()
 
-				// Validasi input metode pembayaran
-				if paymentMethodInput == string(entity.MethodCredit) {
-					paymentMethod = entity.MethodCredit
-					isOkPay = true
-				} else if paymentMethodInput == string(entity.MethodVA) {
-					paymentMethod = entity.MethodVA
-					isOkPay = true
-				} else if paymentMethodInput == string(entity.MethodTransfer) {
-					paymentMethod = entity.MethodTransfer
-					isOkPay = true
+				// Validasi input metode pembayaran terhadap daftar yang ditampilkan
+				for _, method := range methods {
+					if string(method.Code) == paymentMethodInput && (method.Code != entity.MethodStoreCredit || creditBalance > 0) {
+						paymentMethod = method.Code
+						isOkPay = true
+					}
 				}
 
 				if !isOkPay {
@@ -479,21 +534,110 @@ func (c *cliHandler) customerMenu(){
 					continue
 				}
 
+				// Pembayaran VA dilakukan lewat transfer bank ke nomor VA, lalu dicocokkan otomatis saat dana masuk
+				if paymentMethod == entity.MethodVA {
+					vaHandler := handler.VirtualAccountHandler{DB: c.db, Ctx: &c.ctx}
+					for _, bank := range entity.VABanks {
+						fmt.Printf("- %s (%s)\n", bank.Code, bank.Name)
+					}
+					fmt.Print("Pilih bank: ")
+					va, err := vaHandler.IssueVirtualAccount(billing.NumberDisplay, readInput())
+					if err != nil {
+						fmt.Println(err)
+						break
+					}
+					fmt.Printf("Silahkan transfer ke Virtual Account %s: %s\n", strings.ToUpper(va.Bank), va.Number)
+					fmt.Println("Pembayaran tercatat otomatis setelah transfer diterima bank.")
+					break
+				}
+
 				// Input nominal pembayaran
 				fmt.Print("Nominal Pembayaran: ")
 				amountInput := readInput()
 
-				amount, err := strconv.ParseFloat(amountInput, 64)
+				amount, err := entity.ParseMoney(amountInput)
 				if err != nil {
 					fmt.Println("Error converting amount:", err)
 					return
 				}
 
+				// Pembayaran dengan saldo kredit tidak melalui policy kelebihan bayar
+				if paymentMethod == entity.MethodStoreCredit {
+					err = creditHandler.ApplyStoreCredit(&billingHandler, billing, amount)
+					if err != nil {
+						fmt.Println(err)
+					} else {
+						fmt.Println("Pembayaran dengan saldo kredit berhasil dibuat.")
+					}
+					break
+				}
+
+				// Kartu kredit: pilih kartu tersimpan atau daftarkan kartu baru, hanya token kartu yang dikirim ke gateway
+				var cardID int
+				if paymentMethod == entity.MethodCredit {
+					card, ok := c.chooseCard()
+					if !ok {
+						break
+					}
+					cardID = card.ID
+				}
+
+				// Satu idempotency key untuk satu pembayaran, sehingga permintaan yang terkirim ulang tidak menagih dua kali
+				payCtx := utils.WithIdempotencyKey(c.ctx, utils.NewIdempotencyKey())
+				idempotentPayment := paymentHandler
+				idempotentPayment.Ctx = &payCtx
+				var qrCharge entity.PaymentCharge
+				pay := func(policy handler.OverpaymentPolicy) error {
+					switch paymentMethod {
+					case entity.MethodCredit:
+						return idempotentPayment.CreateCardPayment(&billingHandler, billing, amount, cardID, policy)
+					case entity.MethodQRIS:
+						charge, err := idempotentPayment.CreateQRISPayment(&billingHandler, billing, amount, policy)
+						qrCharge = charge
+						return err
+					}
+					return idempotentPayment.CreatePaymentWithPolicy(&billingHandler, billing, amount, paymentMethod, policy)
+				}
+
 				// Buat pembayaran menggunakan handler
-				err = paymentHandler.CreatePayment(&billingHandler, billing, amount, paymentMethod)
-				if err != nil {
+				err = pay(handler.OverpaymentReject)
+
+				// Kelebihan bayar: tawarkan bayar sesuai sisa tagihan atau simpan kelebihan sebagai saldo kredit
+				var overpayment *handler.OverpaymentError
+				if errors.As(err, &overpayment) && overpayment.Remaining > 0 {
+					fmt.Printf("Nominal melebihi sisa tagihan %s (kelebihan %s).\n", overpayment.Remaining.Rupiah(), overpayment.Excess.Rupiah())
+					fmt.Println("1. Bayar sesuai sisa tagihan")
+					fmt.Println("2. Bayar penuh, simpan kelebihan sebagai saldo kredit")
+					fmt.Println("3. Batal")
+					fmt.Print("Choose option: ")
+
+					switch readInput() {
+					case "1":
+						err = pay(handler.OverpaymentAcceptRemainder)
+					case "2":
+						err = pay(handler.OverpaymentStoreCredit)
+						if err == nil {
+							fmt.Printf("Kelebihan %s disimpan sebagai saldo kredit.\n", overpayment.Excess.Rupiah())
+						}
+					default:
+						fmt.Println("Pembayaran dibatalkan.")
+						continue CustomerMenuLabel
+					}
+				}
+
+				var declined *handler.PaymentDeclinedError
+				switch {
+				case errors.Is(err, handler.ErrPaymentPending) && qrCharge.QRPayload != "":
+					fmt.Printf("Pindai QR berikut dari aplikasi bank / e-wallet untuk membayar %s:\n", qrCharge.Total().Rupiah())
+					printQR(qrCharge.QRPayload)
+					fmt.Println("Pembayaran dicatat setelah dikonfirmasi gateway. Cek menu Payment Status secara berkala.")
+				case errors.Is(err, handler.ErrPaymentPending):
+					fmt.Println("Pembayaran menunggu konfirmasi gateway. Cek menu Payment Status secara berkala.")
+				case errors.As(err, &declined):
+					fmt.Printf("Pembayaran ditolak: %s. Silahkan coba metode lain.\n", declined.Reason)
+				case err != nil:
 					fmt.Println(err)
-				} else {
+				default:
 					fmt.Println("Pembayaran berhasil dibuat.")
 				}
 
@@ -501,6 +645,45 @@ func (c *cliHandler) customerMenu(){
 			}
 
 		case "5":
+			// Tampilkan riwayat order dengan filter dan pagination
+			c.orderHistoryMenu(&orderHandler)
+
+		case "6":
+			// Kelola buku alamat pengiriman
+			c.addressMenu()
+
+		case "7":
+			// Lacak pengiriman order berdasarkan nomor order
+			fulfilmentHandler := handler.FulfilmentHandler{DB: c.db, Ctx: &c.ctx}
+			fmt.Print("Masukkan nomor order: ")
+			shipment, err := fulfilmentHandler.TrackOrder(readInput())
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			printShipmentTimeline(shipment)
+
+		case "8":
+			// Pengajuan retur untuk order yang sudah completed
+			c.customerReturnMenu(&orderHandler)
+
+		case "9":
+			// Tampilkan saldo kredit beserta mutasinya
+			c.storeCreditMenu()
+
+		case "10":
+			// Cetak invoice / kuitansi billing milik customer ke file
+			c.exportInvoice()
+
+		case "11":
+			// Cek ulang pembayaran yang masih menunggu konfirmasi gateway
+			c.paymentStatusMenu(&billingHandler)
+
+		case "12":
+			// Riwayat pembayaran per billing beserta sisa tagihan
+			c.myPaymentsMenu()
+
+		case "13":
 			// Logout dan hapus user dari context
 			c.ctx = utils.ClearUser(c.ctx)
 			break CustomerMenuLabel
@@ -513,57 +696,1628 @@ func (c *cliHandler) customerMenu(){
 	}
 }
 
-// readInput membaca input baris dari stdin dan menghapus spasi kosong di depan dan belakang
-func readInput() string {
-	scanner.Scan()
-	return strings.TrimSpace(scanner.Text())
+// exportInvoice menyimpan invoice / kuitansi sebuah billing ke file .txt, .html, atau .pdf
+func (c *cliHandler) exportInvoice() {
+	invoiceHandler := handler.InvoiceHandler{DB: c.db, Ctx: &c.ctx}
+
+	fmt.Print("Nomor billing: ")
+	inv, err := invoiceHandler.GetInvoice(readInput())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Simpan ke file (.txt/.html/.pdf) [%s.pdf]: ", inv.Billing.NumberDisplay)
+	path := readInput()
+	if path == "" {
+		path = inv.Billing.NumberDisplay + ".pdf"
+	}
+
+	if err := invoice.Export(path, invoice.DefaultStore(), inv); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s %s tersimpan di %s\n", invoice.Title(inv), inv.Billing.NumberDisplay, path)
 }
 
-// printOrders menampilkan daftar order dengan detailnya dalam format tabel
-func printOrders(orders []entity.Order) {
-	fmt.Printf("\n===== List Order =====\n")
-	if len(orders) == 0 {
-		fmt.Println("No orders found.")
+// myPaymentsMenu menampilkan billing customer beserta pembayarannya, lalu menyimpan kuitansi pembayaran yang dipilih
+func (c *cliHandler) myPaymentsMenu() {
+	invoiceHandler := handler.InvoiceHandler{DB: c.db, Ctx: &c.ctx}
+
+	history, err := invoiceHandler.GetMyPaymentHistory()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(history) == 0 {
+		fmt.Println("Belum ada billing.")
 		return
 	}
 
-	// Header tabel order
-	fmt.Printf("%-10s %-15s %-12s %-10s %-10s\n", "Order ID", "NumberDisplay", "Order Date", "Status", "Total")
-	fmt.Println(strings.Repeat("-", 60))
+	for _, inv := range history {
+		fmt.Printf("\n%s (%s) - %s\n", inv.Billing.NumberDisplay, inv.OrderNumber, inv.Billing.Status)
+		fmt.Printf("Total: %s | Dibayar: %s | Sisa: %s\n", inv.Billing.AdjustedTotal().Rupiah(), (inv.Paid - inv.Refunded).Rupiah(), inv.BalanceDue.Rupiah())
+		if len(inv.Billing.Payments) == 0 {
+			fmt.Println("  Belum ada pembayaran.")
+			continue
+		}
+		fmt.Printf("  %-6s %-17s %-30s %s\n", "ID", "Date", "Method", "Amount")
+		for _, payment := range inv.Billing.Payments {
+			method := string(payment.Method)
+			if payment.Card != "" {
+				method += " " + payment.Card
+			}
+			fmt.Printf("  %-6d %-17s %-30s %s\n", payment.ID, payment.Date.Format("2006-01-02 15:04"), method, payment.Amount.Rupiah())
+		}
+	}
 
-	// Loop tiap order dan print detailnya
-	for _, order := range orders {
-		fmt.Printf("%-10d %-15s %-12s %-10s %-10.2f\n",
-			order.ID, order.NumberDisplay, order.Date, order.Status, order.Total)
-		fmt.Printf("%-20s %-10s %-20s %-8s %-10s\n",
-			"OrderDetailID", "ProductID", "Name", "Qty", "Subtotal")
+	fmt.Print("\nID payment untuk unduh kuitansi (kosong untuk kembali): ")
+	input := readInput()
+	if input == "" {
+		return
+	}
+	paymentID, err := strconv.Atoi(input)
+	if err != nil {
+		fmt.Println("ID payment tidak valid")
+		return
+	}
 
-		// Loop tiap detail produk dalam order
-		for _, detail := range order.Details {
-			fmt.Printf("%-20d %-10d %-20s %-8d %-10.2f\n",
-				detail.ID, detail.ProductID, detail.Product.Name, detail.Qty, detail.Total)
+	receipt, err := invoiceHandler.GetPaymentReceipt(paymentID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Simpan ke file (.txt/.html/.pdf) [%s.pdf]: ", receipt.Number)
+	path := readInput()
+	if path == "" {
+		path = receipt.Number + ".pdf"
+	}
+
+	if err := invoice.ExportReceipt(path, invoice.DefaultStore(), receipt); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Kuitansi %s tersimpan di %s\n", receipt.Number, path)
+}
+
+// storeCreditMenu menampilkan saldo kredit customer dan riwayat mutasinya
+func (c *cliHandler) storeCreditMenu() {
+	creditHandler := handler.CreditHandler{DB: c.db, Ctx: &c.ctx}
+
+	balance, err := creditHandler.GetMyCreditBalance()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("\nSaldo kredit: %s\n", balance.Rupiah())
+
+	credits, err := creditHandler.GetMyCreditHistory()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(credits) == 0 {
+		fmt.Println("Belum ada mutasi saldo kredit.")
+		return
+	}
+
+	fmt.Printf("%-17s %-12s %-16s %s\n", "Date", "Type", "Amount", "Note")
+	for _, credit := range credits {
+		fmt.Printf("%-17s %-12s %-16s %s\n", credit.CreatedAt.Format("2006-01-02 15:04"), credit.Type, credit.Amount.Rupiah(), credit.Note)
+	}
+}
+
+// paymentStatusMenu mengecek ulang charge pending ke payment gateway lalu menampilkan riwayat charge customer
+func (c *cliHandler) paymentStatusMenu(billingHandler *handler.BillingHandler) {
+	paymentHandler := handler.PaymentHandler{DB: c.db, Ctx: &c.ctx}
+
+	charges, err := paymentHandler.SyncMyPendingCharges(billingHandler)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(charges) == 0 {
+		fmt.Println("Belum ada transaksi di payment gateway.")
+		return
+	}
+
+	fmt.Printf("\n%-17s %-15s %-12s %-16s %-9s %s\n", "Date", "Bill No", "Method", "Amount", "Status", "Keterangan")
+	fmt.Println(strings.Repeat("-", 95))
+	for _, charge := range charges {
+		// Pembayaran kartu ditandai dengan kartu tersamar
+		note := charge.Message
+		if charge.Card != "" {
+			note = strings.TrimSpace(charge.Card + " " + note)
+		}
+		// Biaya metode ditagih di atas nominal yang masuk ke billing
+		if charge.Fee > 0 {
+			note = strings.TrimSpace("biaya " + charge.Fee.Rupiah() + " " + note)
+		}
+		fmt.Printf("%-17s %-15s %-12s %-16s %-9s %s\n",
+			charge.CreatedAt.Format("2006-01-02 15:04"), charge.BillingNumber, charge.Method, charge.Amount.Rupiah(), charge.Status, note)
+	}
+
+	// QR charge QRIS yang belum dibayar ditampilkan ulang agar bisa dipindai
+	for _, charge := range charges {
+		if charge.Status == entity.ChargePending && charge.QRPayload != "" {
+			fmt.Printf("\nQRIS %s (%s):\n", charge.BillingNumber, charge.Total().Rupiah())
+			printQR(charge.QRPayload)
 		}
-		fmt.Println(strings.Repeat("-", 60))
 	}
 }
 
-// PrintProducts menampilkan daftar produk dalam format tabel
-func PrintProducts(products []entity.Product) {
-	fmt.Printf("%-5s %-20s %-6s %-10s %-15s %-10s\n",
-		"ID", "Name", "Stock", "Price", "Category", "Description")
-	fmt.Println(strings.Repeat("-", 70))
+// printQR mencetak payload QRIS sebagai QR di terminal, atau payload mentah jika QR gagal dibuat
+func printQR(payload string) {
+	code, err := qris.Terminal(payload)
+	if err != nil {
+		fmt.Println(payload)
+		return
+	}
+	fmt.Print(code)
+}
 
-	// Loop tiap produk dan tampilkan info dengan truncate description jika panjang
-	for _, p := range products {
-		fmt.Printf("%-5d %-20s %-6d %-10.2f %-15s %-10s\n",
-			p.ID,
-			p.Name,
-			p.Stock,
-			p.Price,
-			p.Category.Name,
-			truncateString(p.Description, 10),
-		)
+// cartMenu menampilkan menu cart customer: tambah, ubah, hapus, lihat, dan checkout
+func (c *cliHandler) cartMenu() {
+	cartHandler := handler.CartHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Cart ===")
+		fmt.Println("1. Add Item")
+		fmt.Println("2. Update Item Qty")
+		fmt.Println("3. Remove Item")
+		fmt.Println("4. View Cart")
+		fmt.Println("5. Checkout")
+		fmt.Println("6. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			// Tampilkan daftar produk agar customer tahu ID produk
+			productHandler := handler.ProductHandler{DB: c.db}
+			products, err := productHandler.GetProducts()
+			if err != nil {
+				fmt.Println("Failed to get products:", err)
+				break
+			}
+			PrintProducts(products)
+
+			productID, qty, ok := readProductQty()
+			if !ok {
+				break
+			}
+
+			if err := cartHandler.AddItem(productID, qty); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Produk berhasil ditambahkan ke cart.")
+		case "2":
+			productID, qty, ok := readProductQty()
+			if !ok {
+				break
+			}
+
+			if err := cartHandler.UpdateItem(productID, qty); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Cart berhasil diupdate.")
+		case "3":
+			fmt.Print("Masukkan ProductId: ")
+			productID, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid ProductId. Please enter a number.")
+				break
+			}
+
+			if err := cartHandler.RemoveItem(productID); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Produk berhasil dihapus dari cart.")
+		case "4":
+			cart, err := cartHandler.GetCart()
+			if err != nil {
+				fmt.Println("Failed to get cart:", err)
+				break
+			}
+			printCart(cart, cartHandler.ValidateCart(cart))
+		case "5":
+			cart, err := cartHandler.GetCart()
+			if err != nil {
+				fmt.Println("Failed to get cart:", err)
+				break
+			}
+			if len(cart.Items) == 0 {
+				fmt.Println("Cart masih kosong.")
+				break
+			}
+			printCart(cart, cartHandler.ValidateCart(cart))
+
+			// Pilih alamat pengiriman dari buku alamat
+			address, ok := c.selectAddress()
+			if !ok {
+				break
+			}
+
+			// Tampilkan estimasi ongkos kirim sebelum konfirmasi
+			shippingHandler := handler.ShippingHandler{DB: c.db, Ctx: &c.ctx}
+			rate, err := shippingHandler.CalculateFee(address.Zone, cart.Weight)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("Ongkos kirim (%s, %d gram): %s\n", rate.Name, cart.Weight, rate.Fee.Rupiah())
+			fmt.Printf("Total belanja + ongkos kirim: %s\n", (cart.Total + rate.Fee).Rupiah())
+
+			// Konfirmasi sebelum cart diubah menjadi order
+			fmt.Print("Lanjutkan checkout (y/n): ")
+			if readInput() != "y" {
+				break
+			}
+
+			// Satu idempotency key untuk satu konfirmasi checkout, sehingga permintaan yang terkirim ulang tidak membuat order dua kali
+			checkoutCtx := utils.WithIdempotencyKey(c.ctx, utils.NewIdempotencyKey())
+			idempotentCart := cartHandler
+			idempotentCart.Ctx = &checkoutCtx
+			order, err := idempotentCart.Checkout(address.ID)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				break
+			}
+			fmt.Printf("Order %s berhasil dibuat.\n", order.NumberDisplay)
+			return
+		case "6":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// addressMenu menampilkan menu buku alamat customer
+func (c *cliHandler) addressMenu() {
+	addressHandler := handler.AddressHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Address Book ===")
+		fmt.Println("1. List Address")
+		fmt.Println("2. Add Address")
+		fmt.Println("3. Set Default Address")
+		fmt.Println("4. Delete Address")
+		fmt.Println("5. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			addresses, err := addressHandler.GetAddresses()
+			if err != nil {
+				fmt.Println("Failed to get addresses:", err)
+				break
+			}
+			printAddresses(addresses)
+		case "2":
+			if _, ok := c.createAddress(); ok {
+				fmt.Println("Alamat berhasil ditambahkan.")
+			}
+		case "3":
+			fmt.Print("Masukkan ID alamat: ")
+			id, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid ID.")
+				break
+			}
+			if err := addressHandler.SetDefaultAddress(id); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Alamat default berhasil diubah.")
+		case "4":
+			fmt.Print("Masukkan ID alamat: ")
+			id, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid ID.")
+				break
+			}
+			if err := addressHandler.DeleteAddress(id); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Alamat berhasil dihapus.")
+		case "5":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// createAddress membaca data alamat baru dari terminal lalu menyimpannya
+func (c *cliHandler) createAddress() (entity.CustomerAddress, bool) {
+	addressHandler := handler.AddressHandler{DB: c.db, Ctx: &c.ctx}
+	var address entity.CustomerAddress
+
+	fmt.Print("Label (Rumah/Kantor/...): ")
+	address.Label = readInput()
+	fmt.Print("Nama Penerima: ")
+	address.RecipientName = readInput()
+	fmt.Print("No. HP Penerima: ")
+	address.PhoneNumber = readInput()
+	fmt.Print("Alamat: ")
+	address.Address = readInput()
+	fmt.Print("Kota: ")
+	address.City = readInput()
+	fmt.Print("Kode Pos: ")
+	address.PostalCode = readInput()
+	fmt.Print("Zona Pengiriman: ")
+	address.Zone = readInput()
+
+	address, err := addressHandler.CreateAddress(address)
+	if err != nil {
+		fmt.Println(err)
+		return address, false
+	}
+
+	return address, true
+}
+
+// selectAddress meminta customer memilih alamat pengiriman, alamat default dipakai jika input kosong
+func (c *cliHandler) selectAddress() (entity.CustomerAddress, bool) {
+	addressHandler := handler.AddressHandler{DB: c.db, Ctx: &c.ctx}
+
+	addresses, err := addressHandler.GetAddresses()
+	if err != nil {
+		fmt.Println("Failed to get addresses:", err)
+		return entity.CustomerAddress{}, false
+	}
+
+	// Customer tanpa alamat diminta menambahkan alamat terlebih dahulu
+	if len(addresses) == 0 {
+		fmt.Println("Belum ada alamat pengiriman, silahkan tambahkan alamat.")
+		return c.createAddress()
+	}
+
+	printAddresses(addresses)
+	fmt.Print("Pilih ID alamat pengiriman (kosongkan untuk alamat default): ")
+	input := readInput()
+	if input == "" {
+		return addresses[0], true
+	}
+
+	id, err := strconv.Atoi(input)
+	if err != nil {
+		fmt.Println("Invalid ID.")
+		return entity.CustomerAddress{}, false
 	}
+
+	address, err := addressHandler.GetAddressByID(id)
+	if err != nil {
+		fmt.Println(err)
+		return address, false
+	}
+
+	return address, true
+}
+
+// paymentMethodMenu menampilkan menu admin untuk mengelola metode pembayaran
+func (c *cliHandler) paymentMethodMenu() {
+	methodHandler := handler.PaymentMethodHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Payment Methods ===")
+		fmt.Println("1. List Methods")
+		fmt.Println("2. Edit Method")
+		fmt.Println("3. Enable/Disable Method")
+		fmt.Println("4. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			methods, err := methodHandler.GetPaymentMethods()
+			if err != nil {
+				fmt.Println("Failed to get payment methods:", err)
+				break
+			}
+			printPaymentMethods(methods)
+		case "2":
+			methods, err := methodHandler.GetPaymentMethods()
+			if err != nil {
+				fmt.Println("Failed to get payment methods:", err)
+				break
+			}
+			fmt.Print("Kode metode: ")
+			code := entity.Method(readInput())
+
+			var method entity.PaymentMethod
+			for _, m := range methods {
+				if m.Code == code {
+					method = m
+				}
+			}
+			if method.Code == "" {
+				fmt.Println("Metode pembayaran tidak ditemukan.")
+				break
+			}
+
+			// Input kosong mempertahankan nilai lama
+			fmt.Printf("Nama [%s]: ", method.Name)
+			if input := readInput(); input != "" {
+				method.Name = input
+			}
+			fmt.Printf("Biaya tetap [%s]: ", method.FeeFlat)
+			if input := readInput(); input != "" {
+				if method.FeeFlat, err = entity.ParseMoney(input); err != nil {
+					fmt.Println("Invalid fee.")
+					break
+				}
+			}
+			fmt.Printf("Biaya persen, contoh 0.7 [%s]: ", formatRate(method.FeeRate))
+			if input := readInput(); input != "" {
+				// Persen dua desimal sama dengan basis poin (0.7% = 70)
+				percent, err := entity.ParseMoney(input)
+				if err != nil {
+					fmt.Println("Invalid rate.")
+					break
+				}
+				method.FeeRate = int64(percent)
+			}
+			fmt.Printf("Nominal minimal, 0 jika tanpa batas [%s]: ", method.MinAmount)
+			if input := readInput(); input != "" {
+				if method.MinAmount, err = entity.ParseMoney(input); err != nil {
+					fmt.Println("Invalid amount.")
+					break
+				}
+			}
+			fmt.Printf("Nominal maksimal, 0 jika tanpa batas [%s]: ", method.MaxAmount)
+			if input := readInput(); input != "" {
+				if method.MaxAmount, err = entity.ParseMoney(input); err != nil {
+					fmt.Println("Invalid amount.")
+					break
+				}
+			}
+			fmt.Printf("Urutan tampil [%d]: ", method.DisplayOrder)
+			if input := readInput(); input != "" {
+				if method.DisplayOrder, err = strconv.Atoi(input); err != nil {
+					fmt.Println("Invalid order.")
+					break
+				}
+			}
+
+			if err := methodHandler.UpdatePaymentMethod(method); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Metode pembayaran berhasil diperbarui.")
+		case "3":
+			fmt.Print("Kode metode: ")
+			code := entity.Method(readInput())
+			fmt.Print("Aktifkan? (y/n): ")
+			enabled := strings.ToLower(readInput()) == "y"
+
+			if err := methodHandler.SetPaymentMethodEnabled(code, enabled); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Status metode pembayaran berhasil diubah.")
+		case "4":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// printPaymentMethods menampilkan daftar metode pembayaran beserta biaya dan batas nominalnya
+func printPaymentMethods(methods []entity.PaymentMethod) {
+	fmt.Printf("%-4s %-13s %-20s %-8s %-16s %-8s %-16s %-16s\n", "No", "Kode", "Nama", "Status", "Biaya Tetap", "Persen", "Minimal", "Maksimal")
+	fmt.Println(strings.Repeat("-", 108))
+	for _, method := range methods {
+		status := "aktif"
+		if !method.Enabled {
+			status = "nonaktif"
+		}
+		maxAmount := "-"
+		if method.MaxAmount > 0 {
+			maxAmount = method.MaxAmount.Rupiah()
+		}
+		fmt.Printf("%-4d %-13s %-20s %-8s %-16s %-8s %-16s %-16s\n",
+			method.DisplayOrder, method.Code, truncateString(method.Name, 20), status, method.FeeFlat.Rupiah(), formatRate(method.FeeRate), method.MinAmount.Rupiah(), maxAmount)
+	}
+}
+
+// paymentMethodTerms menampilkan biaya dan batas nominal metode untuk menu pembayaran customer, kosong jika tidak ada
+func paymentMethodTerms(method entity.PaymentMethod) string {
+	var terms []string
+	switch {
+	case method.FeeFlat > 0 && method.FeeRate > 0:
+		terms = append(terms, fmt.Sprintf("biaya %s + %s", method.FeeFlat.Rupiah(), formatRate(method.FeeRate)))
+	case method.FeeFlat > 0:
+		terms = append(terms, "biaya "+method.FeeFlat.Rupiah())
+	case method.FeeRate > 0:
+		terms = append(terms, "biaya "+formatRate(method.FeeRate))
+	}
+	if method.MinAmount > 0 {
+		terms = append(terms, "min "+method.MinAmount.Rupiah())
+	}
+	if method.MaxAmount > 0 {
+		terms = append(terms, "maks "+method.MaxAmount.Rupiah())
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(terms, ", ") + ")"
+}
+
+// shippingRateMenu menampilkan menu admin untuk mengelola tarif pengiriman
+func (c *cliHandler) shippingRateMenu() {
+	shippingHandler := handler.ShippingHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Shipping Rates ===")
+		fmt.Println("1. List Rates")
+		fmt.Println("2. Add Rate")
+		fmt.Println("3. Enable/Disable Rate")
+		fmt.Println("4. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			rates, err := shippingHandler.GetRates()
+			if err != nil {
+				fmt.Println("Failed to get rates:", err)
+				break
+			}
+			printShippingRates(rates)
+		case "2":
+			var rate entity.ShippingRate
+
+			fmt.Print("Nama tarif: ")
+			rate.Name = readInput()
+			fmt.Printf("Tipe (%s/%s/%s): ", entity.RateFlat, entity.RateWeight, entity.RateZone)
+			rate.Type = entity.ShippingRateType(readInput())
+
+			if rate.Type == entity.RateZone {
+				fmt.Print("Zona: ")
+				rate.Zone = readInput()
+			}
+
+			if rate.Type == entity.RateWeight || rate.Type == entity.RateZone {
+				fmt.Print("Berat minimal (gram, 0 jika tanpa batas): ")
+				if _, err := fmt.Sscanf(readInput(), "%d", &rate.MinWeight); err != nil {
+					fmt.Println("Invalid weight.")
+					break
+				}
+				fmt.Print("Berat maksimal (gram, 0 jika tanpa batas): ")
+				if _, err := fmt.Sscanf(readInput(), "%d", &rate.MaxWeight); err != nil {
+					fmt.Println("Invalid weight.")
+					break
+				}
+			}
+
+			fmt.Print("Ongkos kirim: ")
+			fee, err := entity.ParseMoney(readInput())
+			if err != nil {
+				fmt.Println("Invalid fee.")
+				break
+			}
+			rate.Fee = fee
+
+			if err := shippingHandler.CreateRate(rate); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Tarif pengiriman berhasil dibuat.")
+		case "3":
+			fmt.Print("Masukkan ID tarif: ")
+			id, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid ID.")
+				break
+			}
+			fmt.Print("Aktifkan tarif (y/n): ")
+			active := readInput() == "y"
+
+			if err := shippingHandler.SetRateActive(id, active); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Status tarif berhasil diubah.")
+		case "4":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// paymentPlanMenu menampilkan menu admin untuk membuat dan melihat rencana cicilan billing
+func (c *cliHandler) paymentPlanMenu() {
+	installmentHandler := handler.InstallmentHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Payment Plans ===")
+		fmt.Println("1. Create Plan")
+		fmt.Println("2. View Plan")
+		fmt.Println("3. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			fmt.Print("Nomor billing: ")
+			billNumber := readInput()
+			fmt.Print("Jumlah cicilan: ")
+			count, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid number.")
+				break
+			}
+			fmt.Print("Jatuh tempo cicilan pertama (YYYY-MM-DD): ")
+			firstDue, err := time.ParseInLocation("2006-01-02", readInput(), time.Local)
+			if err != nil {
+				fmt.Println("Invalid date.")
+				break
+			}
+			fmt.Print("Jarak antar cicilan (hari): ")
+			interval, err := strconv.Atoi(readInput())
+			if err != nil || interval <= 0 {
+				fmt.Println("Invalid interval.")
+				break
+			}
+
+			var dueDates []time.Time
+			for i := 0; i < count; i++ {
+				dueDates = append(dueDates, firstDue.AddDate(0, 0, i*interval))
+			}
+
+			installments, err := installmentHandler.CreatePaymentPlan(billNumber, dueDates)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Rencana cicilan berhasil dibuat.")
+			printInstallments(installments)
+		case "2":
+			fmt.Print("Nomor billing: ")
+			installments, err := installmentHandler.GetInstallments(readInput())
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if len(installments) == 0 {
+				fmt.Println("Billing tidak memiliki rencana cicilan.")
+				break
+			}
+			printInstallments(installments)
+		case "3":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// taxMenu menampilkan menu admin untuk mengelola kelas pajak dan tarif berlaku per tanggal
+func (c *cliHandler) taxMenu() {
+	taxHandler := handler.TaxHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Tax Settings ===")
+		fmt.Println("1. List Tax Classes")
+		fmt.Println("2. Add Tax Class")
+		fmt.Println("3. Rate History")
+		fmt.Println("4. Add Rate")
+		fmt.Println("5. Set Category Tax Class")
+		fmt.Println("6. Set Product Tax")
+		fmt.Println("7. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			classes, err := taxHandler.GetTaxClasses()
+			if err != nil {
+				fmt.Println("Failed to get tax classes:", err)
+				break
+			}
+			fmt.Printf("%-4s %-12s %-25s %-10s\n", "ID", "Kode", "Nama", "Tarif")
+			fmt.Println(strings.Repeat("-", 55))
+			for _, class := range classes {
+				rate := "belum diatur"
+				if class.HasRate {
+					rate = formatRate(class.Rate)
+				}
+				fmt.Printf("%-4d %-12s %-25s %-10s\n", class.ID, class.Code, truncateString(class.Name, 25), rate)
+			}
+		case "2":
+			fmt.Print("Kode kelas pajak: ")
+			code := readInput()
+			fmt.Print("Nama kelas pajak: ")
+			name := readInput()
+
+			if err := taxHandler.CreateTaxClass(code, name); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Kelas pajak berhasil dibuat. Tambahkan tarif lewat menu Add Rate.")
+		case "3":
+			fmt.Print("Kode kelas pajak: ")
+			rates, err := taxHandler.GetTaxRates(readInput())
+			if err != nil {
+				fmt.Println("Failed to get rates:", err)
+				break
+			}
+			if len(rates) == 0 {
+				fmt.Println("Belum ada tarif.")
+				break
+			}
+			for _, rate := range rates {
+				until := "sekarang"
+				if !rate.EffectiveTo.IsZero() {
+					until = rate.EffectiveTo.Format("2006-01-02")
+				}
+				fmt.Printf("%-8s | %s s/d %s\n", formatRate(rate.Rate), rate.EffectiveFrom.Format("2006-01-02"), until)
+			}
+		case "4":
+			fmt.Print("Kode kelas pajak: ")
+			code := readInput()
+			fmt.Print("Tarif (persen, contoh 11 atau 12.5): ")
+			percent, err := entity.ParseMoney(readInput())
+			if err != nil {
+				fmt.Println("Invalid rate.")
+				break
+			}
+			fmt.Print("Berlaku mulai (YYYY-MM-DD): ")
+			from, err := time.ParseInLocation("2006-01-02", readInput(), time.Local)
+			if err != nil {
+				fmt.Println("Invalid date.")
+				break
+			}
+
+			// Persen dua desimal sama dengan basis poin (12.5% = 1250)
+			if err := taxHandler.AddTaxRate(code, int64(percent), from); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Tarif pajak berhasil ditambahkan.")
+		case "5":
+			fmt.Print("Masukkan ID kategori: ")
+			categoryID, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid ID.")
+				break
+			}
+			fmt.Print("Kode kelas pajak: ")
+			if err := taxHandler.SetCategoryTaxClass(categoryID, readInput()); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Kelas pajak kategori berhasil diubah.")
+		case "6":
+			fmt.Print("Masukkan ID produk: ")
+			productID, err := strconv.Atoi(readInput())
+			if err != nil {
+				fmt.Println("Invalid ID.")
+				break
+			}
+			fmt.Print("Kode kelas pajak (kosongkan untuk mengikuti kategori): ")
+			code := readInput()
+			fmt.Print("Harga sudah termasuk pajak? (y/n): ")
+			inclusive := readInput() == "y"
+
+			if err := taxHandler.SetProductTax(productID, code, inclusive); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Pajak produk berhasil diubah.")
+		case "7":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// fulfilmentMenu menampilkan menu staff untuk memproses pengiriman order yang sudah lunas
+func (c *cliHandler) fulfilmentMenu() {
+	fulfilmentHandler := handler.FulfilmentHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Fulfilment ===")
+		fmt.Println("1. List Shipments")
+		fmt.Println("2. Advance Shipment")
+		fmt.Println("3. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			fmt.Printf("Filter status (%s/%s/%s/%s, kosongkan untuk semua): ",
+				entity.ShipmentPicking, entity.ShipmentPacking, entity.ShipmentShipped, entity.ShipmentDelivered)
+			shipments, err := fulfilmentHandler.GetShipments(entity.StatusShipment(readInput()))
+			if err != nil {
+				fmt.Println("Failed to get shipments:", err)
+				break
+			}
+			printShipments(shipments)
+		case "2":
+			fmt.Print("Masukkan nomor order: ")
+			orderNumber := readInput()
+
+			// Kurir dan resi hanya ditanyakan ketika order akan dikirim
+			var courier, trackingNumber string
+			fmt.Print("Apakah order akan dikirim sekarang (y/n): ")
+			if readInput() == "y" {
+				fmt.Print("Kurir: ")
+				courier = readInput()
+				fmt.Print("Nomor resi: ")
+				trackingNumber = readInput()
+			}
+			fmt.Print("Catatan (opsional): ")
+			note := readInput()
+
+			shipment, err := fulfilmentHandler.AdvanceShipment(orderNumber, courier, trackingNumber, note)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("Order %s sekarang berstatus %s.\n", shipment.OrderNumber, shipment.Status)
+		case "3":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// customerReturnMenu menampilkan menu customer untuk mengajukan dan melihat retur
+func (c *cliHandler) customerReturnMenu(orderHandler *handler.OrderHandler) {
+	returnHandler := handler.ReturnHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Returns ===")
+		fmt.Println("1. Request Return")
+		fmt.Println("2. My Returns")
+		fmt.Println("3. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			fmt.Print("Masukkan nomor order: ")
+			orderNumber := readInput()
+
+			order, err := orderHandler.GetOrderDetail(orderNumber)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			printOrderDetail(order)
+
+			// Pilih baris order yang ingin diretur
+			var lines []entity.ReturnLine
+			for {
+				fmt.Print("ID detail order yang diretur (kosongkan jika selesai): ")
+				input := readInput()
+				if input == "" {
+					break
+				}
+				var line entity.ReturnLine
+				if _, err := fmt.Sscanf(input, "%d", &line.OrderDetailID); err != nil {
+					fmt.Println("Invalid ID.")
+					continue
+				}
+				fmt.Print("Qty: ")
+				if _, err := fmt.Sscanf(readInput(), "%d", &line.Qty); err != nil {
+					fmt.Println("Invalid qty.")
+					continue
+				}
+				lines = append(lines, line)
+			}
+
+			fmt.Print("Alasan retur: ")
+			reason := readInput()
+
+			ret, err := returnHandler.RequestReturn(orderNumber, reason, lines)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("Retur %s berhasil diajukan, estimasi refund %s.\n", ret.NumberDisplay, ret.RefundAmount.Rupiah())
+		case "2":
+			returns, err := returnHandler.GetMyReturns()
+			if err != nil {
+				fmt.Println("Failed to get returns:", err)
+				break
+			}
+			printReturns(returns)
+		case "3":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// adminReturnMenu menampilkan menu admin untuk meninjau retur dan mencatat barang yang diterima
+func (c *cliHandler) adminReturnMenu() {
+	returnHandler := handler.ReturnHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Returns ===")
+		fmt.Println("1. List Returns")
+		fmt.Println("2. Approve/Reject Return")
+		fmt.Println("3. Receive Returned Items")
+		fmt.Println("4. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			fmt.Printf("Filter status (%s/%s/%s/%s, kosongkan untuk semua): ",
+				entity.ReturnRequested, entity.ReturnApproved, entity.ReturnRejected, entity.ReturnReceived)
+			returns, err := returnHandler.GetReturns(entity.StatusReturn(readInput()))
+			if err != nil {
+				fmt.Println("Failed to get returns:", err)
+				break
+			}
+			printReturns(returns)
+		case "2":
+			fmt.Print("Masukkan nomor retur: ")
+			numberDisplay := readInput()
+			fmt.Print("Setujui retur (y/n): ")
+			approve := readInput() == "y"
+			fmt.Print("Catatan: ")
+			note := readInput()
+
+			if err := returnHandler.ReviewReturn(numberDisplay, approve, note); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Status retur berhasil diubah.")
+		case "3":
+			fmt.Print("Masukkan nomor retur: ")
+			ret, err := returnHandler.GetReturnByNumber(readInput())
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+
+			// Tanyakan kondisi setiap item, default layak jual
+			conditions := make(map[int]entity.ItemCondition)
+			for _, item := range ret.Items {
+				fmt.Printf("%s x%d rusak? (y/n): ", item.ProductName, item.Qty)
+				if readInput() == "y" {
+					conditions[item.ID] = entity.ConditionDamaged
+				} else {
+					conditions[item.ID] = entity.ConditionRestock
+				}
+			}
+
+			if err := returnHandler.ReceiveReturn(ret.NumberDisplay, conditions); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Barang retur berhasil diterima.")
+		case "4":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// refundMenu menampilkan menu admin untuk mengembalikan dana pembayaran sebuah billing
+func (c *cliHandler) refundMenu() {
+	refundHandler := handler.RefundHandler{DB: c.db, Ctx: &c.ctx}
+
+	fmt.Print("Masukkan nomor billing: ")
+	payments, err := refundHandler.GetRefundablePayments(readInput())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(payments) == 0 {
+		fmt.Println("Billing belum memiliki pembayaran.")
+		return
+	}
+
+	fmt.Printf("%-5s %-17s %-12s %-16s %-16s\n", "ID", "Date", "Method", "Amount", "Refunded")
+	fmt.Println(strings.Repeat("-", 70))
+	for _, p := range payments {
+		fmt.Printf("%-5d %-17s %-12s %-16s %-16s\n", p.ID, p.Date.Format("2006-01-02 15:04"), p.Method, p.Amount.Rupiah(), p.Refunded.Rupiah())
+	}
+
+	fmt.Print("ID pembayaran yang direfund: ")
+	paymentID, err := strconv.Atoi(readInput())
+	if err != nil {
+		fmt.Println("Invalid ID.")
+		return
+	}
+	fmt.Print("Jumlah refund: ")
+	amount, err := entity.ParseMoney(readInput())
+	if err != nil {
+		fmt.Println("Invalid amount.")
+		return
+	}
+	fmt.Print("Alasan refund: ")
+	reason := readInput()
+
+	if _, err := refundHandler.CreateRefund(paymentID, amount, reason); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Refund berhasil dicatat.")
+}
+
+// adjustmentMenu menampilkan menu admin untuk membuat dan melihat credit note / debit note billing
+func (c *cliHandler) adjustmentMenu(reportHandler handler.ReportHandler) {
+	adjustmentHandler := handler.AdjustmentHandler{DB: c.db, Ctx: &c.ctx}
+	billingHandler := handler.BillingHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Billing Adjustments ===")
+		fmt.Println("1. Create Credit Note")
+		fmt.Println("2. Create Debit Note")
+		fmt.Println("3. View Adjustments by Billing")
+		fmt.Println("4. Report All Adjustments")
+		fmt.Println("5. Back")
+		fmt.Print("Choose option: ")
+
+		switch choice := readInput(); choice {
+		case "1", "2":
+			fmt.Print("Nomor billing: ")
+			billNumber := readInput()
+			fmt.Print("Nominal: ")
+			amount, err := entity.ParseMoney(readInput())
+			if err != nil {
+				fmt.Println("Invalid amount.")
+				break
+			}
+			fmt.Print("Alasan: ")
+			reason := readInput()
+
+			var adjustment entity.BillingAdjustment
+			if choice == "1" {
+				adjustment, err = adjustmentHandler.CreateCreditNote(&billingHandler, billNumber, amount, reason)
+			} else {
+				adjustment, err = adjustmentHandler.CreateDebitNote(&billingHandler, billNumber, amount, reason)
+			}
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("%s berhasil dibuat untuk %s sebesar %s\n", adjustment.NumberDisplay, billNumber, adjustment.Signed().Rupiah())
+		case "3":
+			fmt.Print("Nomor billing: ")
+			adjustments, err := adjustmentHandler.GetAdjustments(readInput())
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if len(adjustments) == 0 {
+				fmt.Println("Billing tidak memiliki penyesuaian.")
+				break
+			}
+			printAdjustments(adjustments)
+		case "4":
+			adjustments, err := reportHandler.GetAdjustmentReport()
+			if err != nil {
+				fmt.Println("Error fetching adjustments:", err)
+				break
+			}
+			if len(adjustments) == 0 {
+				fmt.Println("No adjustments found.")
+				break
+			}
+
+			fmt.Println("=== Billing Adjustments Report ===")
+			fmt.Printf("%-14s | %-15s | %-20s | %-15s | %-25s | %-20s\n",
+				"No", "Bill No", "Customer", "Amount", "Reason", "Created At")
+			fmt.Println(strings.Repeat("-", 120))
+			for _, adjustment := range adjustments {
+				fmt.Printf("%-14s | %-15s | %-20s | %-15s | %-25s | %-20s\n",
+					adjustment.NumberDisplay, adjustment.BillNumber, truncateString(adjustment.CustomerName, 20),
+					adjustment.Amount.Rupiah(), truncateString(adjustment.Reason, 25), adjustment.CreatedAt)
+			}
+		case "5":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// bankTransferMenu menampilkan menu admin untuk mencatat transfer yang masuk ke virtual account
+// dan merekonsiliasi mutasi dari file statement bank
+func (c *cliHandler) bankTransferMenu() {
+	vaHandler := handler.VirtualAccountHandler{DB: c.db, Ctx: &c.ctx}
+	reconciliationHandler := handler.ReconciliationHandler{DB: c.db, Ctx: &c.ctx}
+	billingHandler := handler.BillingHandler{DB: c.db, Ctx: &c.ctx}
+
+	for {
+		fmt.Println("\n=== Bank Transfers ===")
+		fmt.Println("1. Record Incoming VA Transfer")
+		fmt.Println("2. Import Bank Statement")
+		fmt.Println("3. Review Queue")
+		fmt.Println("4. Back")
+		fmt.Print("Choose option: ")
+
+		switch readInput() {
+		case "1":
+			fmt.Print("Nomor virtual account: ")
+			vaNumber := readInput()
+			fmt.Print("Nominal: ")
+			amount, err := entity.ParseMoney(readInput())
+			if err != nil {
+				fmt.Println("Invalid amount.")
+				break
+			}
+			fmt.Print("Referensi bank: ")
+			reference := readInput()
+
+			charge, err := vaHandler.ReceiveVAPayment(&billingHandler, vaNumber, amount, reference)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("Transfer %s dicatat untuk billing %s.\n", charge.Amount.Rupiah(), charge.BillingNumber)
+		case "2":
+			fmt.Print("Path file statement (CSV / MT940): ")
+			path := readInput()
+			lines, err := statement.ParseFile(path)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+
+			result, err := reconciliationHandler.ImportStatement(&billingHandler, filepath.Base(path), lines)
+			if err != nil {
+				fmt.Println(err)
+			}
+			fmt.Printf("%d mutasi dibaca: %d cocok otomatis, %d masuk antrean review, %d sudah pernah diimpor, %d debit dilewati.\n",
+				result.Lines, result.Matched, result.Review, result.Duplicates, result.Debits)
+		case "3":
+			c.statementReviewMenu(&reconciliationHandler, &billingHandler)
+		case "4":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// statementReviewMenu menampilkan mutasi yang menunggu keputusan admin lalu mencocokkan atau mengabaikan mutasi yang dipilih
+func (c *cliHandler) statementReviewMenu(reconciliationHandler *handler.ReconciliationHandler, billingHandler *handler.BillingHandler) {
+	for {
+		queue, err := reconciliationHandler.GetReviewQueue()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if len(queue) == 0 {
+			fmt.Println("Antrean review kosong.")
+			return
+		}
+
+		fmt.Println("\n=== Statement Review Queue ===")
+		fmt.Printf("%-5s | %-10s | %-15s | %-16s | %-30s | %-15s | %s\n",
+			"ID", "Tanggal", "Nominal", "Referensi", "Keterangan", "Saran Billing", "Catatan")
+		fmt.Println(strings.Repeat("-", 130))
+		for _, line := range queue {
+			fmt.Printf("%-5d | %-10s | %-15s | %-16s | %-30s | %-15s | %s\n",
+				line.ID, line.Date.Format("2006-01-02"), line.Amount.Rupiah(), truncateString(line.Reference, 16),
+				truncateString(line.Description, 30), line.BillingNumber, line.Note)
+		}
+
+		fmt.Print("ID mutasi (kosongkan untuk kembali): ")
+		input := readInput()
+		if input == "" {
+			return
+		}
+		lineID, err := strconv.Atoi(input)
+		if err != nil {
+			fmt.Println("Invalid ID.")
+			continue
+		}
+
+		fmt.Println("1. Cocokkan ke billing")
+		fmt.Println("2. Abaikan")
+		fmt.Print("Choose option: ")
+		switch readInput() {
+		case "1":
+			fmt.Print("Nomor billing: ")
+			line, err := reconciliationHandler.ResolveStatementLine(billingHandler, lineID, readInput())
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("Mutasi %s dicatat sebagai payment billing %s.\n", line.Amount.Rupiah(), line.BillingNumber)
+		case "2":
+			fmt.Print("Alasan: ")
+			if err := reconciliationHandler.IgnoreStatementLine(lineID, readInput()); err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Mutasi diabaikan.")
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// chooseCard menampilkan kartu tersimpan customer dan mengembalikan kartu yang dipilih.
+// Kartu baru divalidasi lalu ditukar dengan token; nomor kartu dan CVV tidak disimpan.
+func (c *cliHandler) chooseCard() (entity.CardToken, bool) {
+	cardHandler := handler.CardHandler{DB: c.db, Ctx: &c.ctx}
+
+	cards, err := cardHandler.GetMyCards()
+	if err != nil {
+		fmt.Println(err)
+		return entity.CardToken{}, false
+	}
+
+	fmt.Println("===== Kartu Tersimpan =====")
+	for i, card := range cards {
+		fmt.Printf("%d. %s (berlaku s.d. %02d/%d)\n", i+1, card.Masked(), card.ExpMonth, card.ExpYear)
+	}
+	fmt.Printf("%d. Tambah kartu baru\n", len(cards)+1)
+	fmt.Print("Pilih kartu: ")
+
+	choice, err := strconv.Atoi(readInput())
+	if err != nil || choice < 1 || choice > len(cards)+1 {
+		fmt.Println("Invalid option.")
+		return entity.CardToken{}, false
+	}
+	if choice <= len(cards) {
+		return cards[choice-1], true
+	}
+
+	var details entity.CardDetails
+	fmt.Print("Nomor kartu: ")
+	details.Number = readInput()
+	fmt.Print("Masa berlaku (MM/YY): ")
+	details.ExpMonth, details.ExpYear, err = entity.ParseCardExpiry(readInput())
+	if err != nil {
+		fmt.Println(err)
+		return entity.CardToken{}, false
+	}
+	fmt.Print("CVV: ")
+	details.CVV = readInput()
+
+	card, err := cardHandler.SaveCard(details)
+	if err != nil {
+		fmt.Println(err)
+		return entity.CardToken{}, false
+	}
+	fmt.Printf("Kartu %s tersimpan.\n", card.Masked())
+
+	return card, true
+}
+
+// readProductQty membaca input ProductId dan Qty dari terminal
+func readProductQty() (int, int, bool) {
+	fmt.Print("Masukkan ProductId: ")
+	productID, err := strconv.Atoi(readInput())
+	if err != nil {
+		fmt.Println("Invalid ProductId. Please enter a number.")
+		return 0, 0, false
+	}
+
+	fmt.Print("Masukkan Qty: ")
+	qty, err := strconv.Atoi(readInput())
+	if err != nil {
+		fmt.Println("Invalid Qty. Please enter a number.")
+		return 0, 0, false
+	}
+
+	return productID, qty, true
+}
+
+// orderHistoryMenu menampilkan riwayat order customer per halaman dan detail order yang dipilih
+func (c *cliHandler) orderHistoryMenu(orderHandler *handler.OrderHandler) {
+	var filter entity.OrderFilter
+
+	// Input filter, kosongkan untuk melewati filter
+	fmt.Printf("Filter status (%s/%s/%s, kosongkan untuk semua): ", entity.StatusProcessing, entity.StatusCompleted, entity.StatusCancel)
+	filter.Status = entity.StatusOrder(readInput())
+
+	fmt.Print("Dari tanggal (YYYY-MM-DD, kosongkan untuk semua): ")
+	if input := readInput(); input != "" {
+		date, err := time.Parse("2006-01-02", input)
+		if err != nil {
+			fmt.Println("Format tanggal tidak valid.")
+			return
+		}
+		filter.DateFrom = date
+	}
+
+	fmt.Print("Sampai tanggal (YYYY-MM-DD, kosongkan untuk semua): ")
+	if input := readInput(); input != "" {
+		date, err := time.Parse("2006-01-02", input)
+		if err != nil {
+			fmt.Println("Format tanggal tidak valid.")
+			return
+		}
+		filter.DateTo = date
+	}
+
+	pageNumber := 1
+	for {
+		page, err := orderHandler.GetOrderHistory(filter)
+		if err != nil {
+			fmt.Println("Failed to get order history:", err)
+			return
+		}
+
+		fmt.Printf("\n===== Order History (Halaman %d) =====", pageNumber)
+		printOrders(page.Orders)
+
+		// Tampilkan navigasi sesuai ketersediaan halaman berikutnya
+		if page.HasMore {
+			fmt.Println("[n] Halaman berikutnya")
+		}
+		fmt.Println("[d] Lihat detail order")
+		fmt.Println("[q] Kembali")
+		fmt.Print("Pilih: ")
+
+		switch readInput() {
+		case "n":
+			if !page.HasMore {
+				fmt.Println("Sudah di halaman terakhir.")
+				continue
+			}
+			filter.Cursor = page.NextCursor
+			pageNumber++
+		case "d":
+			fmt.Print("Silahkan masukan nomor order: ")
+			order, err := orderHandler.GetOrderDetail(readInput())
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				continue
+			}
+			printOrderDetail(order)
+
+			fmt.Print("Tekan Enter untuk kembali...")
+			readInput()
+		case "q":
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// readInput membaca input baris dari stdin dan menghapus spasi kosong di depan dan belakang
+func readInput() string {
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text())
+}
+
+// printOrders menampilkan daftar order dengan detailnya dalam format tabel
+func printOrders(orders []entity.Order) {
+	fmt.Printf("\n===== List Order =====\n")
+	if len(orders) == 0 {
+		fmt.Println("No orders found.")
+		return
+	}
+
+	// Header tabel order
+	fmt.Printf("%-10s %-15s %-12s %-10s %-10s\n", "Order ID", "NumberDisplay", "Order Date", "Status", "Total")
+	fmt.Println(strings.Repeat("-", 60))
+
+	// Loop tiap order dan print detailnya
+	for _, order := range orders {
+		fmt.Printf("%-10d %-15s %-12s %-10s %-10s\n",
+			order.ID, order.NumberDisplay, order.Date, order.Status, order.Total.Rupiah())
+		fmt.Printf("%-20s %-10s %-20s %-8s %-10s\n",
+			"OrderDetailID", "ProductID", "Name", "Qty", "Subtotal")
+
+		// Loop tiap detail produk dalam order
+		for _, detail := range order.Details {
+			fmt.Printf("%-20d %-10d %-20s %-8d %-10s\n",
+				detail.ID, detail.ProductID, detail.Product.Name, detail.Qty, detail.Total.Rupiah())
+		}
+		fmt.Println(strings.Repeat("-", 60))
+	}
+}
+
+// printOrderDetail menampilkan satu order lengkap dengan detail produk, billing, dan pembayarannya
+func printOrderDetail(order entity.Order) {
+	fmt.Printf("\n===== Detail Order %s =====\n", order.NumberDisplay)
+	fmt.Printf("Tanggal : %s\n", order.Date.Format("2006-01-02"))
+	fmt.Printf("Status  : %s\n", order.Status)
+	fmt.Printf("Total   : %s\n", order.Total.Rupiah())
+	fmt.Printf("Ongkir  : %s\n", order.ShippingFee.Rupiah())
+	if order.ShippingAddress != "" {
+		fmt.Printf("Kirim ke: %s\n", order.ShippingAddress)
+	}
+
+	// Detail produk
+	fmt.Printf("\n%-5s %-25s %-8s %-16s %-16s\n", "ID", "Produk", "Qty", "Harga", "Subtotal")
+	fmt.Println(strings.Repeat("-", 74))
+	for _, detail := range order.Details {
+		fmt.Printf("%-5d %-25s %-8d %-16s %-16s\n", detail.ID, truncateString(detail.ProductName, 25), detail.Qty, detail.Price.Rupiah(), detail.Total.Rupiah())
+	}
+
+	// Billing dan pembayaran
+	fmt.Println("\n--- Billing ---")
+	if len(order.Billings) == 0 {
+		fmt.Println("Belum ada billing.")
+		return
+	}
+	for _, billing := range order.Billings {
+		fmt.Printf("%s | Status: %s | Pajak: %s | Ongkir: %s | Total: %s | Jatuh tempo: %s\n",
+			billing.NumberDisplay, billing.Status, billing.Tax.Rupiah(), billing.ShippingFee.Rupiah(), billing.Total.Rupiah(), billing.DueDate.Format("2006-01-02 15:04"))
+		if len(billing.Installments) > 0 {
+			printInstallments(billing.Installments)
+		}
+		if len(billing.Adjustments) > 0 {
+			printAdjustments(billing.Adjustments)
+			fmt.Printf("   Total setelah penyesuaian: %s\n", billing.AdjustedTotal().Rupiah())
+		}
+		for _, line := range billing.TaxLines {
+			inclusive := ""
+			if line.PriceIncludesTax {
+				inclusive = " (termasuk pajak)"
+			}
+			fmt.Printf("   Pajak %-25s | %-8s %6s | DPP: %-12s | Pajak: %s%s\n",
+				truncateString(line.ProductName, 25), line.TaxClass, formatRate(line.Rate), line.TaxableAmount.Rupiah(), line.TaxAmount.Rupiah(), inclusive)
+		}
+
+		if len(billing.Payments) == 0 {
+			fmt.Println("   Belum ada pembayaran.")
+			continue
+		}
+		for _, payment := range billing.Payments {
+			fmt.Printf("   - %s | %-12s | %s\n", payment.Date.Format("2006-01-02 15:04"), payment.Method, payment.Amount.Rupiah())
+		}
+		for _, refund := range billing.Refunds {
+			fmt.Printf("   - %s | %-12s | %s (%s)\n", refund.CreatedAt.Format("2006-01-02 15:04"), "refund", (-refund.Amount).Rupiah(), refund.Reason)
+		}
+	}
+}
+
+// printCart menampilkan isi cart beserta total berjalan dan peringatan validasi
+func printCart(cart entity.Cart, issues []string) {
+	fmt.Printf("\n===== Cart =====\n")
+	if len(cart.Items) == 0 {
+		fmt.Println("Cart masih kosong.")
+		return
+	}
+
+	fmt.Printf("%-10s %-25s %-8s %-16s %-16s\n", "ProductID", "Name", "Qty", "Price", "Subtotal")
+	fmt.Println(strings.Repeat("-", 78))
+	for _, item := range cart.Items {
+		fmt.Printf("%-10d %-25s %-8d %-16s %-16s\n",
+			item.ProductID, truncateString(item.Product.Name, 25), item.Qty, item.Product.Price.Rupiah(), item.Total.Rupiah())
+	}
+	fmt.Println(strings.Repeat("-", 78))
+	fmt.Printf("%-61s %-16s\n", "Total", cart.Total.Rupiah())
+
+	// Tampilkan peringatan stok/harga jika ada
+	for _, issue := range issues {
+		fmt.Println("! " + issue)
+	}
+}
+
+// printAddresses menampilkan buku alamat customer
+func printAddresses(addresses []entity.CustomerAddress) {
+	fmt.Printf("\n===== Address Book =====\n")
+	if len(addresses) == 0 {
+		fmt.Println("Belum ada alamat.")
+		return
+	}
+
+	for _, addr := range addresses {
+		defaultMark := ""
+		if addr.IsDefault {
+			defaultMark = " [default]"
+		}
+		fmt.Printf("%d. %s%s | Zona: %s\n   %s\n", addr.ID, addr.Label, defaultMark, addr.Zone, handler.FormatAddress(addr))
+	}
+}
+
+// printShippingRates menampilkan daftar tarif pengiriman dalam format tabel
+func printShippingRates(rates []entity.ShippingRate) {
+	fmt.Printf("%-5s %-20s %-8s %-15s %-10s %-10s %-16s %-8s\n",
+		"ID", "Name", "Type", "Zone", "Min (g)", "Max (g)", "Fee", "Active")
+	fmt.Println(strings.Repeat("-", 101))
+	for _, r := range rates {
+		fmt.Printf("%-5d %-20s %-8s %-15s %-10d %-10d %-16s %-8t\n",
+			r.ID, truncateString(r.Name, 20), r.Type, r.Zone, r.MinWeight, r.MaxWeight, r.Fee.Rupiah(), r.IsActive)
+	}
+}
+
+// printShipments menampilkan daftar shipment untuk staff dalam format tabel
+func printShipments(shipments []entity.Shipment) {
+	fmt.Printf("%-15s %-20s %-10s %-12s %-20s\n", "Order", "Customer", "Status", "Courier", "Tracking")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, s := range shipments {
+		fmt.Printf("%-15s %-20s %-10s %-12s %-20s\n",
+			s.OrderNumber, truncateString(s.CustomerName, 20), s.Status, s.Courier, s.TrackingNumber)
+	}
+}
+
+// printShipmentTimeline menampilkan status pengiriman dan timeline untuk customer
+func printShipmentTimeline(shipment entity.Shipment) {
+	fmt.Printf("\n===== Tracking %s =====\n", shipment.OrderNumber)
+	fmt.Printf("Status   : %s\n", shipment.Status)
+	if shipment.Courier != "" {
+		fmt.Printf("Kurir    : %s (%s)\n", shipment.Courier, shipment.TrackingNumber)
+	}
+	fmt.Printf("Alamat   : %s\n", shipment.ShippingAddress)
+
+	fmt.Println("\nTimeline:")
+	for _, event := range shipment.Events {
+		line := fmt.Sprintf("- %s  %-10s", event.CreatedAt.Format("2006-01-02 15:04"), event.Status)
+		if event.Note != "" {
+			line += "  " + event.Note
+		}
+		fmt.Println(line)
+	}
+}
+
+// printReturns menampilkan daftar retur beserta item-itemnya
+func printReturns(returns []entity.Return) {
+	fmt.Printf("\n===== Returns =====\n")
+	if len(returns) == 0 {
+		fmt.Println("Belum ada retur.")
+		return
+	}
+
+	for _, ret := range returns {
+		fmt.Printf("%s | Order: %s | Status: %s | Refund: %s\n", ret.NumberDisplay, ret.OrderNumber, ret.Status, ret.RefundAmount.Rupiah())
+		fmt.Printf("   Alasan: %s\n", ret.Reason)
+		if ret.AdminNote != "" {
+			fmt.Printf("   Catatan admin: %s\n", ret.AdminNote)
+		}
+		for _, item := range ret.Items {
+			fmt.Printf("   - %s x%d @ %s (%s)\n", item.ProductName, item.Qty, item.Price.Rupiah(), item.Condition)
+		}
+	}
+}
+
+// PrintProducts menampilkan daftar produk dalam format tabel
+func PrintProducts(products []entity.Product) {
+	fmt.Printf("%-5s %-20s %-6s %-16s %-15s %-10s\n",
+		"ID", "Name", "Stock", "Price", "Category", "Description")
+	fmt.Println(strings.Repeat("-", 76))
+
+	// Loop tiap produk dan tampilkan info dengan truncate description jika panjang
+	for _, p := range products {
+		fmt.Printf("%-5d %-20s %-6d %-16s %-15s %-10s\n",
+			p.ID,
+			p.Name,
+			p.Stock,
+			p.Price.Rupiah(),
+			p.Category.Name,
+			truncateString(p.Description, 10),
+		)
+	}
+}
+
+// printAdjustments menampilkan credit note / debit note sebuah billing
+func printAdjustments(adjustments []entity.BillingAdjustment) {
+	fmt.Printf("   %-14s %-7s %-15s %s\n", "No", "Type", "Amount", "Reason")
+	for _, adjustment := range adjustments {
+		fmt.Printf("   %-14s %-7s %-15s %s\n", adjustment.NumberDisplay, adjustment.Type, adjustment.Signed().Rupiah(), adjustment.Reason)
+	}
+}
+
+// printInstallments menampilkan jadwal cicilan beserta status dan tanda keterlambatan
+func printInstallments(installments []entity.Installment) {
+	now := time.Now()
+	fmt.Printf("   %-4s %-12s %-16s %-16s %-8s\n", "No", "Jatuh Tempo", "Nominal", "Terbayar", "Status")
+	for _, installment := range installments {
+		status := string(installment.Status)
+		if installment.Overdue(now) {
+			status += " (terlambat)"
+		}
+		fmt.Printf("   %-4d %-12s %-16s %-16s %-8s\n",
+			installment.Seq, installment.DueDate.Format("2006-01-02"), installment.Amount.Rupiah(), installment.PaidAmount.Rupiah(), status)
+	}
+}
+
+// formatRate menampilkan tarif basis poin sebagai persen, contoh 1000 menjadi "10.00%"
+func formatRate(rate int64) string {
+	return fmt.Sprintf("%d.%02d%%", rate/100, rate%100)
 }
 
 // truncateString memotong string s jika lebih panjang dari maxLen dan menambahkan "..." di akhir
@@ -10,6 +10,11 @@ import (
 	"github.com/joho/godotenv"          // package untuk load file .env
 )
 
+// LoadEnv memuat file .env jika ada, untuk perintah yang tidak membutuhkan koneksi database
+func LoadEnv() {
+	_ = godotenv.Load()
+}
+
 // InitDB melakukan inisialisasi koneksi ke database MySQL dan mengembalikan *sql.DB
 func InitDB() *sql.DB {
 	// Load konfigurasi dari file .env, jika gagal maka program langsung berhenti
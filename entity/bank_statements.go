@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// StatementLine adalah satu mutasi rekening hasil parsing file statement bank (CSV atau MT940)
+type StatementLine struct {
+	Date		time.Time
+	Amount		Money	// positif untuk dana masuk (kredit), negatif untuk dana keluar (debit)
+	Reference	string	// referensi transaksi dari bank / pengirim
+	Description	string	// keterangan transfer, biasanya berisi berita dari pengirim
+}
+
+// StatementLineStatus adalah status rekonsiliasi satu mutasi kredit
+type StatementLineStatus string
+
+const (
+	StatementMatched	StatementLineStatus = "matched"		// cocok dengan yakin, payment dibuat otomatis
+	StatementReview		StatementLineStatus = "review"		// ambigu, menunggu keputusan admin
+	StatementResolved	StatementLineStatus = "resolved"	// dicocokkan manual oleh admin, payment dibuat
+	StatementIgnored	StatementLineStatus = "ignored"		// bukan pembayaran customer, diabaikan admin
+)
+
+// BankStatementLine merepresentasikan tabel `bank_statement_lines`: mutasi kredit yang sudah diimpor beserta hasil rekonsiliasinya
+type BankStatementLine struct {
+	ID				int
+	Source			string				// nama file statement asal
+	Fingerprint		string				// hash tanggal, nominal, referensi, dan keterangan untuk mencegah impor ganda
+	StatementLine
+	Status			StatementLineStatus
+	BillingID		int					// billing yang dibayar, atau saran billing saat review (0 jika tidak ada)
+	BillingNumber	string
+	PaymentID		int					// payment yang dibuat, 0 jika belum dicocokkan
+	Note			string				// alasan masuk antrean review / diabaikan
+	CreatedAt		time.Time
+	CreatedBy		int
+	ResolvedBy		int
+}
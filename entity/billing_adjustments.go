@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+type AdjustmentType string
+
+const (
+	AdjustmentCredit	AdjustmentType = "credit"	// credit note, mengurangi tagihan (koreksi pajak, diskon goodwill)
+	AdjustmentDebit		AdjustmentType = "debit"	// debit note, menambah tagihan
+)
+
+// BillingAdjustment adalah credit note / debit note atas billing yang sudah terbit.
+// Billing asli tidak diubah; sisa tagihan dihitung dari total billing ditambah seluruh adjustment.
+type BillingAdjustment struct {
+	ID				int
+	NumberDisplay	string	// CN-YYYYMM-NNN atau DN-YYYYMM-NNN
+	BillingID		int
+	BillingNumber	string
+	Type			AdjustmentType
+	Amount			Money	// selalu positif, arah ditentukan Type
+	Reason			string
+	CreatedAt		time.Time
+	CreatedBy		int
+}
+
+// Signed mengembalikan nominal bertanda: negatif untuk credit note, positif untuk debit note
+func (a BillingAdjustment) Signed() Money {
+	if a.Type == AdjustmentCredit {
+		return -a.Amount
+	}
+	return a.Amount
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type BillingAuditAction string
+
+const (
+	AuditSuperseded	BillingAuditAction = "superseded"	// billing unpaid diganti tagihan baru karena nilai order berubah
+	AuditExpired	BillingAuditAction = "expired"		// billing dibatalkan karena lewat due_date (oleh worker, atau GenerateBill untuk billing lesspaid)
+)
+
+type BillingAudit struct {
+	ID			int
+	BillingID	int
+	OrderID		int
+	Action		BillingAuditAction
+	ReplacedBy	int		// 0 jika tidak ada billing pengganti
+	Note		string
+	CreatedAt	time.Time
+	CreatedBy	int		// 0 jika dijalankan worker
+}
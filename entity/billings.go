@@ -19,11 +19,27 @@ type Billing struct {
 	IssueDate 		time.Time
 	DueDate 		time.Time
 	NumberDisplay	string
-	Tax				float64
-	Total			float64
+	Tax				Money
+	ShippingFee		Money
+	Total			Money
 	Status			StatusBilling
+	Payments		[]Payment
+	Refunds			[]Refund
+	TaxLines		[]BillingTaxLine
+	Installments	[]Installment	// kosong jika billing tidak memakai rencana cicilan
+	Adjustments		[]BillingAdjustment	// credit note / debit note, Total tidak ikut berubah
+	VirtualAccounts	[]VirtualAccount	// nomor VA yang sudah diterbitkan untuk billing ini
 	CreatedAt		time.Time
 	UpdatedAt		time.Time
 	CreatedBy		int
 	UpdatedBy		int
-}
\ No newline at end of file
+}
+
+// AdjustedTotal mengembalikan total tagihan setelah credit note dan debit note
+func (b Billing) AdjustedTotal() Money {
+	total := b.Total
+	for _, adjustment := range b.Adjustments {
+		total += adjustment.Signed()
+	}
+	return total
+}
@@ -0,0 +1,169 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CardBrand adalah jaringan kartu kredit yang dikenali dari nomor kartu
+type CardBrand string
+
+const (
+	CardVisa		CardBrand = "visa"
+	CardMastercard	CardBrand = "mastercard"
+	CardAmex		CardBrand = "amex"
+	CardJCB			CardBrand = "jcb"
+)
+
+// CardDetails adalah data kartu yang diketik customer. Hanya hidup di memori selama proses tokenisasi:
+// nomor kartu dan CVV tidak pernah disimpan ke database, log, maupun dokumen.
+type CardDetails struct {
+	Number		string
+	ExpMonth	int
+	ExpYear		int		// 4 digit, contoh 2028
+	CVV			string
+}
+
+// CardToken merepresentasikan tabel `card_tokens`: token kartu dari payment gateway pengganti nomor kartu.
+// Hanya brand, 4 digit terakhir, dan masa berlaku yang disimpan untuk ditampilkan ke customer.
+type CardToken struct {
+	ID			int
+	CustomerID	int
+	Token		string		// token dari gateway, dikirim saat charge
+	Brand		CardBrand
+	Last4		string
+	ExpMonth	int
+	ExpYear		int
+	CreatedAt	time.Time
+	CreatedBy	int
+}
+
+// Masked menampilkan kartu tanpa nomor lengkap, contoh "VISA **** 4242"
+func (c CardToken) Masked() string {
+	return MaskCard(c.Brand, c.Last4)
+}
+
+// Expired bernilai true jika masa berlaku kartu sudah lewat pada waktu now
+func (c CardToken) Expired(now time.Time) bool {
+	return cardExpired(c.ExpMonth, c.ExpYear, now)
+}
+
+// MaskCard menyusun tampilan kartu dari brand dan 4 digit terakhir
+func MaskCard(brand CardBrand, last4 string) string {
+	return fmt.Sprintf("%s **** %s", strings.ToUpper(string(brand)), last4)
+}
+
+// NormalizeCardNumber membuang spasi dan tanda hubung dari nomor kartu yang diketik customer
+func NormalizeCardNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(number))
+}
+
+// DetectCardBrand mengenali brand kartu dari awalan (IIN) nomor kartu; string kosong jika tidak dikenali
+func DetectCardBrand(number string) CardBrand {
+	number = NormalizeCardNumber(number)
+	if len(number) < 4 || !isDigits(number) {
+		return ""
+	}
+
+	prefix2, _ := strconv.Atoi(number[:2])
+	prefix4, _ := strconv.Atoi(number[:4])
+	switch {
+	case number[0] == '4':
+		return CardVisa
+	case prefix2 >= 51 && prefix2 <= 55, prefix4 >= 2221 && prefix4 <= 2720:
+		return CardMastercard
+	case prefix2 == 34 || prefix2 == 37:
+		return CardAmex
+	case prefix4 >= 3528 && prefix4 <= 3589:
+		return CardJCB
+	}
+	return ""
+}
+
+// Validate memeriksa brand, panjang nomor, check digit Luhn, masa berlaku, dan panjang CVV.
+// Mengembalikan brand kartu jika valid.
+func (c CardDetails) Validate(now time.Time) (CardBrand, error) {
+	number := NormalizeCardNumber(c.Number)
+	if !isDigits(number) || number == "" {
+		return "", errors.New("Nomor kartu hanya boleh berisi angka")
+	}
+
+	brand := DetectCardBrand(number)
+	var lengths []int
+	cvvLength := 3
+	switch brand {
+	case CardVisa:
+		lengths = []int{13, 16, 19}
+	case CardMastercard:
+		lengths = []int{16}
+	case CardAmex:
+		lengths, cvvLength = []int{15}, 4
+	case CardJCB:
+		lengths = []int{16, 17, 18, 19}
+	default:
+		return "", errors.New("Jenis kartu tidak didukung (hanya Visa, Mastercard, Amex, JCB)")
+	}
+
+	validLength := false
+	for _, l := range lengths {
+		validLength = validLength || len(number) == l
+	}
+	if !validLength || !LuhnValid(number) {
+		return brand, errors.New("Nomor kartu tidak valid")
+	}
+
+	if c.ExpMonth < 1 || c.ExpMonth > 12 || c.ExpYear < 2000 {
+		return brand, errors.New("Masa berlaku kartu tidak valid")
+	}
+	if cardExpired(c.ExpMonth, c.ExpYear, now) {
+		return brand, errors.New("Kartu sudah kedaluwarsa")
+	}
+
+	if len(c.CVV) != cvvLength || !isDigits(c.CVV) {
+		return brand, fmt.Errorf("CVV harus %d digit angka", cvvLength)
+	}
+
+	return brand, nil
+}
+
+// Last4 mengembalikan 4 digit terakhir nomor kartu
+func (c CardDetails) Last4() string {
+	number := NormalizeCardNumber(c.Number)
+	if len(number) < 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
+
+// ParseCardExpiry membaca masa berlaku kartu dengan format MM/YY atau MM/YYYY
+func ParseCardExpiry(s string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("Format masa berlaku harus MM/YY")
+	}
+
+	month, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, errors.New("Bulan masa berlaku tidak valid")
+	}
+
+	yearText := strings.TrimSpace(parts[1])
+	year, err := strconv.Atoi(yearText)
+	if err != nil || (len(yearText) != 2 && len(yearText) != 4) {
+		return 0, 0, errors.New("Tahun masa berlaku tidak valid")
+	}
+	if len(yearText) == 2 {
+		year += 2000
+	}
+
+	return month, year, nil
+}
+
+// cardExpired menghitung kedaluwarsa kartu: kartu berlaku sampai akhir bulan masa berlakunya
+func cardExpired(month, year int, now time.Time) bool {
+	firstInvalid := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, now.Location())
+	return !now.Before(firstInvalid)
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDetectCardBrand menguji pengenalan brand dari awalan nomor kartu uji.
+func TestDetectCardBrand(t *testing.T) {
+	assert.Equal(t, CardVisa, DetectCardBrand("4111 1111 1111 1111"))
+	assert.Equal(t, CardMastercard, DetectCardBrand("5555555555554444"))
+	assert.Equal(t, CardMastercard, DetectCardBrand("2223003122003222"))
+	assert.Equal(t, CardAmex, DetectCardBrand("378282246310005"))
+	assert.Equal(t, CardJCB, DetectCardBrand("3530111333300000"))
+	assert.Equal(t, CardBrand(""), DetectCardBrand("6011111111111117"))
+	assert.Equal(t, CardBrand(""), DetectCardBrand("41"))
+}
+
+// TestCardDetailsValidate menguji validasi nomor, masa berlaku, dan CVV kartu.
+func TestCardDetailsValidate(t *testing.T) {
+	now := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+
+	brand, err := CardDetails{Number: "4111-1111-1111-1111", ExpMonth: 10, ExpYear: 2026, CVV: "123"}.Validate(now)
+	require.NoError(t, err)
+	assert.Equal(t, CardVisa, brand)
+
+	_, err = CardDetails{Number: "378282246310005", ExpMonth: 1, ExpYear: 2030, CVV: "1234"}.Validate(now)
+	assert.NoError(t, err)
+
+	cases := map[string]CardDetails{
+		"check digit salah":   {Number: "4111111111111112", ExpMonth: 1, ExpYear: 2030, CVV: "123"},
+		"panjang salah":       {Number: "41111111111111", ExpMonth: 1, ExpYear: 2030, CVV: "123"},
+		"brand tidak dikenal": {Number: "6011111111111117", ExpMonth: 1, ExpYear: 2030, CVV: "123"},
+		"kedaluwarsa":         {Number: "4111111111111111", ExpMonth: 9, ExpYear: 2026, CVV: "123"},
+		"bulan salah":         {Number: "4111111111111111", ExpMonth: 13, ExpYear: 2030, CVV: "123"},
+		"CVV amex 3 digit":    {Number: "378282246310005", ExpMonth: 1, ExpYear: 2030, CVV: "123"},
+		"CVV huruf":           {Number: "4111111111111111", ExpMonth: 1, ExpYear: 2030, CVV: "12a"},
+	}
+	for name, card := range cases {
+		_, err := card.Validate(now)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestParseCardExpiry menguji pembacaan masa berlaku MM/YY dan MM/YYYY.
+func TestParseCardExpiry(t *testing.T) {
+	month, year, err := ParseCardExpiry("07/28")
+	require.NoError(t, err)
+	assert.Equal(t, 7, month)
+	assert.Equal(t, 2028, year)
+
+	month, year, err = ParseCardExpiry(" 12 / 2030 ")
+	require.NoError(t, err)
+	assert.Equal(t, 12, month)
+	assert.Equal(t, 2030, year)
+
+	for _, input := range []string{"0728", "13/28", "07/2", "aa/bb"} {
+		_, _, err := ParseCardExpiry(input)
+		assert.Error(t, err, input)
+	}
+}
+
+// TestCardTokenMasked memastikan tampilan kartu hanya memuat 4 digit terakhir.
+func TestCardTokenMasked(t *testing.T) {
+	card := CardDetails{Number: "5555 5555 5555 4444"}
+	token := CardToken{Brand: CardMastercard, Last4: card.Last4(), ExpMonth: 2, ExpYear: 2027}
+	assert.Equal(t, "MASTERCARD **** 4444", token.Masked())
+	assert.False(t, token.Expired(time.Date(2027, 2, 28, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, token.Expired(time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+type CartItem struct {
+	ID				int
+	CustomerID		int
+	ProductID		int
+	Product			Product
+	Qty				int
+	Price			Money	// harga saat item ditambahkan ke cart
+	Total			Money	// qty * harga produk saat ini
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	CreatedBy		int
+	UpdatedBy		int
+}
+
+type Cart struct {
+	Items			[]CartItem
+	Total			Money
+	Weight			int	// total berat dalam gram
+}
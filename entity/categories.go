@@ -5,6 +5,7 @@ import "time"
 type Category struct{
 	ID			int
 	Name		string
+	TaxClass	string
 	CreatedAt	time.Time
 	UpdatedAt	time.Time
 	CreatedBy	time.Time
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+type CustomerAddress struct {
+	ID				int
+	CustomerID		int
+	Label			string	// contoh: Rumah, Kantor
+	RecipientName	string
+	PhoneNumber		string
+	Address			string
+	City			string
+	PostalCode		string
+	Zone			string	// zona pengiriman, dipakai untuk tarif berbasis zona
+	IsDefault		bool
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	CreatedBy		int
+	UpdatedBy		int
+}
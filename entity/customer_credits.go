@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+type CreditType string
+
+const (
+	CreditOverpayment	CreditType = "overpayment"	// kelebihan bayar yang disimpan sebagai saldo
+	CreditApplied		CreditType = "applied"		// saldo dipakai untuk membayar billing (amount negatif)
+	CreditFromNote		CreditType = "credit_note"	// credit note atas billing yang sudah dibayar melebihi total barunya
+	CreditFromExpired	CreditType = "expired_billing"	// dana bersih billing lesspaid yang dibatalkan karena lewat jatuh tempo
+)
+
+type CustomerCredit struct {
+	ID			int
+	CustomerID	int
+	BillingID	int
+	PaymentID	int
+	Type		CreditType
+	Amount		Money	// positif menambah saldo, negatif mengurangi saldo
+	Note		string
+	CreatedAt	time.Time
+	CreatedBy	int
+}
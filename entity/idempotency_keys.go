@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+// IdempotencyWindow adalah lama idempotency key disimpan; setelah lewat, key yang sama dianggap permintaan baru
+const IdempotencyWindow = 24 * time.Hour
+
+// IdempotencyPendingTimeout adalah batas waktu key pending; setelah lewat, permintaan pertama dianggap berhenti
+// di tengah jalan dan key boleh diambil alih oleh permintaan ulang
+const IdempotencyPendingTimeout = 2 * time.Minute
+
+// IdempotencyStatus adalah status permintaan yang memakai idempotency key
+type IdempotencyStatus string
+
+const (
+	IdempotencyPending		IdempotencyStatus = "pending"	// permintaan pertama sedang diproses
+	IdempotencyCompleted	IdempotencyStatus = "completed"	// selesai, Response dikembalikan untuk permintaan ulang
+)
+
+// IdempotencyKey merepresentasikan tabel `idempotency_keys`: hasil permintaan CreateOrder/CreatePayment
+// yang disimpan per user, operasi, dan key agar permintaan ulang tidak menulis data dua kali.
+type IdempotencyKey struct {
+	ID			int
+	Key			string
+	UserID		int
+	Operation	string				// contoh create_order, create_payment
+	RequestHash	string				// sha256 isi permintaan, key yang sama dengan isi berbeda ditolak
+	ResourceID	int					// ID order atau charge yang dibuat
+	Response	string				// hasil permintaan pertama dalam JSON
+	Status		IdempotencyStatus
+	CreatedAt	time.Time
+	ExpiresAt	time.Time
+}
+
+// Expired mengembalikan true jika key sudah melewati IdempotencyWindow
+func (k IdempotencyKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// Stale mengembalikan true jika key masih pending lebih lama dari IdempotencyPendingTimeout
+func (k IdempotencyKey) Stale(now time.Time) bool {
+	return k.Status == IdempotencyPending && now.Sub(k.CreatedAt) > IdempotencyPendingTimeout
+}
@@ -0,0 +1,41 @@
+package entity
+
+import "time"
+
+type StatusInstallment string
+
+const (
+	InstallmentOpen		StatusInstallment = "open"
+	InstallmentPartial	StatusInstallment = "partial"
+	InstallmentPaid		StatusInstallment = "paid"
+)
+
+type Installment struct {
+	ID			int
+	BillingID	int
+	Seq			int
+	Amount		Money
+	PaidAmount	Money
+	DueDate		time.Time
+	Status		StatusInstallment
+	CreatedAt	time.Time
+	CreatedBy	int
+}
+
+// Remaining mengembalikan sisa cicilan yang belum dibayar
+func (i Installment) Remaining() Money {
+	return i.Amount - i.PaidAmount
+}
+
+// Overdue bernilai true jika cicilan belum lunas dan sudah lewat jatuh tempo
+func (i Installment) Overdue(now time.Time) bool {
+	return i.Status != InstallmentPaid && now.After(i.DueDate.AddDate(0, 0, 1))
+}
+
+type PaymentAllocation struct {
+	ID				int
+	PaymentID		int
+	InstallmentID	int
+	Amount			Money
+	CreatedAt		time.Time
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// Invoice adalah data lengkap sebuah billing untuk dicetak sebagai invoice atau kuitansi
+type Invoice struct {
+	Billing			Billing			// beserta Payments, Refunds, TaxLines, dan Installments
+	OrderNumber		string
+	OrderDate		time.Time
+	ShippingAddress	string
+	Customer		Customer
+	Lines			[]OrderDetail
+	Subtotal		Money	// dasar pengenaan pajak, total - pajak - ongkir
+	Paid			Money
+	Refunded		Money
+	BalanceDue		Money	// 0 jika billing sudah tidak aktif (paid, cancelled, refunded)
+}
+
+// IsReceipt bernilai true jika billing sudah lunas sehingga dokumen dicetak sebagai kuitansi
+func (i Invoice) IsReceipt() bool {
+	return i.BalanceDue <= 0 && i.Paid > 0
+}
+
+// PaymentReceipt adalah bukti satu pembayaran atas sebuah billing, dicetak dari menu My Payments
+type PaymentReceipt struct {
+	Number			string	// nomor kuitansi, contoh RCP-202506-001-0007
+	Payment			Payment
+	BillingNumber	string
+	OrderNumber		string
+	Customer		Customer
+	BillingTotal	Money	// total tagihan setelah credit note / debit note yang terbit sebelum pembayaran
+	PaidToDate		Money	// total dibayar sampai dengan pembayaran ini, dikurangi refund sebelumnya
+	BalanceAfter	Money	// sisa tagihan tepat setelah pembayaran ini
+}
@@ -0,0 +1,197 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money menyimpan nominal rupiah dalam satuan sen (1/100 rupiah) sebagai bilangan bulat,
+// sehingga penjumlahan dan perbandingan nominal tidak bergeser seperti float64.
+// Penjumlahan dan pengurangan cukup memakai operator + dan - biasa.
+type Money int64
+
+// NewMoney membuat Money dari nominal rupiah bulat
+func NewMoney(rupiah int64) Money {
+	return Money(rupiah * 100)
+}
+
+// ParseMoney membaca nominal desimal seperti "12345", "12345.5" atau "-12345.67".
+// Digit desimal lebih dari dua dibulatkan half-up ke sen terdekat.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("nominal kosong")
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("nominal tidak valid: %q", s)
+	}
+
+	rupiah, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("nominal tidak valid: %q", s)
+	}
+
+	// Ambil dua digit sen, digit ketiga dipakai untuk pembulatan
+	frac += "000"
+	cents, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		cents++
+	}
+
+	m := Money(rupiah*100 + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// Mul mengalikan nominal dengan qty
+func (m Money) Mul(qty int) Money {
+	return m * Money(qty)
+}
+
+// ApplyRate menghitung persentase nominal dengan tarif dalam basis poin (1000 = 10%),
+// dibulatkan half-up ke sen terdekat. Dipakai untuk perhitungan pajak.
+func (m Money) ApplyRate(basisPoints int64) Money {
+	return m.MulDiv(Money(basisPoints), 10000)
+}
+
+// MulDiv menghitung m * num / den dengan pembulatan half-up ke sen terdekat,
+// misalnya untuk membagi pajak secara proporsional. Perkalian memakai big.Int agar tidak overflow.
+func (m Money) MulDiv(num Money, den Money) Money {
+	if den == 0 {
+		return 0
+	}
+
+	product := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(num)))
+	divisor := big.NewInt(int64(den))
+
+	negative := product.Sign()*divisor.Sign() < 0
+	product.Abs(product)
+	divisor.Abs(divisor)
+
+	quotient, remainder := new(big.Int).QuoRem(product, divisor, new(big.Int))
+	if remainder.Mul(remainder, big.NewInt(2)).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+
+	result := Money(quotient.Int64())
+	if negative {
+		result = -result
+	}
+	return result
+}
+
+// Min mengembalikan nominal yang lebih kecil
+func (m Money) Min(other Money) Money {
+	if other < m {
+		return other
+	}
+	return m
+}
+
+// String menampilkan nominal dengan dua angka desimal, contoh "12345.67"
+func (m Money) String() string {
+	sign := ""
+	value := int64(m)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)
+}
+
+// Rupiah menampilkan nominal dengan format rupiah, contoh "Rp 12.345,67"
+func (m Money) Rupiah() string {
+	sign := ""
+	value := int64(m)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	// Sisipkan titik setiap tiga digit
+	digits := strconv.FormatInt(value/100, 10)
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(d)
+	}
+
+	return fmt.Sprintf("%sRp %s,%02d", sign, grouped.String(), value%100)
+}
+
+// Scan membaca nilai DECIMAL dari database. Driver MySQL mengirim DECIMAL sebagai []byte,
+// sedangkan SQLite bisa mengirim int64, float64, atau string.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = NewMoney(v)
+	case float64:
+		*m = Money(math.Round(v * 100))
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	default:
+		return fmt.Errorf("tidak bisa membaca %T sebagai Money", value)
+	}
+	return nil
+}
+
+// Value mengirim nominal ke database sebagai string desimal agar DECIMAL(10,2) tersimpan persis
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// MarshalJSON menulis nominal sebagai angka JSON dengan dua desimal, contoh 12345.67
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON membaca nominal dari angka JSON maupun string desimal, contoh 12345.67 atau "12345.67"
+func (m *Money) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// isDigits memastikan string hanya berisi angka 0-9
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
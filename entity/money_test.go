@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseMoney menguji pembacaan nominal desimal termasuk pembulatan ke sen.
+func TestParseMoney(t *testing.T) {
+	cases := map[string]Money{
+		"12345":     1234500,
+		"12345.6":   1234560,
+		"12345.67":  1234567,
+		"12345.675": 1234568,
+		"-50000.00": -5000000,
+		".5":        50,
+	}
+	for input, expected := range cases {
+		m, err := ParseMoney(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, m, input)
+	}
+
+	for _, input := range []string{"", "abc", "1.2.3", "1,000"} {
+		_, err := ParseMoney(input)
+		assert.Error(t, err, input)
+	}
+}
+
+// TestMoney_Arithmetic memastikan penjumlahan nominal tidak bergeser seperti float64.
+func TestMoney_Arithmetic(t *testing.T) {
+	// 0.1 + 0.2 pada float64 menghasilkan 0.30000000000000004
+	a, _ := ParseMoney("0.10")
+	b, _ := ParseMoney("0.20")
+	c, _ := ParseMoney("0.30")
+	assert.Equal(t, c, a+b)
+
+	assert.Equal(t, NewMoney(300000), NewMoney(100000).Mul(3))
+	assert.Equal(t, NewMoney(50000), NewMoney(50000).Min(NewMoney(60000)))
+}
+
+// TestMoney_ApplyRate menguji pembulatan pajak half-up ke sen terdekat.
+func TestMoney_ApplyRate(t *testing.T) {
+	assert.Equal(t, NewMoney(35000), NewMoney(350000).ApplyRate(1000))
+
+	// 10% dari 0.05 = 0.005 dibulatkan menjadi 0.01
+	assert.Equal(t, Money(1), Money(5).ApplyRate(1000))
+	// 10% dari 0.04 = 0.004 dibulatkan menjadi 0.00
+	assert.Equal(t, Money(0), Money(4).ApplyRate(1000))
+	// Nominal negatif dibulatkan menjauhi nol
+	assert.Equal(t, Money(-1), Money(-5).ApplyRate(1000))
+
+	// Pembagian proporsional: 100000 x 25000 / 250000 = 10000
+	assert.Equal(t, NewMoney(10000), NewMoney(100000).MulDiv(NewMoney(25000), NewMoney(250000)))
+	assert.Equal(t, Money(0), NewMoney(100000).MulDiv(NewMoney(1), 0))
+}
+
+// TestMoney_Format menguji format tampilan dan nilai yang dikirim ke database.
+func TestMoney_Format(t *testing.T) {
+	m, _ := ParseMoney("1234567.8")
+	assert.Equal(t, "1234567.80", m.String())
+	assert.Equal(t, "Rp 1.234.567,80", m.Rupiah())
+	assert.Equal(t, "-Rp 500,00", NewMoney(-500).Rupiah())
+	assert.Equal(t, "-0.05", Money(-5).String())
+
+	value, err := m.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "1234567.80", value)
+}
+
+// TestMoney_Scan menguji pembacaan dari berbagai tipe yang dikirim driver database.
+func TestMoney_Scan(t *testing.T) {
+	var m Money
+
+	require.NoError(t, m.Scan([]byte("275000.00")))
+	assert.Equal(t, NewMoney(275000), m)
+
+	require.NoError(t, m.Scan("-50000.50"))
+	assert.Equal(t, Money(-5000050), m)
+
+	require.NoError(t, m.Scan(int64(15000)))
+	assert.Equal(t, NewMoney(15000), m)
+
+	// Hasil SUM float dari SQLite dibulatkan ke sen
+	require.NoError(t, m.Scan(0.1+0.2))
+	assert.Equal(t, Money(30), m)
+
+	require.NoError(t, m.Scan(nil))
+	assert.Equal(t, Money(0), m)
+
+	assert.Error(t, m.Scan(true))
+}
+
+// TestMoney_JSON menguji nominal di payload JSON, baik sebagai angka maupun string.
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(struct{ Amount Money }{Money(1234567)})
+	require.NoError(t, err)
+	assert.Equal(t, `{"Amount":12345.67}`, string(data))
+
+	var payload struct{ Amount Money }
+	require.NoError(t, json.Unmarshal([]byte(`{"Amount":50000}`), &payload))
+	assert.Equal(t, NewMoney(50000), payload.Amount)
+	require.NoError(t, json.Unmarshal([]byte(`{"Amount":"0.1"}`), &payload))
+	assert.Equal(t, Money(10), payload.Amount)
+	assert.Error(t, json.Unmarshal([]byte(`{"Amount":"abc"}`), &payload))
+}
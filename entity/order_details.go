@@ -9,7 +9,12 @@ type OrderDetail struct {
 	ProductID	int
 	Product		Product
 	Qty			int
-	Total			float64
+	ProductName	string
+	Price			Money
+	TaxClass	string
+	PriceIncludesTax	bool
+	Weight		int		// snapshot berat produk per unit dalam gram
+	Total			Money
 	CreatedAt	time.Time
 	UpdatedAt	time.Time
 	CreatedBy	int
@@ -19,8 +19,13 @@ type Order struct{
 	NumberDisplay	string
 	Date			time.Time
 	Status			StatusOrder
-	Total			float64
+	Total			Money
+	ShippingAddressID	int
+	ShippingAddress		string	// snapshot alamat saat checkout
+	ShippingZone		string	// snapshot zona alamat saat checkout, dipakai menghitung ulang ongkos kirim
+	ShippingFee		Money
 	Details			[]OrderDetail
+	Billings		[]Billing
 	CreatedAt		time.Time
 	UpdatedAt		time.Time
 	CreatedBy		int
@@ -29,4 +34,20 @@ type Order struct{
 
 type OrderProduct struct{
 	ProductId, Qty int
+}
+
+// OrderFilter berisi kriteria pencarian riwayat order customer
+type OrderFilter struct{
+	Status		StatusOrder	// kosong berarti semua status
+	DateFrom	time.Time	// zero value berarti tanpa batas awal
+	DateTo		time.Time	// zero value berarti tanpa batas akhir
+	Cursor		int			// ID order terakhir dari halaman sebelumnya, 0 untuk halaman pertama
+	Limit		int
+}
+
+// OrderPage adalah satu halaman hasil riwayat order
+type OrderPage struct{
+	Orders		[]Order
+	NextCursor	int
+	HasMore		bool
 }
\ No newline at end of file
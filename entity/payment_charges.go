@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// ChargeStatus adalah status transaksi di payment gateway
+type ChargeStatus string
+
+const (
+	ChargePending	ChargeStatus = "pending"	// menunggu konfirmasi gateway
+	ChargeApproved	ChargeStatus = "approved"	// dana diterima, payment sudah dicatat
+	ChargeDeclined	ChargeStatus = "declined"	// ditolak gateway, tidak ada payment
+)
+
+// PaymentCharge merepresentasikan tabel `payment_charges`: jejak setiap permintaan charge ke payment gateway.
+// Payment hanya dibuat (PaymentID terisi) setelah charge berstatus approved.
+type PaymentCharge struct {
+	ID				int				// Primary key
+	BillingID		int				// Billing yang dibayar
+	BillingNumber	string			// Nomor billing (hasil join)
+	ChargeRef		string			// ID transaksi dari gateway
+	Amount			Money			// Nominal yang dibayarkan ke billing
+	Fee				Money			// Biaya metode pembayaran, ditagih di atas Amount
+	Method			Method			// Metode pembayaran
+	Status			ChargeStatus	// pending / approved / declined
+	Message			string			// Keterangan dari gateway (alasan penolakan, dll)
+	CardTokenID		int				// Kartu yang di-charge, 0 jika bukan credit_card
+	Card			string			// Tampilan kartu tersamar, contoh "VISA **** 4242" (hasil join)
+	QRPayload		string			// Payload QR EMVCo yang dipindai customer, kosong jika bukan qris
+	PaymentID		int				// Payment hasil charge, 0 jika belum approved
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	CreatedBy		int				// User yang melakukan pembayaran
+}
+
+// Total mengembalikan nominal yang benar-benar ditagih gateway ke customer: nominal billing ditambah biaya metode
+func (c PaymentCharge) Total() Money {
+	return c.Amount + c.Fee
+}
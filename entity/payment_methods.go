@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// PaymentMethod adalah pengaturan sebuah metode pembayaran yang dikelola admin lewat tabel `payment_methods`
+type PaymentMethod struct {
+	ID				int
+	Code			Method
+	Name			string	// nama yang ditampilkan ke customer
+	Enabled			bool	// false jika metode tidak bisa dipakai customer
+	FeeFlat			Money	// biaya tetap per transaksi
+	FeeRate			int64	// biaya persentase dalam basis poin (70 = 0,7%)
+	MinAmount		Money	// 0 berarti tanpa batas minimal
+	MaxAmount		Money	// 0 berarti tanpa batas maksimal
+	DisplayOrder	int		// urutan di menu pembayaran customer
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	CreatedBy		int
+	UpdatedBy		int
+}
+
+// Fee menghitung biaya yang ditambahkan ke pembayaran sebesar amount, dibulatkan half-up ke sen terdekat
+func (m PaymentMethod) Fee(amount Money) Money {
+	if amount <= 0 {
+		return 0
+	}
+	return m.FeeFlat + amount.ApplyRate(m.FeeRate)
+}
+
+// InRange bernilai true jika amount berada di antara batas minimal dan maksimal metode
+func (m PaymentMethod) InRange(amount Money) bool {
+	if m.MinAmount > 0 && amount < m.MinAmount {
+		return false
+	}
+	return m.MaxAmount <= 0 || amount <= m.MaxAmount
+}
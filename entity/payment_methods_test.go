@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPaymentMethodFee menguji perhitungan biaya dan batas nominal sebuah metode pembayaran.
+func TestPaymentMethodFee(t *testing.T) {
+	method := PaymentMethod{FeeFlat: NewMoney(1000), FeeRate: 250, MinAmount: NewMoney(10000)}
+	assert.Equal(t, NewMoney(3500), method.Fee(NewMoney(100000)), "1000 + 2,5% x 100000")
+	assert.Equal(t, Money(0), method.Fee(0))
+	assert.Equal(t, Money(1), PaymentMethod{FeeRate: 70}.Fee(Money(100)), "0,7% x Rp 1,00 dibulatkan half-up")
+
+	assert.False(t, method.InRange(NewMoney(9999)))
+	assert.True(t, method.InRange(NewMoney(10000)))
+	assert.True(t, method.InRange(NewMoney(1000000000)), "MaxAmount 0 berarti tanpa batas")
+
+	method.MaxAmount = NewMoney(50000)
+	assert.False(t, method.InRange(NewMoney(50001)))
+}
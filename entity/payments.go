@@ -8,14 +8,18 @@ const (
 	MethodCredit			Method = "credit_card"
 	MethodVA				Method = "va"
 	MethodTransfer			Method = "transfer"
+	MethodStoreCredit		Method = "store_credit"	// dibayar dari saldo kredit customer
+	MethodQRIS				Method = "qris"			// customer memindai QR pembayaran dari aplikasi bank / e-wallet
 )
 
 type Payment struct {
 	ID			int
 	BillingID	int
 	Date		time.Time
-	Amount		float64
+	Amount		Money
 	Method		Method
+	Refunded	Money	// total yang sudah direfund dari pembayaran ini
+	Card		string	// kartu tersamar untuk pembayaran credit_card, contoh "VISA **** 4242"
 	CreatedAt	time.Time
 	UpdatedAt	time.Time
 	CreatedBy	int
@@ -9,7 +9,10 @@ type Product struct{
 	Description	string
 	CategoryID	int
 	Category	Category
-	Price		float64
+	Price		Money
+	TaxClass	string	// kosong berarti mengikuti kelas pajak kategori
+	PriceIncludesTax	bool
+	Weight		int	// gram
 	CreatedAt	time.Time
 	UpdatedAt	time.Time
 	CreatedBy	int
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// RefundStatus adalah status pengembalian dana
+type RefundStatus string
+
+const (
+	RefundPending	RefundStatus = "pending"	// tercatat, menunggu payment gateway mengembalikan dana
+	RefundCompleted	RefundStatus = "completed"	// dana sudah dikembalikan
+	RefundFailed	RefundStatus = "failed"		// ditolak gateway, tidak mengurangi dana billing
+)
+
+type Refund struct {
+	ID			int
+	BillingID	int
+	PaymentID	int
+	ReturnID	int		// 0 jika refund tidak berasal dari retur
+	Amount		Money
+	Reason		string
+	Status		RefundStatus
+	ChargeRef	string	// charge gateway yang direfund, kosong untuk pembayaran tanpa charge
+	GatewayRef	string	// ID refund dari gateway
+	Message		string	// alasan penolakan dari gateway
+	CreatedAt	time.Time
+	CreatedBy	int
+}
@@ -0,0 +1,54 @@
+package entity
+
+import "time"
+
+type StatusReturn string
+
+const (
+	ReturnRequested		StatusReturn = "requested"
+	ReturnApproved		StatusReturn = "approved"
+	ReturnRejected		StatusReturn = "rejected"
+	ReturnReceived		StatusReturn = "received"
+)
+
+type ItemCondition string
+
+const (
+	ConditionPending	ItemCondition = "pending"	// barang belum diterima gudang
+	ConditionRestock	ItemCondition = "restock"	// barang layak jual, stok dikembalikan
+	ConditionDamaged	ItemCondition = "damaged"	// barang rusak, dihapusbukukan
+)
+
+type Return struct {
+	ID				int
+	NumberDisplay	string
+	OrderID			int
+	OrderNumber		string
+	BillingID		int
+	CustomerID		int
+	Status			StatusReturn
+	Reason			string
+	AdminNote		string
+	RefundAmount	Money	// nilai barang + pajak yang dikembalikan ke customer
+	Items			[]ReturnItem
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	CreatedBy		int
+	UpdatedBy		int
+}
+
+type ReturnItem struct {
+	ID				int
+	ReturnID		int
+	OrderDetailID	int
+	ProductID		int
+	ProductName		string
+	Qty				int
+	Price			Money
+	Condition		ItemCondition
+}
+
+// ReturnLine adalah input baris order yang ingin dikembalikan customer
+type ReturnLine struct {
+	OrderDetailID, Qty int
+}
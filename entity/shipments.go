@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+type StatusShipment string
+
+const (
+	ShipmentPicking		StatusShipment = "picking"
+	ShipmentPacking		StatusShipment = "packing"
+	ShipmentShipped		StatusShipment = "shipped"
+	ShipmentDelivered	StatusShipment = "delivered"
+)
+
+// ShipmentFlow adalah urutan tahapan fulfilment yang harus dilalui sebuah order
+var ShipmentFlow = []StatusShipment{ShipmentPicking, ShipmentPacking, ShipmentShipped, ShipmentDelivered}
+
+type Shipment struct {
+	ID				int
+	OrderID			int
+	OrderNumber		string
+	CustomerName	string
+	ShippingAddress	string
+	Status			StatusShipment
+	Courier			string
+	TrackingNumber	string
+	Events			[]ShipmentEvent
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	CreatedBy		int
+	UpdatedBy		int
+}
+
+type ShipmentEvent struct {
+	ID			int
+	ShipmentID	int
+	Status		StatusShipment
+	Note		string
+	CreatedAt	time.Time
+	CreatedBy	int
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+type ShippingRateType string
+
+const (
+	RateFlat		ShippingRateType = "flat"
+	RateWeight		ShippingRateType = "weight"
+	RateZone		ShippingRateType = "zone"
+)
+
+type ShippingRate struct {
+	ID			int
+	Name		string
+	Type		ShippingRateType
+	Zone		string	// hanya untuk tipe zone
+	MinWeight	int		// gram, 0 berarti tanpa batas bawah
+	MaxWeight	int		// gram, 0 berarti tanpa batas atas
+	Fee			Money
+	IsActive	bool
+	CreatedAt	time.Time
+	UpdatedAt	time.Time
+	CreatedBy	int
+	UpdatedBy	int
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type StatusReservation string
+
+const (
+	ReservationReserved		StatusReservation = "reserved"	// stok ditahan selama billing belum lunas
+	ReservationCommitted	StatusReservation = "committed"	// billing lunas, stok terjual
+	ReservationReleased		StatusReservation = "released"	// billing kedaluwarsa, stok dikembalikan
+)
+
+type StockReservation struct {
+	ID			int
+	BillingID	int
+	ProductID	int
+	Qty			int
+	Status		StatusReservation
+	CreatedAt	time.Time
+	UpdatedAt	time.Time
+}
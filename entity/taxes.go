@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+type TaxClass struct {
+	ID			int
+	Code		string
+	Name		string
+	Rate		int64	// tarif yang berlaku hari ini dalam basis poin (1000 = 10%)
+	HasRate		bool	// false jika belum ada tarif yang berlaku
+	CreatedAt	time.Time
+	UpdatedAt	time.Time
+	CreatedBy	int
+	UpdatedBy	int
+}
+
+type TaxRate struct {
+	ID				int
+	TaxClass		string
+	Rate			int64		// basis poin (1000 = 10%)
+	EffectiveFrom	time.Time
+	EffectiveTo		time.Time	// zero value berarti masih berlaku
+	CreatedAt		time.Time
+	CreatedBy		int
+}
+
+// BillingTaxLine adalah rincian pajak per baris order yang disimpan saat billing dibuat
+type BillingTaxLine struct {
+	ID					int
+	BillingID			int
+	OrderDetailID		int
+	ProductName			string
+	TaxClass			string
+	Rate				int64	// basis poin (1000 = 10%)
+	PriceIncludesTax	bool
+	TaxableAmount		Money	// dasar pengenaan pajak (tanpa pajak)
+	TaxAmount			Money
+}
@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// VABank adalah bank penerbit virtual account beserta kode perusahaan (prefix) toko di bank tersebut
+type VABank struct {
+	Code	string	// kode bank yang dipilih customer, contoh "bca"
+	Name	string
+	Prefix	string	// 5 digit kode perusahaan di bank
+}
+
+// VABanks adalah daftar bank yang bisa menerbitkan virtual account
+var VABanks = []VABank{
+	{Code: "bca", Name: "BCA", Prefix: "39358"},
+	{Code: "bni", Name: "BNI", Prefix: "98808"},
+	{Code: "bri", Name: "BRI", Prefix: "12658"},
+	{Code: "mandiri", Name: "Mandiri", Prefix: "89608"},
+}
+
+// VANumberLength adalah panjang nomor virtual account: prefix 5 + customer 5 + billing 5 + check digit 1
+const VANumberLength = 16
+
+// VirtualAccount merepresentasikan tabel `virtual_accounts`: nomor VA per billing per bank.
+// Transfer masuk ke nomor ini otomatis dicocokkan ke billing-nya.
+type VirtualAccount struct {
+	ID				int
+	BillingID		int
+	BillingNumber	string
+	CustomerID		int
+	Bank			string	// kode bank, lihat VABanks
+	Number			string	// 16 digit, lihat NewVANumber
+	CreatedAt		time.Time
+	CreatedBy		int
+}
+
+// FindVABank mencari bank virtual account berdasarkan kode atau prefix nomornya
+func FindVABank(codeOrPrefix string) (VABank, bool) {
+	for _, bank := range VABanks {
+		if bank.Code == codeOrPrefix || bank.Prefix == codeOrPrefix {
+			return bank, true
+		}
+	}
+	return VABank{}, false
+}
+
+// NewVANumber menyusun nomor virtual account dari prefix bank, 5 digit terakhir ID customer,
+// 5 digit terakhir ID billing, dan check digit Luhn
+func NewVANumber(prefix string, customerID, billingID int) string {
+	base := fmt.Sprintf("%s%05d%05d", prefix, customerID%100000, billingID%100000)
+	return fmt.Sprintf("%s%d", base, LuhnCheckDigit(base))
+}
+
+// ValidVANumber memeriksa panjang, prefix bank, dan check digit nomor virtual account
+func ValidVANumber(number string) bool {
+	if len(number) != VANumberLength {
+		return false
+	}
+	if _, ok := FindVABank(number[:5]); !ok {
+		return false
+	}
+	return LuhnValid(number)
+}
+
+// LuhnCheckDigit menghitung check digit Luhn (mod 10) untuk deret angka digits
+func LuhnCheckDigit(digits string) int {
+	sum := 0
+	double := true // digit paling kanan dari base akan berada di posisi genap setelah check digit ditambahkan
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// LuhnValid memeriksa nomor yang diakhiri check digit Luhn; hanya menerima angka
+func LuhnValid(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	last := len(number) - 1
+	return LuhnCheckDigit(number[:last]) == int(number[last]-'0')
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLuhn menguji check digit Luhn dengan contoh baku dan nomor kartu uji.
+func TestLuhn(t *testing.T) {
+	assert.Equal(t, 3, LuhnCheckDigit("7992739871"))
+	assert.True(t, LuhnValid("79927398713"))
+	assert.True(t, LuhnValid("4111111111111111"))
+	assert.False(t, LuhnValid("4111111111111112"))
+	assert.False(t, LuhnValid("41111111111a1111"))
+	assert.False(t, LuhnValid("4"))
+}
+
+// TestNewVANumber menguji susunan nomor virtual account dan validasinya.
+func TestNewVANumber(t *testing.T) {
+	number := NewVANumber("39358", 12, 345)
+	assert.Len(t, number, VANumberLength)
+	assert.Equal(t, "393580001200345", number[:15])
+	assert.True(t, ValidVANumber(number))
+
+	bank, ok := FindVABank(number[:5])
+	assert.True(t, ok)
+	assert.Equal(t, "bca", bank.Code)
+
+	// Salah ketik satu digit terdeteksi oleh check digit
+	typo := []byte(number)
+	typo[10] = '9'
+	assert.False(t, ValidVANumber(string(typo)))
+
+	// Prefix bank yang tidak dikenal ditolak
+	assert.False(t, ValidVANumber(NewVANumber("11111", 12, 345)))
+	assert.False(t, ValidVANumber(number[:15]))
+}
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// WebhookEventStatus adalah hasil pemrosesan satu event webhook
+type WebhookEventStatus string
+
+const (
+	WebhookReceived		WebhookEventStatus = "received"		// tersimpan, sedang diproses
+	WebhookProcessed	WebhookEventStatus = "processed"	// selesai diproses, pengiriman ulang diabaikan
+	WebhookFailed		WebhookEventStatus = "failed"		// gagal diproses, pengiriman ulang dari gateway akan dicoba lagi
+)
+
+// WebhookEvent merepresentasikan tabel `webhook_events`: jejak event dari payment gateway.
+// EventID unik sehingga event yang dikirim ulang gateway tidak diproses dua kali.
+type WebhookEvent struct {
+	ID			int
+	EventID		string				// ID event dari gateway
+	Type		string				// contoh charge.approved
+	ChargeRef	string				// charge yang statusnya berubah
+	Payload		string				// body asli yang diterima
+	Status		WebhookEventStatus
+	Error		string				// alasan gagal terakhir
+	Attempts	int					// berapa kali event diterima
+	ReceivedAt	time.Time
+	ProcessedAt	time.Time
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"os"
+	"pairproject/entity"
+	"sync"
+	"time"
+)
+
+// ChargeRequest adalah permintaan penagihan dana ke payment gateway
+type ChargeRequest struct {
+	Reference string        // nomor billing yang dibayar
+	Amount    entity.Money  // nominal yang ditagihkan
+	Method    entity.Method // metode pembayaran pilihan customer
+	CardToken string        // token kartu dari Tokenize, wajib untuk credit_card
+	QRPayload string        // payload QR yang ditampilkan ke customer, wajib untuk qris
+}
+
+// Charge adalah hasil penagihan dari payment gateway
+type Charge struct {
+	ID      string              // ID transaksi dari gateway, dipakai untuk cek status dan refund
+	Status  entity.ChargeStatus // pending, approved, atau declined
+	Message string              // alasan penolakan / keterangan dari gateway
+}
+
+// Refund adalah hasil pengembalian dana dari payment gateway
+type Refund struct {
+	ID        string
+	ChargeID  string
+	Reference string // referensi refund dari aplikasi, dipakai gateway sebagai kunci idempotensi
+	Amount    entity.Money
+	Status    entity.RefundStatus // completed, failed, atau pending jika gateway belum selesai memproses
+	Message   string              // alasan penolakan dari gateway
+}
+
+// ErrRefundNotFound menandakan gateway belum pernah menerima refund dengan referensi tersebut
+var ErrRefundNotFound = errors.New("Refund tidak ditemukan di payment gateway")
+
+// PaymentGateway adalah penyedia pembayaran. Pembayaran baru dicatat setelah charge berstatus approved.
+// Implementasi saat ini hanya Simulator; provider asli cukup memenuhi interface ini.
+type PaymentGateway interface {
+	// Tokenize memvalidasi kartu lalu menukarnya dengan token; nomor kartu tidak pernah keluar dari gateway
+	Tokenize(ctx context.Context, card entity.CardDetails) (entity.CardToken, error)
+	// Charge menagih dana customer; hasilnya bisa langsung approved/declined atau masih pending
+	Charge(ctx context.Context, req ChargeRequest) (Charge, error)
+	// Status mengambil status terbaru sebuah charge
+	Status(ctx context.Context, chargeID string) (Charge, error)
+	// Refund mengembalikan sebagian atau seluruh dana charge yang sudah approved. Refund dengan reference yang sama
+	// hanya diproses sekali; permintaan ulang mengembalikan hasil yang pertama. Penolakan dikembalikan sebagai
+	// Status failed, sedangkan error berarti hasilnya tidak diketahui dan aman diulang dengan reference yang sama.
+	Refund(ctx context.Context, chargeID string, reference string, amount entity.Money) (Refund, error)
+	// RefundStatus mengambil status terbaru refund berdasarkan reference; ErrRefundNotFound jika belum pernah diterima
+	RefundStatus(ctx context.Context, reference string) (Refund, error)
+}
+
+var (
+	defaultGateway PaymentGateway
+	defaultOnce    sync.Once
+)
+
+// Default mengembalikan gateway yang dipakai aplikasi. Saat ini berupa Simulator yang diatur lewat environment:
+// PAYMENT_GATEWAY_MODE (approve, decline, delay; default approve) dan PAYMENT_GATEWAY_DELAY (default 30s).
+func Default() PaymentGateway {
+	defaultOnce.Do(func() {
+		sim := NewSimulator(Mode(os.Getenv("PAYMENT_GATEWAY_MODE")))
+		if delay, err := time.ParseDuration(os.Getenv("PAYMENT_GATEWAY_DELAY")); err == nil && delay > 0 {
+			sim.Delay = delay
+		}
+		defaultGateway = sim
+	})
+
+	return defaultGateway
+}
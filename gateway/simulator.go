@@ -0,0 +1,248 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/qris"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mode menentukan perilaku Simulator terhadap setiap charge
+type Mode string
+
+const (
+	ModeApprove Mode = "approve" // charge langsung approved
+	ModeDecline Mode = "decline" // charge langsung declined
+	ModeDelay   Mode = "delay"   // charge pending, lalu approved setelah Delay berlalu
+)
+
+// DefaultDelay adalah lama charge pending pada mode delay
+const DefaultDelay = 30 * time.Second
+
+// Simulator adalah payment gateway lokal tanpa koneksi ke provider.
+// Hasil charge disimpan di dalam ID-nya (SIM-<kode>-<waktu>-<urutan>), sehingga proses worker terpisah
+// tetap bisa mengecek status charge yang dibuat oleh proses CLI. Charge QRIS selalu pending sampai customer
+// "memindai" QR, yang disimulasikan dengan approved setelah Delay berlalu (kecuali mode decline atau limit).
+// ID refund diturunkan dari reference-nya (SIM-R-<reference>), sehingga refund yang diulang proses lain tetap
+// menghasilkan refund yang sama; riwayat refund untuk RefundStatus hanya disimpan di memori proses ini.
+type Simulator struct {
+	Mode         Mode
+	Delay        time.Duration    // lama pending pada mode delay dan charge QRIS
+	DeclineAbove entity.Money     // jika > 0, charge di atas nominal ini ditolak (simulasi limit transaksi)
+	Now          func() time.Time // nil berarti time.Now
+
+	seq     uint64
+	mu      sync.Mutex
+	refunds map[string]Refund // refund yang sudah diproses per reference
+}
+
+// NewSimulator membuat simulator; mode kosong atau tidak dikenal dianggap approve
+func NewSimulator(mode Mode) *Simulator {
+	switch mode {
+	case ModeApprove, ModeDecline, ModeDelay:
+	default:
+		mode = ModeApprove
+	}
+
+	return &Simulator{Mode: mode, Delay: DefaultDelay}
+}
+
+// DeclinedCardNumber adalah kartu uji yang selalu ditolak bank penerbit saat di-charge
+const DeclinedCardNumber = "4000000000000002"
+
+// Tokenize memvalidasi kartu lalu membuat token acak tok_sim_<kode>_<hex>. Kode C berarti kartu normal,
+// kode K berarti kartu uji DeclinedCardNumber yang charge-nya akan ditolak.
+func (s *Simulator) Tokenize(ctx context.Context, card entity.CardDetails) (entity.CardToken, error) {
+	brand, err := card.Validate(s.now())
+	if err != nil {
+		return entity.CardToken{}, err
+	}
+
+	code := "C"
+	if entity.NormalizeCardNumber(card.Number) == DeclinedCardNumber {
+		code = "K"
+	}
+
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return entity.CardToken{}, err
+	}
+
+	return entity.CardToken{
+		Token:    fmt.Sprintf("tok_sim_%s_%s", code, hex.EncodeToString(random)),
+		Brand:    brand,
+		Last4:    card.Last4(),
+		ExpMonth: card.ExpMonth,
+		ExpYear:  card.ExpYear,
+	}, nil
+}
+
+// Charge mensimulasikan penagihan sesuai Mode
+func (s *Simulator) Charge(ctx context.Context, req ChargeRequest) (Charge, error) {
+	if req.Amount <= 0 {
+		return Charge{}, errors.New("Nominal charge harus lebih dari 0")
+	}
+
+	cardCode := ""
+	if req.Method == entity.MethodCredit {
+		if req.CardToken == "" {
+			return Charge{}, errors.New("Token kartu wajib untuk pembayaran kartu kredit")
+		}
+		parts := strings.Split(req.CardToken, "_")
+		if len(parts) != 4 || parts[0] != "tok" || parts[1] != "sim" || (parts[2] != "C" && parts[2] != "K") {
+			return Charge{}, errors.New("Token kartu tidak dikenal")
+		}
+		cardCode = parts[2]
+	}
+	if req.Method == entity.MethodQRIS {
+		if err := checkQRPayload(req); err != nil {
+			return Charge{}, err
+		}
+	}
+
+	code := "A"
+	switch {
+	case s.DeclineAbove > 0 && req.Amount > s.DeclineAbove:
+		code = "L"
+	case cardCode == "K":
+		code = "X"
+	case s.Mode == ModeDecline:
+		code = "X"
+	case s.Mode == ModeDelay:
+		code = "D"
+	case req.Method == entity.MethodQRIS:
+		code = "R"
+	}
+
+	seq := atomic.AddUint64(&s.seq, 1)
+	id := fmt.Sprintf("SIM-%s-%d-%d", code, s.now().UnixMilli(), seq)
+
+	return s.Status(ctx, id)
+}
+
+// Status menghitung status charge dari ID-nya
+func (s *Simulator) Status(ctx context.Context, chargeID string) (Charge, error) {
+	code, createdAt, err := parseChargeID(chargeID)
+	if err != nil {
+		return Charge{}, err
+	}
+
+	charge := Charge{ID: chargeID}
+	switch code {
+	case "A":
+		charge.Status = entity.ChargeApproved
+	case "X":
+		charge.Status, charge.Message = entity.ChargeDeclined, "Transaksi ditolak oleh bank penerbit"
+	case "L":
+		charge.Status, charge.Message = entity.ChargeDeclined, "Nominal melebihi limit transaksi"
+	case "D":
+		if s.now().Before(createdAt.Add(s.Delay)) {
+			charge.Status, charge.Message = entity.ChargePending, "Menunggu konfirmasi bank"
+		} else {
+			charge.Status = entity.ChargeApproved
+		}
+	case "R":
+		// Simulasi customer memindai QR dan mengonfirmasi pembayaran setelah Delay
+		if s.now().Before(createdAt.Add(s.Delay)) {
+			charge.Status, charge.Message = entity.ChargePending, "Menunggu customer memindai QR"
+		} else {
+			charge.Status = entity.ChargeApproved
+		}
+	}
+
+	return charge, nil
+}
+
+// Refund mensimulasikan pengembalian dana; hanya charge yang sudah approved yang bisa direfund.
+// Reference yang sudah pernah diproses mengembalikan hasil sebelumnya tanpa memproses ulang.
+func (s *Simulator) Refund(ctx context.Context, chargeID string, reference string, amount entity.Money) (Refund, error) {
+	if amount <= 0 {
+		return Refund{}, errors.New("Nominal refund harus lebih dari 0")
+	}
+	if reference == "" {
+		return Refund{}, errors.New("Referensi refund wajib diisi")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if refund, ok := s.refunds[reference]; ok {
+		return refund, nil
+	}
+
+	refund := Refund{ChargeID: chargeID, Reference: reference, Amount: amount, Status: entity.RefundCompleted}
+	charge, err := s.Status(ctx, chargeID)
+	switch {
+	case err != nil:
+		refund.Status, refund.Message = entity.RefundFailed, err.Error()
+	case charge.Status != entity.ChargeApproved:
+		refund.Status, refund.Message = entity.RefundFailed, fmt.Sprintf("Charge %s berstatus %s, tidak bisa direfund", chargeID, charge.Status)
+	default:
+		refund.ID = "SIM-R-" + reference
+	}
+
+	if s.refunds == nil {
+		s.refunds = make(map[string]Refund)
+	}
+	s.refunds[reference] = refund
+
+	return refund, nil
+}
+
+// RefundStatus mengambil hasil refund yang pernah diproses simulator ini
+func (s *Simulator) RefundStatus(ctx context.Context, reference string) (Refund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refund, ok := s.refunds[reference]
+	if !ok {
+		return Refund{}, ErrRefundNotFound
+	}
+	return refund, nil
+}
+
+// checkQRPayload memastikan QR yang ditampilkan ke customer valid dan sesuai dengan charge
+func checkQRPayload(req ChargeRequest) error {
+	if req.QRPayload == "" {
+		return errors.New("Payload QR wajib untuk pembayaran QRIS")
+	}
+	payload, err := qris.Parse(req.QRPayload)
+	if err != nil {
+		return err
+	}
+	if payload.Amount != req.Amount || payload.BillNumber != req.Reference {
+		return errors.New("Nominal atau nomor billing di QR tidak sesuai dengan charge")
+	}
+	return nil
+}
+
+// now mengembalikan waktu saat ini, bisa diganti saat testing
+func (s *Simulator) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// parseChargeID memecah ID charge simulator menjadi kode hasil dan waktu dibuat
+func parseChargeID(chargeID string) (string, time.Time, error) {
+	parts := strings.Split(chargeID, "-")
+	if len(parts) != 4 || parts[0] != "SIM" || len(parts[1]) != 1 || !strings.Contains("AXLDR", parts[1]) {
+		return "", time.Time{}, fmt.Errorf("Charge %s tidak ditemukan", chargeID)
+	}
+
+	millis, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Charge %s tidak ditemukan", chargeID)
+	}
+
+	return parts[1], time.UnixMilli(millis), nil
+}
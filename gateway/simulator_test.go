@@ -0,0 +1,178 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/qris"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulator_Modes menguji hasil charge untuk mode approve, decline, dan delay.
+func TestSimulator_Modes(t *testing.T) {
+	ctx := context.Background()
+	req := ChargeRequest{Reference: "BIL-001", Amount: entity.NewMoney(10000), Method: entity.MethodVA}
+
+	charge, err := NewSimulator(ModeApprove).Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeApproved, charge.Status)
+
+	charge, err = NewSimulator(ModeDecline).Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeDeclined, charge.Status)
+	assert.NotEmpty(t, charge.Message)
+
+	// Mode tidak dikenal dianggap approve
+	assert.Equal(t, ModeApprove, NewSimulator("random").Mode)
+
+	now := time.Now()
+	sim := NewSimulator(ModeDelay)
+	sim.Now = func() time.Time { return now }
+	charge, err = sim.Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargePending, charge.Status)
+
+	// Status dihitung dari ID, sehingga simulator lain dengan delay yang sama memberi hasil yang sama
+	other := NewSimulator(ModeApprove)
+	other.Now = func() time.Time { return now.Add(DefaultDelay) }
+	status, err := other.Status(ctx, charge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeApproved, status.Status)
+
+	_, err = sim.Charge(ctx, ChargeRequest{Amount: 0})
+	assert.Error(t, err)
+}
+
+// TestSimulator_Refund menguji refund hanya untuk charge approved dan ID yang dikenal, serta idempotensi per reference.
+func TestSimulator_Refund(t *testing.T) {
+	ctx := context.Background()
+	sim := NewSimulator(ModeApprove)
+	sim.DeclineAbove = entity.NewMoney(50000)
+
+	approved, err := sim.Charge(ctx, ChargeRequest{Amount: entity.NewMoney(50000)})
+	require.NoError(t, err)
+	_, err = sim.RefundStatus(ctx, "RF-1")
+	assert.ErrorIs(t, err, ErrRefundNotFound)
+
+	refund, err := sim.Refund(ctx, approved.ID, "RF-1", entity.NewMoney(20000))
+	require.NoError(t, err)
+	assert.Equal(t, approved.ID, refund.ChargeID)
+	assert.Equal(t, entity.RefundCompleted, refund.Status)
+	assert.NotEmpty(t, refund.ID)
+
+	// Reference yang sama tidak diproses dua kali, dan proses lain menghasilkan ID refund yang sama
+	again, err := sim.Refund(ctx, approved.ID, "RF-1", entity.NewMoney(20000))
+	require.NoError(t, err)
+	assert.Equal(t, refund, again)
+	status, err := sim.RefundStatus(ctx, "RF-1")
+	require.NoError(t, err)
+	assert.Equal(t, refund, status)
+	other, err := NewSimulator(ModeApprove).Refund(ctx, approved.ID, "RF-1", entity.NewMoney(20000))
+	require.NoError(t, err)
+	assert.Equal(t, refund.ID, other.ID)
+
+	declined, err := sim.Charge(ctx, ChargeRequest{Amount: entity.NewMoney(50001)})
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeDeclined, declined.Status)
+	failed, err := sim.Refund(ctx, declined.ID, "RF-2", entity.NewMoney(1000))
+	require.NoError(t, err)
+	assert.Equal(t, entity.RefundFailed, failed.Status)
+	assert.NotEmpty(t, failed.Message)
+	assert.Empty(t, failed.ID)
+
+	unknown, err := sim.Refund(ctx, "PAY-123", "RF-3", entity.NewMoney(1000))
+	require.NoError(t, err)
+	assert.Equal(t, entity.RefundFailed, unknown.Status)
+
+	_, err = sim.Refund(ctx, approved.ID, "RF-4", 0)
+	assert.Error(t, err)
+	_, err = sim.Refund(ctx, approved.ID, "", entity.NewMoney(1000))
+	assert.Error(t, err)
+	_, err = sim.Status(ctx, "PAY-123")
+	assert.Error(t, err)
+	_, err = sim.Status(ctx, "SIM-Q-1-1")
+	assert.Error(t, err)
+}
+
+// TestSimulator_Tokenize menguji tokenisasi kartu dan charge kartu kredit yang wajib memakai token.
+func TestSimulator_Tokenize(t *testing.T) {
+	ctx := context.Background()
+	sim := NewSimulator(ModeApprove)
+
+	card := entity.CardDetails{Number: "4111 1111 1111 1111", ExpMonth: 12, ExpYear: time.Now().Year() + 1, CVV: "123"}
+	token, err := sim.Tokenize(ctx, card)
+	require.NoError(t, err)
+	assert.Equal(t, entity.CardVisa, token.Brand)
+	assert.Equal(t, "1111", token.Last4)
+	assert.NotContains(t, token.Token, "4111111111111111")
+
+	// Kartu yang sama menghasilkan token berbeda
+	again, err := sim.Tokenize(ctx, card)
+	require.NoError(t, err)
+	assert.NotEqual(t, token.Token, again.Token)
+
+	card.Number = "4111111111111112"
+	_, err = sim.Tokenize(ctx, card)
+	assert.Error(t, err)
+
+	req := ChargeRequest{Reference: "BIL-001", Amount: entity.NewMoney(10000), Method: entity.MethodCredit}
+	_, err = sim.Charge(ctx, req)
+	assert.Error(t, err, "kartu kredit tanpa token ditolak")
+
+	req.CardToken = "tok_lain_123"
+	_, err = sim.Charge(ctx, req)
+	assert.Error(t, err)
+
+	req.CardToken = token.Token
+	charge, err := sim.Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeApproved, charge.Status)
+
+	// Kartu uji yang ditolak bank penerbit
+	card.Number = DeclinedCardNumber
+	declined, err := sim.Tokenize(ctx, card)
+	require.NoError(t, err)
+	req.CardToken = declined.Token
+	charge, err = sim.Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeDeclined, charge.Status)
+}
+
+// TestSimulator_QRIS menguji charge QRIS yang pending sampai QR dipindai, serta validasi payload QR.
+func TestSimulator_QRIS(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	sim := NewSimulator(ModeApprove)
+	sim.Now = func() time.Time { return now }
+
+	amount := entity.NewMoney(75000)
+	payload, err := qris.Generate(qris.DefaultMerchant(), "BIL-001", amount)
+	require.NoError(t, err)
+
+	req := ChargeRequest{Reference: "BIL-001", Amount: amount, Method: entity.MethodQRIS, QRPayload: payload}
+	charge, err := sim.Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargePending, charge.Status)
+
+	// Setelah Delay berlalu QR dianggap sudah dipindai customer
+	sim.Now = func() time.Time { return now.Add(DefaultDelay) }
+	status, err := sim.Status(ctx, charge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeApproved, status.Status)
+
+	// Mode decline tetap menolak
+	charge, err = NewSimulator(ModeDecline).Charge(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeDeclined, charge.Status)
+
+	// QR tanpa payload atau dengan nominal berbeda ditolak
+	_, err = sim.Charge(ctx, ChargeRequest{Reference: "BIL-001", Amount: amount, Method: entity.MethodQRIS})
+	assert.Error(t, err)
+	req.Amount = entity.NewMoney(1000)
+	_, err = sim.Charge(ctx, req)
+	assert.Error(t, err)
+}
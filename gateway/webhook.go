@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader adalah header HTTP berisi tanda tangan webhook dengan format t=<unix>,v1=<hex>
+const SignatureHeader = "X-Gateway-Signature"
+
+// DefaultTolerance adalah selisih waktu maksimum antara tanda tangan dan waktu terima webhook
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidSignature dikembalikan jika header tanda tangan tidak ada, rusak, atau tidak cocok dengan body
+	ErrInvalidSignature = errors.New("Tanda tangan webhook tidak valid")
+	// ErrSignatureExpired dikembalikan jika tanda tangan dibuat di luar toleransi waktu (mencegah replay)
+	ErrSignatureExpired = errors.New("Tanda tangan webhook sudah kedaluwarsa")
+)
+
+// Event adalah notifikasi perubahan status charge yang dikirim payment gateway lewat webhook.
+// ID unik per event dan dipakai untuk menolak pengiriman ulang yang sama.
+type Event struct {
+	ID        string              `json:"id"`
+	Type      string              `json:"type"` // charge.approved, charge.declined, charge.pending
+	ChargeID  string              `json:"charge_id"`
+	Status    entity.ChargeStatus `json:"status"`
+	Amount    entity.Money        `json:"amount"`
+	Message   string              `json:"message,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// Validate memastikan field wajib event terisi dan statusnya dikenali
+func (e Event) Validate() error {
+	if e.ID == "" || e.ChargeID == "" {
+		return errors.New("Event webhook wajib memiliki id dan charge_id")
+	}
+	switch e.Status {
+	case entity.ChargePending, entity.ChargeApproved, entity.ChargeDeclined:
+	default:
+		return fmt.Errorf("Status charge %q tidak dikenal", e.Status)
+	}
+	if e.Amount <= 0 {
+		return errors.New("Nominal event webhook harus lebih dari 0")
+	}
+	return nil
+}
+
+// SignWebhook membuat nilai SignatureHeader: HMAC-SHA256 dari "<unix>.<body>" dengan secret bersama
+func SignWebhook(secret []byte, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", unix, webhookMAC(secret, unix, body))
+}
+
+// VerifyWebhook memeriksa SignatureHeader terhadap body yang diterima apa adanya.
+// Tanda tangan yang lebih lama atau lebih baru dari tolerance terhadap now ditolak dengan ErrSignatureExpired.
+func VerifyWebhook(secret []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	if len(secret) == 0 {
+		return errors.New("Secret webhook belum diatur")
+	}
+
+	var unix string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if unix == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	// Bandingkan dengan waktu konstan; beberapa v1 diizinkan saat secret sedang dirotasi
+	expected := webhookMAC(secret, unix, body)
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
+
+// webhookMAC menghitung HMAC-SHA256 heksadesimal dari "<unix>.<body>"
+func webhookMAC(secret []byte, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
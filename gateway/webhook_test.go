@@ -0,0 +1,44 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"pairproject/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyWebhook menguji tanda tangan yang valid, body yang diubah, secret lain, dan replay di luar toleransi.
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("rahasia")
+	body := []byte(`{"id":"evt_1"}`)
+	signedAt := time.Unix(1760000000, 0)
+	header := SignWebhook(secret, signedAt, body)
+
+	require.NoError(t, VerifyWebhook(secret, header, body, signedAt.Add(time.Minute), 0))
+
+	assert.ErrorIs(t, VerifyWebhook(secret, header, []byte(`{"id":"evt_2"}`), signedAt, 0), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyWebhook([]byte("lain"), header, body, signedAt, 0), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyWebhook(secret, "", body, signedAt, 0), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyWebhook(secret, "t=abc,v1=00", body, signedAt, 0), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyWebhook(secret, header, body, signedAt.Add(DefaultTolerance+time.Second), 0), ErrSignatureExpired)
+	assert.Error(t, VerifyWebhook(nil, header, body, signedAt, 0))
+
+	// Saat rotasi secret, salah satu v1 yang cocok sudah cukup
+	rotated := header + ",v1=" + SignWebhook([]byte("baru"), signedAt, body)[len("t=1760000000,v1="):]
+	require.NoError(t, VerifyWebhook([]byte("baru"), rotated, body, signedAt, 0))
+}
+
+// TestEventValidate menguji field wajib event webhook.
+func TestEventValidate(t *testing.T) {
+	event := Event{ID: "evt_1", ChargeID: "SIM-D-1-1", Status: entity.ChargeApproved, Amount: entity.NewMoney(1000)}
+	require.NoError(t, event.Validate())
+
+	event.Status = "refunded"
+	assert.Error(t, event.Validate())
+
+	event.Status, event.ID = entity.ChargeApproved, ""
+	assert.Error(t, event.Validate())
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+)
+
+// AddressHandler menangani buku alamat pengiriman milik customer
+type AddressHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// CreateAddress menambahkan alamat baru ke buku alamat customer.
+// Alamat pertama otomatis menjadi alamat default.
+func (a *AddressHandler) CreateAddress(address entity.CustomerAddress) (entity.CustomerAddress, error) {
+	user, ok := utils.GetUser(*a.Ctx)
+	if !ok {
+		return address, fmt.Errorf("Please Login!")
+	}
+
+	// Validasi field wajib
+	if strings.TrimSpace(address.Label) == "" || strings.TrimSpace(address.Address) == "" || strings.TrimSpace(address.Zone) == "" {
+		return address, errors.New("Label, alamat, dan zona wajib diisi")
+	}
+
+	// Jika customer belum punya alamat, jadikan alamat ini default
+	var count int
+	err := a.DB.QueryRow("SELECT COUNT(*) FROM customer_addresses WHERE customer_id = ?", user.Customer.ID).Scan(&count)
+	if err != nil {
+		return address, fmt.Errorf("Terjadi kesalahan mengambil alamat: %s", err)
+	}
+	if count == 0 {
+		address.IsDefault = true
+	}
+
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return address, err
+	}
+
+	// Hanya boleh ada satu alamat default
+	if address.IsDefault {
+		_, err = tx.Exec("UPDATE customer_addresses SET is_default = FALSE, updated_by = ? WHERE customer_id = ?", user.ID, user.Customer.ID)
+		if err != nil {
+			tx.Rollback()
+			return address, errors.New("Terjadi kesalahan menyimpan alamat")
+		}
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO customer_addresses (customer_id, label, recipient_name, phone_number, address, city, postal_code, zone, is_default, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		user.Customer.ID,
+		strings.TrimSpace(address.Label),
+		strings.TrimSpace(address.RecipientName),
+		strings.TrimSpace(address.PhoneNumber),
+		strings.TrimSpace(address.Address),
+		strings.TrimSpace(address.City),
+		strings.TrimSpace(address.PostalCode),
+		strings.ToLower(strings.TrimSpace(address.Zone)),
+		address.IsDefault,
+		user.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return address, errors.New("Terjadi kesalahan menyimpan alamat")
+	}
+
+	addressID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return address, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return address, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	address.ID = int(addressID)
+	address.CustomerID = user.Customer.ID
+	address.CreatedBy = user.ID
+	return address, nil
+}
+
+// GetAddresses mengambil semua alamat milik customer, alamat default tampil paling atas
+func (a *AddressHandler) GetAddresses() ([]entity.CustomerAddress, error) {
+	user, ok := utils.GetUser(*a.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	rows, err := a.DB.Query(`
+		SELECT id, customer_id, label, recipient_name, phone_number, address, city, postal_code, zone, is_default
+		FROM customer_addresses
+		WHERE customer_id = ?
+		ORDER BY is_default DESC, id ASC
+	`, user.Customer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil alamat: %w", err)
+	}
+	defer rows.Close()
+
+	var addresses []entity.CustomerAddress
+	for rows.Next() {
+		var addr entity.CustomerAddress
+		err := rows.Scan(
+			&addr.ID,
+			&addr.CustomerID,
+			&addr.Label,
+			&addr.RecipientName,
+			&addr.PhoneNumber,
+			&addr.Address,
+			&addr.City,
+			&addr.PostalCode,
+			&addr.Zone,
+			&addr.IsDefault,
+		)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, addr)
+	}
+
+	return addresses, rows.Err()
+}
+
+// GetAddressByID mengambil satu alamat milik customer yang sedang login
+func (a *AddressHandler) GetAddressByID(id int) (entity.CustomerAddress, error) {
+	addresses, err := a.GetAddresses()
+	if err != nil {
+		return entity.CustomerAddress{}, err
+	}
+
+	for _, addr := range addresses {
+		if addr.ID == id {
+			return addr, nil
+		}
+	}
+
+	return entity.CustomerAddress{}, errors.New("Alamat tidak ditemukan")
+}
+
+// SetDefaultAddress menjadikan alamat tertentu sebagai alamat default customer
+func (a *AddressHandler) SetDefaultAddress(id int) error {
+	user, ok := utils.GetUser(*a.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	// Pastikan alamat milik customer
+	if _, err := a.GetAddressByID(id); err != nil {
+		return err
+	}
+
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE customer_addresses SET is_default = (id = ?), updated_by = ? WHERE customer_id = ?", id, user.ID, user.Customer.ID)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Terjadi kesalahan mengubah alamat default")
+	}
+
+	return tx.Commit()
+}
+
+// DeleteAddress menghapus alamat dari buku alamat. Alamat default tidak bisa dihapus.
+func (a *AddressHandler) DeleteAddress(id int) error {
+	user, ok := utils.GetUser(*a.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	address, err := a.GetAddressByID(id)
+	if err != nil {
+		return err
+	}
+	if address.IsDefault {
+		return errors.New("Alamat default tidak bisa dihapus, pilih alamat default lain terlebih dahulu")
+	}
+
+	_, err = a.DB.Exec("DELETE FROM customer_addresses WHERE id = ? AND customer_id = ?", id, user.Customer.ID)
+	if err != nil {
+		// Alamat yang sudah dipakai order tidak bisa dihapus karena foreign key
+		return errors.New("Alamat tidak bisa dihapus karena sudah digunakan pada order")
+	}
+
+	return nil
+}
+
+// FormatAddress menggabungkan data alamat menjadi satu baris untuk snapshot dan tampilan
+func FormatAddress(addr entity.CustomerAddress) string {
+	return fmt.Sprintf("%s (%s) - %s, %s %s", addr.RecipientName, addr.PhoneNumber, addr.Address, addr.City, addr.PostalCode)
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+	"time"
+)
+
+// AdjustmentHandler mengelola credit note dan debit note atas billing yang sudah terbit (khusus admin)
+type AdjustmentHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// CreateCreditNote mengurangi tagihan sebuah billing tanpa mengubah billing aslinya.
+// Jika billing sudah dibayar melebihi total barunya, kelebihannya masuk ke saldo kredit customer.
+func (a *AdjustmentHandler) CreateCreditNote(billingHandler *BillingHandler, billNumber string, amount entity.Money, reason string) (entity.BillingAdjustment, error) {
+	return a.createAdjustment(billingHandler, billNumber, entity.AdjustmentCredit, amount, reason)
+}
+
+// CreateDebitNote menambah tagihan billing yang belum lunas tanpa mengubah billing aslinya
+func (a *AdjustmentHandler) CreateDebitNote(billingHandler *BillingHandler, billNumber string, amount entity.Money, reason string) (entity.BillingAdjustment, error) {
+	return a.createAdjustment(billingHandler, billNumber, entity.AdjustmentDebit, amount, reason)
+}
+
+// createAdjustment menyimpan credit note / debit note beserta efeknya ke saldo kredit dan status billing
+func (a *AdjustmentHandler) createAdjustment(billingHandler *BillingHandler, billNumber string, adjType entity.AdjustmentType, amount entity.Money, reason string) (entity.BillingAdjustment, error) {
+	adjustment := entity.BillingAdjustment{BillingNumber: billNumber, Type: adjType, Amount: amount, Reason: strings.TrimSpace(reason)}
+
+	user, ok := utils.GetUser(*a.Ctx)
+	if !ok {
+		return adjustment, fmt.Errorf("Please Login!")
+	}
+
+	if amount <= 0 {
+		return adjustment, errors.New("Nominal harus lebih dari 0")
+	}
+	if adjustment.Reason == "" {
+		return adjustment, errors.New("Alasan wajib diisi")
+	}
+
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return adjustment, err
+	}
+
+	// Ambil billing beserta total setelah adjustment sebelumnya dan dana bersih yang sudah masuk
+	var status entity.StatusBilling
+	var customerID int
+	var total, adjusted, paid, refunded entity.Money
+	err = tx.QueryRow(`
+		SELECT b.id, b.status, b.total, o.customer_id,
+			(SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = b.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = b.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = b.id AND status <> 'failed')
+		FROM billings b
+		JOIN orders o ON o.id = b.order_id
+		WHERE b.number_display = ?
+	`, billNumber).Scan(&adjustment.BillingID, &status, &total, &customerID, &adjusted, &paid, &refunded)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return adjustment, errors.New("Billing tidak ditemukan")
+		}
+		return adjustment, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	// Debit note hanya untuk billing yang masih aktif; credit note juga boleh untuk billing lunas
+	switch {
+	case status == entity.StatusCancelled || status == entity.StatusRefunded:
+		tx.Rollback()
+		return adjustment, fmt.Errorf("Billing berstatus %s tidak bisa disesuaikan", status)
+	case adjType == entity.AdjustmentDebit && status == entity.StatusPaid:
+		tx.Rollback()
+		return adjustment, errors.New("Debit note hanya bisa dibuat untuk billing yang belum lunas")
+	}
+
+	// Jadwal cicilan dibagi dari total lama, sehingga billing bercicilan tidak bisa disesuaikan
+	var installments int
+	err = tx.QueryRow("SELECT COUNT(*) FROM billing_installments WHERE billing_id = ?", adjustment.BillingID).Scan(&installments)
+	if err != nil {
+		tx.Rollback()
+		return adjustment, fmt.Errorf("Terjadi kesalahan mengambil cicilan: %s", err)
+	}
+	if installments > 0 {
+		tx.Rollback()
+		return adjustment, errors.New("Billing dengan rencana cicilan tidak bisa disesuaikan")
+	}
+
+	before := total + adjusted
+	after := before + adjustment.Signed()
+	if after < 0 {
+		tx.Rollback()
+		return adjustment, fmt.Errorf("Credit note melebihi total tagihan, maksimal %s", before.Rupiah())
+	}
+
+	adjustment.NumberDisplay = a.GenerateAdjustmentNumber(tx, adjType)
+	res, err := tx.Exec(
+		"INSERT INTO billing_adjustments (number_display, billing_id, type, amount, reason, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+		adjustment.NumberDisplay, adjustment.BillingID, string(adjType), amount, adjustment.Reason, user.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return adjustment, errors.New("Terjadi kesalahan menyimpan adjustment")
+	}
+
+	adjustmentID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return adjustment, err
+	}
+	adjustment.ID = int(adjustmentID)
+
+	// Bagian dana yang kini melebihi total baru dikembalikan sebagai saldo kredit customer
+	netPaid := paid - refunded
+	excess := maxMoney(netPaid-after, 0) - maxMoney(netPaid-before, 0)
+	if excess > 0 {
+		credit := entity.CustomerCredit{
+			CustomerID: customerID,
+			BillingID:  adjustment.BillingID,
+			Type:       entity.CreditFromNote,
+			Amount:     excess,
+			Note:       adjustment.NumberDisplay + " " + billNumber,
+		}
+		if err := recordCreditTx(tx, user.ID, credit); err != nil {
+			tx.Rollback()
+			return adjustment, err
+		}
+	}
+
+	// Billing aktif yang sisa tagihannya habis karena credit note langsung menjadi lunas
+	if status != entity.StatusPaid && netPaid >= after {
+		err = billingHandler.updateOrderAndBillingStatusTx(tx, adjustment.BillingID)
+		if err != nil {
+			tx.Rollback()
+			return adjustment, fmt.Errorf("Gagal mengupdate order dan billing: %s", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return adjustment, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	adjustment.CreatedBy = user.ID
+	return adjustment, nil
+}
+
+// GetAdjustments mengambil credit note / debit note sebuah billing berdasarkan nomor billing
+func (a *AdjustmentHandler) GetAdjustments(billNumber string) ([]entity.BillingAdjustment, error) {
+	if _, ok := utils.GetUser(*a.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	var billingID int
+	err := a.DB.QueryRow("SELECT id FROM billings WHERE number_display = ?", billNumber).Scan(&billingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Billing tidak ditemukan")
+		}
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	return getBillingAdjustments(a.DB, billingID)
+}
+
+// GenerateAdjustmentNumber membuat nomor credit note (CN-YYYYMM-NNN) atau debit note (DN-YYYYMM-NNN).
+// Masing-masing jenis memiliki nomor urut sendiri per bulan.
+func (a *AdjustmentHandler) GenerateAdjustmentNumber(tx *sql.Tx, adjType entity.AdjustmentType) string {
+	prefix := "CN"
+	if adjType == entity.AdjustmentDebit {
+		prefix = "DN"
+	}
+
+	currentYearMonth := time.Now().Format("200601") // Format YYYYMM
+	var lastNumber int
+
+	query := `
+		SELECT
+			COALESCE(
+				CAST(SUBSTR(number_display, 11, 3) AS UNSIGNED),
+				0
+			) AS last_number
+		FROM billing_adjustments
+		WHERE SUBSTR(number_display, 1, 2) = ? AND SUBSTR(number_display, 4, 6) = ?
+		ORDER BY last_number DESC
+		LIMIT 1
+	`
+	err := tx.QueryRow(query, prefix, currentYearMonth).Scan(&lastNumber)
+	if err != nil {
+		lastNumber = 0 // Jika tidak ada data, mulai dari 0
+	}
+
+	return fmt.Sprintf("%s-%s-%03d", prefix, currentYearMonth, lastNumber+1)
+}
+
+// getBillingAdjustments mengambil credit note / debit note sebuah billing urut dari yang terlama
+func getBillingAdjustments(q queryer, billingID int) ([]entity.BillingAdjustment, error) {
+	rows, err := q.Query(`
+		SELECT ba.id, ba.number_display, ba.billing_id, b.number_display, ba.type, ba.amount, ba.reason, ba.created_at, ba.created_by
+		FROM billing_adjustments ba
+		JOIN billings b ON b.id = ba.billing_id
+		WHERE ba.billing_id = ?
+		ORDER BY ba.id ASC
+	`, billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil adjustment: %w", err)
+	}
+	defer rows.Close()
+
+	var adjustments []entity.BillingAdjustment
+	for rows.Next() {
+		var adjustment entity.BillingAdjustment
+		err := rows.Scan(
+			&adjustment.ID,
+			&adjustment.NumberDisplay,
+			&adjustment.BillingID,
+			&adjustment.BillingNumber,
+			&adjustment.Type,
+			&adjustment.Amount,
+			&adjustment.Reason,
+			&adjustment.CreatedAt,
+			&adjustment.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+
+	return adjustments, rows.Err()
+}
+
+// billingAdjustmentTotal menjumlahkan seluruh adjustment billing: debit note positif, credit note negatif
+func billingAdjustmentTotal(q queryer, billingID int) (entity.Money, error) {
+	var adjusted entity.Money
+	err := q.QueryRow(
+		"SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = ?",
+		billingID,
+	).Scan(&adjusted)
+	if err != nil {
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil adjustment: %s", err)
+	}
+
+	return adjusted, nil
+}
+
+// maxMoney mengembalikan nominal yang lebih besar
+func maxMoney(a, b entity.Money) entity.Money {
+	if a > b {
+		return a
+	}
+	return b
+}
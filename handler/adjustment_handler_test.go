@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateCreditNote menguji credit note yang melunasi billing lesspaid dan credit note atas billing lunas.
+func TestCreateCreditNote(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	adjustmentHandler := &AdjustmentHandler{DB: db, Ctx: &ctx}
+	paymentHandler := &PaymentHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+	creditHandler := &CreditHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+	require.NoError(t, paymentHandler.CreatePayment(billingHandler, billing, entity.NewMoney(60000), entity.MethodVA))
+
+	// Alasan wajib diisi dan nominal tidak boleh melebihi total tagihan
+	_, err := adjustmentHandler.CreateCreditNote(billingHandler, "BIL-001", entity.NewMoney(1000), " ")
+	assert.Error(t, err)
+	_, err = adjustmentHandler.CreateCreditNote(billingHandler, "BIL-001", entity.NewMoney(100001), "Diskon")
+	assert.Error(t, err)
+
+	// Credit note sebesar sisa tagihan membuat billing lunas tanpa menambah saldo kredit
+	note, err := adjustmentHandler.CreateCreditNote(billingHandler, "BIL-001", entity.NewMoney(40000), "Koreksi pajak")
+	require.NoError(t, err)
+	assert.Equal(t, "CN-"+time.Now().Format("200601")+"-001", note.NumberDisplay)
+	assert.Equal(t, entity.NewMoney(-40000), note.Signed())
+
+	var status, total string
+	require.NoError(t, db.QueryRow("SELECT status, total FROM billings WHERE id = 1").Scan(&status, &total))
+	assert.Equal(t, string(entity.StatusPaid), status)
+	assert.Equal(t, "100000", total, "billing asli tidak berubah")
+
+	balance, err := creditHandler.GetMyCreditBalance()
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), balance)
+
+	// Credit note atas billing lunas dikembalikan sebagai saldo kredit customer
+	note, err = adjustmentHandler.CreateCreditNote(billingHandler, "BIL-001", entity.NewMoney(10000), "Diskon goodwill")
+	require.NoError(t, err)
+	assert.Equal(t, "CN-"+time.Now().Format("200601")+"-002", note.NumberDisplay)
+
+	history, err := creditHandler.GetMyCreditHistory()
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, entity.CreditFromNote, history[0].Type)
+	assert.Equal(t, entity.NewMoney(10000), history[0].Amount)
+
+	adjustments, err := adjustmentHandler.GetAdjustments("BIL-001")
+	require.NoError(t, err)
+	require.Len(t, adjustments, 2)
+	assert.Equal(t, "Koreksi pajak", adjustments[0].Reason)
+}
+
+// TestCreateDebitNote menguji debit note pada billing aktif, penolakan pada billing lunas, dan billing bercicilan.
+func TestCreateDebitNote(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	adjustmentHandler := &AdjustmentHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	_, err := db.Exec("UPDATE billings SET status = 'paid' WHERE id = 1")
+	require.NoError(t, err)
+
+	// Billing lunas tidak bisa ditambah tagihannya
+	_, err = adjustmentHandler.CreateDebitNote(billingHandler, "BIL-001", entity.NewMoney(5000), "Ongkir kurang")
+	assert.Error(t, err)
+
+	// Debit note memiliki nomor urut sendiri dan menambah sisa tagihan
+	note, err := adjustmentHandler.CreateDebitNote(billingHandler, "BIL-002", entity.NewMoney(5000), "Ongkir kurang")
+	require.NoError(t, err)
+	assert.Equal(t, "DN-"+time.Now().Format("200601")+"-001", note.NumberDisplay)
+
+	remaining, err := billingRemaining(db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(55000), remaining)
+
+	// Trigger pembayaran ikut memperhitungkan debit note
+	_, err = db.Exec("INSERT INTO payments (billing_id, amount, method, created_by) VALUES (2, 55000, 'va', 1)")
+	assert.NoError(t, err)
+
+	// Billing dengan rencana cicilan tidak bisa disesuaikan
+	_, err = db.Exec(`
+		INSERT INTO orders (customer_id) VALUES (1);
+		INSERT INTO billings (order_id, number_display, total) VALUES (3, 'BIL-003', 90000);
+		INSERT INTO billing_installments (billing_id, seq, amount, due_date) VALUES (3, 1, 90000, '2025-07-01');
+	`)
+	require.NoError(t, err)
+	_, err = adjustmentHandler.CreateDebitNote(billingHandler, "BIL-003", entity.NewMoney(5000), "Ongkir kurang")
+	assert.Error(t, err)
+	_, err = adjustmentHandler.CreateCreditNote(billingHandler, "BIL-003", entity.NewMoney(5000), "Diskon")
+	assert.Error(t, err)
+
+	// Billing yang tidak ada ditolak
+	_, err = adjustmentHandler.CreateCreditNote(billingHandler, "BIL-404", entity.NewMoney(5000), "Diskon")
+	assert.Error(t, err)
+}
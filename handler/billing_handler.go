@@ -21,16 +21,20 @@ type BillingWithPaymentsSimple struct {
 	BillingID     int
 	OrderID       int
 	NumberDisplay string
-	Tax           float64
-	Total         float64
+	Tax           entity.Money
+	Total         entity.Money
 	Status        string
 	Payments      []struct {
 		ID     int
-		Amount float64
+		Amount entity.Money
 	}
 }
 
-// GenerateBill membuat tagihan berdasarkan informasi order
+// GenerateBill membuat tagihan berdasarkan informasi order.
+// Satu order hanya boleh punya satu billing aktif (unpaid/lesspaid): jika nilainya masih sama billing lama dikembalikan,
+// jika order berubah atau billing lama sudah lewat jatuh tempo billing lama digantikan dan dicatat di billing_audits.
+// Billing lesspaid yang belum jatuh tempo dikembalikan apa adanya; jika sudah lewat jatuh tempo diperlakukan sama seperti
+// oleh worker kedaluwarsa: dibatalkan, dana bersihnya dipindahkan ke saldo kredit, lalu digantikan tagihan baru.
 func (b *BillingHandler) GenerateBill(o entity.Order) (entity.Billing, error) {
 	var billing entity.Billing
 
@@ -40,26 +44,86 @@ func (b *BillingHandler) GenerateBill(o entity.Order) (entity.Billing, error) {
 		return billing, fmt.Errorf("Please Login.")
 	}
 
-	// Hitung pajak 10%
-	tax := o.Total * 0.10
-	// Hitung total setelah pajak
-	total := o.Total + tax
-	// Buat nomor tagihan
-	numberDisplay := b.GenerateBillNumber()
-
 	// Tanggal issue dan due (30 menit ke depan)
 	issueDate := time.Now()
 	dueDate := issueDate.Add(30 * time.Minute)
 
+	// Hitung pajak per baris order sesuai kelas pajak dan tarif yang berlaku pada tanggal tagihan
+	// (ongkos kirim tidak dikenai pajak)
+	taxLines, err := computeTaxLines(b.DB, o.ID, issueDate)
+	if err != nil {
+		return billing, err
+	}
+	if len(taxLines) == 0 {
+		return billing, errors.New("Order tidak memiliki detail produk")
+	}
+
+	// Harga inclusive sudah mengandung pajak, sehingga total dihitung dari dasar pengenaan pajak + pajak
+	var tax, taxable entity.Money
+	for _, line := range taxLines {
+		tax += line.TaxAmount
+		taxable += line.TaxableAmount
+	}
+	// Hitung total setelah pajak dan ongkos kirim
+	total := taxable + tax + o.ShippingFee
+	// Buat nomor tagihan
+	numberDisplay := b.GenerateBillNumber()
+
+	tx, err := b.DB.Begin()
+	if err != nil {
+		return billing, err
+	}
+
+	// Cek billing aktif order ini sebelum membuat tagihan baru
+	active, found, err := getActiveBilling(tx, o.ID)
+	if err != nil {
+		tx.Rollback()
+		return billing, err
+	}
+	var credited entity.Money
+	if found {
+		// Billing yang sudah dibayar sebagian dan belum jatuh tempo tidak bisa diganti
+		if active.Status == entity.StatusLesspaid && issueDate.Before(active.DueDate) {
+			tx.Rollback()
+			active.TaxLines, err = getBillingTaxLines(b.DB, active.ID)
+			return active, err
+		}
+
+		// Billing yang nilainya sama cukup dipakai ulang
+		if active.Total == total && issueDate.Before(active.DueDate) {
+			tx.Rollback()
+			active.TaxLines, err = getBillingTaxLines(b.DB, active.ID)
+			return active, err
+		}
+
+		// Batalkan billing lama dan kembalikan stok yang ditahannya sebelum tagihan baru dibuat;
+		// dana billing lesspaid yang kedaluwarsa dipindahkan ke saldo kredit seperti oleh worker
+		if active.Status == entity.StatusLesspaid {
+			var customerID int
+			if err := tx.QueryRow("SELECT customer_id FROM orders WHERE id = ?", o.ID).Scan(&customerID); err != nil {
+				tx.Rollback()
+				return billing, fmt.Errorf("Terjadi kesalahan mengambil order: %s", err)
+			}
+			credited, err = cancelLesspaidBillingTx(tx, active.ID, customerID, user.ID)
+		} else {
+			err = cancelBillingTx(tx, active.ID, user.ID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return billing, err
+		}
+	}
+
 	// Insert tagihan ke DB
 	insertQuery := `
-		INSERT INTO billings (order_id, tax, total, number_display, issue_date, due_date, created_by)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO billings (order_id, tax, shipping_fee, total, number_display, issue_date, due_date, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	res, err := b.DB.Exec(
+	res, err := tx.Exec(
 		insertQuery,
 		o.ID,
 		tax,
+		o.ShippingFee,
 		total,
 		numberDisplay,
 		issueDate,
@@ -67,25 +131,93 @@ func (b *BillingHandler) GenerateBill(o entity.Order) (entity.Billing, error) {
 		user.ID,
 	)
 	if err != nil {
+		tx.Rollback()
+		// Unique index billing aktif per order menolak tagihan ganda yang dibuat bersamaan
+		if active, found, _ := getActiveBilling(b.DB, o.ID); found {
+			return active, nil
+		}
 		return billing, errors.New("Kesalahan membuat tagihan")
 	}
 
 	// Ambil ID dari billing yang baru dibuat
 	billingID, err := res.LastInsertId()
 	if err != nil {
+		tx.Rollback()
 		return billing, errors.New("Terjadi kesalahan saat mengambil id")
 	}
 
+	// Simpan rincian pajak per baris sebagai bagian dari tagihan
+	for i := range taxLines {
+		taxLines[i].BillingID = int(billingID)
+		res, err := tx.Exec(`
+			INSERT INTO billing_tax_lines (billing_id, order_detail_id, tax_class, rate, price_includes_tax, taxable_amount, tax_amount)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, billingID, taxLines[i].OrderDetailID, taxLines[i].TaxClass, basisPointsToPercent(taxLines[i].Rate),
+			taxLines[i].PriceIncludesTax, taxLines[i].TaxableAmount, taxLines[i].TaxAmount)
+		if err != nil {
+			tx.Rollback()
+			return billing, errors.New("Terjadi kesalahan menyimpan rincian pajak")
+		}
+
+		lineID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return billing, err
+		}
+		taxLines[i].ID = int(lineID)
+	}
+
+	// Tahan stok produk sampai billing lunas atau kedaluwarsa
+	if _, err := reserveStockTx(tx, int(billingID), o.ID); err != nil {
+		tx.Rollback()
+		return billing, err
+	}
+
+	// Catat penggantian billing lama; billing lesspaid yang kedaluwarsa dicatat expired seperti oleh worker
+	if found {
+		action := entity.AuditSuperseded
+		note := fmt.Sprintf("Digantikan %s (total %s menjadi %s)", numberDisplay, active.Total, total)
+		if !issueDate.Before(active.DueDate) {
+			note = fmt.Sprintf("Lewat jatuh tempo, digantikan %s", numberDisplay)
+		}
+		if active.Status == entity.StatusLesspaid {
+			action = entity.AuditExpired
+			if credited > 0 {
+				note = fmt.Sprintf("Lewat jatuh tempo, dana %s dipindahkan ke saldo kredit, digantikan %s", credited.Rupiah(), numberDisplay)
+			}
+		}
+		audit := entity.BillingAudit{
+			BillingID:  active.ID,
+			OrderID:    o.ID,
+			Action:     action,
+			ReplacedBy: int(billingID),
+			Note:       note,
+			CreatedBy:  user.ID,
+		}
+		if err := recordBillingAuditTx(tx, audit); err != nil {
+			tx.Rollback()
+			return billing, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return billing, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
 	// Kembalikan data billing
 	billing = entity.Billing{
 		ID:            int(billingID),
 		OrderID:       o.ID,
 		Tax:           tax,
+		ShippingFee:   o.ShippingFee,
 		Total:         total,
 		NumberDisplay: numberDisplay,
 		IssueDate:     issueDate,
 		DueDate:       dueDate,
+		Status:        entity.StatusUnpaid,
 		CreatedBy:     user.ID,
+		TaxLines:      taxLines,
 	}
 
 	return billing, nil
@@ -127,7 +259,7 @@ func (b *BillingHandler) GetBillByNumberDisplay(numberDisplay string) (entity.Bi
 	}
 
 	query := `
-		SELECT billings.id, order_id, billings.number_display, issue_date, due_date, billings.status, tax, billings.total, billings.created_by
+		SELECT billings.id, order_id, billings.number_display, issue_date, due_date, billings.status, tax, billings.shipping_fee, billings.total, billings.created_by
 		FROM billings
 		JOIN orders on orders.id = billings.order_id
 		WHERE billings.number_display = ? AND orders.customer_id = ? AND (billings.status = 'unpaid' OR billings.status = 'lesspaid')
@@ -143,6 +275,7 @@ func (b *BillingHandler) GetBillByNumberDisplay(numberDisplay string) (entity.Bi
 		&billing.DueDate,
 		&billing.Status,
 		&billing.Tax,
+		&billing.ShippingFee,
 		&billing.Total,
 		&billing.CreatedBy,
 	)
@@ -154,11 +287,28 @@ func (b *BillingHandler) GetBillByNumberDisplay(numberDisplay string) (entity.Bi
 		return billing, fmt.Errorf("Terjadi kesalahan: %s", err)
 	}
 
+	// Sertakan rencana cicilan agar customer tahu cicilan yang harus dibayar berikutnya
+	billing.Installments, err = getInstallments(b.DB, billing.ID)
+	if err != nil {
+		return billing, err
+	}
+
+	// Credit note / debit note mengubah sisa tagihan yang harus dibayar
+	billing.Adjustments, err = getBillingAdjustments(b.DB, billing.ID)
+	if err != nil {
+		return billing, err
+	}
+
 	return billing, nil
 }
 
 // GetBillingWithSimplePayments mengambil data billing dan semua pembayaran terkait (jika ada)
 func (b *BillingHandler) GetBillingWithSimplePayments(billingID int) (BillingWithPaymentsSimple, error) {
+	return getBillingWithSimplePayments(b.DB, billingID)
+}
+
+// getBillingWithSimplePayments sama dengan GetBillingWithSimplePayments, namun bisa dijalankan di dalam transaksi
+func getBillingWithSimplePayments(q queryer, billingID int) (BillingWithPaymentsSimple, error) {
 	var result BillingWithPaymentsSimple
 
 	query := `
@@ -171,7 +321,7 @@ func (b *BillingHandler) GetBillingWithSimplePayments(billingID int) (BillingWit
 		WHERE billings.id = ?
 	`
 
-	rows, err := b.DB.Query(query, billingID)
+	rows, err := q.Query(query, billingID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return result, err
@@ -186,11 +336,11 @@ func (b *BillingHandler) GetBillingWithSimplePayments(billingID int) (BillingWit
 			billingID     int
 			orderID       int
 			numberDisplay string
-			tax           float64
-			total         float64
+			tax           entity.Money
+			total         entity.Money
 			status        string
 			paymentID     sql.NullInt64
-			paymentAmount sql.NullFloat64
+			paymentAmount entity.Money // NULL terbaca sebagai 0
 		)
 
 		err := rows.Scan(&billingID, &orderID, &numberDisplay, &tax, &total, &status, &paymentID, &paymentAmount)
@@ -208,10 +358,10 @@ func (b *BillingHandler) GetBillingWithSimplePayments(billingID int) (BillingWit
 		// Tambahkan payment jika tersedia
 		result.Payments = append(result.Payments, struct {
 			ID     int
-			Amount float64
+			Amount entity.Money
 		}{
 			ID:     int(paymentID.Int64),
-			Amount: paymentAmount.Float64,
+			Amount: paymentAmount,
 		})
 	}
 
@@ -226,45 +376,416 @@ func (b *BillingHandler) UpdateOrderAndBillingStatus(billingID int) error {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
+	if err := lockBillingTx(tx, billingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := b.updateOrderAndBillingStatusTx(tx, billingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaksi
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return nil
+}
+
+// lockBillingTx mengunci baris billing sampai transaksi selesai, sehingga posting payment lain untuk billing yang sama
+// menunggu lalu menghitung sisa tagihan dari data terbaru. Dipakai UPDATE tanpa perubahan karena SELECT ... FOR UPDATE
+// tidak tersedia di SQLite yang dipakai testing.
+func lockBillingTx(tx *sql.Tx, billingID int) error {
+	_, err := tx.Exec("UPDATE billings SET status = status WHERE id = ?", billingID)
+	if err != nil {
+		return fmt.Errorf("Gagal mengunci billing: %s", err)
+	}
+	return nil
+}
+
+// updateOrderAndBillingStatusTx menghitung ulang status billing dan order dari payment, refund, dan adjustment
+// yang terbaca di transaksi tx, sehingga payment yang baru disimpan di transaksi yang sama ikut dihitung.
+// Billing yang sudah dibatalkan (termasuk kedaluwarsa) atau direfund ditolak agar tidak hidup kembali.
+// Rollback/commit menjadi tanggung jawab pemanggil.
+func (b *BillingHandler) updateOrderAndBillingStatusTx(tx *sql.Tx, billingID int) error {
 	// Ambil billing dan semua payment
-	billPayments, err := b.GetBillingWithSimplePayments(billingID)
+	billPayments, err := getBillingWithSimplePayments(tx, billingID)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
+	if billPayments.BillingID == 0 {
+		return errors.New("Billing tidak ditemukan")
+	}
+	switch entity.StatusBilling(billPayments.Status) {
+	case entity.StatusCancelled, entity.StatusRefunded:
+		return fmt.Errorf("Billing %s berstatus %s, status tidak bisa diperbarui", billPayments.NumberDisplay, billPayments.Status)
+	}
 
-	// Hitung total pembayaran
-	var total float64
+	// Hitung total pembayaran dikurangi dana yang sudah direfund
+	var total entity.Money
 	for _, payment := range billPayments.Payments {
 		total += payment.Amount
 	}
 
+	var refunded entity.Money
+	err = tx.QueryRow("SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = ? AND status <> 'failed'", billingID).Scan(&refunded)
+	if err != nil {
+		return err
+	}
+	total -= refunded
+
+	// Tagihan yang harus dilunasi sudah termasuk credit note / debit note
+	adjusted, err := billingAdjustmentTotal(tx, billingID)
+	if err != nil {
+		return err
+	}
+
 	// Update status billing dan order sesuai pembayaran
-	if total >= billPayments.Total {
-		_, err = tx.Exec("UPDATE billings SET status = 'paid' WHERE id = ?", billingID)
+	if total >= billPayments.Total+adjusted {
+		_, err = tx.Exec("UPDATE billings SET status = 'paid' WHERE id = ? AND status IN ('unpaid', 'lesspaid', 'paid')", billingID)
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
 
 		_, err = tx.Exec("UPDATE orders SET status = 'completed' WHERE id = ?", billPayments.OrderID)
 		if err != nil {
-			tx.Rollback()
+			return err
+		}
+
+		// Stok yang ditahan billing kini resmi terjual
+		err = commitReservationsTx(tx, billingID)
+		if err != nil {
+			return err
+		}
+
+		// Order lunas langsung masuk antrian fulfilment
+		var userID int
+		if user, ok := utils.GetUser(*b.Ctx); ok {
+			userID = user.ID
+		}
+		err = createShipmentTx(tx, billPayments.OrderID, userID)
+		if err != nil {
 			return err
 		}
 	} else {
-		_, err = tx.Exec("UPDATE billings SET status = 'lesspaid' WHERE id = ?", billingID)
+		_, err = tx.Exec("UPDATE billings SET status = 'lesspaid' WHERE id = ? AND status IN ('unpaid', 'lesspaid', 'paid')", billingID)
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
 	}
 
-	// Commit transaksi
+	return nil
+}
+
+// GetBillingsByOrderID mengambil semua billing sebuah order (terlama lebih dulu) beserta pembayarannya
+func (b *BillingHandler) GetBillingsByOrderID(orderID int) ([]entity.Billing, error) {
+	query := `
+		SELECT id, order_id, number_display, issue_date, due_date, status, tax, shipping_fee, total, created_by
+		FROM billings
+		WHERE order_id = ?
+		ORDER BY id ASC
+	`
+
+	rows, err := b.DB.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil billing: %w", err)
+	}
+	defer rows.Close()
+
+	var billings []entity.Billing
+	for rows.Next() {
+		var billing entity.Billing
+		var dueDate sql.NullTime // due_date bisa kosong untuk data lama
+		err := rows.Scan(
+			&billing.ID,
+			&billing.OrderID,
+			&billing.NumberDisplay,
+			&billing.IssueDate,
+			&dueDate,
+			&billing.Status,
+			&billing.Tax,
+			&billing.ShippingFee,
+			&billing.Total,
+			&billing.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		billing.DueDate = dueDate.Time
+		billings = append(billings, billing)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Lengkapi setiap billing dengan rincian pajak, cicilan, penyesuaian, riwayat pembayaran dan refund
+	paymentHandler := PaymentHandler{DB: b.DB, Ctx: b.Ctx}
+	refundHandler := RefundHandler{DB: b.DB, Ctx: b.Ctx}
+	for i := range billings {
+		billings[i].TaxLines, err = getBillingTaxLines(b.DB, billings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		billings[i].Installments, err = getInstallments(b.DB, billings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		billings[i].Adjustments, err = getBillingAdjustments(b.DB, billings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		billings[i].Payments, err = paymentHandler.GetPaymentsByBillingID(billings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		billings[i].Refunds, err = refundHandler.GetRefundsByBillingID(billings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return billings, nil
+}
+
+// ExpireOverdueBillings membatalkan billing unpaid/lesspaid yang sudah melewati due_date dan mengembalikan stok yang ditahannya.
+// Dana bersih billing lesspaid (pembayaran dikurangi refund) dipindahkan ke saldo kredit customer.
+// Dijalankan oleh worker terjadwal (tanpa user login); order tetap processing sehingga customer bisa membuat tagihan baru.
+func (b *BillingHandler) ExpireOverdueBillings(now time.Time) (int, error) {
+	rows, err := b.DB.Query(
+		"SELECT id FROM billings WHERE status IN (?, ?) AND due_date IS NOT NULL AND due_date < ? ORDER BY id ASC",
+		string(entity.StatusUnpaid), string(entity.StatusLesspaid), now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil billing kedaluwarsa: %w", err)
+	}
+
+	var billingIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		billingIDs = append(billingIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var expired int
+	for _, billingID := range billingIDs {
+		ok, err := b.expireBilling(billingID)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// expireBilling membatalkan satu billing dan melepas reservasi stoknya dalam satu transaksi.
+// Mengembalikan false jika billing sudah tidak unpaid/lesspaid (misal baru saja dilunasi).
+func (b *BillingHandler) expireBilling(billingID int) (bool, error) {
+	tx, err := b.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	// Kunci billing agar pembayaran yang masuk bersamaan menunggu, lalu cek ulang statusnya
+	if err := lockBillingTx(tx, billingID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var orderID, customerID, createdBy int
+	var status entity.StatusBilling
+	err = tx.QueryRow(`
+		SELECT b.order_id, o.customer_id, b.status, b.created_by
+		FROM billings b
+		JOIN orders o ON o.id = b.order_id
+		WHERE b.id = ?
+	`, billingID).Scan(&orderID, &customerID, &status, &createdBy)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	audit := entity.BillingAudit{BillingID: billingID, OrderID: orderID, Action: entity.AuditExpired, Note: "Lewat jatuh tempo"}
+	switch status {
+	case entity.StatusUnpaid:
+		if err := cancelBillingTx(tx, billingID, 0); err != nil {
+			tx.Rollback()
+			if err == errBillingNotUnpaid {
+				return false, nil
+			}
+			return false, err
+		}
+	case entity.StatusLesspaid:
+		credited, err := cancelLesspaidBillingTx(tx, billingID, customerID, createdBy)
+		if err != nil {
+			tx.Rollback()
+			if err == errBillingNotLesspaid {
+				return false, nil
+			}
+			return false, err
+		}
+		if credited > 0 {
+			audit.Note = fmt.Sprintf("Lewat jatuh tempo, dana %s dipindahkan ke saldo kredit", credited.Rupiah())
+		}
+	default:
+		tx.Rollback()
+		return false, nil
+	}
+
+	if err := recordBillingAuditTx(tx, audit); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+		return false, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return true, nil
+}
+
+// cancelLesspaidBillingTx membatalkan billing lesspaid, melepas reservasi stoknya, dan memindahkan dana bersih
+// setiap pembayaran (pembayaran dikurangi refund) ke saldo kredit customer. Kredit dicatat per pembayaran
+// beserta payment_id-nya sehingga dana yang sudah dipindahkan tidak bisa direfund lagi. Mutasi saldo dicatat
+// atas nama pembuat billing karena worker berjalan tanpa user login. Mengembalikan total nominal yang dipindahkan.
+// Status diubah lebih dulu sehingga billing yang sudah dibatalkan jalur lain (worker atau GenerateBill) tidak dikreditkan dua kali.
+func cancelLesspaidBillingTx(tx *sql.Tx, billingID int, customerID int, createdBy int) (entity.Money, error) {
+	res, err := tx.Exec(
+		"UPDATE billings SET status = ? WHERE id = ? AND status = ?",
+		string(entity.StatusCancelled), billingID, string(entity.StatusLesspaid),
+	)
+	if err != nil {
+		return 0, errors.New("Terjadi kesalahan membatalkan billing")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return 0, errBillingNotLesspaid
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, amount - (SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE payment_id = payments.id AND status <> 'failed')
+		FROM payments
+		WHERE billing_id = ?
+		ORDER BY id ASC
+	`, billingID)
+	if err != nil {
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil pembayaran billing: %s", err)
+	}
+
+	var credits []entity.CustomerCredit
+	for rows.Next() {
+		credit := entity.CustomerCredit{
+			CustomerID: customerID,
+			BillingID:  billingID,
+			Type:       entity.CreditFromExpired,
+			Note:       "Billing dibatalkan karena lewat jatuh tempo",
+		}
+		if err := rows.Scan(&credit.PaymentID, &credit.Amount); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if credit.Amount > 0 {
+			credits = append(credits, credit)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := releaseReservationsTx(tx, billingID); err != nil {
+		return 0, err
 	}
 
+	var total entity.Money
+	for _, credit := range credits {
+		if err := recordCreditTx(tx, createdBy, credit); err != nil {
+			return 0, err
+		}
+		total += credit.Amount
+	}
+
+	return total, nil
+}
+
+// errBillingNotUnpaid menandakan billing sudah berubah status (misal baru dibayar) sehingga tidak bisa dibatalkan
+var errBillingNotUnpaid = errors.New("Billing sudah tidak berstatus unpaid")
+
+// errBillingNotLesspaid menandakan billing lesspaid sudah berubah status (misal sudah dibatalkan) sehingga tidak bisa dibatalkan lagi
+var errBillingNotLesspaid = errors.New("Billing sudah tidak berstatus lesspaid")
+
+// getActiveBilling mengambil billing aktif (unpaid/lesspaid) sebuah order jika ada
+func getActiveBilling(q queryer, orderID int) (entity.Billing, bool, error) {
+	var billing entity.Billing
+	var dueDate sql.NullTime // due_date bisa kosong untuk data lama
+	err := q.QueryRow(`
+		SELECT id, order_id, number_display, issue_date, due_date, status, tax, shipping_fee, total, created_by
+		FROM billings
+		WHERE order_id = ? AND status IN (?, ?)
+		ORDER BY id DESC
+		LIMIT 1
+	`, orderID, string(entity.StatusUnpaid), string(entity.StatusLesspaid)).Scan(
+		&billing.ID,
+		&billing.OrderID,
+		&billing.NumberDisplay,
+		&billing.IssueDate,
+		&dueDate,
+		&billing.Status,
+		&billing.Tax,
+		&billing.ShippingFee,
+		&billing.Total,
+		&billing.CreatedBy,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return billing, false, nil
+		}
+		return billing, false, fmt.Errorf("Terjadi kesalahan mengambil billing aktif: %s", err)
+	}
+	billing.DueDate = dueDate.Time
+
+	return billing, true, nil
+}
+
+// cancelBillingTx membatalkan billing unpaid dan mengembalikan stok yang ditahannya.
+// userID 0 berarti dibatalkan oleh worker.
+func cancelBillingTx(tx *sql.Tx, billingID int, userID int) error {
+	res, err := tx.Exec(
+		"UPDATE billings SET status = ?, updated_by = ? WHERE id = ? AND status = ?",
+		string(entity.StatusCancelled), nullInt(userID), billingID, string(entity.StatusUnpaid),
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan membatalkan billing")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errBillingNotUnpaid
+	}
+
+	return releaseReservationsTx(tx, billingID)
+}
+
+// recordBillingAuditTx mencatat riwayat pembatalan atau penggantian billing
+func recordBillingAuditTx(tx *sql.Tx, audit entity.BillingAudit) error {
+	_, err := tx.Exec(
+		"INSERT INTO billing_audits (billing_id, order_id, action, replaced_by, note, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+		audit.BillingID, audit.OrderID, string(audit.Action), nullInt(audit.ReplacedBy), nullString(audit.Note), nullInt(audit.CreatedBy),
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mencatat riwayat billing")
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -21,6 +21,9 @@ func SetupBillingAndOrdersDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite", ":memory:")
 	require.NoError(t, err, "Gagal membuka database in-memory")
 
+	// Satu koneksi agar transaksi GenerateBill melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
 	// Ambil format tahun-bulan saat ini untuk penomoran tagihan
 	currentYearMonth := time.Now().Format("200601") // contoh: "202506"
 
@@ -39,6 +42,62 @@ func SetupBillingAndOrdersDB(t *testing.T) *sql.DB {
 		INSERT INTO orders (number_display, customer_id, created_by, total)
 		VALUES ("ORD-%s-001", 1, 1, 1000000);
 
+		CREATE TABLE order_details (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			product_id INTEGER,
+			qty INTEGER,
+			product_name TEXT NOT NULL,
+			price NUMERIC DEFAULT 0 NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL
+		);
+
+		-- Detail order 1 dengan nilai barang 350.000 pada kelas pajak standar
+		INSERT INTO order_details (order_id, product_id, qty, product_name, price)
+		VALUES
+			(1, 1, 2, 'Adjustable Dumbbell 20kg', 100000),
+			(1, 2, 3, 'Treadmill Compact X100', 50000);
+
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			stock INTEGER DEFAULT 0 NOT NULL
+		);
+
+		INSERT INTO products (name, stock) VALUES
+			('Adjustable Dumbbell 20kg', 10),
+			('Treadmill Compact X100', 4);
+
+		CREATE TABLE stock_reservations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'reserved'
+		);
+
+		CREATE TABLE tax_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tax_class TEXT NOT NULL,
+			rate NUMERIC NOT NULL,
+			effective_from DATE NOT NULL,
+			effective_to DATE
+		);
+
+		INSERT INTO tax_rates (tax_class, rate, effective_from) VALUES ('standard', 10.00, '2020-01-01');
+
+		CREATE TABLE billing_tax_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			order_detail_id INTEGER NOT NULL,
+			tax_class TEXT NOT NULL,
+			rate NUMERIC NOT NULL,
+			price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+			taxable_amount NUMERIC NOT NULL DEFAULT 0,
+			tax_amount NUMERIC NOT NULL DEFAULT 0
+		);
+
 		CREATE TABLE billings ( 
 			id INTEGER PRIMARY KEY AUTOINCREMENT, 
 			order_id INTEGER NOT NULL, 
@@ -46,14 +105,56 @@ func SetupBillingAndOrdersDB(t *testing.T) *sql.DB {
 			issue_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			due_date TIMESTAMP,
 			tax NUMERIC DEFAULT 0 CHECK (tax >= 0) NOT NULL, 
+			shipping_fee NUMERIC DEFAULT 0 CHECK (shipping_fee >= 0) NOT NULL, 
 			total NUMERIC DEFAULT 0 CHECK (total >= 0) NOT NULL, 
-			status TEXT DEFAULT 'unpaid' CHECK (status IN ('unpaid', 'paid', 'cancelled', 'refunded')), 
+			status TEXT DEFAULT 'unpaid' CHECK (status IN ('unpaid', 'lesspaid', 'paid', 'cancelled', 'refunded')), 
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			created_by INTEGER NOT NULL, 
 			updated_by INTEGER
 		);
 
+		-- Maksimal satu billing aktif (unpaid/lesspaid) per order
+		CREATE UNIQUE INDEX uq_billings_active_order ON billings (order_id) WHERE status IN ('unpaid', 'lesspaid');
+
+		CREATE TABLE billing_audits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			order_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			replaced_by INTEGER,
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER
+		);
+
+		CREATE TABLE payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL
+		);
+
+		CREATE TABLE refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			payment_id INTEGER,
+			amount NUMERIC NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed'
+		);
+
+		CREATE TABLE customer_credits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			billing_id INTEGER,
+			payment_id INTEGER,
+			type TEXT NOT NULL,
+			amount NUMERIC NOT NULL CHECK (amount <> 0),
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
 		-- Tambahkan 2 tagihan awal agar test GenerateBillNumber menghasilkan BIL-XXX-003
 		INSERT INTO billings (order_id, number_display, tax, total, status, created_by, updated_by)
 		VALUES 
@@ -76,15 +177,15 @@ func TestGenerateBill(t *testing.T) {
 	handler := &BillingHandler{DB: db, Ctx: &ctx}
 
 	// Buat order baru dengan total 350.000
-	order := entity.Order{ID: 1, Total: 350000}
+	order := entity.Order{ID: 1, Total: entity.NewMoney(350000)}
 
 	// Jalankan GenerateBill()
 	billing, err := handler.GenerateBill(order)
 	require.NoError(t, err)
 
 	// Pastikan perhitungan pajak dan total tagihan sesuai (10% PPN)
-	assert.Equal(t, 35000.0, billing.Tax)
-	assert.Equal(t, 385000.0, billing.Total)
+	assert.Equal(t, entity.NewMoney(35000), billing.Tax)
+	assert.Equal(t, entity.NewMoney(385000), billing.Total)
 	assert.NotZero(t, billing.ID) // Billing berhasil dibuat
 }
 
@@ -102,4 +203,321 @@ func TestGenerateBillNumber(t *testing.T) {
 	// Seharusnya menjadi BIL-<YYYYMM>-003 karena sudah ada 2 tagihan sebelumnya
 	expected := fmt.Sprintf("BIL-%s-003", time.Now().Format("200601"))
 	assert.Equal(t, expected, num)
-}
\ No newline at end of file
+}
+// TestGenerateBill_WithShippingFee memastikan ongkos kirim masuk ke total tagihan tanpa dikenai pajak
+func TestGenerateBill_WithShippingFee(t *testing.T) {
+	db := SetupBillingAndOrdersDB(t)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	order := entity.Order{ID: 1, Total: entity.NewMoney(350000), ShippingFee: entity.NewMoney(20000)}
+
+	billing, err := handler.GenerateBill(order)
+	require.NoError(t, err)
+
+	assert.Equal(t, entity.NewMoney(35000), billing.Tax, "Pajak hanya dihitung dari nilai barang")
+	assert.Equal(t, entity.NewMoney(20000), billing.ShippingFee)
+	assert.Equal(t, entity.NewMoney(405000), billing.Total, "Total = barang + pajak + ongkos kirim")
+}
+
+// TestGenerateBill_ReservesStock memastikan stok ditahan saat tagihan dibuat dan ditolak jika tidak mencukupi
+func TestGenerateBill_ReservesStock(t *testing.T) {
+	db := SetupBillingAndOrdersDB(t)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	// Stok treadmill hanya 2, padahal order membutuhkan 3
+	_, err := db.Exec("UPDATE products SET stock = 2 WHERE id = 2")
+	require.NoError(t, err)
+
+	_, err = handler.GenerateBill(entity.Order{ID: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tidak mencukupi")
+
+	// Transaksi dibatalkan sehingga tidak ada billing maupun stok yang berkurang
+	var billings, dumbbellStock int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM billings").Scan(&billings))
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = 1").Scan(&dumbbellStock))
+	assert.Equal(t, 2, billings)
+	assert.Equal(t, 10, dumbbellStock)
+}
+
+// TestExpireOverdueBillings menguji pembatalan billing kedaluwarsa, pelepasan stok, dan pembuatan tagihan ulang
+func TestExpireOverdueBillings(t *testing.T) {
+	db := SetupBillingAndOrdersDB(t)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	stock := func(productID int) int {
+		var s int
+		require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = ?", productID).Scan(&s))
+		return s
+	}
+
+	billing, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 8, stock(1))
+	assert.Equal(t, 1, stock(2))
+
+	// Billing yang belum jatuh tempo tidak disentuh
+	expired, err := handler.ExpireOverdueBillings(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+
+	// Lewat 30 menit, billing dibatalkan dan stok kembali
+	expired, err = handler.ExpireOverdueBillings(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 10, stock(1))
+	assert.Equal(t, 4, stock(2))
+
+	var status string
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = ?", billing.ID).Scan(&status))
+	assert.Equal(t, string(entity.StatusCancelled), status)
+
+	var reserved int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM stock_reservations WHERE status = 'reserved'").Scan(&reserved))
+	assert.Equal(t, 0, reserved)
+
+	var action string
+	require.NoError(t, db.QueryRow("SELECT action FROM billing_audits WHERE billing_id = ?", billing.ID).Scan(&action))
+	assert.Equal(t, string(entity.AuditExpired), action)
+
+	// Proses ulang tidak mengembalikan stok dua kali
+	expired, err = handler.ExpireOverdueBillings(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	assert.Equal(t, 10, stock(1))
+
+	// Customer bisa membuat tagihan baru untuk order yang sama
+	fresh, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, billing.NumberDisplay, fresh.NumberDisplay)
+	assert.Equal(t, 8, stock(1))
+
+	// Billing lesspaid yang ditinggalkan juga dibatalkan: stok kembali dan dana bersih tiap pembayaran menjadi saldo kredit
+	_, err = db.Exec(`
+		UPDATE billings SET status = 'lesspaid' WHERE id = ?;
+		INSERT INTO payments (billing_id, amount, method) VALUES (?, 100000, 'va'), (?, 50000, 'va');
+		INSERT INTO refunds (billing_id, payment_id, amount) VALUES (?, (SELECT MAX(id) FROM payments), 20000);
+	`, fresh.ID, fresh.ID, fresh.ID, fresh.ID)
+	require.NoError(t, err)
+
+	expired, err = handler.ExpireOverdueBillings(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 10, stock(1))
+	assert.Equal(t, 4, stock(2))
+
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = ?", fresh.ID).Scan(&status))
+	assert.Equal(t, string(entity.StatusCancelled), status)
+
+	rows, err := db.Query(`
+		SELECT c.customer_id, c.type, c.amount
+		FROM customer_credits c
+		JOIN payments p ON p.id = c.payment_id
+		WHERE c.billing_id = ?
+		ORDER BY c.id
+	`, fresh.ID)
+	require.NoError(t, err)
+	var credited []entity.Money
+	for rows.Next() {
+		var creditType string
+		var amount entity.Money
+		var customerID int
+		require.NoError(t, rows.Scan(&customerID, &creditType, &amount))
+		assert.Equal(t, 1, customerID)
+		assert.Equal(t, string(entity.CreditFromExpired), creditType)
+		credited = append(credited, amount)
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []entity.Money{entity.NewMoney(100000), entity.NewMoney(30000)}, credited)
+
+	var note string
+	require.NoError(t, db.QueryRow("SELECT action, note FROM billing_audits WHERE billing_id = ?", fresh.ID).Scan(&action, &note))
+	assert.Equal(t, string(entity.AuditExpired), action)
+	assert.Contains(t, note, "saldo kredit")
+
+	// Billing yang sudah dibatalkan tidak hidup kembali walaupun status dihitung ulang
+	err = handler.UpdateOrderAndBillingStatus(fresh.ID)
+	assert.Error(t, err)
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = ?", fresh.ID).Scan(&status))
+	assert.Equal(t, string(entity.StatusCancelled), status)
+}
+
+// TestGenerateBill_SingleActiveBilling memastikan satu order hanya punya satu billing aktif
+func TestGenerateBill_SingleActiveBilling(t *testing.T) {
+	db := SetupBillingAndOrdersDB(t)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	countActive := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM billings WHERE order_id = 1 AND status IN ('unpaid', 'lesspaid')").Scan(&n))
+		return n
+	}
+
+	// Billing unpaid lama (tanpa jatuh tempo) digantikan tagihan baru
+	first, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, countActive())
+
+	// Memanggil ulang dengan nilai order yang sama mengembalikan billing yang sama
+	again, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.Equal(t, first.NumberDisplay, again.NumberDisplay)
+	assert.Len(t, again.TaxLines, 2)
+
+	var dumbbellStock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = 1").Scan(&dumbbellStock))
+	assert.Equal(t, 8, dumbbellStock, "Stok hanya ditahan sekali")
+
+	// Qty order berubah sehingga billing lama digantikan
+	_, err = db.Exec("UPDATE order_details SET qty = 1 WHERE id = 2")
+	require.NoError(t, err)
+
+	replaced, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, replaced.ID)
+	assert.Equal(t, entity.NewMoney(275000), replaced.Total)
+	assert.Equal(t, 1, countActive())
+
+	var treadmillStock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = 2").Scan(&treadmillStock))
+	assert.Equal(t, 3, treadmillStock)
+
+	var action string
+	var replacedBy int
+	require.NoError(t, db.QueryRow("SELECT action, replaced_by FROM billing_audits WHERE billing_id = ?", first.ID).Scan(&action, &replacedBy))
+	assert.Equal(t, string(entity.AuditSuperseded), action)
+	assert.Equal(t, replaced.ID, replacedBy)
+
+	// Database menolak billing aktif kedua untuk order yang sama
+	_, err = db.Exec("INSERT INTO billings (order_id, number_display, status, created_by) VALUES (1, 'BIL-DUP', 'unpaid', 1)")
+	assert.Error(t, err)
+}
+
+// TestGenerateBill_OverdueLesspaid memastikan billing lesspaid yang lewat jatuh tempo dibatalkan dengan kredit lalu digantikan,
+// sama seperti oleh worker kedaluwarsa
+func TestGenerateBill_OverdueLesspaid(t *testing.T) {
+	db := SetupBillingAndOrdersDB(t)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+
+	// Billing sudah dibayar sebagian tetapi belum jatuh tempo: dikembalikan apa adanya
+	_, err = db.Exec(`
+		UPDATE billings SET status = 'lesspaid' WHERE id = ?;
+		INSERT INTO payments (billing_id, amount, method) VALUES (?, 50000, 'va');
+	`, billing.ID, billing.ID)
+	require.NoError(t, err)
+
+	again, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, billing.ID, again.ID)
+	assert.Len(t, again.TaxLines, 2)
+
+	// Jatuh tempo terlewati: billing dibatalkan, dananya menjadi saldo kredit, dan tagihan baru dibuat
+	_, err = db.Exec("UPDATE billings SET due_date = ? WHERE id = ?", time.Now().Add(-time.Hour), billing.ID)
+	require.NoError(t, err)
+
+	fresh, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, billing.ID, fresh.ID)
+	assert.Equal(t, entity.StatusUnpaid, fresh.Status)
+	assert.True(t, fresh.DueDate.After(time.Now()))
+
+	var status string
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = ?", billing.ID).Scan(&status))
+	assert.Equal(t, string(entity.StatusCancelled), status)
+
+	var credited entity.Money
+	require.NoError(t, db.QueryRow("SELECT amount FROM customer_credits WHERE billing_id = ? AND type = 'expired_billing'", billing.ID).Scan(&credited))
+	assert.Equal(t, entity.NewMoney(50000), credited)
+
+	var action, note string
+	var replacedBy int
+	require.NoError(t, db.QueryRow("SELECT action, replaced_by, note FROM billing_audits WHERE billing_id = ?", billing.ID).Scan(&action, &replacedBy, &note))
+	assert.Equal(t, string(entity.AuditExpired), action)
+	assert.Equal(t, fresh.ID, replacedBy)
+	assert.Contains(t, note, "saldo kredit")
+
+	// Reservasi stok pindah ke billing baru
+	var reserved int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM stock_reservations WHERE billing_id = ? AND status = 'reserved'", billing.ID).Scan(&reserved))
+	assert.Equal(t, 0, reserved)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM stock_reservations WHERE billing_id = ? AND status = 'reserved'", fresh.ID).Scan(&reserved))
+	assert.Equal(t, 2, reserved)
+}
+
+// TestExpireLesspaid_WorkerAndGenerateBill memastikan billing lesspaid yang kedaluwarsa hanya dikreditkan sekali
+// walaupun worker dan GenerateBill sama-sama memprosesnya
+func TestExpireLesspaid_WorkerAndGenerateBill(t *testing.T) {
+	db := SetupBillingAndOrdersDB(t)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	credits := func() (int, entity.Money) {
+		var count int
+		var total entity.Money
+		require.NoError(t, db.QueryRow("SELECT COUNT(*), IFNULL(SUM(amount), 0) FROM customer_credits WHERE type = 'expired_billing'").Scan(&count, &total))
+		return count, total
+	}
+	overdueLesspaid := func(billingID int) {
+		_, err := db.Exec("UPDATE billings SET status = 'lesspaid', due_date = ? WHERE id = ?", time.Now().Add(-time.Hour), billingID)
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO payments (billing_id, amount, method) VALUES (?, 40000, 'va')", billingID)
+		require.NoError(t, err)
+	}
+
+	// Worker lebih dulu, lalu GenerateBill: billing lama sudah tidak aktif sehingga langsung dibuat tagihan baru
+	first, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	overdueLesspaid(first.ID)
+
+	expired, err := handler.ExpireOverdueBillings(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	second, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	count, total := credits()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, entity.NewMoney(40000), total)
+
+	// GenerateBill lebih dulu, lalu worker: billing lama sudah cancelled sehingga tidak diproses lagi
+	overdueLesspaid(second.ID)
+
+	third, err := handler.GenerateBill(entity.Order{ID: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, second.ID, third.ID)
+
+	expired, err = handler.ExpireOverdueBillings(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+
+	count, total = credits()
+	assert.Equal(t, 2, count)
+	assert.Equal(t, entity.NewMoney(80000), total)
+
+	// Membatalkan billing yang sudah cancelled tidak menambah kredit
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = cancelLesspaidBillingTx(tx, second.ID, 1, 1)
+	assert.Equal(t, errBillingNotLesspaid, err)
+	require.NoError(t, tx.Rollback())
+
+	count, _ = credits()
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+	"time"
+)
+
+// CardHandler menyimpan kartu kredit customer dalam bentuk token dari payment gateway.
+// Nomor kartu dan CVV hanya diteruskan ke gateway dan tidak pernah disimpan.
+type CardHandler struct {
+	DB      *sql.DB
+	Ctx     *context.Context
+	Gateway gateway.PaymentGateway
+}
+
+// SaveCard memvalidasi kartu, menukarnya dengan token di gateway, lalu menyimpan token beserta brand dan 4 digit terakhir
+func (c *CardHandler) SaveCard(card entity.CardDetails) (entity.CardToken, error) {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return entity.CardToken{}, fmt.Errorf("Please Login!")
+	}
+	if user.Customer.ID == 0 {
+		return entity.CardToken{}, fmt.Errorf("Hanya customer yang bisa menyimpan kartu")
+	}
+
+	// Validasi di aplikasi lebih dulu agar kartu yang jelas salah tidak dikirim ke gateway
+	if _, err := card.Validate(time.Now()); err != nil {
+		return entity.CardToken{}, err
+	}
+
+	token, err := gatewayOrDefault(c.Gateway).Tokenize(*c.Ctx, card)
+	if err != nil {
+		return entity.CardToken{}, fmt.Errorf("Gagal tokenisasi kartu: %w", err)
+	}
+	token.CustomerID, token.CreatedBy = user.Customer.ID, user.ID
+
+	res, err := c.DB.Exec(
+		"INSERT INTO card_tokens (customer_id, token, brand, last4, exp_month, exp_year, created_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		token.CustomerID, token.Token, string(token.Brand), token.Last4, token.ExpMonth, token.ExpYear, token.CreatedBy,
+	)
+	if err != nil {
+		return token, fmt.Errorf("Gagal menyimpan kartu %s: %s", token.Masked(), err)
+	}
+
+	tokenID, err := res.LastInsertId()
+	if err != nil {
+		return token, err
+	}
+	token.ID = int(tokenID)
+
+	return token, nil
+}
+
+// GetMyCards mengambil kartu tersimpan milik customer yang login yang masih berlaku, terbaru di atas
+func (c *CardHandler) GetMyCards() ([]entity.CardToken, error) {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	cards, err := getCardTokens(c.DB, "WHERE customer_id = ? ORDER BY id DESC", user.Customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var active []entity.CardToken
+	for _, card := range cards {
+		if !card.Expired(now) {
+			active = append(active, card)
+		}
+	}
+
+	return active, nil
+}
+
+// getCardTokens mengambil token kartu; filter berisi WHERE dan ORDER BY
+func getCardTokens(q queryer, filter string, args ...interface{}) ([]entity.CardToken, error) {
+	rows, err := q.Query(`
+		SELECT id, customer_id, token, brand, last4, exp_month, exp_year, created_at, created_by
+		FROM card_tokens
+		`+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil kartu: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []entity.CardToken
+	for rows.Next() {
+		var card entity.CardToken
+		err := rows.Scan(&card.ID, &card.CustomerID, &card.Token, &card.Brand, &card.Last4, &card.ExpMonth, &card.ExpYear, &card.CreatedAt, &card.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// getPaymentCards memetakan payment billing yang dibayar dengan kartu ke tampilan kartu tersamarnya
+func getPaymentCards(q queryer, billingID int) (map[int]string, error) {
+	rows, err := q.Query(`
+		SELECT pc.payment_id, ct.brand, ct.last4
+		FROM payment_charges pc
+		JOIN card_tokens ct ON ct.id = pc.card_token_id
+		WHERE pc.billing_id = ? AND pc.payment_id IS NOT NULL
+	`, billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil kartu pembayaran: %w", err)
+	}
+	defer rows.Close()
+
+	cards := map[int]string{}
+	for rows.Next() {
+		var paymentID int
+		var brand entity.CardBrand
+		var last4 string
+		if err := rows.Scan(&paymentID, &brand, &last4); err != nil {
+			return nil, err
+		}
+		cards[paymentID] = entity.MaskCard(brand, last4)
+	}
+
+	return cards, rows.Err()
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSaveCard memastikan kartu disimpan sebagai token tanpa nomor kartu lengkap.
+func TestSaveCard(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &CardHandler{DB: db, Ctx: &ctx, Gateway: gateway.NewSimulator(gateway.ModeApprove)}
+
+	expYear := time.Now().Year() + 2
+	card, err := handler.SaveCard(entity.CardDetails{Number: "5555 5555 5555 4444", ExpMonth: 6, ExpYear: expYear, CVV: "321"})
+	require.NoError(t, err)
+	assert.Equal(t, entity.CardMastercard, card.Brand)
+	assert.Equal(t, "MASTERCARD **** 4444", card.Masked())
+
+	// Tidak ada kolom yang memuat nomor kartu maupun CVV
+	var stored string
+	require.NoError(t, db.QueryRow("SELECT token || brand || last4 || exp_month || exp_year FROM card_tokens WHERE id = ?", card.ID).Scan(&stored))
+	assert.NotContains(t, stored, "5555555555554444")
+	assert.NotContains(t, stored, "321")
+
+	// Kartu tidak valid ditolak sebelum sampai ke gateway
+	_, err = handler.SaveCard(entity.CardDetails{Number: "5555555555554445", ExpMonth: 6, ExpYear: expYear, CVV: "321"})
+	assert.Error(t, err)
+
+	// Kartu kedaluwarsa tidak ditawarkan lagi
+	_, err = db.Exec("INSERT INTO card_tokens (customer_id, token, brand, last4, exp_month, exp_year, created_by) VALUES (1, 'tok_sim_C_old', 'visa', '1111', 1, 2020, 1)")
+	require.NoError(t, err)
+	cards, err := handler.GetMyCards()
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, card.ID, cards[0].ID)
+}
+
+// TestCreateCardPayment menguji pembayaran kartu kredit yang hanya mengirim token ke gateway.
+func TestCreateCardPayment(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	sim := gateway.NewSimulator(gateway.ModeApprove)
+	cardHandler := &CardHandler{DB: db, Ctx: &ctx, Gateway: sim}
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: sim}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+	expYear := time.Now().Year() + 1
+
+	// credit_card tanpa kartu tersimpan ditolak
+	err := handler.CreatePayment(billingHandler, billing, entity.NewMoney(10000), entity.MethodCredit)
+	assert.ErrorIs(t, err, ErrCardRequired)
+
+	card, err := cardHandler.SaveCard(entity.CardDetails{Number: "4111111111111111", ExpMonth: 12, ExpYear: expYear, CVV: "123"})
+	require.NoError(t, err)
+	require.NoError(t, handler.CreateCardPayment(billingHandler, billing, entity.NewMoney(40000), card.ID, OverpaymentReject))
+
+	charges, err := handler.SyncMyPendingCharges(billingHandler)
+	require.NoError(t, err)
+	require.Len(t, charges, 1)
+	assert.Equal(t, entity.ChargeApproved, charges[0].Status)
+	assert.Equal(t, card.ID, charges[0].CardTokenID)
+	assert.Equal(t, "VISA **** 1111", charges[0].Card)
+
+	// Kartu uji yang ditolak bank penerbit tidak menghasilkan payment
+	declinedCard, err := cardHandler.SaveCard(entity.CardDetails{Number: gateway.DeclinedCardNumber, ExpMonth: 12, ExpYear: expYear, CVV: "123"})
+	require.NoError(t, err)
+	err = handler.CreateCardPayment(billingHandler, billing, entity.NewMoney(10000), declinedCard.ID, OverpaymentReject)
+	var declined *PaymentDeclinedError
+	assert.True(t, errors.As(err, &declined))
+
+	// Kartu milik customer lain tidak bisa dipakai
+	otherCtx := utils.WithUser(context.Background(), &entity.User{ID: 2, Customer: entity.Customer{ID: 2}})
+	otherHandler := &PaymentHandler{DB: db, Ctx: &otherCtx, Gateway: sim}
+	err = otherHandler.CreateCardPayment(billingHandler, billing, entity.NewMoney(10000), card.ID, OverpaymentReject)
+	assert.Error(t, err)
+
+	var paid entity.Money
+	require.NoError(t, db.QueryRow("SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = 1").Scan(&paid))
+	assert.Equal(t, entity.NewMoney(40000), paid)
+}
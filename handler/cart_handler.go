@@ -0,0 +1,396 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+)
+
+// CartHandler menangani keranjang belanja customer yang disimpan di database sebelum checkout
+type CartHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// AddItem menambahkan produk ke cart customer. Jika produk sudah ada di cart, qty akan dijumlahkan.
+func (c *CartHandler) AddItem(productID int, qty int) error {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	if qty <= 0 {
+		return errors.New("Qty harus lebih dari 0")
+	}
+
+	// Ambil data produk untuk validasi stok dan harga saat ini
+	product, err := c.getProduct(productID)
+	if err != nil {
+		return err
+	}
+
+	// Cek apakah produk sudah ada di cart
+	var cartItemID, currentQty int
+	err = c.DB.QueryRow(
+		"SELECT id, qty FROM cart_items WHERE customer_id = ? AND product_id = ?",
+		user.Customer.ID, productID,
+	).Scan(&cartItemID, &currentQty)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("Terjadi kesalahan mengambil cart: %s", err)
+	}
+
+	// Validasi total qty terhadap stok
+	if currentQty+qty > product.Stock {
+		return fmt.Errorf("Stok %s tidak mencukupi (tersedia: %d)", product.Name, product.Stock)
+	}
+
+	if err == sql.ErrNoRows {
+		// Produk belum ada di cart, insert baru
+		_, err = c.DB.Exec(
+			"INSERT INTO cart_items (customer_id, product_id, qty, price, created_by) VALUES (?, ?, ?, ?, ?)",
+			user.Customer.ID, productID, qty, product.Price, user.ID,
+		)
+	} else {
+		// Produk sudah ada, tambahkan qty dan perbarui harga ke harga terbaru
+		_, err = c.DB.Exec(
+			"UPDATE cart_items SET qty = ?, price = ?, updated_by = ? WHERE id = ?",
+			currentQty+qty, product.Price, user.ID, cartItemID,
+		)
+	}
+	if err != nil {
+		return errors.New("Terjadi kesalahan menyimpan cart")
+	}
+
+	return nil
+}
+
+// UpdateItem mengubah qty produk yang sudah ada di cart
+func (c *CartHandler) UpdateItem(productID int, qty int) error {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	if qty <= 0 {
+		return errors.New("Qty harus lebih dari 0, gunakan hapus item untuk mengeluarkan produk dari cart")
+	}
+
+	product, err := c.getProduct(productID)
+	if err != nil {
+		return err
+	}
+
+	if qty > product.Stock {
+		return fmt.Errorf("Stok %s tidak mencukupi (tersedia: %d)", product.Name, product.Stock)
+	}
+
+	res, err := c.DB.Exec(
+		"UPDATE cart_items SET qty = ?, price = ?, updated_by = ? WHERE customer_id = ? AND product_id = ?",
+		qty, product.Price, user.ID, user.Customer.ID, productID,
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mengubah cart")
+	}
+
+	// Pastikan produk memang ada di cart
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("Produk tidak ada di cart")
+	}
+
+	return nil
+}
+
+// RemoveItem menghapus produk dari cart customer
+func (c *CartHandler) RemoveItem(productID int) error {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	res, err := c.DB.Exec("DELETE FROM cart_items WHERE customer_id = ? AND product_id = ?", user.Customer.ID, productID)
+	if err != nil {
+		return errors.New("Terjadi kesalahan menghapus item cart")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("Produk tidak ada di cart")
+	}
+
+	return nil
+}
+
+// GetCart mengambil isi cart customer beserta total berjalan berdasarkan harga produk saat ini
+func (c *CartHandler) GetCart() (entity.Cart, error) {
+	var cart entity.Cart
+
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return cart, fmt.Errorf("Please Login!")
+	}
+
+	query := `
+		SELECT ci.id, ci.customer_id, ci.product_id, ci.qty, ci.price, ci.created_by,
+			p.name, p.stock, p.price, p.weight
+		FROM cart_items ci
+		JOIN products p ON p.id = ci.product_id
+		WHERE ci.customer_id = ?
+		ORDER BY ci.id ASC
+	`
+
+	rows, err := c.DB.Query(query, user.Customer.ID)
+	if err != nil {
+		return cart, fmt.Errorf("Terjadi kesalahan mengambil cart: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item entity.CartItem
+		err := rows.Scan(
+			&item.ID,
+			&item.CustomerID,
+			&item.ProductID,
+			&item.Qty,
+			&item.Price,
+			&item.CreatedBy,
+			&item.Product.Name,
+			&item.Product.Stock,
+			&item.Product.Price,
+			&item.Product.Weight,
+		)
+		if err != nil {
+			return cart, err
+		}
+
+		// Subtotal dihitung dari harga produk saat ini
+		item.Product.ID = item.ProductID
+		item.Total = item.Product.Price.Mul(item.Qty)
+		cart.Total += item.Total
+		cart.Weight += item.Product.Weight * item.Qty
+		cart.Items = append(cart.Items, item)
+	}
+
+	return cart, rows.Err()
+}
+
+// ValidateCart memeriksa isi cart terhadap stok dan harga produk saat ini.
+// Mengembalikan daftar peringatan (stok kurang / harga berubah) yang perlu ditampilkan ke customer.
+func (c *CartHandler) ValidateCart(cart entity.Cart) []string {
+	var issues []string
+
+	for _, item := range cart.Items {
+		if item.Qty > item.Product.Stock {
+			issues = append(issues, fmt.Sprintf("Stok %s tidak mencukupi (diminta: %d, tersedia: %d)",
+				item.Product.Name, item.Qty, item.Product.Stock))
+		}
+		if item.Price != item.Product.Price {
+			issues = append(issues, fmt.Sprintf("Harga %s berubah dari %s menjadi %s",
+				item.Product.Name, item.Price, item.Product.Price))
+		}
+	}
+
+	return issues
+}
+
+// Checkout mengubah isi cart menjadi order dalam satu transaksi dengan alamat pengiriman yang dipilih.
+// Stok divalidasi ulang di dalam transaksi, ongkos kirim dihitung dari total berat dan zona alamat,
+// dan cart dikosongkan hanya jika order berhasil dibuat. Jika context berisi idempotency key, checkout ulang
+// dengan key yang sama mengembalikan order pertama tanpa membuat order baru.
+func (c *CartHandler) Checkout(addressID int) (entity.Order, error) {
+	var order entity.Order
+
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return order, fmt.Errorf("Please Login!")
+	}
+
+	// Isi cart sudah kosong setelah checkout pertama, jadi permintaan dibandingkan dari alamatnya saja
+	orderHandler := OrderHandler{DB: c.DB, Ctx: c.Ctx}
+	key, hasKey := utils.GetIdempotencyKey(*c.Ctx)
+	var hash string
+	if hasKey {
+		var err error
+		hash, err = requestHash(struct{ AddressID int }{addressID})
+		if err != nil {
+			return order, err
+		}
+		if order, done, err := orderHandler.replayOrder(user.ID, idempotencyCheckout, key, hash); done || err != nil {
+			return order, err
+		}
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return order, err
+	}
+
+	// Ambil alamat pengiriman milik customer
+	var address entity.CustomerAddress
+	err = tx.QueryRow(`
+		SELECT id, recipient_name, phone_number, address, city, postal_code, zone
+		FROM customer_addresses
+		WHERE id = ? AND customer_id = ?
+	`, addressID, user.Customer.ID).Scan(
+		&address.ID,
+		&address.RecipientName,
+		&address.PhoneNumber,
+		&address.Address,
+		&address.City,
+		&address.PostalCode,
+		&address.Zone,
+	)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return order, errors.New("Alamat pengiriman tidak ditemukan")
+		}
+		return order, fmt.Errorf("Terjadi kesalahan mengambil alamat: %s", err)
+	}
+
+	// Ambil isi cart di dalam transaksi, diurutkan per produk agar urutan penguncian stok selalu sama
+	rows, err := tx.Query(`
+		SELECT ci.product_id, ci.qty, p.name, p.weight
+		FROM cart_items ci
+		JOIN products p ON p.id = ci.product_id
+		WHERE ci.customer_id = ?
+		ORDER BY ci.product_id ASC
+	`, user.Customer.ID)
+	if err != nil {
+		tx.Rollback()
+		return order, fmt.Errorf("Terjadi kesalahan mengambil cart: %w", err)
+	}
+
+	var oProducts []entity.OrderProduct
+	var names []string
+	var totalWeight int
+	for rows.Next() {
+		var op entity.OrderProduct
+		var name string
+		var weight int
+		if err := rows.Scan(&op.ProductId, &op.Qty, &name, &weight); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return order, err
+		}
+		totalWeight += weight * op.Qty
+		oProducts = append(oProducts, op)
+		names = append(names, name)
+	}
+	rows.Close()
+
+	if len(oProducts) == 0 {
+		tx.Rollback()
+		return order, errors.New("Cart masih kosong")
+	}
+
+	// Validasi stok terakhir sebelum order dibuat, produk dikunci sampai transaksi selesai
+	for i, op := range oProducts {
+		available, err := availableStockTx(tx, op.ProductId)
+		if err != nil {
+			tx.Rollback()
+			return order, err
+		}
+		if op.Qty > available {
+			tx.Rollback()
+			return order, fmt.Errorf("Stok %s tidak mencukupi (diminta: %d, tersedia: %d)", names[i], op.Qty, available)
+		}
+	}
+
+	// Buat order dari isi cart menggunakan transaksi yang sama
+	order, err = orderHandler.createOrderTx(tx, user, oProducts)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	// Hitung ongkos kirim dan simpan snapshot alamat ke order
+	rate, err := calculateShippingFee(tx, address.Zone, totalWeight)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	order.ShippingAddressID = address.ID
+	order.ShippingAddress = FormatAddress(address)
+	order.ShippingZone = address.Zone
+	order.ShippingFee = rate.Fee
+	_, err = tx.Exec(
+		"UPDATE orders SET shipping_address_id = ?, shipping_address = ?, shipping_zone = ?, shipping_fee = ? WHERE id = ?",
+		order.ShippingAddressID, order.ShippingAddress, order.ShippingZone, order.ShippingFee, order.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return order, errors.New("Terjadi kesalahan menyimpan alamat pengiriman")
+	}
+
+	// Kosongkan cart setelah order dibuat
+	_, err = tx.Exec("DELETE FROM cart_items WHERE customer_id = ?", user.Customer.ID)
+	if err != nil {
+		tx.Rollback()
+		return order, errors.New("Terjadi kesalahan mengosongkan cart")
+	}
+
+	// Key disimpan di transaksi yang sama dengan order sehingga keduanya tercatat bersamaan
+	if hasKey {
+		response, err := json.Marshal(order)
+		if err != nil {
+			tx.Rollback()
+			return entity.Order{}, err
+		}
+		record := entity.IdempotencyKey{
+			Key:         key,
+			UserID:      user.ID,
+			Operation:   idempotencyCheckout,
+			RequestHash: hash,
+			ResourceID:  order.ID,
+			Response:    string(response),
+			Status:      entity.IdempotencyCompleted,
+		}
+		if _, err := insertIdempotencyKey(tx, record); err != nil {
+			tx.Rollback()
+			// Checkout lain dengan key yang sama selesai lebih dulu
+			if order, done, replayErr := orderHandler.replayOrder(user.ID, idempotencyCheckout, key, hash); done || replayErr != nil {
+				return order, replayErr
+			}
+			return entity.Order{}, fmt.Errorf("Gagal menyimpan idempotency key: %s", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return order, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return order, nil
+}
+
+// getProduct mengambil data produk yang dibutuhkan untuk validasi cart
+func (c *CartHandler) getProduct(productID int) (entity.Product, error) {
+	var product entity.Product
+
+	err := c.DB.QueryRow("SELECT id, name, stock, price FROM products WHERE id = ?", productID).Scan(
+		&product.ID,
+		&product.Name,
+		&product.Stock,
+		&product.Price,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return product, fmt.Errorf("Produk dengan id %d tidak ditemukan", productID)
+		}
+		return product, fmt.Errorf("Terjadi kesalahan mengambil produk: %s", err)
+	}
+
+	return product, nil
+}
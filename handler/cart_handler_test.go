@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestCartDB membuat database in-memory SQLite berisi produk, cart, dan tabel order untuk checkout.
+func SetupTestCartDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	_, err = db.Exec(`
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			stock INTEGER DEFAULT 0 NOT NULL,
+			price REAL DEFAULT 0 NOT NULL,
+			category_id INTEGER,
+			tax_class TEXT,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL,
+			weight INTEGER DEFAULT 0 NOT NULL
+		);
+
+		CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL
+		);
+
+		INSERT INTO products (name, stock, price, weight) VALUES
+		('Adjustable Dumbbell 20kg', 10, 100000.00, 2000),
+		('Treadmill Compact X100', 1, 50000.00, 3000);
+
+		CREATE TABLE customer_addresses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			label TEXT NOT NULL,
+			recipient_name TEXT NOT NULL,
+			phone_number TEXT NOT NULL,
+			address TEXT NOT NULL,
+			city TEXT NOT NULL,
+			postal_code TEXT NOT NULL,
+			zone TEXT NOT NULL,
+			is_default BOOLEAN NOT NULL DEFAULT 0,
+			created_by INTEGER NOT NULL,
+			updated_by INTEGER
+		);
+
+		INSERT INTO customer_addresses (customer_id, label, recipient_name, phone_number, address, city, postal_code, zone, is_default, created_by) VALUES
+		(1, 'Rumah', 'John Doe', '081234567890', 'Jl. Merdeka No. 123', 'Jakarta', '10110', 'jabodetabek', 1, 1),
+		(2, 'Rumah', 'Jane Smith', '089876543210', 'Jl. Sudirman No. 10', 'Bandung', '40111', 'jawa', 1, 2);
+
+		CREATE TABLE shipping_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			zone TEXT,
+			min_weight INTEGER,
+			max_weight INTEGER,
+			fee REAL NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT 1,
+			created_by INTEGER NOT NULL,
+			updated_by INTEGER
+		);
+
+		INSERT INTO shipping_rates (name, type, zone, min_weight, max_weight, fee, created_by) VALUES
+		('Flat Nasional', 'flat', NULL, NULL, NULL, 50000, 1),
+		('Ringan s/d 5kg', 'weight', NULL, NULL, 5000, 20000, 1),
+		('Jabodetabek', 'zone', 'jabodetabek', NULL, 5000, 15000, 1);
+
+		CREATE TABLE cart_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL DEFAULT 1 CHECK (qty > 0),
+			price REAL NOT NULL DEFAULT 0,
+			created_by INTEGER NOT NULL,
+			updated_by INTEGER,
+			UNIQUE (customer_id, product_id)
+		);
+
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT,
+			customer_id INTEGER,
+			date DATETIME DEFAULT CURRENT_TIMESTAMP,
+			status TEXT DEFAULT 'processing',
+			created_by INTEGER,
+			total REAL DEFAULT 0,
+			shipping_address_id INTEGER,
+			shipping_address TEXT,
+			shipping_zone TEXT,
+			shipping_fee REAL DEFAULT 0
+		);
+
+		CREATE TABLE order_details (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			product_id INTEGER,
+			qty INTEGER,
+			product_name TEXT NOT NULL,
+			price REAL DEFAULT 0 NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL,
+			weight INTEGER DEFAULT 0 NOT NULL,
+			created_by INTEGER
+		);
+
+		CREATE TRIGGER trg_order_details_after_insert
+		AFTER INSERT ON order_details
+		BEGIN
+			UPDATE orders
+			SET total = (SELECT IFNULL(SUM(qty * price), 0) FROM order_details WHERE order_id = NEW.order_id)
+			WHERE id = NEW.order_id;
+		END;
+
+		-- Stok order yang sudah punya billing ditahan lewat stock_reservations
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			status TEXT DEFAULT 'unpaid'
+		);
+
+		CREATE TABLE stock_reservations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'reserved'
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema cart")
+
+	return db
+}
+
+// TestCart_AddUpdateRemove menguji penambahan, perubahan qty, dan penghapusan item cart.
+func TestCart_AddUpdateRemove(t *testing.T) {
+	db := SetupTestCartDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &CartHandler{DB: db, Ctx: &ctx}
+
+	// Tambah produk yang sama dua kali, qty harus dijumlahkan
+	require.NoError(t, handler.AddItem(1, 2))
+	require.NoError(t, handler.AddItem(1, 1))
+	require.NoError(t, handler.AddItem(2, 1))
+
+	cart, err := handler.GetCart()
+	require.NoError(t, err)
+	assert.Len(t, cart.Items, 2)
+	assert.Equal(t, 3, cart.Items[0].Qty)
+	assert.Equal(t, entity.NewMoney(350000), cart.Total, "Total = 3 x 100000 + 1 x 50000")
+
+	// Ubah qty dan hapus item
+	require.NoError(t, handler.UpdateItem(1, 5))
+	require.NoError(t, handler.RemoveItem(2))
+
+	cart, err = handler.GetCart()
+	require.NoError(t, err)
+	assert.Len(t, cart.Items, 1)
+	assert.Equal(t, entity.NewMoney(500000), cart.Total)
+
+	// Menghapus produk yang tidak ada di cart harus error
+	assert.Error(t, handler.RemoveItem(2))
+}
+
+// TestCart_StockAndPriceValidation menguji validasi stok dan deteksi perubahan harga.
+func TestCart_StockAndPriceValidation(t *testing.T) {
+	db := SetupTestCartDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &CartHandler{DB: db, Ctx: &ctx}
+
+	// Stok treadmill hanya 1
+	err := handler.AddItem(2, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tidak mencukupi")
+
+	// Produk tidak ditemukan
+	assert.Error(t, handler.AddItem(99, 1))
+
+	// Harga berubah setelah produk masuk cart
+	require.NoError(t, handler.AddItem(1, 1))
+	_, err = db.Exec("UPDATE products SET price = 120000 WHERE id = 1")
+	require.NoError(t, err)
+
+	cart, err := handler.GetCart()
+	require.NoError(t, err)
+	issues := handler.ValidateCart(cart)
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0], "Harga")
+}
+
+// TestCart_Checkout menguji checkout cart menjadi order dan cart dikosongkan.
+func TestCart_Checkout(t *testing.T) {
+	db := SetupTestCartDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &CartHandler{DB: db, Ctx: &ctx}
+
+	// Checkout cart kosong harus gagal
+	_, err := handler.Checkout(1)
+	assert.Error(t, err)
+
+	require.NoError(t, handler.AddItem(1, 2))
+	require.NoError(t, handler.AddItem(2, 1))
+
+	// Alamat milik customer lain tidak boleh dipakai
+	_, err = handler.Checkout(2)
+	assert.Error(t, err)
+
+	order, err := handler.Checkout(1)
+	require.NoError(t, err)
+	assert.NotZero(t, order.ID)
+	assert.Len(t, order.Details, 2)
+
+	var total, shippingFee float64
+	var shippingAddress string
+	require.NoError(t, db.QueryRow("SELECT total, shipping_fee, shipping_address FROM orders WHERE id = ?", order.ID).Scan(&total, &shippingFee, &shippingAddress))
+	assert.Equal(t, float64(250000), total)
+	assert.Equal(t, float64(50000), shippingFee, "Berat 7kg melebihi tarif zona & berat, jatuh ke tarif flat")
+	assert.Contains(t, shippingAddress, "Jl. Merdeka No. 123")
+
+	cart, err := handler.GetCart()
+	require.NoError(t, err)
+	assert.Empty(t, cart.Items, "Cart harus kosong setelah checkout")
+}
+
+// TestCart_CheckoutStockChanged memastikan checkout dibatalkan seluruhnya jika stok berubah.
+func TestCart_CheckoutStockChanged(t *testing.T) {
+	db := SetupTestCartDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &CartHandler{DB: db, Ctx: &ctx}
+
+	require.NoError(t, handler.AddItem(1, 2))
+	_, err := db.Exec("UPDATE products SET stock = 1 WHERE id = 1")
+	require.NoError(t, err)
+
+	_, err = handler.Checkout(1)
+	require.Error(t, err)
+
+	// Tidak ada order yang tersimpan dan cart tetap utuh
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&count))
+	assert.Equal(t, 0, count)
+
+	cart, err := handler.GetCart()
+	require.NoError(t, err)
+	assert.Len(t, cart.Items, 1)
+}
+
+// TestCart_CheckoutLastUnit memastikan unit terakhir tidak bisa di-checkout dua order yang belum dibilling.
+func TestCart_CheckoutLastUnit(t *testing.T) {
+	db := SetupTestCartDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &CartHandler{DB: db, Ctx: &ctx}
+
+	_, err := db.Exec("UPDATE products SET stock = 1 WHERE id = 1")
+	require.NoError(t, err)
+
+	require.NoError(t, handler.AddItem(1, 1))
+	first, err := handler.Checkout(1)
+	require.NoError(t, err)
+
+	// Order pertama belum dibilling, unit terakhir sudah menjadi miliknya
+	require.NoError(t, handler.AddItem(1, 1))
+	_, err = handler.Checkout(1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tersedia: 0")
+
+	// Setelah billing menahan stok, stok produk berkurang dan order tidak dihitung dua kali
+	_, err = db.Exec(`
+		INSERT INTO billings (order_id) VALUES (?);
+		UPDATE products SET stock = 0 WHERE id = 1;
+		INSERT INTO stock_reservations (billing_id, product_id, qty) VALUES (1, 1, 1);
+	`, first.ID)
+	require.NoError(t, err)
+	_, err = handler.Checkout(1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tersedia: 0")
+
+	// Billing kedaluwarsa mengembalikan stok, tetapi order pertama masih menunggu billing baru
+	_, err = db.Exec("UPDATE stock_reservations SET status = 'released'; UPDATE products SET stock = 1 WHERE id = 1")
+	require.NoError(t, err)
+	_, err = handler.Checkout(1)
+	require.Error(t, err)
+
+	// Order pertama dibatalkan, unit bisa dibeli lagi
+	_, err = db.Exec("UPDATE orders SET status = 'cancel' WHERE id = ?", first.ID)
+	require.NoError(t, err)
+	_, err = handler.Checkout(1)
+	require.NoError(t, err)
+}
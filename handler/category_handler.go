@@ -42,7 +42,7 @@ func (c *CategoryHandler) CreateCategory(name string) error {
 // GetCategories mengambil semua kategori dari database dan mengembalikannya dalam bentuk slice.
 func (c *CategoryHandler) GetCategories() ([]entity.Category, error) {
 	// Jalankan query SQL untuk mengambil semua kategori
-	rows, err := c.DB.Query("SELECT id, name FROM categories")
+	rows, err := c.DB.Query("SELECT id, name, tax_class FROM categories")
 	var emptyCategory []entity.Category
 	if err != nil {
 		// Jika gagal query, kembalikan slice kosong dan error
@@ -58,7 +58,7 @@ func (c *CategoryHandler) GetCategories() ([]entity.Category, error) {
 	for rows.Next() {
 		var c entity.Category
 		// Scan data hasil query ke dalam struct Category
-		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.TaxClass); err != nil {
 			// Jika gagal scan, kembalikan slice kosong dan error
 			return emptyCategory, err
 		}
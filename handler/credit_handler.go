@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"time"
+)
+
+// CreditHandler mengelola saldo kredit customer yang berasal dari kelebihan bayar
+type CreditHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// GetMyCreditBalance mengambil saldo kredit customer yang sedang login
+func (c *CreditHandler) GetMyCreditBalance() (entity.Money, error) {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return 0, fmt.Errorf("Please Login!")
+	}
+
+	return creditBalance(c.DB, user.Customer.ID)
+}
+
+// GetMyCreditHistory mengambil mutasi saldo kredit customer yang sedang login, terbaru di atas
+func (c *CreditHandler) GetMyCreditHistory() ([]entity.CustomerCredit, error) {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	rows, err := c.DB.Query(`
+		SELECT id, customer_id, IFNULL(billing_id, 0), IFNULL(payment_id, 0), type, amount, IFNULL(note, ''), created_at, created_by
+		FROM customer_credits
+		WHERE customer_id = ?
+		ORDER BY id DESC
+	`, user.Customer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil saldo kredit: %w", err)
+	}
+	defer rows.Close()
+
+	var credits []entity.CustomerCredit
+	for rows.Next() {
+		var credit entity.CustomerCredit
+		err := rows.Scan(
+			&credit.ID,
+			&credit.CustomerID,
+			&credit.BillingID,
+			&credit.PaymentID,
+			&credit.Type,
+			&credit.Amount,
+			&credit.Note,
+			&credit.CreatedAt,
+			&credit.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, credit)
+	}
+
+	return credits, rows.Err()
+}
+
+// ApplyStoreCredit membayar billing milik customer yang sedang login dengan saldo kredit.
+// Nominal dibatasi saldo dan sisa tagihan; pembayaran dicatat dengan method store_credit.
+func (c *CreditHandler) ApplyStoreCredit(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money) error {
+	user, ok := utils.GetUser(*c.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	if amount <= 0 {
+		return errors.New("Nominal pembayaran harus lebih dari 0")
+	}
+	if time.Now().After(billing.DueDate) {
+		return errors.New("cannot create payment: order is past due date")
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Kunci billing agar sisa tagihan tidak berubah oleh posting payment lain sampai transaksi selesai
+	if err := lockBillingTx(tx, billing.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Billing harus milik customer yang sedang login
+	var customerID int
+	err = tx.QueryRow("SELECT o.customer_id FROM billings b JOIN orders o ON o.id = b.order_id WHERE b.id = ?", billing.ID).Scan(&customerID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("Billing tidak ditemukan")
+		}
+		return fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+	if customerID != user.Customer.ID {
+		tx.Rollback()
+		return errors.New("Billing tidak ditemukan")
+	}
+
+	// Saldo kredit juga bisa dinonaktifkan atau dibatasi nominalnya oleh admin; saldo kredit tidak dikenai biaya
+	if _, err := checkPaymentMethod(tx, entity.MethodStoreCredit, amount); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	balance, err := creditBalance(tx, customerID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if amount > balance {
+		tx.Rollback()
+		return fmt.Errorf("Saldo kredit tidak mencukupi, saldo tersedia %s", balance.Rupiah())
+	}
+
+	remaining, err := billingRemaining(tx, billing.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if amount > remaining {
+		tx.Rollback()
+		return &OverpaymentError{Remaining: remaining, Excess: amount - remaining}
+	}
+
+	paymentID, err := insertPaymentTx(tx, user.ID, billing.ID, amount, entity.MethodStoreCredit)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	credit := entity.CustomerCredit{
+		CustomerID: customerID,
+		BillingID:  billing.ID,
+		PaymentID:  paymentID,
+		Type:       entity.CreditApplied,
+		Amount:     -amount,
+		Note:       "Pembayaran " + billing.NumberDisplay,
+	}
+	if err := recordCreditTx(tx, user.ID, credit); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Status billing dan order diperbarui di transaksi yang sama dengan payment
+	err = billingHandler.updateOrderAndBillingStatusTx(tx, billing.ID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Gagal mengupdate order dan billing: %s", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return nil
+}
+
+// creditBalance menjumlahkan seluruh mutasi saldo kredit seorang customer
+func creditBalance(q queryer, customerID int) (entity.Money, error) {
+	var balance entity.Money
+	err := q.QueryRow("SELECT IFNULL(SUM(amount), 0) FROM customer_credits WHERE customer_id = ?", customerID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil saldo kredit: %s", err)
+	}
+
+	return balance, nil
+}
+
+// recordCreditTx mencatat satu mutasi saldo kredit di dalam transaksi yang sedang berjalan
+func recordCreditTx(tx *sql.Tx, userID int, credit entity.CustomerCredit) error {
+	_, err := tx.Exec(
+		"INSERT INTO customer_credits (customer_id, billing_id, payment_id, type, amount, note, created_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		credit.CustomerID, nullInt(credit.BillingID), nullInt(credit.PaymentID), string(credit.Type), credit.Amount, nullString(credit.Note), userID,
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan menyimpan saldo kredit")
+	}
+
+	return nil
+}
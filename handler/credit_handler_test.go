@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestCreditDB membuat database in-memory SQLite berisi dua billing unpaid milik customer berbeda.
+// Memakai shared cache karena UpdateOrderAndBillingStatus masih membaca lewat koneksi lain saat transaksinya terbuka.
+func SetupTestCreditDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	_, err = db.Exec(`
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'processing'
+		);
+
+		INSERT INTO orders (customer_id) VALUES (1), (2);
+
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			number_display TEXT NOT NULL,
+			tax NUMERIC NOT NULL DEFAULT 0,
+			total NUMERIC NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'unpaid'
+		);
+
+		INSERT INTO billings (order_id, number_display, total) VALUES
+		(1, 'BIL-001', 100000),
+		(2, 'BIL-002', 50000);
+
+		CREATE TABLE payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL CHECK (method IN ('credit_card', 'va', 'transfer', 'store_credit', 'qris')),
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TRIGGER trg_payment_before_insert
+		BEFORE INSERT ON payments
+		FOR EACH ROW
+		BEGIN
+			SELECT
+				CASE
+					WHEN (
+						(SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = NEW.billing_id)
+						- (SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = NEW.billing_id AND status <> 'failed')
+						+ NEW.amount
+					) > (SELECT total FROM billings WHERE id = NEW.billing_id)
+						+ (SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = NEW.billing_id)
+					THEN RAISE(ABORT, 'Total payment exceeds billing total')
+				END;
+		END;
+
+		CREATE TABLE card_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			brand TEXT NOT NULL,
+			last4 TEXT NOT NULL,
+			exp_month INTEGER NOT NULL,
+			exp_year INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE payment_charges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			charge_ref TEXT NOT NULL UNIQUE,
+			amount NUMERIC NOT NULL,
+			fee NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			message TEXT,
+			card_token_id INTEGER,
+			qr_payload TEXT,
+			payment_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE billing_adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT NOT NULL UNIQUE,
+			billing_id INTEGER NOT NULL,
+			type TEXT NOT NULL CHECK (type IN ('credit', 'debit')),
+			amount NUMERIC NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed'
+		);
+
+		CREATE TABLE billing_installments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			seq INTEGER NOT NULL,
+			amount NUMERIC NOT NULL,
+			paid_amount NUMERIC NOT NULL DEFAULT 0,
+			due_date DATE NOT NULL,
+			status TEXT NOT NULL DEFAULT 'open'
+		);
+
+		CREATE TABLE payment_allocations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			payment_id INTEGER NOT NULL,
+			installment_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL
+		);
+
+		CREATE TABLE stock_reservations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'reserved'
+		);
+
+		CREATE TABLE shipments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL UNIQUE,
+			status TEXT NOT NULL DEFAULT 'picking',
+			created_by INTEGER
+		);
+
+		CREATE TABLE shipment_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			shipment_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			note TEXT,
+			created_by INTEGER
+		);
+
+		CREATE TABLE customer_credits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			billing_id INTEGER,
+			payment_id INTEGER,
+			type TEXT NOT NULL CHECK (type IN ('overpayment', 'applied', 'credit_note', 'expired_billing')),
+			amount NUMERIC NOT NULL CHECK (amount <> 0),
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema saldo kredit")
+	addPaymentMethodsTable(t, db)
+
+	return db
+}
+
+// TestCreatePayment_OverpaymentPolicy menguji penolakan, penerimaan sisa tagihan, dan penyimpanan kelebihan bayar.
+func TestCreatePayment_OverpaymentPolicy(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	paymentHandler := &PaymentHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+	creditHandler := &CreditHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+
+	// Default: kelebihan bayar ditolak di Go dengan sisa tagihan yang jelas
+	err := paymentHandler.CreatePayment(billingHandler, billing, entity.NewMoney(150000), entity.MethodVA)
+	var overpayment *OverpaymentError
+	require.True(t, errors.As(err, &overpayment))
+	assert.Equal(t, entity.NewMoney(100000), overpayment.Remaining)
+	assert.Equal(t, entity.NewMoney(50000), overpayment.Excess)
+
+	// Metode store_credit tidak bisa dipakai langsung
+	assert.Error(t, paymentHandler.CreatePayment(billingHandler, billing, entity.NewMoney(1000), entity.MethodStoreCredit))
+
+	// Bayar 60000, lalu 70000 dengan kelebihan 30000 disimpan sebagai saldo kredit
+	require.NoError(t, paymentHandler.CreatePayment(billingHandler, billing, entity.NewMoney(60000), entity.MethodVA))
+	require.NoError(t, paymentHandler.CreatePaymentWithPolicy(billingHandler, billing, entity.NewMoney(70000), entity.MethodVA, OverpaymentStoreCredit))
+
+	var paid entity.Money
+	var status string
+	require.NoError(t, db.QueryRow("SELECT SUM(amount) FROM payments WHERE billing_id = 1").Scan(&paid))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	assert.Equal(t, entity.NewMoney(100000), paid)
+	assert.Equal(t, string(entity.StatusPaid), status)
+
+	balance, err := creditHandler.GetMyCreditBalance()
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(30000), balance)
+
+	history, err := creditHandler.GetMyCreditHistory()
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, entity.CreditOverpayment, history[0].Type)
+	assert.Equal(t, 1, history[0].BillingID)
+
+	// Billing yang sudah lunas tidak menerima pembayaran lagi meski policy menerima sisa tagihan
+	err = paymentHandler.CreatePaymentWithPolicy(billingHandler, billing, entity.NewMoney(1000), entity.MethodVA, OverpaymentAcceptRemainder)
+	assert.True(t, errors.As(err, &overpayment))
+}
+
+// TestCreatePayment_AfterRefund memastikan billing lesspaid karena refund sebagian bisa dilunasi lagi,
+// karena sisa tagihan dihitung dari dana bersih (pembayaran dikurangi refund).
+func TestCreatePayment_AfterRefund(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	// BIL-001 sempat lunas 100000, lalu 30000 direfund
+	_, err := db.Exec(`
+		INSERT INTO payments (billing_id, amount, method, created_by) VALUES (1, 100000, 'va', 1);
+		INSERT INTO refunds (billing_id, amount) VALUES (1, 30000);
+		UPDATE billings SET status = 'lesspaid' WHERE id = 1;
+		INSERT INTO customer_credits (customer_id, type, amount, created_by) VALUES (1, 'overpayment', 10000, 1);
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	paymentHandler := &PaymentHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+	creditHandler := &CreditHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+
+	// Sisa tagihan adalah dana yang direfund, bukan 0
+	err = paymentHandler.CreatePayment(billingHandler, billing, entity.NewMoney(40000), entity.MethodVA)
+	var overpayment *OverpaymentError
+	require.True(t, errors.As(err, &overpayment))
+	assert.Equal(t, entity.NewMoney(30000), overpayment.Remaining)
+
+	require.NoError(t, paymentHandler.CreatePayment(billingHandler, billing, entity.NewMoney(20000), entity.MethodVA))
+	require.NoError(t, creditHandler.ApplyStoreCredit(billingHandler, billing, entity.NewMoney(10000)))
+
+	var status string
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	assert.Equal(t, string(entity.StatusPaid), status)
+
+	remaining, err := billingRemaining(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), remaining)
+}
+
+// TestApplyStoreCredit menguji pemakaian saldo kredit untuk membayar billing lain.
+func TestApplyStoreCredit(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO orders (customer_id) VALUES (1);
+		INSERT INTO billings (order_id, number_display, total) VALUES (3, 'BIL-003', 40000);
+		INSERT INTO customer_credits (customer_id, type, amount, created_by) VALUES (1, 'overpayment', 25000, 1);
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+	creditHandler := &CreditHandler{DB: db, Ctx: &ctx}
+
+	dueDate := time.Now().Add(time.Hour)
+
+	// Billing milik customer lain ditolak
+	assert.Error(t, creditHandler.ApplyStoreCredit(billingHandler, entity.Billing{ID: 2, DueDate: dueDate}, entity.NewMoney(10000)))
+
+	billing := entity.Billing{ID: 3, NumberDisplay: "BIL-003", DueDate: dueDate}
+
+	// Nominal melebihi saldo ditolak
+	assert.Error(t, creditHandler.ApplyStoreCredit(billingHandler, billing, entity.NewMoney(30000)))
+
+	require.NoError(t, creditHandler.ApplyStoreCredit(billingHandler, billing, entity.NewMoney(25000)))
+
+	balance, err := creditHandler.GetMyCreditBalance()
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), balance)
+
+	var method, status string
+	require.NoError(t, db.QueryRow("SELECT method FROM payments WHERE billing_id = 3").Scan(&method))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 3").Scan(&status))
+	assert.Equal(t, string(entity.MethodStoreCredit), method)
+	assert.Equal(t, string(entity.StatusLesspaid), status)
+
+	// Sisa tagihan 15000: bayar 20000 dengan AcceptRemainder hanya menerima 15000
+	require.NoError(t, (&PaymentHandler{DB: db, Ctx: &ctx}).CreatePaymentWithPolicy(billingHandler, billing, entity.NewMoney(20000), entity.MethodTransfer, OverpaymentAcceptRemainder))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 3").Scan(&status))
+	assert.Equal(t, string(entity.StatusPaid), status)
+
+	balance, err = creditHandler.GetMyCreditBalance()
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), balance, "AcceptRemainder tidak menyimpan kelebihan sebagai saldo")
+}
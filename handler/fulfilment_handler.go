@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+)
+
+// FulfilmentHandler menangani proses pengiriman order yang sudah lunas,
+// mulai dari picking sampai diterima customer
+type FulfilmentHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// createShipmentTx membuat shipment baru berstatus picking untuk order yang sudah lunas.
+// Dipanggil di dalam transaksi pembayaran sehingga shipment hanya tercatat jika order benar-benar completed.
+func createShipmentTx(tx *sql.Tx, orderID int, userID int) error {
+	// Order yang sudah punya shipment tidak perlu dibuatkan lagi
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM shipments WHERE order_id = ?", orderID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan mengambil shipment: %s", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	res, err := tx.Exec(
+		"INSERT INTO shipments (order_id, status, created_by) VALUES (?, ?, ?)",
+		orderID, string(entity.ShipmentPicking), nullInt(userID),
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan membuat shipment")
+	}
+
+	shipmentID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO shipment_events (shipment_id, status, note, created_by) VALUES (?, ?, ?, ?)",
+		shipmentID, string(entity.ShipmentPicking), "Pembayaran diterima, pesanan sedang disiapkan", nullInt(userID),
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mencatat riwayat shipment")
+	}
+
+	return nil
+}
+
+// GetShipments mengambil daftar shipment untuk staff, bisa difilter berdasarkan status (kosong = semua)
+func (f *FulfilmentHandler) GetShipments(status entity.StatusShipment) ([]entity.Shipment, error) {
+	if _, ok := utils.GetUser(*f.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	query := `
+		SELECT s.id, s.order_id, o.number_display, c.name, IFNULL(o.shipping_address, ''),
+			s.status, IFNULL(s.courier, ''), IFNULL(s.tracking_number, '')
+		FROM shipments s
+		JOIN orders o ON o.id = s.order_id
+		JOIN customers c ON c.id = o.customer_id
+	`
+	var args []interface{}
+	if status != "" {
+		query += " WHERE s.status = ?"
+		args = append(args, string(status))
+	}
+	query += " ORDER BY s.id ASC"
+
+	rows, err := f.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil shipment: %w", err)
+	}
+	defer rows.Close()
+
+	var shipments []entity.Shipment
+	for rows.Next() {
+		var s entity.Shipment
+		err := rows.Scan(&s.ID, &s.OrderID, &s.OrderNumber, &s.CustomerName, &s.ShippingAddress, &s.Status, &s.Courier, &s.TrackingNumber)
+		if err != nil {
+			return nil, err
+		}
+		shipments = append(shipments, s)
+	}
+
+	return shipments, rows.Err()
+}
+
+// AdvanceShipment memindahkan shipment ke tahap berikutnya.
+// Kurir dan nomor resi wajib diisi saat shipment masuk tahap shipped.
+func (f *FulfilmentHandler) AdvanceShipment(orderNumber string, courier string, trackingNumber string, note string) (entity.Shipment, error) {
+	var shipment entity.Shipment
+
+	user, ok := utils.GetUser(*f.Ctx)
+	if !ok {
+		return shipment, fmt.Errorf("Please Login!")
+	}
+
+	tx, err := f.DB.Begin()
+	if err != nil {
+		return shipment, err
+	}
+
+	// Ambil shipment berdasarkan nomor order
+	err = tx.QueryRow(`
+		SELECT s.id, s.order_id, o.number_display, s.status, IFNULL(s.courier, ''), IFNULL(s.tracking_number, '')
+		FROM shipments s
+		JOIN orders o ON o.id = s.order_id
+		WHERE o.number_display = ?
+	`, orderNumber).Scan(&shipment.ID, &shipment.OrderID, &shipment.OrderNumber, &shipment.Status, &shipment.Courier, &shipment.TrackingNumber)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return shipment, errors.New("Shipment untuk order tersebut tidak ditemukan")
+		}
+		return shipment, fmt.Errorf("Terjadi kesalahan mengambil shipment: %s", err)
+	}
+
+	// Tentukan tahap berikutnya sesuai urutan fulfilment
+	next, err := nextShipmentStatus(shipment.Status)
+	if err != nil {
+		tx.Rollback()
+		return shipment, err
+	}
+
+	if next == entity.ShipmentShipped {
+		courier = strings.TrimSpace(courier)
+		trackingNumber = strings.TrimSpace(trackingNumber)
+		if courier == "" || trackingNumber == "" {
+			tx.Rollback()
+			return shipment, errors.New("Kurir dan nomor resi wajib diisi saat order dikirim")
+		}
+		shipment.Courier = courier
+		shipment.TrackingNumber = trackingNumber
+	}
+
+	_, err = tx.Exec(
+		"UPDATE shipments SET status = ?, courier = ?, tracking_number = ?, updated_by = ? WHERE id = ?",
+		string(next), nullString(shipment.Courier), nullString(shipment.TrackingNumber), user.ID, shipment.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return shipment, errors.New("Terjadi kesalahan mengubah status shipment")
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO shipment_events (shipment_id, status, note, created_by) VALUES (?, ?, ?, ?)",
+		shipment.ID, string(next), strings.TrimSpace(note), user.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return shipment, errors.New("Terjadi kesalahan mencatat riwayat shipment")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return shipment, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	shipment.Status = next
+	shipment.UpdatedBy = user.ID
+	return shipment, nil
+}
+
+// TrackOrder mengambil status pengiriman beserta timeline untuk order milik customer yang sedang login
+func (f *FulfilmentHandler) TrackOrder(orderNumber string) (entity.Shipment, error) {
+	var shipment entity.Shipment
+
+	user, ok := utils.GetUser(*f.Ctx)
+	if !ok {
+		return shipment, fmt.Errorf("Please Login!")
+	}
+
+	err := f.DB.QueryRow(`
+		SELECT s.id, s.order_id, o.number_display, IFNULL(o.shipping_address, ''),
+			s.status, IFNULL(s.courier, ''), IFNULL(s.tracking_number, '')
+		FROM shipments s
+		JOIN orders o ON o.id = s.order_id
+		WHERE o.number_display = ? AND o.customer_id = ?
+	`, orderNumber, user.Customer.ID).Scan(
+		&shipment.ID,
+		&shipment.OrderID,
+		&shipment.OrderNumber,
+		&shipment.ShippingAddress,
+		&shipment.Status,
+		&shipment.Courier,
+		&shipment.TrackingNumber,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return shipment, errors.New("Order belum diproses untuk pengiriman atau tidak ditemukan")
+		}
+		return shipment, fmt.Errorf("Terjadi kesalahan mengambil shipment: %s", err)
+	}
+
+	// Ambil timeline pengiriman dari yang paling awal
+	rows, err := f.DB.Query(`
+		SELECT id, shipment_id, status, IFNULL(note, ''), created_at
+		FROM shipment_events
+		WHERE shipment_id = ?
+		ORDER BY id ASC
+	`, shipment.ID)
+	if err != nil {
+		return shipment, fmt.Errorf("Terjadi kesalahan mengambil riwayat shipment: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event entity.ShipmentEvent
+		if err := rows.Scan(&event.ID, &event.ShipmentID, &event.Status, &event.Note, &event.CreatedAt); err != nil {
+			return shipment, err
+		}
+		shipment.Events = append(shipment.Events, event)
+	}
+
+	return shipment, rows.Err()
+}
+
+// nextShipmentStatus mengembalikan tahap setelah status saat ini berdasarkan entity.ShipmentFlow
+func nextShipmentStatus(current entity.StatusShipment) (entity.StatusShipment, error) {
+	for i, status := range entity.ShipmentFlow {
+		if status != current {
+			continue
+		}
+		if i == len(entity.ShipmentFlow)-1 {
+			return "", errors.New("Order sudah diterima customer")
+		}
+		return entity.ShipmentFlow[i+1], nil
+	}
+
+	return "", fmt.Errorf("Status shipment tidak valid: %s", current)
+}
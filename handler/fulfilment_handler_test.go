@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestFulfilmentDB membuat database in-memory SQLite berisi order yang sudah lunas dan tabel shipment.
+func SetupTestFulfilmentDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	// Satu koneksi agar semua query melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE customers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL
+		);
+
+		INSERT INTO customers (name) VALUES ('John Doe'), ('Jane Smith');
+
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT,
+			customer_id INTEGER,
+			status TEXT DEFAULT 'processing',
+			shipping_address TEXT
+		);
+
+		INSERT INTO orders (number_display, customer_id, status, shipping_address) VALUES
+		('ORD-001', 1, 'completed', 'John Doe (081234567890) - Jl. Merdeka No. 123, Jakarta 10110'),
+		('ORD-002', 2, 'completed', 'Jane Smith (089876543210) - Jl. Sudirman No. 10, Bandung 40111');
+
+		CREATE TABLE shipments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL UNIQUE,
+			status TEXT NOT NULL DEFAULT 'picking',
+			courier TEXT,
+			tracking_number TEXT,
+			created_by INTEGER,
+			updated_by INTEGER
+		);
+
+		CREATE TABLE shipment_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			shipment_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema fulfilment")
+
+	// Buat shipment untuk kedua order seperti setelah pembayaran lunas
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, createShipmentTx(tx, 1, 0))
+	require.NoError(t, createShipmentTx(tx, 2, 0))
+	// Pemanggilan ulang tidak boleh membuat shipment ganda
+	require.NoError(t, createShipmentTx(tx, 1, 0))
+	require.NoError(t, tx.Commit())
+
+	return db
+}
+
+// TestAdvanceShipment menguji perpindahan tahap fulfilment sampai order diterima.
+func TestAdvanceShipment(t *testing.T) {
+	db := SetupTestFulfilmentDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &FulfilmentHandler{DB: db, Ctx: &ctx}
+
+	shipments, err := handler.GetShipments(entity.ShipmentPicking)
+	require.NoError(t, err)
+	assert.Len(t, shipments, 2)
+
+	shipment, err := handler.AdvanceShipment("ORD-001", "", "", "Barang diambil dari gudang")
+	require.NoError(t, err)
+	assert.Equal(t, entity.ShipmentPacking, shipment.Status)
+
+	// Kurir dan resi wajib diisi saat dikirim
+	_, err = handler.AdvanceShipment("ORD-001", "", "", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resi")
+
+	shipment, err = handler.AdvanceShipment("ORD-001", "JNE", "JNE123456", "")
+	require.NoError(t, err)
+	assert.Equal(t, entity.ShipmentShipped, shipment.Status)
+
+	shipment, err = handler.AdvanceShipment("ORD-001", "", "", "Diterima oleh satpam")
+	require.NoError(t, err)
+	assert.Equal(t, entity.ShipmentDelivered, shipment.Status)
+	assert.Equal(t, "JNE123456", shipment.TrackingNumber)
+
+	// Order yang sudah diterima tidak bisa dimajukan lagi
+	_, err = handler.AdvanceShipment("ORD-001", "", "", "")
+	assert.Error(t, err)
+
+	// Order tanpa shipment
+	_, err = handler.AdvanceShipment("ORD-999", "", "", "")
+	assert.Error(t, err)
+}
+
+// TestTrackOrder menguji tampilan timeline pengiriman untuk customer.
+func TestTrackOrder(t *testing.T) {
+	db := SetupTestFulfilmentDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &FulfilmentHandler{DB: db, Ctx: &ctx}
+
+	_, err := handler.AdvanceShipment("ORD-001", "", "", "")
+	require.NoError(t, err)
+	_, err = handler.AdvanceShipment("ORD-001", "SiCepat", "SC0001", "")
+	require.NoError(t, err)
+
+	shipment, err := handler.TrackOrder("ORD-001")
+	require.NoError(t, err)
+	assert.Equal(t, entity.ShipmentShipped, shipment.Status)
+	assert.Equal(t, "SiCepat", shipment.Courier)
+	require.Len(t, shipment.Events, 3)
+	assert.Equal(t, entity.ShipmentPicking, shipment.Events[0].Status)
+	assert.Equal(t, entity.ShipmentShipped, shipment.Events[2].Status)
+
+	// Order milik customer lain tidak bisa dilacak
+	_, err = handler.TrackOrder("ORD-002")
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"time"
+)
+
+// Operasi yang mendukung idempotency key, lihat utils.WithIdempotencyKey
+const (
+	idempotencyCreateOrder   = "create_order"
+	idempotencyCreatePayment = "create_payment"
+	idempotencyCheckout      = "checkout"
+)
+
+var (
+	// ErrIdempotencyConflict dikembalikan jika idempotency key yang sama dipakai untuk permintaan dengan isi berbeda
+	ErrIdempotencyConflict = errors.New("Idempotency key sudah dipakai untuk permintaan yang berbeda")
+	// ErrIdempotencyInProgress dikembalikan jika permintaan pertama dengan key yang sama belum selesai diproses
+	ErrIdempotencyInProgress = errors.New("Permintaan dengan idempotency key ini masih diproses")
+)
+
+// requestHash menghitung sha256 dari isi permintaan agar key yang dipakai ulang untuk permintaan lain bisa dikenali
+func requestHash(request interface{}) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// findIdempotencyKey mengambil key yang masih berlaku. Key yang sudah melewati entity.IdempotencyWindow, atau masih
+// pending melewati entity.IdempotencyPendingTimeout, dihapus sehingga key yang sama diperlakukan sebagai permintaan baru.
+// Mengembalikan false jika key belum pernah dipakai.
+func findIdempotencyKey(q queryer, userID int, operation, key string) (entity.IdempotencyKey, bool, error) {
+	record := entity.IdempotencyKey{Key: key, UserID: userID, Operation: operation}
+
+	var resourceID sql.NullInt64
+	var response sql.NullString
+	err := q.QueryRow(
+		"SELECT id, request_hash, resource_id, response, status, created_at, expires_at FROM idempotency_keys WHERE user_id = ? AND operation = ? AND idem_key = ?",
+		userID, operation, key,
+	).Scan(&record.ID, &record.RequestHash, &resourceID, &response, &record.Status, &record.CreatedAt, &record.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return record, false, nil
+	}
+	if err != nil {
+		return record, false, fmt.Errorf("Terjadi kesalahan mengambil idempotency key: %s", err)
+	}
+	record.ResourceID, record.Response = int(resourceID.Int64), response.String
+
+	if record.Expired(time.Now()) {
+		if _, err := q.Exec("DELETE FROM idempotency_keys WHERE id = ?", record.ID); err != nil {
+			return record, false, fmt.Errorf("Gagal menghapus idempotency key kedaluwarsa: %s", err)
+		}
+		return entity.IdempotencyKey{Key: key, UserID: userID, Operation: operation}, false, nil
+	}
+
+	// Permintaan pertama tidak pernah selesai (misal proses berhenti), key diambil alih
+	if record.Stale(time.Now()) {
+		res, err := q.Exec("DELETE FROM idempotency_keys WHERE id = ? AND status = ?", record.ID, string(entity.IdempotencyPending))
+		if err != nil {
+			return record, false, fmt.Errorf("Gagal menghapus idempotency key pending: %s", err)
+		}
+		// Permintaan pertama selesai tepat sebelum dihapus, baca ulang hasilnya
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return findIdempotencyKey(q, userID, operation, key)
+		}
+		return entity.IdempotencyKey{Key: key, UserID: userID, Operation: operation}, false, nil
+	}
+
+	return record, true, nil
+}
+
+// checkIdempotencyKey memastikan key yang sudah ada bisa dipakai untuk mengembalikan hasil sebelumnya:
+// isi permintaan harus sama dan permintaan pertama harus sudah selesai.
+func checkIdempotencyKey(record entity.IdempotencyKey, hash string) error {
+	if record.RequestHash != hash {
+		return ErrIdempotencyConflict
+	}
+	if record.Status != entity.IdempotencyCompleted {
+		return ErrIdempotencyInProgress
+	}
+	return nil
+}
+
+// insertIdempotencyKey menyimpan key baru dengan status status; constraint unik (user_id, operation, idem_key)
+// menolak permintaan kedua yang datang bersamaan.
+func insertIdempotencyKey(q queryer, record entity.IdempotencyKey) (int, error) {
+	// created_at diisi dari jam aplikasi agar sebanding dengan pengecekan Stale
+	now := time.Now()
+	res, err := q.Exec(
+		"INSERT INTO idempotency_keys (idem_key, user_id, operation, request_hash, resource_id, response, status, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		record.Key, record.UserID, record.Operation, record.RequestHash, nullInt(record.ResourceID), nullString(record.Response),
+		string(record.Status), now, now.Add(entity.IdempotencyWindow),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// completeIdempotencyKey menyimpan hasil permintaan pertama agar dikembalikan untuk permintaan ulang
+func completeIdempotencyKey(q queryer, id int, resourceID int, response interface{}) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		"UPDATE idempotency_keys SET resource_id = ?, response = ?, status = ? WHERE id = ?",
+		nullInt(resourceID), string(b), string(entity.IdempotencyCompleted), id,
+	)
+	if err != nil {
+		return fmt.Errorf("Gagal menyimpan hasil idempotency key: %s", err)
+	}
+	return nil
+}
+
+// releaseIdempotencyKey menghapus key pending jika permintaan gagal sebelum menulis data,
+// sehingga key yang sama bisa dipakai lagi setelah kesalahan diperbaiki
+func releaseIdempotencyKey(q queryer, id int) error {
+	_, err := q.Exec("DELETE FROM idempotency_keys WHERE id = ? AND status = ?", id, string(entity.IdempotencyPending))
+	return err
+}
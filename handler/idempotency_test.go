@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addIdempotencyTable menambahkan tabel idempotency_keys ke database test
+func addIdempotencyTable(t *testing.T, db *sql.DB) {
+	_, err := db.Exec(`
+		CREATE TABLE idempotency_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			idem_key TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			operation TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			resource_id INTEGER,
+			response TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			UNIQUE (user_id, operation, idem_key)
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat tabel idempotency_keys")
+}
+
+// TestCreateOrder_Idempotent menguji order yang dikirim ulang dengan key yang sama tidak dibuat dua kali.
+func TestCreateOrder_Idempotent(t *testing.T) {
+	db := SetupTestOrderDB(t)
+	defer db.Close()
+	addIdempotencyTable(t, db)
+
+	ctx := utils.WithIdempotencyKey(utils.NewTestContextWithUser(), "order-key-1")
+	handler := &OrderHandler{DB: db, Ctx: &ctx}
+
+	products := []entity.OrderProduct{{ProductId: 1, Qty: 2}}
+	first, err := handler.CreateOrder(products)
+	require.NoError(t, err)
+
+	// Permintaan ulang mengembalikan order yang sama
+	second, err := handler.CreateOrder(products)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.NumberDisplay, second.NumberDisplay)
+
+	var orders int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&orders))
+	assert.Equal(t, 2, orders, "Hanya satu order baru di samping data awal")
+
+	// Key yang sama untuk isi berbeda ditolak
+	_, err = handler.CreateOrder([]entity.OrderProduct{{ProductId: 2, Qty: 1}})
+	assert.ErrorIs(t, err, ErrIdempotencyConflict)
+
+	// Key kedaluwarsa diperlakukan sebagai permintaan baru
+	_, err = db.Exec("UPDATE idempotency_keys SET expires_at = ?", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	third, err := handler.CreateOrder(products)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, third.ID)
+
+	// Tanpa key setiap permintaan membuat order baru
+	plain := utils.NewTestContextWithUser()
+	handler.Ctx = &plain
+	_, err = handler.CreateOrder(products)
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&orders))
+	assert.Equal(t, 4, orders)
+}
+
+// TestCreatePayment_Idempotent menguji pembayaran yang dikirim ulang tidak menagih gateway dua kali.
+func TestCreatePayment_Idempotent(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+	addIdempotencyTable(t, db)
+
+	ctx := utils.WithIdempotencyKey(utils.NewTestContextWithUser(), "payment-key-1")
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: gateway.NewSimulator(gateway.ModeApprove)}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+
+	// Kelebihan bayar ditolak tanpa menagih, key bisa dipakai lagi dengan policy lain
+	err := handler.CreatePayment(billingHandler, billing, entity.NewMoney(150000), entity.MethodVA)
+	var overpayment *OverpaymentError
+	require.True(t, errors.As(err, &overpayment))
+
+	require.NoError(t, handler.CreatePaymentWithPolicy(billingHandler, billing, entity.NewMoney(150000), entity.MethodVA, OverpaymentAcceptRemainder))
+	require.NoError(t, handler.CreatePaymentWithPolicy(billingHandler, billing, entity.NewMoney(150000), entity.MethodVA, OverpaymentAcceptRemainder))
+
+	var payments, charges int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payments").Scan(&payments))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_charges").Scan(&charges))
+	assert.Equal(t, 1, payments)
+	assert.Equal(t, 1, charges)
+
+	// Key yang sama untuk nominal berbeda ditolak
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(1000), entity.MethodVA)
+	assert.ErrorIs(t, err, ErrIdempotencyConflict)
+
+	// Charge yang ditolak gateway mengembalikan penolakan yang sama saat diulang
+	declineCtx := utils.WithIdempotencyKey(utils.NewTestContextWithUser(), "payment-key-2")
+	handler.Ctx, handler.Gateway = &declineCtx, gateway.NewSimulator(gateway.ModeDecline)
+	billing = entity.Billing{ID: 2, NumberDisplay: "BIL-002", DueDate: time.Now().Add(time.Hour)}
+	for i := 0; i < 2; i++ {
+		err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(10000), entity.MethodVA)
+		var declined *PaymentDeclinedError
+		require.True(t, errors.As(err, &declined))
+	}
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_charges").Scan(&charges))
+	assert.Equal(t, 2, charges)
+}
+
+// TestCreatePayment_IdempotentInProgress menguji permintaan kedua saat permintaan pertama belum selesai.
+func TestCreatePayment_IdempotentInProgress(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+	addIdempotencyTable(t, db)
+
+	ctx := utils.WithIdempotencyKey(context.Background(), "payment-key-3")
+	ctx = utils.WithUser(ctx, &entity.User{ID: 1, Customer: entity.Customer{ID: 1}})
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: gateway.NewSimulator(gateway.ModeApprove)}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	request := paymentRequest{BillingID: 1, Amount: entity.NewMoney(10000), Method: entity.MethodVA}
+	hash, err := requestHash(request)
+	require.NoError(t, err)
+	_, err = insertIdempotencyKey(db, entity.IdempotencyKey{Key: "payment-key-3", UserID: 1, Operation: idempotencyCreatePayment, RequestHash: hash, Status: entity.IdempotencyPending})
+	require.NoError(t, err)
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(10000), entity.MethodVA)
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+
+	// Permintaan pertama berhenti di tengah jalan: setelah timeout key diambil alih dan pembayaran diproses
+	_, err = db.Exec("UPDATE idempotency_keys SET created_at = ?", time.Now().Add(-entity.IdempotencyPendingTimeout-time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, handler.CreatePayment(billingHandler, billing, entity.NewMoney(10000), entity.MethodVA))
+
+	var keys, charges int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM idempotency_keys WHERE status = 'completed'").Scan(&keys))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_charges").Scan(&charges))
+	assert.Equal(t, 1, keys)
+	assert.Equal(t, 1, charges)
+}
+
+// TestCheckout_Idempotent menguji checkout yang dikirim ulang dengan key yang sama tidak membuat order dua kali.
+func TestCheckout_Idempotent(t *testing.T) {
+	db := SetupTestCartDB(t)
+	defer db.Close()
+	addIdempotencyTable(t, db)
+
+	ctx := utils.WithIdempotencyKey(utils.NewTestContextWithUser(), "checkout-key-1")
+	handler := &CartHandler{DB: db, Ctx: &ctx}
+	require.NoError(t, handler.AddItem(1, 2))
+
+	first, err := handler.Checkout(1)
+	require.NoError(t, err)
+
+	// Cart sudah kosong, permintaan ulang tetap mengembalikan order pertama
+	second, err := handler.Checkout(1)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.NumberDisplay, second.NumberDisplay)
+
+	var orders int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&orders))
+	assert.Equal(t, 1, orders)
+
+	// Key yang sama untuk alamat lain ditolak
+	_, err = handler.Checkout(2)
+	assert.ErrorIs(t, err, ErrIdempotencyConflict)
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"time"
+)
+
+// maxInstallments adalah batas jumlah cicilan dalam satu rencana pembayaran
+const maxInstallments = 12
+
+// InstallmentHandler mengelola rencana cicilan billing dan alokasi pembayaran ke setiap cicilan
+type InstallmentHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// CreatePaymentPlan membagi sisa tagihan billing menjadi beberapa cicilan dengan jatuh tempo masing-masing (khusus admin).
+// Sisa pembagian (sen) dibebankan ke cicilan pertama. Jatuh tempo billing ikut dimundurkan ke cicilan terakhir.
+func (h *InstallmentHandler) CreatePaymentPlan(billNumber string, dueDates []time.Time) ([]entity.Installment, error) {
+	user, ok := utils.GetUser(*h.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	if len(dueDates) < 2 || len(dueDates) > maxInstallments {
+		return nil, fmt.Errorf("Jumlah cicilan harus antara 2 sampai %d", maxInstallments)
+	}
+	today := time.Now().Format("2006-01-02")
+	if dueDates[0].Format("2006-01-02") < today {
+		return nil, errors.New("Jatuh tempo cicilan pertama tidak boleh sebelum hari ini")
+	}
+	for i := 1; i < len(dueDates); i++ {
+		if dueDates[i].Format("2006-01-02") <= dueDates[i-1].Format("2006-01-02") {
+			return nil, errors.New("Jatuh tempo cicilan harus berurutan")
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ambil billing (total setelah credit note / debit note) beserta dana bersih yang sudah masuk
+	var billingID int
+	var status entity.StatusBilling
+	var total, paid, refunded entity.Money
+	err = tx.QueryRow(`
+		SELECT id, status,
+			total + (SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = billings.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = billings.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = billings.id AND status <> 'failed')
+		FROM billings
+		WHERE number_display = ?
+	`, billNumber).Scan(&billingID, &status, &total, &paid, &refunded)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Billing tidak ditemukan")
+		}
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+	if status != entity.StatusUnpaid && status != entity.StatusLesspaid {
+		tx.Rollback()
+		return nil, errors.New("Rencana cicilan hanya bisa dibuat untuk billing unpaid atau lesspaid")
+	}
+
+	var existing int
+	err = tx.QueryRow("SELECT COUNT(*) FROM billing_installments WHERE billing_id = ?", billingID).Scan(&existing)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil cicilan: %s", err)
+	}
+	if existing > 0 {
+		tx.Rollback()
+		return nil, errors.New("Billing sudah memiliki rencana cicilan")
+	}
+
+	outstanding := total - (paid - refunded)
+	if outstanding < entity.Money(len(dueDates)) {
+		tx.Rollback()
+		return nil, errors.New("Sisa tagihan terlalu kecil untuk dicicil")
+	}
+
+	// Bagi rata, sisa pembagian masuk ke cicilan pertama
+	base := outstanding / entity.Money(len(dueDates))
+	remainder := outstanding - base*entity.Money(len(dueDates))
+
+	var installments []entity.Installment
+	for i, dueDate := range dueDates {
+		installment := entity.Installment{
+			BillingID: billingID,
+			Seq:       i + 1,
+			Amount:    base,
+			DueDate:   dueDate,
+			Status:    entity.InstallmentOpen,
+			CreatedBy: user.ID,
+		}
+		if i == 0 {
+			installment.Amount += remainder
+		}
+
+		res, err := tx.Exec(
+			"INSERT INTO billing_installments (billing_id, seq, amount, due_date, status, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+			billingID, installment.Seq, installment.Amount, dueDate.Format("2006-01-02"), string(installment.Status), user.ID,
+		)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.New("Terjadi kesalahan menyimpan cicilan")
+		}
+
+		installmentID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		installment.ID = int(installmentID)
+		installments = append(installments, installment)
+	}
+
+	// Billing berlaku sampai akhir hari jatuh tempo cicilan terakhir
+	last := dueDates[len(dueDates)-1]
+	billingDue := time.Date(last.Year(), last.Month(), last.Day(), 23, 59, 59, 0, last.Location())
+	_, err = tx.Exec("UPDATE billings SET due_date = ?, updated_by = ? WHERE id = ?", billingDue, user.ID, billingID)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("Terjadi kesalahan mengubah jatuh tempo billing")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return installments, nil
+}
+
+// GetInstallments mengambil rencana cicilan sebuah billing berdasarkan nomor billing
+func (h *InstallmentHandler) GetInstallments(billNumber string) ([]entity.Installment, error) {
+	if _, ok := utils.GetUser(*h.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	var billingID int
+	err := h.DB.QueryRow("SELECT id FROM billings WHERE number_display = ?", billNumber).Scan(&billingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Billing tidak ditemukan")
+		}
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	return getInstallments(h.DB, billingID)
+}
+
+// getInstallments mengambil cicilan sebuah billing urut dari cicilan pertama
+func getInstallments(q queryer, billingID int) ([]entity.Installment, error) {
+	rows, err := q.Query(`
+		SELECT id, billing_id, seq, amount, paid_amount, due_date, status, created_by
+		FROM billing_installments
+		WHERE billing_id = ?
+		ORDER BY seq ASC
+	`, billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil cicilan: %w", err)
+	}
+	defer rows.Close()
+
+	var installments []entity.Installment
+	for rows.Next() {
+		var installment entity.Installment
+		err := rows.Scan(
+			&installment.ID,
+			&installment.BillingID,
+			&installment.Seq,
+			&installment.Amount,
+			&installment.PaidAmount,
+			&installment.DueDate,
+			&installment.Status,
+			&installment.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		installments = append(installments, installment)
+	}
+
+	return installments, rows.Err()
+}
+
+// allocatePaymentTx membagikan pembayaran ke cicilan tertua yang belum lunas.
+// Billing tanpa rencana cicilan tidak dialokasikan. Mengembalikan sisa pembayaran yang tidak teralokasi.
+func allocatePaymentTx(tx *sql.Tx, billingID int, paymentID int, amount entity.Money) (entity.Money, error) {
+	rows, err := tx.Query(`
+		SELECT id, amount, paid_amount
+		FROM billing_installments
+		WHERE billing_id = ? AND status != ?
+		ORDER BY seq ASC
+	`, billingID, string(entity.InstallmentPaid))
+	if err != nil {
+		return amount, fmt.Errorf("Terjadi kesalahan mengambil cicilan: %w", err)
+	}
+
+	var open []entity.Installment
+	for rows.Next() {
+		var installment entity.Installment
+		if err := rows.Scan(&installment.ID, &installment.Amount, &installment.PaidAmount); err != nil {
+			rows.Close()
+			return amount, err
+		}
+		open = append(open, installment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return amount, err
+	}
+
+	left := amount
+	for _, installment := range open {
+		if left <= 0 {
+			break
+		}
+
+		portion := left.Min(installment.Remaining())
+		installment.PaidAmount += portion
+		status := entity.InstallmentPartial
+		if installment.PaidAmount >= installment.Amount {
+			status = entity.InstallmentPaid
+		}
+
+		_, err := tx.Exec(
+			"UPDATE billing_installments SET paid_amount = ?, status = ? WHERE id = ?",
+			installment.PaidAmount, string(status), installment.ID,
+		)
+		if err != nil {
+			return left, errors.New("Terjadi kesalahan mengubah cicilan")
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO payment_allocations (payment_id, installment_id, amount) VALUES (?, ?, ?)",
+			paymentID, installment.ID, portion,
+		)
+		if err != nil {
+			return left, errors.New("Terjadi kesalahan menyimpan alokasi pembayaran")
+		}
+
+		left -= portion
+	}
+
+	return left, nil
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestInstallmentDB membuat database in-memory SQLite berisi billing unpaid yang sudah dibayar sebagian.
+func SetupTestInstallmentDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	// Satu koneksi agar semua query melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			number_display TEXT,
+			due_date TIMESTAMP,
+			total NUMERIC DEFAULT 0 NOT NULL,
+			status TEXT DEFAULT 'unpaid',
+			updated_by INTEGER
+		);
+
+		INSERT INTO billings (order_id, number_display, total, status) VALUES
+		(1, 'BIL-001', 1000000.01, 'lesspaid'),
+		(2, 'BIL-002', 500000, 'paid');
+
+		CREATE TABLE payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL DEFAULT 0
+		);
+
+		INSERT INTO payments (billing_id, amount) VALUES (1, 100000), (2, 500000);
+
+		CREATE TABLE card_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			brand TEXT NOT NULL,
+			last4 TEXT NOT NULL,
+			exp_month INTEGER NOT NULL,
+			exp_year INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE payment_charges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			charge_ref TEXT NOT NULL UNIQUE,
+			amount NUMERIC NOT NULL,
+			fee NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			message TEXT,
+			card_token_id INTEGER,
+			qr_payload TEXT,
+			payment_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE billing_adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT NOT NULL UNIQUE,
+			billing_id INTEGER NOT NULL,
+			type TEXT NOT NULL CHECK (type IN ('credit', 'debit')),
+			amount NUMERIC NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed'
+		);
+
+		CREATE TABLE billing_installments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			seq INTEGER NOT NULL,
+			amount NUMERIC NOT NULL,
+			paid_amount NUMERIC NOT NULL DEFAULT 0,
+			due_date DATE NOT NULL,
+			status TEXT NOT NULL DEFAULT 'open',
+			created_by INTEGER NOT NULL,
+			UNIQUE (billing_id, seq)
+		);
+
+		CREATE TABLE payment_allocations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			payment_id INTEGER NOT NULL,
+			installment_id INTEGER NOT NULL,
+			amount NUMERIC NOT NULL
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema cicilan")
+	addPaymentMethodsTable(t, db)
+
+	return db
+}
+
+// TestCreatePaymentPlan menguji pembagian sisa tagihan menjadi cicilan dan validasinya.
+func TestCreatePaymentPlan(t *testing.T) {
+	db := SetupTestInstallmentDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &InstallmentHandler{DB: db, Ctx: &ctx}
+
+	today := time.Now()
+	dueDates := []time.Time{today, today.AddDate(0, 1, 0), today.AddDate(0, 2, 0)}
+
+	// Validasi jumlah cicilan, urutan jatuh tempo, dan status billing
+	_, err := handler.CreatePaymentPlan("BIL-001", dueDates[:1])
+	assert.Error(t, err)
+	_, err = handler.CreatePaymentPlan("BIL-001", []time.Time{dueDates[1], dueDates[0]})
+	assert.Error(t, err)
+	_, err = handler.CreatePaymentPlan("BIL-002", dueDates)
+	assert.Error(t, err)
+
+	// Sisa tagihan 900000.01 dibagi tiga, sisa sen masuk cicilan pertama
+	installments, err := handler.CreatePaymentPlan("BIL-001", dueDates)
+	require.NoError(t, err)
+	require.Len(t, installments, 3)
+	assert.Equal(t, entity.Money(30000001), installments[0].Amount)
+	assert.Equal(t, entity.NewMoney(300000), installments[1].Amount)
+	assert.Equal(t, entity.NewMoney(300000), installments[2].Amount)
+
+	// Jatuh tempo billing dimundurkan ke cicilan terakhir
+	var dueDate time.Time
+	require.NoError(t, db.QueryRow("SELECT due_date FROM billings WHERE id = 1").Scan(&dueDate))
+	assert.Equal(t, dueDates[2].Format("2006-01-02"), dueDate.Format("2006-01-02"))
+
+	// Rencana cicilan hanya bisa dibuat sekali
+	_, err = handler.CreatePaymentPlan("BIL-001", dueDates)
+	assert.Error(t, err)
+}
+
+// TestAllocatePayment memastikan pembayaran dialokasikan ke cicilan tertua yang belum lunas.
+func TestAllocatePayment(t *testing.T) {
+	db := SetupTestInstallmentDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &InstallmentHandler{DB: db, Ctx: &ctx}
+
+	today := time.Now()
+	_, err := handler.CreatePaymentPlan("BIL-001", []time.Time{today, today.AddDate(0, 1, 0), today.AddDate(0, 2, 0)})
+	require.NoError(t, err)
+
+	allocate := func(paymentID int, amount entity.Money) entity.Money {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		left, err := allocatePaymentTx(tx, 1, paymentID, amount)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+		return left
+	}
+
+	// 400000 melunasi cicilan pertama lalu sebagian cicilan kedua
+	assert.Equal(t, entity.Money(0), allocate(10, entity.NewMoney(400000)))
+
+	installments, err := handler.GetInstallments("BIL-001")
+	require.NoError(t, err)
+	assert.Equal(t, entity.InstallmentPaid, installments[0].Status)
+	assert.Equal(t, entity.InstallmentPartial, installments[1].Status)
+	assert.Equal(t, entity.Money(9999999), installments[1].PaidAmount)
+	assert.Equal(t, entity.InstallmentOpen, installments[2].Status)
+
+	var allocations int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_allocations WHERE payment_id = 10").Scan(&allocations))
+	assert.Equal(t, 2, allocations)
+
+	// Kelebihan bayar dikembalikan sebagai sisa yang tidak teralokasi
+	assert.Equal(t, entity.Money(9999999), allocate(11, entity.NewMoney(600000)))
+
+	installments, err = handler.GetInstallments("BIL-001")
+	require.NoError(t, err)
+	for _, installment := range installments {
+		assert.Equal(t, entity.InstallmentPaid, installment.Status)
+		assert.False(t, installment.Overdue(today.AddDate(1, 0, 0)))
+	}
+
+	// Billing tanpa rencana cicilan tidak dialokasikan
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	left, err := allocatePaymentTx(tx, 2, 12, entity.NewMoney(50000))
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, entity.NewMoney(50000), left)
+}
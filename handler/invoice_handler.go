@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+)
+
+// InvoiceHandler menyiapkan data invoice / kuitansi sebuah billing untuk dicetak
+type InvoiceHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// GetInvoice mengambil data lengkap billing berdasarkan nomor billing: order, customer, baris produk,
+// pajak, credit note / debit note, nomor virtual account, pembayaran, refund, dan sisa tagihan. Selain admin hanya bisa mengambil billing miliknya sendiri.
+func (h *InvoiceHandler) GetInvoice(billNumber string) (entity.Invoice, error) {
+	var inv entity.Invoice
+
+	user, ok := utils.GetUser(*h.Ctx)
+	if !ok {
+		return inv, fmt.Errorf("Please Login!")
+	}
+
+	query := `
+		SELECT b.id, b.order_id, b.number_display, b.issue_date, b.due_date, b.status, b.tax, b.shipping_fee, b.total, b.created_by,
+			o.number_display, o.date, IFNULL(o.shipping_address, ''),
+			c.id, c.name, c.address, c.email, c.phone_number
+		FROM billings b
+		JOIN orders o ON o.id = b.order_id
+		JOIN customers c ON c.id = o.customer_id
+		WHERE b.number_display = ?
+	`
+	args := []interface{}{billNumber}
+	if user.Role != entity.RoleAdmin {
+		query += " AND o.customer_id = ?"
+		args = append(args, user.Customer.ID)
+	}
+
+	var dueDate sql.NullTime // due_date bisa kosong untuk data lama
+	err := h.DB.QueryRow(query, args...).Scan(
+		&inv.Billing.ID,
+		&inv.Billing.OrderID,
+		&inv.Billing.NumberDisplay,
+		&inv.Billing.IssueDate,
+		&dueDate,
+		&inv.Billing.Status,
+		&inv.Billing.Tax,
+		&inv.Billing.ShippingFee,
+		&inv.Billing.Total,
+		&inv.Billing.CreatedBy,
+		&inv.OrderNumber,
+		&inv.OrderDate,
+		&inv.ShippingAddress,
+		&inv.Customer.ID,
+		&inv.Customer.Name,
+		&inv.Customer.Address,
+		&inv.Customer.Email,
+		&inv.Customer.PhoneNumber,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return inv, errors.New("Billing tidak ditemukan")
+		}
+		return inv, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+	inv.Billing.DueDate = dueDate.Time
+
+	// Baris produk diambil dari snapshot order_details
+	orderHandler := OrderHandler{DB: h.DB, Ctx: h.Ctx}
+	inv.Lines, err = orderHandler.getOrderDetails(inv.Billing.OrderID)
+	if err != nil {
+		return inv, err
+	}
+
+	inv.Billing.TaxLines, err = getBillingTaxLines(h.DB, inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+	inv.Billing.Installments, err = getInstallments(h.DB, inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+	inv.Billing.Adjustments, err = getBillingAdjustments(h.DB, inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+	inv.Billing.VirtualAccounts, err = getVirtualAccounts(h.DB, inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+
+	paymentHandler := PaymentHandler{DB: h.DB, Ctx: h.Ctx}
+	inv.Billing.Payments, err = paymentHandler.GetPaymentsByBillingID(inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+	// Kuitansi hanya menampilkan brand dan 4 digit terakhir kartu
+	cards, err := getPaymentCards(h.DB, inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+	for i := range inv.Billing.Payments {
+		inv.Billing.Payments[i].Card = cards[inv.Billing.Payments[i].ID]
+	}
+	refundHandler := RefundHandler{DB: h.DB, Ctx: h.Ctx}
+	inv.Billing.Refunds, err = refundHandler.GetRefundsByBillingID(inv.Billing.ID)
+	if err != nil {
+		return inv, err
+	}
+
+	for _, payment := range inv.Billing.Payments {
+		inv.Paid += payment.Amount
+	}
+	for _, refund := range inv.Billing.Refunds {
+		inv.Refunded += refund.Amount
+	}
+
+	// Harga inclusive sudah memuat pajak, sehingga subtotal dihitung balik dari total billing
+	inv.Subtotal = inv.Billing.Total - inv.Billing.Tax - inv.Billing.ShippingFee
+
+	// Sisa tagihan hanya relevan selama billing masih aktif, dihitung dari total setelah credit note / debit note
+	if inv.Billing.Status == entity.StatusUnpaid || inv.Billing.Status == entity.StatusLesspaid {
+		inv.BalanceDue = inv.Billing.AdjustedTotal() - (inv.Paid - inv.Refunded)
+		if inv.BalanceDue < 0 {
+			inv.BalanceDue = 0
+		}
+	}
+
+	return inv, nil
+}
+
+// GetMyPaymentHistory mengambil semua billing milik customer yang sedang login, terbaru lebih dulu,
+// masing-masing beserta pembayaran, metode, refund, dan sisa tagihannya
+func (h *InvoiceHandler) GetMyPaymentHistory() ([]entity.Invoice, error) {
+	user, ok := utils.GetUser(*h.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT b.number_display
+		FROM billings b
+		JOIN orders o ON o.id = b.order_id
+		WHERE o.customer_id = ?
+		ORDER BY b.issue_date DESC, b.id DESC
+	`, user.Customer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	var numbers []string
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Setiap billing dihitung dengan aturan yang sama seperti invoice agar sisa tagihannya selalu cocok
+	var history []entity.Invoice
+	for _, number := range numbers {
+		inv, err := h.GetInvoice(number)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, inv)
+	}
+
+	return history, nil
+}
+
+// GetPaymentReceipt menyiapkan kuitansi satu pembayaran. Selain admin hanya bisa mengambil pembayaran miliknya sendiri.
+func (h *InvoiceHandler) GetPaymentReceipt(paymentID int) (entity.PaymentReceipt, error) {
+	var receipt entity.PaymentReceipt
+
+	user, ok := utils.GetUser(*h.Ctx)
+	if !ok {
+		return receipt, fmt.Errorf("Please Login!")
+	}
+
+	query := `
+		SELECT b.number_display
+		FROM payments p
+		JOIN billings b ON b.id = p.billing_id
+		JOIN orders o ON o.id = b.order_id
+		WHERE p.id = ?
+	`
+	args := []interface{}{paymentID}
+	if user.Role != entity.RoleAdmin {
+		query += " AND o.customer_id = ?"
+		args = append(args, user.Customer.ID)
+	}
+
+	var billNumber string
+	err := h.DB.QueryRow(query, args...).Scan(&billNumber)
+	if err == sql.ErrNoRows {
+		return receipt, errors.New("Pembayaran tidak ditemukan")
+	}
+	if err != nil {
+		return receipt, fmt.Errorf("Terjadi kesalahan mengambil pembayaran: %s", err)
+	}
+
+	inv, err := h.GetInvoice(billNumber)
+	if err != nil {
+		return receipt, err
+	}
+
+	found := false
+	for _, payment := range inv.Billing.Payments {
+		if payment.ID == paymentID {
+			receipt.Payment, found = payment, true
+			break
+		}
+	}
+	if !found {
+		return receipt, errors.New("Pembayaran tidak ditemukan")
+	}
+
+	receipt.Number = receiptNumber(billNumber, paymentID)
+	receipt.BillingNumber = inv.Billing.NumberDisplay
+	receipt.OrderNumber = inv.OrderNumber
+	receipt.Customer = inv.Customer
+
+	// Posisi tagihan dihitung ulang per saat pembayaran: penyesuaian dan refund yang terjadi sesudahnya tidak ikut
+	paidAt := receipt.Payment.Date
+	receipt.BillingTotal = inv.Billing.Total
+	for _, adjustment := range inv.Billing.Adjustments {
+		if !adjustment.CreatedAt.After(paidAt) {
+			receipt.BillingTotal += adjustment.Signed()
+		}
+	}
+	for _, payment := range inv.Billing.Payments {
+		if payment.Date.Before(paidAt) || (payment.Date.Equal(paidAt) && payment.ID <= paymentID) {
+			receipt.PaidToDate += payment.Amount
+		}
+	}
+	for _, refund := range inv.Billing.Refunds {
+		if refund.CreatedAt.Before(paidAt) {
+			receipt.PaidToDate -= refund.Amount
+		}
+	}
+	receipt.BalanceAfter = maxMoney(receipt.BillingTotal-receipt.PaidToDate, 0)
+
+	return receipt, nil
+}
+
+// receiptNumber membentuk nomor kuitansi dari nomor billing dan ID payment, contoh BIL-202506-001 → RCP-202506-001-0007
+func receiptNumber(billNumber string, paymentID int) string {
+	return fmt.Sprintf("RCP-%s-%04d", strings.TrimPrefix(billNumber, "BIL-"), paymentID)
+}
@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestInvoiceDB membuat database in-memory SQLite berisi satu billing lesspaid lengkap dengan pajak dan pembayaran.
+func SetupTestInvoiceDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	// Satu koneksi agar semua query melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE customers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			address TEXT NOT NULL,
+			email TEXT NOT NULL,
+			phone_number TEXT NOT NULL
+		);
+
+		INSERT INTO customers (name, address, email, phone_number) VALUES
+		('Budi Santoso', 'Jl. Merdeka 1', 'budi@example.com', '0811'),
+		('Sari Dewi', 'Jl. Sudirman 2', 'sari@example.com', '0812');
+
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT,
+			customer_id INTEGER,
+			date DATETIME DEFAULT CURRENT_TIMESTAMP,
+			shipping_address TEXT
+		);
+
+		INSERT INTO orders (number_display, customer_id, shipping_address) VALUES
+		('ORD-001', 1, 'Jl. Merdeka 1, Jakarta'),
+		('ORD-002', 2, NULL);
+
+		CREATE TABLE order_details (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			product_id INTEGER,
+			qty INTEGER,
+			product_name TEXT NOT NULL,
+			price NUMERIC DEFAULT 0 NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL,
+			created_by INTEGER NOT NULL DEFAULT 1
+		);
+
+		INSERT INTO order_details (order_id, product_id, qty, product_name, price, price_includes_tax) VALUES
+		(1, 1, 2, 'Adjustable Dumbbell 20kg', 100000, 0),
+		(1, 3, 1, 'Yoga Mat Premium', 110000, 1),
+		(2, 1, 1, 'Adjustable Dumbbell 20kg', 100000, 0);
+
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			number_display TEXT,
+			issue_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			due_date TIMESTAMP,
+			tax NUMERIC DEFAULT 0 NOT NULL,
+			shipping_fee NUMERIC DEFAULT 0 NOT NULL,
+			total NUMERIC DEFAULT 0 NOT NULL,
+			status TEXT DEFAULT 'unpaid',
+			created_by INTEGER NOT NULL DEFAULT 1
+		);
+
+		INSERT INTO billings (order_id, number_display, tax, shipping_fee, total, status) VALUES
+		(1, 'BIL-001', 30000, 15000, 345000, 'lesspaid'),
+		(2, 'BIL-002', 10000, 0, 110000, 'unpaid');
+
+		CREATE TABLE billing_tax_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			order_detail_id INTEGER NOT NULL,
+			tax_class TEXT NOT NULL,
+			rate NUMERIC NOT NULL,
+			price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+			taxable_amount NUMERIC NOT NULL DEFAULT 0,
+			tax_amount NUMERIC NOT NULL DEFAULT 0
+		);
+
+		INSERT INTO billing_tax_lines (billing_id, order_detail_id, tax_class, rate, price_includes_tax, taxable_amount, tax_amount) VALUES
+		(1, 1, 'standard', 10.00, 0, 200000, 20000),
+		(1, 2, 'standard', 10.00, 1, 100000, 10000);
+
+		CREATE TABLE billing_installments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			seq INTEGER NOT NULL,
+			amount NUMERIC NOT NULL,
+			paid_amount NUMERIC NOT NULL DEFAULT 0,
+			due_date DATE NOT NULL,
+			status TEXT NOT NULL DEFAULT 'open',
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			date DATETIME DEFAULT CURRENT_TIMESTAMP,
+			amount NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL DEFAULT 1,
+			updated_by INTEGER
+		);
+
+		INSERT INTO payments (billing_id, amount, method) VALUES (1, 200000, 'credit_card');
+
+		CREATE TABLE card_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			brand TEXT NOT NULL,
+			last4 TEXT NOT NULL,
+			exp_month INTEGER NOT NULL,
+			exp_year INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		INSERT INTO card_tokens (customer_id, token, brand, last4, exp_month, exp_year, created_by) VALUES (1, 'tok_sim_C_abc', 'visa', '4242', 12, 2030, 1);
+
+		CREATE TABLE payment_charges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			charge_ref TEXT NOT NULL UNIQUE,
+			amount NUMERIC NOT NULL,
+			fee NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			message TEXT,
+			card_token_id INTEGER,
+			qr_payload TEXT,
+			payment_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		INSERT INTO payment_charges (billing_id, charge_ref, amount, method, status, card_token_id, payment_id, created_by) VALUES (1, 'SIM-A-1-1', 200000, 'credit_card', 'approved', 1, 1, 1);
+
+		CREATE TABLE refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			payment_id INTEGER NOT NULL,
+			return_id INTEGER,
+			amount NUMERIC NOT NULL,
+			reason TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed',
+			charge_ref TEXT,
+			gateway_ref TEXT,
+			message TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		INSERT INTO refunds (billing_id, payment_id, amount, reason, created_by) VALUES (1, 1, 5000, 'Selisih ongkir', 1);
+
+		CREATE TABLE billing_adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT NOT NULL UNIQUE,
+			billing_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			amount NUMERIC NOT NULL,
+			reason TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		INSERT INTO billing_adjustments (number_display, billing_id, type, amount, reason, created_by) VALUES ('CN-202506-001', 2, 'credit', 10000, 'Diskon goodwill', 1);
+
+		CREATE TABLE virtual_accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL,
+			bank TEXT NOT NULL,
+			va_number TEXT NOT NULL UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		INSERT INTO virtual_accounts (billing_id, customer_id, bank, va_number, created_by) VALUES (1, 1, 'bca', '3935800001000011', 1);
+	`)
+	require.NoError(t, err, "Gagal membuat schema invoice")
+
+	return db
+}
+
+// TestGetInvoice menguji perhitungan subtotal, pembayaran bersih, sisa tagihan, dan pembatasan akses customer.
+func TestGetInvoice(t *testing.T) {
+	db := SetupTestInvoiceDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &InvoiceHandler{DB: db, Ctx: &ctx}
+
+	inv, err := handler.GetInvoice("BIL-001")
+	require.NoError(t, err)
+	assert.Equal(t, "ORD-001", inv.OrderNumber)
+	assert.Equal(t, "Budi Santoso", inv.Customer.Name)
+	require.Len(t, inv.Lines, 2)
+	require.Len(t, inv.Billing.TaxLines, 2)
+	require.Len(t, inv.Billing.Payments, 1)
+	require.Len(t, inv.Billing.Refunds, 1)
+	assert.Equal(t, "VISA **** 4242", inv.Billing.Payments[0].Card, "kuitansi hanya menampilkan 4 digit terakhir kartu")
+
+	assert.Equal(t, entity.NewMoney(300000), inv.Subtotal, "345000 - pajak 30000 - ongkir 15000")
+	assert.Equal(t, entity.NewMoney(200000), inv.Paid)
+	assert.Equal(t, entity.NewMoney(5000), inv.Refunded)
+	assert.Equal(t, entity.NewMoney(150000), inv.BalanceDue, "345000 - (200000 - 5000)")
+	require.Len(t, inv.Billing.VirtualAccounts, 1)
+	assert.Equal(t, "3935800001000011", inv.Billing.VirtualAccounts[0].Number)
+	assert.False(t, inv.IsReceipt())
+
+	// Customer tidak bisa mengambil billing milik customer lain
+	_, err = handler.GetInvoice("BIL-002")
+	assert.Error(t, err)
+
+	// Admin bisa mengambil billing siapa pun
+	adminCtx := utils.WithUser(context.Background(), &entity.User{ID: 1, Role: entity.RoleAdmin})
+	adminHandler := &InvoiceHandler{DB: db, Ctx: &adminCtx}
+	inv, err = adminHandler.GetInvoice("BIL-002")
+	require.NoError(t, err)
+	assert.Equal(t, "Sari Dewi", inv.Customer.Name)
+	require.Len(t, inv.Billing.Adjustments, 1)
+	assert.Equal(t, entity.NewMoney(100000), inv.Billing.AdjustedTotal())
+	assert.Equal(t, entity.NewMoney(100000), inv.BalanceDue, "110000 - credit note 10000")
+
+	// Billing lunas dicetak sebagai kuitansi tanpa sisa tagihan
+	_, err = db.Exec("UPDATE billings SET status = 'paid' WHERE id = 1")
+	require.NoError(t, err)
+	inv, err = handler.GetInvoice("BIL-001")
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), inv.BalanceDue)
+	assert.True(t, inv.IsReceipt())
+}
+
+// TestGetMyPaymentHistory menguji daftar billing customer beserta pembayaran dan sisa tagihannya.
+func TestGetMyPaymentHistory(t *testing.T) {
+	db := SetupTestInvoiceDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &InvoiceHandler{DB: db, Ctx: &ctx}
+
+	history, err := handler.GetMyPaymentHistory()
+	require.NoError(t, err)
+	require.Len(t, history, 1, "billing customer lain tidak ikut tampil")
+	assert.Equal(t, "BIL-001", history[0].Billing.NumberDisplay)
+	require.Len(t, history[0].Billing.Payments, 1)
+	assert.Equal(t, entity.MethodCredit, history[0].Billing.Payments[0].Method)
+	assert.Equal(t, entity.NewMoney(150000), history[0].BalanceDue)
+
+	otherCtx := utils.WithUser(context.Background(), &entity.User{ID: 2, Customer: entity.Customer{ID: 2}})
+	handler.Ctx = &otherCtx
+	history, err = handler.GetMyPaymentHistory()
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Empty(t, history[0].Billing.Payments)
+	assert.Equal(t, entity.NewMoney(100000), history[0].BalanceDue)
+}
+
+// TestGetPaymentReceipt menguji posisi tagihan per pembayaran dan pembatasan akses kuitansi.
+func TestGetPaymentReceipt(t *testing.T) {
+	db := SetupTestInvoiceDB(t)
+	defer db.Close()
+
+	// Pembayaran kedua setelah refund pembayaran pertama
+	_, err := db.Exec(`
+		UPDATE refunds SET created_at = datetime('now', '-1 minute');
+		UPDATE payments SET date = datetime('now', '-2 minute') WHERE id = 1;
+		INSERT INTO payments (billing_id, amount, method) VALUES (1, 100000, 'va');
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &InvoiceHandler{DB: db, Ctx: &ctx}
+
+	receipt, err := handler.GetPaymentReceipt(1)
+	require.NoError(t, err)
+	assert.Equal(t, "RCP-001-0001", receipt.Number)
+	assert.Equal(t, "ORD-001", receipt.OrderNumber)
+	assert.Equal(t, "VISA **** 4242", receipt.Payment.Card)
+	assert.Equal(t, entity.NewMoney(200000), receipt.PaidToDate, "refund sesudahnya tidak ikut")
+	assert.Equal(t, entity.NewMoney(145000), receipt.BalanceAfter)
+
+	receipt, err = handler.GetPaymentReceipt(2)
+	require.NoError(t, err)
+	assert.Equal(t, entity.MethodVA, receipt.Payment.Method)
+	assert.Equal(t, entity.NewMoney(295000), receipt.PaidToDate, "200000 - refund 5000 + 100000")
+	assert.Equal(t, entity.NewMoney(50000), receipt.BalanceAfter)
+
+	// Pembayaran milik customer lain dan pembayaran yang tidak ada ditolak
+	otherCtx := utils.WithUser(context.Background(), &entity.User{ID: 2, Customer: entity.Customer{ID: 2}})
+	handler.Ctx = &otherCtx
+	_, err = handler.GetPaymentReceipt(1)
+	assert.EqualError(t, err, "Pembayaran tidak ditemukan")
+	_, err = handler.GetPaymentReceipt(99)
+	assert.EqualError(t, err, "Pembayaran tidak ditemukan")
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"pairproject/entity"
 	"pairproject/utils"
@@ -15,6 +16,9 @@ type OrderDetailHandler struct {
 }
 
 // UpdateDetail mengubah jumlah (qty) dari sebuah item order_detail berdasarkan ID.
+// Hanya admin atau customer pemilik order yang boleh mengubah, dan hanya untuk order processing
+// yang belum dibuatkan billing aktif. Ongkos kirim order dihitung ulang dari snapshot zona dan berat
+// dalam transaksi yang sama, sehingga orders.shipping_fee tidak basi saat tagihan dibuat.
 // Parameter:
 // - id: ID dari order_detail yang ingin di-update
 // - qty: jumlah baru yang akan diset
@@ -31,23 +35,99 @@ func (od *OrderDetailHandler) UpdateDetail(id int, qty int) (entity.OrderDetail,
 		return orderDetail, fmt.Errorf("failed to get user from context")
 	}
 
+	if qty <= 0 {
+		return orderDetail, errors.New("Kuantitas harus lebih dari 0")
+	}
+
+	tx, err := od.DB.Begin()
+	if err != nil {
+		return orderDetail, err
+	}
+
+	// Pastikan detail ada lewat SELECT; RowsAffected MySQL bernilai 0 jika qty tidak berubah
+	var orderID, customerID, billings int
+	var status entity.StatusOrder
+	err = tx.QueryRow(`
+		SELECT od.order_id, o.customer_id, o.status,
+			(SELECT COUNT(*) FROM billings b WHERE b.order_id = o.id AND b.status <> 'cancelled')
+		FROM order_details od
+		JOIN orders o ON o.id = od.order_id
+		WHERE od.id = ?
+	`, id).Scan(&orderID, &customerID, &status, &billings)
+	if err == sql.ErrNoRows || (err == nil && user.Role != entity.RoleAdmin && customerID != user.Customer.ID) {
+		tx.Rollback()
+		return orderDetail, errors.New("Order detail tidak ditemukan")
+	}
+	if err != nil {
+		tx.Rollback()
+		return orderDetail, fmt.Errorf("Terjadi kesalahan mengambil order detail: %s", err)
+	}
+
+	// Qty hanya boleh berubah sebelum tagihan dibuat, karena total billing dan reservasi stok memakai qty lama
+	if status != entity.StatusProcessing {
+		tx.Rollback()
+		return orderDetail, fmt.Errorf("Order berstatus %s tidak bisa diubah", status)
+	}
+	if billings > 0 {
+		tx.Rollback()
+		return orderDetail, errors.New("Order yang sudah dibuatkan billing tidak bisa diubah")
+	}
+
 	// Eksekusi perintah update untuk mengubah qty dan updated_by
-	res, err := od.DB.Exec(
+	_, err = tx.Exec(
 		"UPDATE order_details SET qty = ?, updated_by = ? WHERE id = ?",
 		qty, user.ID, id,
 	)
 	if err != nil {
 		// Jika terjadi error saat eksekusi query update
+		tx.Rollback()
 		return orderDetail, fmt.Errorf("Terjadi kesalahan update data: %s", err)
 	}
 
-	// ⚠️ Catatan: `LastInsertId()` hanya digunakan untuk operasi INSERT.
-	// Dalam konteks UPDATE, pemanggilan `LastInsertId()` ini tidak valid dan sebaiknya dihapus.
-	_, err = res.LastInsertId()
+	// Hitung ulang ongkos kirim dari total berat baru
+	err = updateOrderShippingFeeTx(tx, orderID)
 	if err != nil {
-		return orderDetail, fmt.Errorf("Terjadi kesalahan mengambil order detail id: %s", err)
+		tx.Rollback()
+		return orderDetail, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return orderDetail, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
 	}
 
 	// Kembalikan struct OrderDetail dengan qty baru
-	return entity.OrderDetail{Qty: qty}, nil
-}
\ No newline at end of file
+	return entity.OrderDetail{ID: id, OrderID: orderID, Qty: qty}, nil
+}
+
+// updateOrderShippingFeeTx menghitung ulang orders.shipping_fee dari snapshot zona pengiriman di orders dan
+// snapshot berat di order_details, sehingga perubahan alamat atau berat produk setelah checkout tidak ikut terhitung.
+// Order tanpa snapshot zona (data lama) dibiarkan apa adanya.
+func updateOrderShippingFeeTx(tx *sql.Tx, orderID int) error {
+	var zone sql.NullString
+	err := tx.QueryRow("SELECT shipping_zone FROM orders WHERE id = ?", orderID).Scan(&zone)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan mengambil zona pengiriman: %s", err)
+	}
+	if !zone.Valid || zone.String == "" {
+		return nil
+	}
+
+	var totalWeight int
+	err = tx.QueryRow("SELECT IFNULL(SUM(qty * weight), 0) FROM order_details WHERE order_id = ?", orderID).Scan(&totalWeight)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan menghitung berat order: %s", err)
+	}
+
+	rate, err := calculateShippingFee(tx, zone.String, totalWeight)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE orders SET shipping_fee = ? WHERE id = ?", rate.Fee, orderID)
+	if err != nil {
+		return errors.New("Terjadi kesalahan menyimpan ongkos kirim")
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestOrderDetailDB membuat database in-memory SQLite berisi tarif ongkir, order dengan snapshot zona,
+// dan order detail dengan snapshot berat. Tabel alamat dan produk sengaja tidak dibuat karena ongkos kirim
+// hanya boleh dihitung dari snapshot.
+func SetupTestOrderDetailDB(t *testing.T) *sql.DB {
+	db := SetupTestShippingDB(t)
+
+	// Satu koneksi agar transaksi UpdateDetail melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err := db.Exec(`
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'processing',
+			shipping_address_id INTEGER,
+			shipping_zone TEXT,
+			shipping_fee NUMERIC NOT NULL DEFAULT 0
+		);
+
+		INSERT INTO orders (customer_id, status, shipping_address_id, shipping_zone, shipping_fee) VALUES
+		(1, 'processing', 1, 'jawa', 20000),     -- 2 x 2kg = tarif ringan
+		(1, 'processing', NULL, NULL, 0),        -- data lama tanpa alamat
+		(2, 'processing', NULL, 'jawa', 20000),  -- milik customer lain
+		(1, 'processing', 1, 'jawa', 20000),     -- sudah dibuatkan billing
+		(1, 'completed', 1, 'jawa', 20000);
+
+		CREATE TABLE order_details (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL,
+			weight INTEGER NOT NULL DEFAULT 0,
+			updated_by INTEGER
+		);
+
+		INSERT INTO order_details (order_id, product_id, qty, weight) VALUES
+		(1, 1, 2, 2000), (2, 1, 1, 2000), (3, 1, 1, 2000), (4, 1, 1, 2000), (5, 1, 1, 2000);
+
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'unpaid'
+		);
+
+		INSERT INTO billings (order_id, status) VALUES (4, 'unpaid'), (1, 'cancelled');
+	`)
+	require.NoError(t, err, "Gagal membuat schema order detail")
+
+	return db
+}
+
+// TestUpdateDetail_RecomputesShippingFee memastikan ongkos kirim order ikut dihitung ulang dari snapshot saat qty diubah.
+func TestUpdateDetail_RecomputesShippingFee(t *testing.T) {
+	db := SetupTestOrderDetailDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &OrderDetailHandler{DB: db, Ctx: &ctx}
+
+	shippingFee := func(orderID int) entity.Money {
+		var fee entity.Money
+		require.NoError(t, db.QueryRow("SELECT shipping_fee FROM orders WHERE id = ?", orderID).Scan(&fee))
+		return fee
+	}
+
+	// 3 x 2kg = 6kg masuk bracket 5-20kg
+	detail, err := handler.UpdateDetail(1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, detail.OrderID)
+	assert.Equal(t, 3, detail.Qty)
+	assert.Equal(t, entity.NewMoney(45000), shippingFee(1))
+
+	// Kembali ke 2kg memakai tarif ringan lagi, qty yang sama boleh disimpan ulang
+	_, err = handler.UpdateDetail(1, 1)
+	require.NoError(t, err)
+	_, err = handler.UpdateDetail(1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(20000), shippingFee(1))
+
+	// Order tanpa snapshot zona tidak diberi ongkos kirim
+	_, err = handler.UpdateDetail(2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), shippingFee(2))
+
+	// Order milik customer lain, order yang sudah dibilling, dan order selesai tidak bisa diubah
+	_, err = handler.UpdateDetail(3, 2)
+	assert.EqualError(t, err, "Order detail tidak ditemukan")
+	_, err = handler.UpdateDetail(4, 2)
+	assert.Error(t, err)
+	_, err = handler.UpdateDetail(5, 2)
+	assert.Error(t, err)
+	_, err = handler.UpdateDetail(1, 0)
+	assert.Error(t, err)
+
+	// Admin boleh mengubah order milik customer mana pun
+	adminCtx := utils.WithUser(context.Background(), &entity.User{ID: 9, Role: entity.RoleAdmin})
+	admin := &OrderDetailHandler{DB: db, Ctx: &adminCtx}
+	_, err = admin.UpdateDetail(3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(45000), shippingFee(3))
+
+	// Tidak ada tarif yang berlaku: qty tidak ikut berubah
+	_, err = db.Exec("UPDATE shipping_rates SET is_active = 0")
+	require.NoError(t, err)
+	_, err = handler.UpdateDetail(1, 4)
+	require.Error(t, err)
+
+	var qty int
+	require.NoError(t, db.QueryRow("SELECT qty FROM order_details WHERE id = 1").Scan(&qty))
+	assert.Equal(t, 1, qty)
+
+	_, err = handler.UpdateDetail(99, 1)
+	assert.Error(t, err)
+}
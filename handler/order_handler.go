@@ -3,20 +3,27 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"pairproject/entity"
 	"pairproject/utils"
+	"strings"
 	"time"
 )
 
+// defaultOrderPageSize adalah jumlah order per halaman jika filter tidak menentukan limit
+const defaultOrderPageSize = 5
+
 // OrderHandler adalah struct handler utama untuk proses order
 type OrderHandler struct {
 	DB  *sql.DB           // koneksi ke database
 	Ctx *context.Context  // konteks untuk mendapatkan data user login
 }
 
-// CreateOrder membuat order baru beserta detailnya dan menghitung total otomatis melalui trigger
+// CreateOrder membuat order baru beserta detailnya dan menghitung total otomatis melalui trigger.
+// Jika context berisi idempotency key (utils.WithIdempotencyKey), permintaan ulang dengan key dan produk yang sama
+// dalam entity.IdempotencyWindow mengembalikan order pertama tanpa membuat order baru.
 func (o *OrderHandler) CreateOrder(oProducts []entity.OrderProduct) (entity.Order, error) {
 	var order entity.Order
 
@@ -26,13 +33,87 @@ func (o *OrderHandler) CreateOrder(oProducts []entity.OrderProduct) (entity.Orde
 		return order, fmt.Errorf("Please Login!")
 	}
 
+	// Permintaan ulang dengan idempotency key yang sama mengembalikan order yang sudah dibuat
+	key, hasKey := utils.GetIdempotencyKey(*o.Ctx)
+	var hash string
+	if hasKey {
+		var err error
+		hash, err = requestHash(oProducts)
+		if err != nil {
+			return order, err
+		}
+		if order, done, err := o.replayOrder(user.ID, idempotencyCreateOrder, key, hash); done || err != nil {
+			return order, err
+		}
+	}
+
 	// Mulai transaksi
 	tx, err := o.DB.Begin()
+	if err != nil {
+		return order, err
+	}
+
+	// Buat order dan detailnya di dalam transaksi
+	order, err = o.createOrderTx(tx, user, oProducts)
 	if err != nil {
 		tx.Rollback()
 		return order, err
 	}
 
+	// Key disimpan di transaksi yang sama dengan order sehingga keduanya tercatat bersamaan
+	if hasKey {
+		response, err := json.Marshal(order)
+		if err != nil {
+			tx.Rollback()
+			return entity.Order{}, err
+		}
+		record := entity.IdempotencyKey{
+			Key:         key,
+			UserID:      user.ID,
+			Operation:   idempotencyCreateOrder,
+			RequestHash: hash,
+			ResourceID:  order.ID,
+			Response:    string(response),
+			Status:      entity.IdempotencyCompleted,
+		}
+		if _, err := insertIdempotencyKey(tx, record); err != nil {
+			tx.Rollback()
+			// Permintaan lain dengan key yang sama selesai lebih dulu
+			if order, done, replayErr := o.replayOrder(user.ID, idempotencyCreateOrder, key, hash); done || replayErr != nil {
+				return order, replayErr
+			}
+			return entity.Order{}, fmt.Errorf("Gagal menyimpan idempotency key: %s", err)
+		}
+	}
+
+	// Commit transaksi
+	err = tx.Commit()
+	if err != nil {
+		return order, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return order, nil
+}
+
+// replayOrder mengembalikan order hasil permintaan sebelumnya (CreateOrder atau checkout) dengan idempotency key yang sama.
+// done bernilai false jika key belum pernah dipakai sehingga order baru perlu dibuat.
+func (o *OrderHandler) replayOrder(userID int, operation, key, hash string) (order entity.Order, done bool, err error) {
+	record, found, err := findIdempotencyKey(o.DB, userID, operation, key)
+	if err != nil || !found {
+		return order, false, err
+	}
+	if err := checkIdempotencyKey(record, hash); err != nil {
+		return order, true, err
+	}
+	if err := json.Unmarshal([]byte(record.Response), &order); err != nil {
+		return entity.Order{}, true, fmt.Errorf("Gagal membaca hasil order sebelumnya: %s", err)
+	}
+	return order, true, nil
+}
+
+// createOrderTx menyimpan order beserta detail dan snapshot produknya menggunakan transaksi milik pemanggil.
+// Rollback/commit menjadi tanggung jawab pemanggil agar bisa digabung dengan proses lain (misal checkout cart).
+func (o *OrderHandler) createOrderTx(tx *sql.Tx, user *entity.User, oProducts []entity.OrderProduct) (entity.Order, error) {
 	// Generate nomor order unik
 	numberDisplay := o.GenerateOrderNumber(tx)
 
@@ -40,57 +121,84 @@ func (o *OrderHandler) CreateOrder(oProducts []entity.OrderProduct) (entity.Orde
 	createdDate := time.Now().Format("2006-01-02")
 	res, err := tx.Exec("INSERT INTO orders (number_display, customer_id, date, created_by) VALUES (?, ?, ?, ?)", numberDisplay, user.Customer.ID, createdDate, user.ID)
 	if err != nil {
-		tx.Rollback()
-		return order, errors.New("Terjadi kesalahan membuat order")
+		return entity.Order{}, errors.New("Terjadi kesalahan membuat order")
 	}
 
 	// Ambil ID order yang baru dibuat
 	orderID, err := res.LastInsertId()
 	if err != nil {
-		tx.Rollback()
-		return order, err
+		return entity.Order{}, err
 	}
 
-	// Persiapkan statement untuk insert ke order_details
-	stmt, err := tx.Prepare("INSERT INTO order_details (order_id, product_id, qty, created_by) VALUES (?, ?, ?, ?)")
+	// Persiapkan statement untuk insert ke order_details beserta snapshot produk
+	stmt, err := tx.Prepare("INSERT INTO order_details (order_id, product_id, qty, product_name, price, tax_class, price_includes_tax, weight, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
-		tx.Rollback()
-		return order, errors.New("Terjadi kesalahan membuat detail order")
+		return entity.Order{}, errors.New("Terjadi kesalahan membuat detail order")
 	}
 	defer stmt.Close()
 
 	// Loop produk yang dipesan, insert ke order_details
 	var orderDetails []entity.OrderDetail
 	for _, op := range oProducts {
-		_, err := stmt.Exec(orderID, op.ProductId, op.Qty, user.ID)
+		// Ambil nama, harga, kelas pajak, dan berat produk saat ini untuk disimpan sebagai snapshot.
+		// Produk tanpa kelas pajak mengikuti kelas pajak kategorinya.
+		var product entity.Product
+		err := tx.QueryRow(`
+			SELECT p.id, p.name, p.price, COALESCE(p.tax_class, c.tax_class, 'standard'), p.price_includes_tax, p.weight
+			FROM products p
+			LEFT JOIN categories c ON c.id = p.category_id
+			WHERE p.id = ?
+		`, op.ProductId).Scan(
+			&product.ID,
+			&product.Name,
+			&product.Price,
+			&product.TaxClass,
+			&product.PriceIncludesTax,
+			&product.Weight,
+		)
 		if err != nil {
-			tx.Rollback()
-			return order, errors.New("Terjadi kesalahan membuat detail order")
+			if err == sql.ErrNoRows {
+				return entity.Order{}, fmt.Errorf("Produk dengan id %d tidak ditemukan", op.ProductId)
+			}
+			return entity.Order{}, errors.New("Terjadi kesalahan membuat detail order")
+		}
+
+		res, err := stmt.Exec(orderID, op.ProductId, op.Qty, product.Name, product.Price, product.TaxClass, product.PriceIncludesTax, product.Weight, user.ID)
+		if err != nil {
+			return entity.Order{}, errors.New("Terjadi kesalahan membuat detail order")
+		}
+
+		// Ambil ID detail yang baru dibuat
+		detailID, err := res.LastInsertId()
+		if err != nil {
+			return entity.Order{}, err
 		}
 
 		// Simpan ke struct
 		orderDetails = append(orderDetails, entity.OrderDetail{
-			OrderID:   int(orderID),
-			ProductID: op.ProductId,
-			Qty:       op.Qty,
-			CreatedBy: user.ID,
+			ID:               int(detailID),
+			OrderID:          int(orderID),
+			ProductID:        op.ProductId,
+			Product:          product,
+			Qty:              op.Qty,
+			ProductName:      product.Name,
+			Price:            product.Price,
+			TaxClass:         product.TaxClass,
+			PriceIncludesTax: product.PriceIncludesTax,
+			Weight:           product.Weight,
+			Total:            product.Price.Mul(op.Qty),
+			CreatedBy:        user.ID,
 		})
 	}
 
 	// Set nilai order untuk dikembalikan
-	order = entity.Order{
+	order := entity.Order{
 		ID:            int(orderID),
 		NumberDisplay: numberDisplay,
 		CreatedBy:     user.ID,
 		Details:       orderDetails,
 	}
 
-	// Commit transaksi
-	err = tx.Commit()
-	if err != nil {
-		return order, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
-	}
-
 	return order, nil
 }
 
@@ -132,17 +240,16 @@ func (o *OrderHandler) GetOrders() ([]entity.Order, error) {
 		return orders, fmt.Errorf("failed to get user from context")
 	}
 
-	// Query ambil order dan detail produk
+	// Query ambil order dan detail produk menggunakan snapshot harga & nama di order_details
 	query := `
 		SELECT 
-			ord.id, ord.number_display, ord.date, ord.status, ord.total, ord.created_by,
-			od.id, od.product_id, od.qty, od.qty * p.price as od_total, od.created_by,
-			p.price, p.name
+			ord.id, ord.number_display, ord.date, ord.status, ord.total, ord.shipping_fee, ord.created_by,
+			od.id, od.product_id, od.qty, od.qty * od.price as od_total, od.created_by,
+			od.price, od.product_name, od.tax_class
 		FROM orders ord
 		JOIN order_details od ON ord.id = od.order_id
-		JOIN products p ON od.product_id = p.id
 		WHERE ord.customer_id = ? AND status = "processing"
-		ORDER BY ord.id DESC
+		ORDER BY ord.id DESC, od.id ASC
 	`
 
 	rows, err := o.DB.Query(query, user.Customer.ID)
@@ -152,6 +259,7 @@ func (o *OrderHandler) GetOrders() ([]entity.Order, error) {
 	defer rows.Close()
 
 	orderMap := make(map[int]*entity.Order)
+	var orderIDs []int // urutan order sesuai hasil query agar tampilan stabil
 
 	// Gabungkan order dan detail ke dalam map agar tidak duplikat
 	for rows.Next() {
@@ -160,25 +268,28 @@ func (o *OrderHandler) GetOrders() ([]entity.Order, error) {
 			numberDisplay  string
 			date           string
 			status         entity.StatusOrder
-			total          float64
+			total          entity.Money
+			shippingFee    entity.Money
 			createdBy      int
 			orderDetailID  int
 			productID      int
 			qty            int
-			subtotal       float64
+			subtotal       entity.Money
 			detailCreatedBy int
-			price          float64
+			price          entity.Money
 			productName    string
+			taxClass       string
 		)
 
-		err := rows.Scan(&orderID, &numberDisplay, &date, &status, &total, &createdBy,
-			&orderDetailID, &productID, &qty, &subtotal, &detailCreatedBy, &price, &productName)
+		err := rows.Scan(&orderID, &numberDisplay, &date, &status, &total, &shippingFee, &createdBy,
+			&orderDetailID, &productID, &qty, &subtotal, &detailCreatedBy, &price, &productName, &taxClass)
 		if err != nil {
 			return orders, err
 		}
 
 		// Jika order belum ada, buat baru
 		if _, exists := orderMap[orderID]; !exists {
+			orderIDs = append(orderIDs, orderID)
 			parsedDate, _ := time.Parse("2006-01-02", date)
 			orderMap[orderID] = &entity.Order{
 				ID:            orderID,
@@ -186,6 +297,7 @@ func (o *OrderHandler) GetOrders() ([]entity.Order, error) {
 				Date:          parsedDate,
 				Status:        status,
 				Total:         total,
+				ShippingFee:   shippingFee,
 				CreatedBy:     createdBy,
 				Details:       []entity.OrderDetail{},
 			}
@@ -193,23 +305,27 @@ func (o *OrderHandler) GetOrders() ([]entity.Order, error) {
 
 		// Tambahkan detail ke order
 		orderMap[orderID].Details = append(orderMap[orderID].Details, entity.OrderDetail{
-			ID:        orderDetailID,
-			OrderID:   orderID,
-			ProductID: productID,
-			Qty:       qty,
-			Total:     subtotal,
-			CreatedBy: detailCreatedBy,
+			ID:          orderDetailID,
+			OrderID:     orderID,
+			ProductID:   productID,
+			Qty:         qty,
+			ProductName: productName,
+			Price:       price,
+			TaxClass:    taxClass,
+			Total:       subtotal,
+			CreatedBy:   detailCreatedBy,
 			Product: entity.Product{
-				ID:    productID,
-				Name:  productName,
-				Price: price,
+				ID:       productID,
+				Name:     productName,
+				Price:    price,
+				TaxClass: taxClass,
 			},
 		})
 	}
 
-	// Konversi map ke slice
-	for _, order := range orderMap {
-		orders = append(orders, *order)
+	// Konversi map ke slice sesuai urutan query
+	for _, orderID := range orderIDs {
+		orders = append(orders, *orderMap[orderID])
 	}
 
 	return orders, nil
@@ -227,7 +343,7 @@ func (o *OrderHandler) GetOrderByNumberDisplay(numberDisplay string) (entity.Ord
 
 	// Query order by number
 	query := `
-		SELECT id, number_display, date, status, total, created_by
+		SELECT id, number_display, date, status, total, IFNULL(shipping_address, ''), shipping_fee, created_by
 		FROM orders
 		WHERE number_display = ? AND customer_id = ?
 		LIMIT 1
@@ -239,6 +355,8 @@ func (o *OrderHandler) GetOrderByNumberDisplay(numberDisplay string) (entity.Ord
 		&order.Date,
 		&order.Status,
 		&order.Total,
+		&order.ShippingAddress,
+		&order.ShippingFee,
 		&order.CreatedBy,
 	)
 	if err != nil {
@@ -246,4 +364,169 @@ func (o *OrderHandler) GetOrderByNumberDisplay(numberDisplay string) (entity.Ord
 	}
 
 	return order, nil
-}
\ No newline at end of file
+}
+
+// GetOrderHistory mengambil riwayat order customer (semua status) dengan filter status, rentang tanggal,
+// dan pagination berbasis cursor. Order diurutkan dari yang terbaru (id terbesar) agar urutan stabil.
+func (o *OrderHandler) GetOrderHistory(filter entity.OrderFilter) (entity.OrderPage, error) {
+	var page entity.OrderPage
+
+	// Ambil user login dari context
+	user, ok := utils.GetUser(*o.Ctx)
+	if !ok {
+		return page, fmt.Errorf("failed to get user from context")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultOrderPageSize
+	}
+
+	// Susun kondisi query sesuai filter yang diisi
+	conditions := []string{"customer_id = ?"}
+	args := []interface{}{user.Customer.ID}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if !filter.DateFrom.IsZero() {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, filter.DateFrom.Format("2006-01-02"))
+	}
+	if !filter.DateTo.IsZero() {
+		conditions = append(conditions, "date <= ?")
+		args = append(args, filter.DateTo.Format("2006-01-02"))
+	}
+	if filter.Cursor > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.Cursor)
+	}
+
+	// Ambil satu data lebih banyak dari limit untuk mengetahui apakah masih ada halaman berikutnya
+	query := fmt.Sprintf(`
+		SELECT id, number_display, date, status, total, shipping_fee, created_by
+		FROM orders
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT ?
+	`, strings.Join(conditions, " AND "))
+	args = append(args, filter.Limit+1)
+
+	rows, err := o.DB.Query(query, args...)
+	if err != nil {
+		return page, fmt.Errorf("Terjadi kesalahan mengambil riwayat order: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(&order.ID, &order.NumberDisplay, &order.Date, &order.Status, &order.Total, &order.ShippingFee, &order.CreatedBy)
+		if err != nil {
+			return page, err
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	// Potong kelebihan data dan set cursor untuk halaman berikutnya
+	if len(page.Orders) > filter.Limit {
+		page.Orders = page.Orders[:filter.Limit]
+		page.HasMore = true
+	}
+	if len(page.Orders) > 0 {
+		page.NextCursor = page.Orders[len(page.Orders)-1].ID
+	}
+
+	// Lengkapi order dengan detail produknya dalam satu query
+	orderIDs := make([]int, len(page.Orders))
+	for i, order := range page.Orders {
+		orderIDs[i] = order.ID
+	}
+	detailsByOrder, err := o.getOrderDetailsByOrderIDs(orderIDs)
+	if err != nil {
+		return page, err
+	}
+	for i := range page.Orders {
+		page.Orders[i].Details = detailsByOrder[page.Orders[i].ID]
+	}
+
+	return page, nil
+}
+
+// GetOrderDetail mengambil satu order customer lengkap dengan detail produk, billing, dan pembayarannya
+func (o *OrderHandler) GetOrderDetail(numberDisplay string) (entity.Order, error) {
+	order, err := o.GetOrderByNumberDisplay(numberDisplay)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return order, errors.New("Order tidak ditemukan")
+		}
+		return order, err
+	}
+
+	// Ambil detail produk dari snapshot order_details
+	order.Details, err = o.getOrderDetails(order.ID)
+	if err != nil {
+		return order, err
+	}
+
+	// Ambil semua billing milik order beserta pembayarannya
+	billingHandler := BillingHandler{DB: o.DB, Ctx: o.Ctx}
+	order.Billings, err = billingHandler.GetBillingsByOrderID(order.ID)
+	if err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// getOrderDetails mengambil detail produk sebuah order berdasarkan snapshot di order_details
+func (o *OrderHandler) getOrderDetails(orderID int) ([]entity.OrderDetail, error) {
+	detailsByOrder, err := o.getOrderDetailsByOrderIDs([]int{orderID})
+	if err != nil {
+		return nil, err
+	}
+	return detailsByOrder[orderID], nil
+}
+
+// getOrderDetailsByOrderIDs mengambil detail produk beberapa order sekaligus, dikelompokkan per order_id
+func (o *OrderHandler) getOrderDetailsByOrderIDs(orderIDs []int) (map[int][]entity.OrderDetail, error) {
+	detailsByOrder := make(map[int][]entity.OrderDetail)
+	if len(orderIDs) == 0 {
+		return detailsByOrder, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(orderIDs)), ", ")
+	args := make([]interface{}, len(orderIDs))
+	for i, id := range orderIDs {
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, order_id, product_id, qty, product_name, price, tax_class, price_includes_tax, qty * price, created_by
+		FROM order_details
+		WHERE order_id IN (%s)
+		ORDER BY order_id, id ASC
+	`, placeholders)
+
+	rows, err := o.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil detail order: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var od entity.OrderDetail
+		err := rows.Scan(&od.ID, &od.OrderID, &od.ProductID, &od.Qty, &od.ProductName, &od.Price, &od.TaxClass, &od.PriceIncludesTax, &od.Total, &od.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+
+		// Isi juga data Product agar kompatibel dengan tampilan yang memakai detail.Product
+		od.Product = entity.Product{ID: od.ProductID, Name: od.ProductName, Price: od.Price, TaxClass: od.TaxClass, PriceIncludesTax: od.PriceIncludesTax}
+		detailsByOrder[od.OrderID] = append(detailsByOrder[od.OrderID], od)
+	}
+
+	return detailsByOrder, rows.Err()
+}
@@ -33,7 +33,9 @@ func SetupTestOrderDB(t *testing.T) *sql.DB {
 			date DATETIME DEFAULT CURRENT_TIMESTAMP,
 			status TEXT DEFAULT 'processing' CHECK (status IN ('processing', 'completed', 'cancel')),
 			created_by INTEGER,
-			total REAL DEFAULT 0
+			total REAL DEFAULT 0,
+			shipping_address TEXT,
+			shipping_fee REAL DEFAULT 0
 		);
 
 		INSERT INTO orders (number_display, customer_id, created_by)
@@ -44,16 +46,35 @@ func SetupTestOrderDB(t *testing.T) *sql.DB {
 			order_id INTEGER,
 			product_id INTEGER,
 			qty INTEGER,
+			product_name TEXT NOT NULL,
+			price REAL DEFAULT 0 NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL,
+			weight INTEGER DEFAULT 0 NOT NULL,
 			created_by INTEGER
 		);
 
+		CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL
+		);
+
+		INSERT INTO categories (name, tax_class) VALUES
+		('Fitness Equipment', 'standard'),
+		('Outdoor Gear', 'standard'),
+		('Nutrition & Supplements', 'reduced');
+
 		CREATE TABLE products (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
 			stock INTEGER DEFAULT 0 NOT NULL,
 			description TEXT,
 			category_id INTEGER NOT NULL,
-			price REAL DEFAULT 0 NOT NULL
+			price REAL DEFAULT 0 NOT NULL,
+			tax_class TEXT,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL,
+			weight INTEGER DEFAULT 0 NOT NULL
 		);
 
 		INSERT INTO products (name, stock, description, category_id, price)
@@ -70,9 +91,8 @@ func SetupTestOrderDB(t *testing.T) *sql.DB {
 		BEGIN
 			UPDATE orders
 			SET total = (
-				SELECT IFNULL(SUM(od.qty * p.price), 0)
+				SELECT IFNULL(SUM(od.qty * od.price), 0)
 				FROM order_details od
-				JOIN products p ON od.product_id = p.id
 				WHERE od.order_id = NEW.order_id
 			)
 			WHERE id = NEW.order_id;
@@ -116,7 +136,7 @@ func TestCreateOrder(t *testing.T) {
 		t.Fatalf("GetOrderByNumberDisplay failed: %v", err)
 	}
 
-	assert.Equal(t, float64(350000), order.Total, "Total order harus sesuai (200000 + 150000)")
+	assert.Equal(t, entity.NewMoney(350000), order.Total, "Total order harus sesuai (200000 + 150000)")
 }
 
 // TestGenerateOrderNumber menguji penomoran otomatis order berdasarkan nomor terakhir di database.
@@ -145,4 +165,142 @@ func TestGenerateOrderNumber(t *testing.T) {
 	if err := tx.Commit(); err != nil {
 		t.Fatalf("failed commit tx: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// TestCreateOrder_PriceSnapshot memastikan perubahan harga produk tidak mengubah order yang sudah dibuat.
+func TestCreateOrder_PriceSnapshot(t *testing.T) {
+	db := SetupTestOrderDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &OrderHandler{DB: db, Ctx: &ctx}
+
+	order, err := handler.CreateOrder([]entity.OrderProduct{{ProductId: 1, Qty: 2}})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+
+	assert.Equal(t, "Adjustable Dumbbell 20kg", order.Details[0].ProductName, "Nama produk harus tersimpan sebagai snapshot")
+	assert.Equal(t, entity.NewMoney(100000), order.Details[0].Price, "Harga produk harus tersimpan sebagai snapshot")
+
+	// Ubah harga dan nama produk setelah order dibuat
+	_, err = db.Exec("UPDATE products SET price = 999999, name = 'Dumbbell Baru' WHERE id = 1")
+	if err != nil {
+		t.Fatalf("failed update product: %v", err)
+	}
+
+	orders, err := handler.GetOrders()
+	if err != nil {
+		t.Fatalf("GetOrders failed: %v", err)
+	}
+
+	var found bool
+	for _, o := range orders {
+		if o.ID != order.ID {
+			continue
+		}
+		found = true
+		assert.Equal(t, entity.NewMoney(200000), o.Total, "Total order tidak boleh berubah")
+		assert.Equal(t, entity.NewMoney(200000), o.Details[0].Total, "Subtotal detail harus memakai harga snapshot")
+		assert.Equal(t, "Adjustable Dumbbell 20kg", o.Details[0].ProductName, "Nama produk harus memakai snapshot")
+	}
+	assert.True(t, found, "Order yang baru dibuat harus muncul di GetOrders")
+}
+
+// TestCreateOrder_ProductNotFound menguji order dengan produk yang tidak ada di database.
+func TestCreateOrder_ProductNotFound(t *testing.T) {
+	db := SetupTestOrderDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &OrderHandler{DB: db, Ctx: &ctx}
+
+	_, err := handler.CreateOrder([]entity.OrderProduct{{ProductId: 99, Qty: 1}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tidak ditemukan")
+}
+
+// TestGetOrderHistory menguji filter status dan pagination cursor pada riwayat order.
+func TestGetOrderHistory(t *testing.T) {
+	db := SetupTestOrderDB(t)
+	defer db.Close()
+
+	// Tambahkan beberapa order dengan status berbeda (order id 1 sudah ada dari setup)
+	_, err := db.Exec(`
+		INSERT INTO orders (number_display, customer_id, date, status, created_by) VALUES
+		('ORD-HIST-002', 1, '2025-06-02', 'completed', 1),
+		('ORD-HIST-003', 1, '2025-06-03', 'cancel', 1),
+		('ORD-HIST-004', 1, '2025-06-04', 'completed', 1),
+		('ORD-HIST-005', 2, '2025-06-04', 'completed', 2);
+
+		INSERT INTO order_details (order_id, product_id, qty, product_name, price, created_by) VALUES
+		(2, 1, 1, 'Adjustable Dumbbell 20kg', 200000, 1),
+		(4, 1, 2, 'Adjustable Dumbbell 20kg', 200000, 1),
+		(4, 2, 1, 'Treadmill Compact X100', 50000, 1);
+	`)
+	if err != nil {
+		t.Fatalf("failed seed orders: %v", err)
+	}
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &OrderHandler{DB: db, Ctx: &ctx}
+
+	// Halaman pertama berisi 2 order terbaru milik customer 1
+	page, err := handler.GetOrderHistory(entity.OrderFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetOrderHistory failed: %v", err)
+	}
+	assert.Len(t, page.Orders, 2)
+	assert.True(t, page.HasMore, "Masih ada halaman berikutnya")
+	assert.Equal(t, "ORD-HIST-004", page.Orders[0].NumberDisplay, "Order terbaru tampil lebih dulu")
+	assert.Equal(t, "ORD-HIST-003", page.Orders[1].NumberDisplay)
+
+	// Detail dimuat per order dan tidak tercampur dengan order lain
+	assert.Len(t, page.Orders[0].Details, 2)
+	for _, d := range page.Orders[0].Details {
+		assert.Equal(t, page.Orders[0].ID, d.OrderID)
+	}
+	assert.Empty(t, page.Orders[1].Details)
+
+	// Halaman kedua dimulai setelah cursor halaman pertama
+	page, err = handler.GetOrderHistory(entity.OrderFilter{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("GetOrderHistory failed: %v", err)
+	}
+	assert.Len(t, page.Orders, 2)
+	assert.False(t, page.HasMore, "Halaman terakhir")
+	assert.Equal(t, "ORD-HIST-002", page.Orders[0].NumberDisplay)
+	assert.Len(t, page.Orders[0].Details, 1)
+
+	// Filter status completed hanya mengembalikan order milik customer login
+	page, err = handler.GetOrderHistory(entity.OrderFilter{Status: entity.StatusCompleted})
+	if err != nil {
+		t.Fatalf("GetOrderHistory failed: %v", err)
+	}
+	assert.Len(t, page.Orders, 2)
+	for _, o := range page.Orders {
+		assert.Equal(t, entity.StatusCompleted, o.Status)
+	}
+
+	// Rentang tanggal inklusif di kedua ujung
+	page, err = handler.GetOrderHistory(entity.OrderFilter{
+		DateFrom: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		DateTo:   time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("GetOrderHistory failed: %v", err)
+	}
+	if assert.Len(t, page.Orders, 2) {
+		assert.Equal(t, "ORD-HIST-003", page.Orders[0].NumberDisplay)
+		assert.Equal(t, "ORD-HIST-002", page.Orders[1].NumberDisplay)
+	}
+
+	// Hanya batas akhir, order dari setup (hari ini) tidak ikut
+	page, err = handler.GetOrderHistory(entity.OrderFilter{DateTo: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("GetOrderHistory failed: %v", err)
+	}
+	if assert.Len(t, page.Orders, 1) {
+		assert.Equal(t, "ORD-HIST-002", page.Orders[0].NumberDisplay)
+	}
+}
@@ -3,51 +3,656 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/qris"
 	"pairproject/utils"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // PaymentHandler adalah struct yang bertugas menangani logika terkait pembayaran.
 // DB digunakan untuk koneksi database dan Ctx menyimpan informasi pengguna yang sedang login.
+// Gateway adalah payment gateway penagih dana; nil berarti memakai gateway.Default().
 type PaymentHandler struct {
-	DB  *sql.DB
-	Ctx *context.Context
+	DB      *sql.DB
+	Ctx     *context.Context
+	Gateway gateway.PaymentGateway
+}
+
+// OverpaymentPolicy menentukan perlakuan pembayaran yang melebihi sisa tagihan
+type OverpaymentPolicy int
+
+const (
+	OverpaymentReject			OverpaymentPolicy = iota	// tolak pembayaran (default)
+	OverpaymentAcceptRemainder								// terima sebesar sisa tagihan saja
+	OverpaymentStoreCredit									// terima sisa tagihan, kelebihan disimpan sebagai saldo kredit customer
+)
+
+// OverpaymentError dikembalikan jika nominal pembayaran melebihi sisa tagihan.
+// Pesannya sama dengan trigger trg_payment_before_insert agar pemanggil lama tetap mengenalinya.
+type OverpaymentError struct {
+	Remaining	entity.Money
+	Excess		entity.Money
+}
+
+func (e *OverpaymentError) Error() string {
+	return fmt.Sprintf("Total payment exceeds billing total: sisa tagihan %s, kelebihan %s", e.Remaining.Rupiah(), e.Excess.Rupiah())
+}
+
+// ErrPaymentPending dikembalikan jika gateway belum mengonfirmasi charge; payment dicatat saat charge approved
+var ErrPaymentPending = errors.New("Pembayaran sedang diproses gateway, payment dicatat setelah dikonfirmasi")
+
+// ErrCardRequired dikembalikan jika pembayaran credit_card tidak melalui CreateCardPayment (tanpa token kartu)
+var ErrCardRequired = errors.New("Pembayaran kartu kredit membutuhkan kartu tersimpan, gunakan CreateCardPayment")
+
+// ErrPaymentDuplicate dikembalikan jika dana dengan referensi yang sama (transfer VA, mutasi rekening) sudah pernah dicatat
+var ErrPaymentDuplicate = errors.New("Dana dengan referensi ini sudah pernah dicatat")
+
+// PaymentDeclinedError dikembalikan jika gateway menolak charge; tidak ada payment yang dicatat
+type PaymentDeclinedError struct {
+	ChargeRef	string
+	Reason		string
+}
+
+func (e *PaymentDeclinedError) Error() string {
+	return fmt.Sprintf("Pembayaran ditolak gateway (%s): %s", e.ChargeRef, e.Reason)
 }
 
 // CreatePayment membuat entri pembayaran baru untuk suatu tagihan (billing).
-// Jika pembayaran melebihi batas yang diizinkan oleh trigger DB, maka akan gagal.
+// Pembayaran yang melebihi sisa tagihan ditolak dengan *OverpaymentError.
 // Setelah pembayaran berhasil, status order dan billing akan diperbarui melalui billingHandler.
-func (p *PaymentHandler) CreatePayment(billingHandler *BillingHandler, billing entity.Billing, amount float64, paymentMethod entity.Method) error {
+func (p *PaymentHandler) CreatePayment(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money, paymentMethod entity.Method) error {
+	return p.CreatePaymentWithPolicy(billingHandler, billing, amount, paymentMethod, OverpaymentReject)
+}
+
+// CreatePaymentWithPolicy sama dengan CreatePayment, tetapi kelebihan bayar diperlakukan sesuai policy:
+// ditolak, diterima sebesar sisa tagihan, atau kelebihannya disimpan sebagai saldo kredit customer.
+// Dana ditagih lewat payment gateway dan payment hanya dicatat setelah charge approved;
+// charge yang ditolak menghasilkan *PaymentDeclinedError dan charge yang masih pending menghasilkan ErrPaymentPending.
+func (p *PaymentHandler) CreatePaymentWithPolicy(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money, paymentMethod entity.Method, policy OverpaymentPolicy) error {
+	// Kartu kredit wajib membawa token kartu
+	if paymentMethod == entity.MethodCredit {
+		return ErrCardRequired
+	}
+
+	_, err := p.createCharge(billingHandler, billing, amount, paymentMethod, policy, nil)
+	return err
+}
+
+// CreateCardPayment membayar billing dengan kartu kredit tersimpan milik customer yang login.
+// Hanya token kartu yang dikirim ke gateway; kartu kedaluwarsa atau milik customer lain ditolak.
+func (p *PaymentHandler) CreateCardPayment(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money, cardTokenID int, policy OverpaymentPolicy) error {
+	user, ok := utils.GetUser(*p.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	cards, err := getCardTokens(p.DB, "WHERE id = ? AND customer_id = ?", cardTokenID, user.Customer.ID)
+	if err != nil {
+		return err
+	}
+	if len(cards) == 0 {
+		return errors.New("Kartu tidak ditemukan")
+	}
+	if cards[0].Expired(time.Now()) {
+		return fmt.Errorf("Kartu %s sudah kedaluwarsa", cards[0].Masked())
+	}
+
+	_, err = p.createCharge(billingHandler, billing, amount, entity.MethodCredit, policy, &cards[0])
+	return err
+}
+
+// CreateQRISPayment membuat charge QRIS untuk billing dan mengembalikan charge beserta payload QR yang harus
+// dipindai customer. Charge QRIS selalu pending (ErrPaymentPending) sampai gateway mengonfirmasi pembayarannya.
+func (p *PaymentHandler) CreateQRISPayment(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money, policy OverpaymentPolicy) (entity.PaymentCharge, error) {
+	return p.createCharge(billingHandler, billing, amount, entity.MethodQRIS, policy, nil)
+}
+
+// paymentRequest adalah isi permintaan pembayaran yang dibandingkan saat idempotency key dipakai ulang
+type paymentRequest struct {
+	BillingID   int
+	Amount      entity.Money
+	Method      entity.Method
+	Policy      OverpaymentPolicy
+	CardTokenID int
+}
+
+// paymentResponse adalah hasil charge yang disimpan bersama idempotency key
+type paymentResponse struct {
+	ChargeRef string              `json:"charge_ref"`
+	Status    entity.ChargeStatus `json:"status"`
+	Message   string              `json:"message,omitempty"`
+}
+
+// createCharge menjalankan submitCharge. Jika context berisi idempotency key, permintaan ulang dengan isi yang sama
+// mengembalikan hasil charge pertama tanpa menagih gateway lagi.
+func (p *PaymentHandler) createCharge(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money, paymentMethod entity.Method, policy OverpaymentPolicy, card *entity.CardToken) (entity.PaymentCharge, error) {
+	key, hasKey := utils.GetIdempotencyKey(*p.Ctx)
+	if !hasKey {
+		return p.submitCharge(billingHandler, billing, amount, paymentMethod, policy, card)
+	}
+
+	user, ok := utils.GetUser(*p.Ctx)
+	if !ok {
+		return entity.PaymentCharge{}, fmt.Errorf("Please Login!")
+	}
+
+	request := paymentRequest{BillingID: billing.ID, Amount: amount, Method: paymentMethod, Policy: policy}
+	if card != nil {
+		request.CardTokenID = card.ID
+	}
+	hash, err := requestHash(request)
+	if err != nil {
+		return entity.PaymentCharge{}, err
+	}
+
+	// Key dicatat pending sebelum gateway dipanggil agar permintaan kedua tidak ikut menagih
+	record, found, err := findIdempotencyKey(p.DB, user.ID, idempotencyCreatePayment, key)
+	if err != nil {
+		return entity.PaymentCharge{}, err
+	}
+	if found {
+		if err := checkIdempotencyKey(record, hash); err != nil {
+			return entity.PaymentCharge{}, err
+		}
+		return p.replayPayment(record)
+	}
+	record.RequestHash, record.Status = hash, entity.IdempotencyPending
+	record.ID, err = insertIdempotencyKey(p.DB, record)
+	if err != nil {
+		return entity.PaymentCharge{}, ErrIdempotencyInProgress
+	}
+
+	charge, err := p.submitCharge(billingHandler, billing, amount, paymentMethod, policy, card)
+	if charge.ID == 0 {
+		// Tidak ada dana yang ditagih, key dilepas agar bisa dipakai lagi (misal dengan policy kelebihan bayar lain)
+		if releaseErr := releaseIdempotencyKey(p.DB, record.ID); releaseErr != nil {
+			return charge, fmt.Errorf("Gagal melepas idempotency key: %s", releaseErr)
+		}
+		return charge, err
+	}
+
+	response := paymentResponse{ChargeRef: charge.ChargeRef, Status: entity.ChargePending}
+	var declined *PaymentDeclinedError
+	switch {
+	case err == nil:
+		response.Status = entity.ChargeApproved
+	case errors.As(err, &declined):
+		response.Status, response.Message = entity.ChargeDeclined, declined.Reason
+	}
+	if completeErr := completeIdempotencyKey(p.DB, record.ID, charge.ID, response); completeErr != nil {
+		return charge, completeErr
+	}
+
+	return charge, err
+}
+
+// replayPayment mengembalikan charge dan hasil yang tersimpan pada idempotency key
+func (p *PaymentHandler) replayPayment(record entity.IdempotencyKey) (entity.PaymentCharge, error) {
+	var response paymentResponse
+	if err := json.Unmarshal([]byte(record.Response), &response); err != nil {
+		return entity.PaymentCharge{}, fmt.Errorf("Gagal membaca hasil pembayaran sebelumnya: %s", err)
+	}
+
+	var charge entity.PaymentCharge
+	charges, err := p.getCharges("WHERE pc.id = ?", record.ResourceID)
+	if err != nil {
+		return charge, err
+	}
+	if len(charges) > 0 {
+		charge = charges[0]
+	}
+
+	switch response.Status {
+	case entity.ChargeApproved:
+		return charge, nil
+	case entity.ChargeDeclined:
+		return charge, &PaymentDeclinedError{ChargeRef: response.ChargeRef, Reason: response.Message}
+	}
+	return charge, ErrPaymentPending
+}
+
+// submitCharge menagih dana lewat gateway lalu mencatat payment sesuai hasil charge; card hanya diisi untuk credit_card.
+// Charge yang dikembalikan memiliki ID 0 jika gagal sebelum charge tersimpan.
+func (p *PaymentHandler) submitCharge(billingHandler *BillingHandler, billing entity.Billing, amount entity.Money, paymentMethod entity.Method, policy OverpaymentPolicy, card *entity.CardToken) (entity.PaymentCharge, error) {
 	// Ambil informasi user dari context
 	user, ok := utils.GetUser(*p.Ctx)
 	if !ok {
-		return fmt.Errorf("failed to get user from context")
+		return entity.PaymentCharge{}, fmt.Errorf("failed to get user from context")
+	}
+
+	// Saldo kredit hanya bisa dipakai lewat CreditHandler.ApplyStoreCredit
+	if paymentMethod == entity.MethodStoreCredit {
+		return entity.PaymentCharge{}, errors.New("Gunakan menu saldo kredit untuk membayar dengan store_credit")
+	}
+	if amount <= 0 {
+		return entity.PaymentCharge{}, errors.New("Nominal pembayaran harus lebih dari 0")
 	}
 	
 	// Cek apakah sudah melewati batas waktu pembayaran
 	if time.Now().After(billing.DueDate) {
-		return errors.New("cannot create payment: order is past due date")
+		return entity.PaymentCharge{}, errors.New("cannot create payment: order is past due date")
 	}
 
-	// Query untuk menyimpan data payment baru
-	insertQuery := "INSERT INTO payments (billing_id, amount, created_by, method) VALUES (?, ?, ?, ?)"
-	_, err := p.DB.Exec(insertQuery, billing.ID, amount, user.ID, string(paymentMethod))
+	// Validasi sisa tagihan di Go sebelum dana ditagih ke gateway
+	remaining, err := billingRemaining(p.DB, billing.ID)
 	if err != nil {
-		return fmt.Errorf("Gagal membuat payment: %s", err)
+		return entity.PaymentCharge{}, err
 	}
-	
-	// Setelah payment berhasil dibuat, update status billing dan order terkait
-	err = billingHandler.UpdateOrderAndBillingStatus(billing.ID)
+
+	// Nominal yang ditagih: sisa tagihan saja, atau penuh jika kelebihannya disimpan sebagai saldo kredit
+	charged := amount
+	if amount > remaining {
+		if policy == OverpaymentReject || remaining <= 0 {
+			return entity.PaymentCharge{}, &OverpaymentError{Remaining: remaining, Excess: amount - remaining}
+		}
+		if policy == OverpaymentAcceptRemainder {
+			charged = remaining
+		}
+	}
+
+	// Metode harus aktif dan nominal yang ditagih berada di dalam batas metode; biaya metode ditagih di atas nominal
+	method, err := checkPaymentMethod(p.DB, paymentMethod, charged)
+	if err != nil {
+		return entity.PaymentCharge{}, err
+	}
+	fee := method.Fee(charged)
+
+	req := gateway.ChargeRequest{Reference: billing.NumberDisplay, Amount: charged + fee, Method: paymentMethod}
+	if card != nil {
+		req.CardToken = card.Token
+	}
+	// QR dibuat untuk nominal yang benar-benar ditagih, setelah policy kelebihan bayar dan biaya metode diterapkan
+	if paymentMethod == entity.MethodQRIS {
+		req.QRPayload, err = qris.Generate(qris.DefaultMerchant(), billing.NumberDisplay, req.Amount)
+		if err != nil {
+			return entity.PaymentCharge{}, err
+		}
+	}
+	result, err := p.gateway().Charge(*p.Ctx, req)
+	if err != nil {
+		return entity.PaymentCharge{}, fmt.Errorf("Gagal menghubungi payment gateway: %w", err)
+	}
+
+	charge := entity.PaymentCharge{
+		BillingID:     billing.ID,
+		BillingNumber: billing.NumberDisplay,
+		ChargeRef:     result.ID,
+		Amount:        charged,
+		Fee:           fee,
+		Method:        paymentMethod,
+		Status:        entity.ChargePending,
+		QRPayload:     req.QRPayload,
+		CreatedBy:     user.ID,
+	}
+	if card != nil {
+		charge.CardTokenID, charge.Card = card.ID, card.Masked()
+	}
+	res, err := p.DB.Exec(
+		"INSERT INTO payment_charges (billing_id, charge_ref, amount, fee, method, status, card_token_id, qr_payload, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		charge.BillingID, charge.ChargeRef, charge.Amount, charge.Fee, string(charge.Method), string(charge.Status), nullInt(charge.CardTokenID), nullString(charge.QRPayload), charge.CreatedBy,
+	)
 	if err != nil {
-		return fmt.Errorf("Gagal mengupdate order dan billing: %s", err)
+		return entity.PaymentCharge{}, fmt.Errorf("Gagal menyimpan charge %s: %s", charge.ChargeRef, err)
+	}
+	chargeID, err := res.LastInsertId()
+	if err != nil {
+		return charge, err
+	}
+	charge.ID = int(chargeID)
+
+	return charge, p.resolveCharge(billingHandler, charge, result)
+}
+
+// SyncPendingCharges mengecek ulang semua charge pending ke gateway dan mencatat payment untuk yang sudah approved.
+// Dipanggil oleh worker sehingga tidak membutuhkan user login. Mengembalikan jumlah charge yang statusnya berubah.
+func (p *PaymentHandler) SyncPendingCharges(billingHandler *BillingHandler) (int, error) {
+	return p.syncCharges(billingHandler, 0)
+}
+
+// SyncMyPendingCharges mengecek ulang charge pending milik user yang sedang login,
+// lalu mengembalikan seluruh riwayat charge user tersebut (terbaru di atas).
+func (p *PaymentHandler) SyncMyPendingCharges(billingHandler *BillingHandler) ([]entity.PaymentCharge, error) {
+	user, ok := utils.GetUser(*p.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	if _, err := p.syncCharges(billingHandler, user.ID); err != nil {
+		return nil, err
+	}
+
+	return p.getCharges("WHERE pc.created_by = ?", user.ID)
+}
+
+// syncCharges mengecek charge pending ke gateway; createdBy 0 berarti semua user
+func (p *PaymentHandler) syncCharges(billingHandler *BillingHandler, createdBy int) (int, error) {
+	filter, args := "WHERE pc.status = 'pending'", []interface{}{}
+	if createdBy > 0 {
+		filter, args = filter+" AND pc.created_by = ?", append(args, createdBy)
+	}
+
+	charges, err := p.getCharges(filter, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, charge := range charges {
+		result, err := p.gateway().Status(*p.Ctx, charge.ChargeRef)
+		if err != nil {
+			return changed, fmt.Errorf("Gagal mengecek charge %s: %w", charge.ChargeRef, err)
+		}
+		if result.Status == entity.ChargePending {
+			continue
+		}
+
+		err = p.resolveCharge(billingHandler, charge, result)
+		var declined *PaymentDeclinedError
+		if err != nil && !errors.As(err, &declined) {
+			return changed, err
+		}
+		changed++
+	}
+
+	return changed, nil
+}
+
+// resolveCharge memperbarui charge sesuai hasil gateway. Charge approved dicatat sebagai payment:
+// bagian yang melebihi sisa tagihan (misal billing keburu lunas atau kedaluwarsa saat charge pending)
+// disimpan sebagai saldo kredit karena dananya sudah diterima.
+// Hanya charge yang masih pending yang diproses, sehingga worker dan webhook yang menerima hasil yang sama
+// tidak mencatat payment dua kali.
+func (p *PaymentHandler) resolveCharge(billingHandler *BillingHandler, charge entity.PaymentCharge, result gateway.Charge) error {
+	switch result.Status {
+	case entity.ChargePending:
+		return ErrPaymentPending
+	case entity.ChargeDeclined:
+		res, err := p.DB.Exec(
+			"UPDATE payment_charges SET status = ?, message = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'pending'",
+			string(entity.ChargeDeclined), nullString(result.Message), charge.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("Gagal memperbarui charge %s: %s", charge.ChargeRef, err)
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return nil
+		}
+		return &PaymentDeclinedError{ChargeRef: charge.ChargeRef, Reason: result.Message}
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := p.approveChargeTx(tx, billingHandler, charge, result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
 	}
 		
 	return nil // Berhasil
 }
 
+// approveChargeTx menandai charge pending sebagai approved lalu mencatat payment dan saldo kredit kelebihannya
+// menggunakan transaksi milik pemanggil. Charge yang sudah diselesaikan proses lain dilewati tanpa error.
+// Rollback/commit menjadi tanggung jawab pemanggil.
+func (p *PaymentHandler) approveChargeTx(tx *sql.Tx, billingHandler *BillingHandler, charge entity.PaymentCharge, result gateway.Charge) error {
+	// Klaim charge lebih dulu; charge yang sudah diselesaikan proses lain dilewati
+	res, err := tx.Exec(
+		"UPDATE payment_charges SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'pending'",
+		string(entity.ChargeApproved), charge.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("Gagal memperbarui charge %s: %s", charge.ChargeRef, err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return err
+	}
+
+	// Kunci billing agar sisa tagihan tidak berubah oleh posting payment lain sampai transaksi selesai
+	if err := lockBillingTx(tx, charge.BillingID); err != nil {
+		return err
+	}
+
+	// Billing yang sudah dibatalkan tidak menerima payment, seluruh dana menjadi saldo kredit
+	var status entity.StatusBilling
+	var customerID int
+	err = tx.QueryRow("SELECT b.status, o.customer_id FROM billings b JOIN orders o ON o.id = b.order_id WHERE b.id = ?", charge.BillingID).Scan(&status, &customerID)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	remaining := entity.Money(0)
+	if status != entity.StatusCancelled && status != entity.StatusRefunded {
+		remaining, err = billingRemaining(tx, charge.BillingID)
+		if err != nil {
+			return err
+		}
+	}
+
+	paid := charge.Amount.Min(maxMoney(remaining, 0))
+	if paid > 0 {
+		charge.PaymentID, err = insertPaymentTx(tx, charge.CreatedBy, charge.BillingID, paid, charge.Method)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Kelebihan bayar disimpan sebagai saldo kredit milik customer pemilik order
+	if excess := charge.Amount - paid; excess > 0 {
+		credit := entity.CustomerCredit{
+			CustomerID: customerID,
+			BillingID:  charge.BillingID,
+			PaymentID:  charge.PaymentID,
+			Type:       entity.CreditOverpayment,
+			Amount:     excess,
+			Note:       "Kelebihan bayar " + charge.BillingNumber,
+		}
+		if err := recordCreditTx(tx, charge.CreatedBy, credit); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE payment_charges SET message = ?, payment_id = ? WHERE id = ?",
+		nullString(result.Message), nullInt(charge.PaymentID), charge.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("Gagal memperbarui charge %s: %s", charge.ChargeRef, err)
+	}
+
+	// Status billing dan order diperbarui di transaksi yang sama, sehingga payment tidak pernah
+	// tercatat dengan status billing yang basi
+	if paid > 0 {
+		err = billingHandler.updateOrderAndBillingStatusTx(tx, charge.BillingID)
+		if err != nil {
+			return fmt.Errorf("Gagal mengupdate order dan billing: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// recordReceivedPayment mencatat dana yang sudah diterima di luar gateway (transfer VA, mutasi rekening)
+// sebagai charge approved lalu membuat payment-nya. ChargeRef yang sudah pernah dicatat ditolak dengan ErrPaymentDuplicate.
+// Charge dan payment disimpan dalam satu transaksi, sehingga kegagalan di tengah jalan tidak meninggalkan charge
+// pending yang membuat referensi yang sama ditolak saat dicoba lagi.
+func (p *PaymentHandler) recordReceivedPayment(billingHandler *BillingHandler, charge entity.PaymentCharge) (entity.PaymentCharge, error) {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return charge, err
+	}
+
+	// Constraint unik charge_ref menolak referensi yang sama, termasuk notifikasi ganda yang datang bersamaan
+	charge.Status = entity.ChargePending
+	res, err := tx.Exec(
+		"INSERT INTO payment_charges (billing_id, charge_ref, amount, method, status, message, created_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		charge.BillingID, charge.ChargeRef, charge.Amount, string(charge.Method), string(charge.Status), nullString(charge.Message), charge.CreatedBy,
+	)
+	if err != nil {
+		tx.Rollback()
+		if isDuplicateKeyError(err) {
+			return charge, ErrPaymentDuplicate
+		}
+		return charge, fmt.Errorf("Gagal menyimpan charge %s: %s", charge.ChargeRef, err)
+	}
+	chargeID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return charge, err
+	}
+	charge.ID = int(chargeID)
+
+	result := gateway.Charge{ID: charge.ChargeRef, Status: entity.ChargeApproved, Message: charge.Message}
+	if err := p.approveChargeTx(tx, billingHandler, charge, result); err != nil {
+		tx.Rollback()
+		return charge, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return charge, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	charges, err := p.getCharges("WHERE pc.id = ?", charge.ID)
+	if err != nil || len(charges) == 0 {
+		return charge, err
+	}
+
+	return charges[0], nil
+}
+
+// isDuplicateKeyError mengenali pelanggaran constraint unik dari MySQL (1062) maupun SQLite yang dipakai testing
+func isDuplicateKeyError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// getCharges mengambil charge beserta nomor billing-nya, terbaru di atas
+func (p *PaymentHandler) getCharges(filter string, args ...interface{}) ([]entity.PaymentCharge, error) {
+	rows, err := p.DB.Query(`
+		SELECT pc.id, pc.billing_id, b.number_display, pc.charge_ref, pc.amount, pc.fee, pc.method, pc.status,
+			IFNULL(pc.message, ''), IFNULL(pc.card_token_id, 0), IFNULL(ct.brand, ''), IFNULL(ct.last4, ''),
+			IFNULL(pc.qr_payload, ''), IFNULL(pc.payment_id, 0), pc.created_at, pc.updated_at, pc.created_by
+		FROM payment_charges pc
+		JOIN billings b ON b.id = pc.billing_id
+		LEFT JOIN card_tokens ct ON ct.id = pc.card_token_id
+		`+filter+`
+		ORDER BY pc.id DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil charge: %w", err)
+	}
+	defer rows.Close()
+
+	var charges []entity.PaymentCharge
+	for rows.Next() {
+		var charge entity.PaymentCharge
+		var brand entity.CardBrand
+		var last4 string
+		err := rows.Scan(
+			&charge.ID,
+			&charge.BillingID,
+			&charge.BillingNumber,
+			&charge.ChargeRef,
+			&charge.Amount,
+			&charge.Fee,
+			&charge.Method,
+			&charge.Status,
+			&charge.Message,
+			&charge.CardTokenID,
+			&brand,
+			&last4,
+			&charge.QRPayload,
+			&charge.PaymentID,
+			&charge.CreatedAt,
+			&charge.UpdatedAt,
+			&charge.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if charge.CardTokenID != 0 {
+			charge.Card = entity.MaskCard(brand, last4)
+		}
+		charges = append(charges, charge)
+	}
+
+	return charges, rows.Err()
+}
+
+// gateway mengembalikan payment gateway handler, atau gateway default jika belum diisi
+func (p *PaymentHandler) gateway() gateway.PaymentGateway {
+	return gatewayOrDefault(p.Gateway)
+}
+
+// gatewayOrDefault mengembalikan gw, atau gateway.Default() jika nil
+func gatewayOrDefault(gw gateway.PaymentGateway) gateway.PaymentGateway {
+	if gw != nil {
+		return gw
+	}
+	return gateway.Default()
+}
+
+// billingRemaining menghitung sisa tagihan dengan aturan yang sama seperti trigger trg_payment_before_insert:
+// total setelah credit note / debit note dikurangi dana bersih (pembayaran dikurangi refund)
+func billingRemaining(q queryer, billingID int) (entity.Money, error) {
+	var total, paid, refunded entity.Money
+	err := q.QueryRow(`
+		SELECT total
+			+ (SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = billings.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = billings.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = billings.id AND status <> 'failed')
+		FROM billings
+		WHERE id = ?
+	`, billingID).Scan(&total, &paid, &refunded)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("Billing tidak ditemukan")
+		}
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	return total - (paid - refunded), nil
+}
+
+// insertPaymentTx menyimpan payment lalu mengalokasikannya ke cicilan tertua yang belum lunas
+// (jika billing memakai rencana cicilan). Mengembalikan ID payment baru.
+func insertPaymentTx(tx *sql.Tx, userID int, billingID int, amount entity.Money, paymentMethod entity.Method) (int, error) {
+	// Query untuk menyimpan data payment baru
+	insertQuery := "INSERT INTO payments (billing_id, amount, created_by, method) VALUES (?, ?, ?, ?)"
+	res, err := tx.Exec(insertQuery, billingID, amount, userID, string(paymentMethod))
+	if err != nil {
+		return 0, fmt.Errorf("Gagal membuat payment: %s", err)
+	}
+
+	paymentID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := allocatePaymentTx(tx, billingID, int(paymentID), amount); err != nil {
+		return 0, err
+	}
+
+	return int(paymentID), nil
+}
+
 // GetPaymentsByBillingID mengambil semua riwayat pembayaran berdasarkan ID tagihan (billing_id)
 func (p *PaymentHandler) GetPaymentsByBillingID(billingID int) ([]entity.Payment, error) {
 	// Query untuk mengambil semua payment berdasarkan billing_id
@@ -56,7 +661,7 @@ func (p *PaymentHandler) GetPaymentsByBillingID(billingID int) ([]entity.Payment
 			id, billing_id, date, amount, method, created_at, updated_at, created_by, IFNULL(updated_by, 0)
 		FROM payments
 		WHERE billing_id = ?
-		ORDER BY date ASC
+		ORDER BY date ASC, id ASC
 	`
 
 	rows, err := p.DB.Query(query, billingID)
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/qris"
 	"pairproject/utils"
 	"testing"
 	"time"
@@ -65,13 +68,61 @@ CREATE TABLE payments (
     billing_id INTEGER NOT NULL,
     date DATETIME DEFAULT CURRENT_TIMESTAMP,
     amount NUMERIC DEFAULT 0 CHECK (amount >= 0) NOT NULL,
-    method TEXT NOT NULL CHECK (method IN ('credit_card', 'va', 'transfer')),
+    method TEXT NOT NULL CHECK (method IN ('credit_card', 'va', 'transfer', 'qris')),
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     created_by INTEGER NOT NULL,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (billing_id) REFERENCES billings(id) ON DELETE CASCADE
 );
 
+CREATE TABLE card_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    brand TEXT NOT NULL,
+    last4 TEXT NOT NULL,
+    exp_month INTEGER NOT NULL,
+    exp_year INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL
+);
+
+CREATE TABLE payment_charges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    charge_ref TEXT NOT NULL UNIQUE,
+    amount NUMERIC NOT NULL,
+    fee NUMERIC NOT NULL DEFAULT 0,
+    method TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    message TEXT,
+    card_token_id INTEGER,
+    qr_payload TEXT,
+    payment_id INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL
+);
+
+CREATE TABLE billing_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    number_display TEXT NOT NULL UNIQUE,
+    billing_id INTEGER NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('credit', 'debit')),
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL
+);
+
+CREATE TABLE refunds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    payment_id INTEGER NOT NULL,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    status TEXT NOT NULL DEFAULT 'completed'
+);
+
 -- Trigger untuk mencegah pembayaran yang melebihi total tagihan
 CREATE TRIGGER validate_payment_amount
 BEFORE INSERT ON payments
@@ -81,8 +132,10 @@ BEGIN
         CASE
             WHEN (
                 (SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = NEW.billing_id)
+                - (SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = NEW.billing_id AND status <> 'failed')
                 + NEW.amount
-            ) > (SELECT amount FROM billings WHERE id = NEW.billing_id)
+            ) > (SELECT total FROM billings WHERE id = NEW.billing_id)
+                + (SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = NEW.billing_id)
             THEN RAISE(ABORT, 'Total payment exceeds billing total')
         END;
 END;
@@ -93,6 +146,7 @@ END;
 	if err != nil {
 		t.Fatalf("failed create schema: %v", err) // Jika gagal membuat schema, hentikan test
 	}
+	addPaymentMethodsTable(t, db)
 	return db
 }
 
@@ -107,12 +161,183 @@ func TestCreatePayment_Error(t *testing.T){
 
 	// Simulasi data pembayaran
 	var method entity.Method = "va"
-	billing := entity.Billing{ID: 1, DueDate: time.Now()} // Billing yang sudah ada di DB (ID:1)
+	billing := entity.Billing{ID: 1, DueDate: time.Now().Add(time.Hour)} // Billing yang sudah ada di DB (ID:1), belum jatuh tempo
 
 	// Coba membuat payment dengan nominal lebih besar dari total billing yang seharusnya
-	err := handler.CreatePayment(billingHandler, billing, 30000000.00, method)
+	err := handler.CreatePayment(billingHandler, billing, entity.NewMoney(30000000), method)
 
 	// Pastikan error terjadi
 	require.Error(t, err) // Test gagal jika tidak ada error
 	assert.Contains(t, err.Error(), "Total payment exceeds billing total") // Pastikan pesan error sesuai
-}
\ No newline at end of file
+}
+
+// TestCreatePayment_GatewayDeclined memastikan charge yang ditolak gateway tidak menghasilkan payment
+func TestCreatePayment_GatewayDeclined(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	sim := gateway.NewSimulator(gateway.ModeApprove)
+	sim.DeclineAbove = entity.NewMoney(50000)
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: sim}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+
+	// Nominal di atas limit simulator ditolak
+	err := handler.CreatePayment(billingHandler, billing, entity.NewMoney(60000), entity.MethodVA)
+	var declined *PaymentDeclinedError
+	require.True(t, errors.As(err, &declined))
+	assert.Equal(t, "Nominal melebihi limit transaksi", declined.Reason)
+
+	// Mode decline menolak semua charge
+	handler.Gateway = gateway.NewSimulator(gateway.ModeDecline)
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(10000), entity.MethodVA)
+	require.True(t, errors.As(err, &declined))
+
+	var payments, declinedCharges int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payments").Scan(&payments))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_charges WHERE status = 'declined' AND payment_id IS NULL").Scan(&declinedCharges))
+	assert.Equal(t, 0, payments)
+	assert.Equal(t, 2, declinedCharges)
+}
+
+// TestCreateQRISPayment menguji charge QRIS yang menampilkan QR lalu dicatat setelah gateway mengonfirmasi pembayaran
+func TestCreateQRISPayment(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	sim := gateway.NewSimulator(gateway.ModeApprove)
+	sim.Delay = time.Minute
+	sim.Now = func() time.Time { return now }
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: sim}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	// Kelebihan bayar diterima sebesar sisa tagihan, QR dibuat untuk nominal yang ditagih
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: now.Add(time.Hour)}
+	charge, err := handler.CreateQRISPayment(billingHandler, billing, entity.NewMoney(120000), OverpaymentAcceptRemainder)
+	assert.ErrorIs(t, err, ErrPaymentPending)
+	require.NotEmpty(t, charge.QRPayload)
+
+	payload, err := qris.Parse(charge.QRPayload)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(100000), payload.Amount)
+	assert.Equal(t, "BIL-001", payload.BillNumber)
+
+	charges, err := handler.SyncMyPendingCharges(billingHandler)
+	require.NoError(t, err)
+	require.Len(t, charges, 1)
+	assert.Equal(t, entity.MethodQRIS, charges[0].Method)
+	assert.Equal(t, charge.QRPayload, charges[0].QRPayload)
+	assert.Equal(t, entity.ChargePending, charges[0].Status)
+
+	// Setelah QR dipindai gateway mengonfirmasi charge dan payment dicatat
+	now = now.Add(2 * time.Minute)
+	changed, err := handler.SyncPendingCharges(billingHandler)
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+
+	var method, status string
+	require.NoError(t, db.QueryRow("SELECT method FROM payments WHERE billing_id = 1").Scan(&method))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	assert.Equal(t, string(entity.MethodQRIS), method)
+	assert.Equal(t, string(entity.StatusPaid), status)
+}
+
+// TestCreatePayment_GatewayDelayed menguji charge pending yang baru dicatat sebagai payment setelah dikonfirmasi gateway
+func TestCreatePayment_GatewayDelayed(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	sim := gateway.NewSimulator(gateway.ModeDelay)
+	sim.Delay = time.Minute
+	sim.Now = func() time.Time { return now }
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: sim}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: now.Add(time.Hour)}
+	err := handler.CreatePayment(billingHandler, billing, entity.NewMoney(70000), entity.MethodVA)
+	assert.ErrorIs(t, err, ErrPaymentPending)
+
+	// Selama pending belum ada payment, namun sisa tagihan tetap bisa dibayar dengan cara lain
+	handler.Gateway = gateway.NewSimulator(gateway.ModeApprove)
+	require.NoError(t, handler.CreatePayment(billingHandler, billing, entity.NewMoney(50000), entity.MethodTransfer))
+	handler.Gateway = sim
+
+	charges, err := handler.SyncMyPendingCharges(billingHandler)
+	require.NoError(t, err)
+	require.Len(t, charges, 2)
+	assert.Equal(t, entity.ChargePending, charges[1].Status)
+	assert.Equal(t, 0, charges[1].PaymentID)
+
+	// Setelah delay berlalu charge approved: 50000 melunasi billing, kelebihan 20000 menjadi saldo kredit
+	now = now.Add(2 * time.Minute)
+	changed, err := handler.SyncPendingCharges(billingHandler)
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+
+	var paid entity.Money
+	var status string
+	require.NoError(t, db.QueryRow("SELECT SUM(amount) FROM payments WHERE billing_id = 1").Scan(&paid))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	assert.Equal(t, entity.NewMoney(100000), paid)
+	assert.Equal(t, string(entity.StatusPaid), status)
+
+	balance, err := (&CreditHandler{DB: db, Ctx: &ctx}).GetMyCreditBalance()
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(20000), balance)
+
+	charges, err = handler.SyncMyPendingCharges(billingHandler)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChargeApproved, charges[1].Status)
+	assert.NotZero(t, charges[1].PaymentID)
+}
+
+// TestCreatePayment_SingleTransaction menguji posting payment dan update status billing/order sebagai satu transaksi
+func TestCreatePayment_SingleTransaction(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	// Satu koneksi saja: update status yang memakai koneksi di luar transaksi akan deadlock
+	db.SetMaxOpenConns(1)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: gateway.NewSimulator(gateway.ModeApprove)}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+	require.NoError(t, handler.CreatePayment(billingHandler, billing, entity.NewMoney(100000), entity.MethodVA))
+
+	var billingStatus, orderStatus string
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&billingStatus))
+	require.NoError(t, db.QueryRow("SELECT status FROM orders WHERE id = 1").Scan(&orderStatus))
+	assert.Equal(t, string(entity.StatusPaid), billingStatus)
+	assert.Equal(t, "completed", orderStatus)
+
+	// Update order yang gagal membatalkan payment yang baru dicatat
+	_, err := db.Exec(`
+		CREATE TRIGGER trg_order_locked BEFORE UPDATE ON orders
+		BEGIN
+			SELECT RAISE(ABORT, 'Order terkunci');
+		END;
+	`)
+	require.NoError(t, err)
+
+	otherCtx := utils.WithUser(ctx, &entity.User{ID: 2, Customer: entity.Customer{ID: 2}})
+	handler.Ctx, billingHandler.Ctx = &otherCtx, &otherCtx
+	billing = entity.Billing{ID: 2, NumberDisplay: "BIL-002", DueDate: time.Now().Add(time.Hour)}
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(50000), entity.MethodVA)
+	assert.ErrorContains(t, err, "Order terkunci")
+
+	var payments int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payments WHERE billing_id = 2").Scan(&payments))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 2").Scan(&billingStatus))
+	assert.Equal(t, 0, payments)
+	assert.Equal(t, string(entity.StatusUnpaid), billingStatus)
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+)
+
+// PaymentMethodHandler mengelola metode pembayaran: aktif/nonaktif, biaya, batas nominal, dan urutan tampil
+type PaymentMethodHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// paymentMethodColumns adalah kolom payment_methods yang dibaca scanPaymentMethods
+const paymentMethodColumns = "id, code, name, enabled, fee_flat, fee_percent, min_amount, max_amount, display_order"
+
+// GetPaymentMethods mengambil semua metode pembayaran, termasuk yang nonaktif, urut sesuai display_order (untuk admin)
+func (h *PaymentMethodHandler) GetPaymentMethods() ([]entity.PaymentMethod, error) {
+	if _, ok := utils.GetUser(*h.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	return getPaymentMethods(h.DB, "")
+}
+
+// GetAvailablePaymentMethods mengambil metode pembayaran aktif untuk menu pembayaran customer
+func (h *PaymentMethodHandler) GetAvailablePaymentMethods() ([]entity.PaymentMethod, error) {
+	if _, ok := utils.GetUser(*h.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	return getPaymentMethods(h.DB, "WHERE enabled = 1")
+}
+
+// UpdatePaymentMethod menyimpan nama, biaya, batas nominal, dan urutan tampil sebuah metode pembayaran (khusus admin)
+func (h *PaymentMethodHandler) UpdatePaymentMethod(method entity.PaymentMethod) error {
+	user, ok := utils.GetUser(*h.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	method.Name = strings.TrimSpace(method.Name)
+	switch {
+	case method.Name == "":
+		return errors.New("Nama metode pembayaran wajib diisi")
+	case method.FeeFlat < 0:
+		return errors.New("Biaya tetap tidak boleh negatif")
+	case method.FeeRate < 0 || method.FeeRate > 10000:
+		return errors.New("Biaya persentase harus antara 0 dan 100%")
+	case method.MinAmount < 0 || method.MaxAmount < 0:
+		return errors.New("Batas nominal tidak boleh negatif")
+	case method.MaxAmount > 0 && method.MaxAmount < method.MinAmount:
+		return errors.New("Batas maksimal harus lebih besar dari batas minimal")
+	}
+
+	res, err := h.DB.Exec(
+		"UPDATE payment_methods SET name = ?, fee_flat = ?, fee_percent = ?, min_amount = ?, max_amount = ?, display_order = ?, updated_by = ? WHERE code = ?",
+		method.Name, method.FeeFlat, basisPointsToPercent(method.FeeRate), method.MinAmount, method.MaxAmount, method.DisplayOrder, user.ID, string(method.Code),
+	)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan menyimpan metode pembayaran: %s", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("Metode pembayaran %s tidak ditemukan", method.Code)
+	}
+
+	return nil
+}
+
+// SetPaymentMethodEnabled mengaktifkan atau menonaktifkan sebuah metode pembayaran (khusus admin).
+// Charge yang sudah berjalan tetap diselesaikan; metode nonaktif hanya menolak pembayaran baru.
+func (h *PaymentMethodHandler) SetPaymentMethodEnabled(code entity.Method, enabled bool) error {
+	user, ok := utils.GetUser(*h.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	res, err := h.DB.Exec("UPDATE payment_methods SET enabled = ?, updated_by = ? WHERE code = ?", enabled, user.ID, string(code))
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan menyimpan metode pembayaran: %s", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("Metode pembayaran %s tidak ditemukan", code)
+	}
+
+	return nil
+}
+
+// getPaymentMethods mengambil metode pembayaran dengan filter tambahan, urut sesuai display_order
+func getPaymentMethods(q queryer, filter string) ([]entity.PaymentMethod, error) {
+	rows, err := q.Query("SELECT " + paymentMethodColumns + " FROM payment_methods " + filter + " ORDER BY display_order ASC, id ASC")
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil metode pembayaran: %w", err)
+	}
+	defer rows.Close()
+
+	var methods []entity.PaymentMethod
+	for rows.Next() {
+		method, err := scanPaymentMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	return methods, rows.Err()
+}
+
+// scanPaymentMethod membaca satu baris paymentMethodColumns
+func scanPaymentMethod(row interface{ Scan(...interface{}) error }) (entity.PaymentMethod, error) {
+	var method entity.PaymentMethod
+	var code string
+	var feePercent float64
+	err := row.Scan(&method.ID, &code, &method.Name, &method.Enabled, &method.FeeFlat, &feePercent, &method.MinAmount, &method.MaxAmount, &method.DisplayOrder)
+	if err != nil {
+		return method, err
+	}
+	method.Code = entity.Method(code)
+	method.FeeRate = percentToBasisPoints(feePercent)
+
+	return method, nil
+}
+
+// checkPaymentMethod memastikan metode pembayaran aktif dan amount berada di dalam batas nominalnya.
+// Mengembalikan pengaturan metode agar biayanya bisa dihitung.
+func checkPaymentMethod(q queryer, code entity.Method, amount entity.Money) (entity.PaymentMethod, error) {
+	method, err := scanPaymentMethod(q.QueryRow("SELECT "+paymentMethodColumns+" FROM payment_methods WHERE code = ?", string(code)))
+	if err == sql.ErrNoRows {
+		return method, fmt.Errorf("Metode pembayaran %s tidak tersedia", code)
+	}
+	if err != nil {
+		return method, fmt.Errorf("Terjadi kesalahan mengambil metode pembayaran: %s", err)
+	}
+	if !method.Enabled {
+		return method, fmt.Errorf("Metode pembayaran %s sedang tidak tersedia", method.Name)
+	}
+
+	if !method.InRange(amount) {
+		if method.MinAmount > 0 && amount < method.MinAmount {
+			return method, fmt.Errorf("Nominal pembayaran %s minimal %s", method.Name, method.MinAmount.Rupiah())
+		}
+		return method, fmt.Errorf("Nominal pembayaran %s maksimal %s", method.Name, method.MaxAmount.Rupiah())
+	}
+
+	return method, nil
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/qris"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addPaymentMethodsTable menambahkan tabel payment_methods berisi semua metode aktif tanpa biaya dan batas nominal
+func addPaymentMethodsTable(t *testing.T, db *sql.DB) {
+	_, err := db.Exec(`
+		CREATE TABLE payment_methods (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			fee_flat NUMERIC NOT NULL DEFAULT 0,
+			fee_percent NUMERIC NOT NULL DEFAULT 0,
+			min_amount NUMERIC NOT NULL DEFAULT 0,
+			max_amount NUMERIC NOT NULL DEFAULT 0,
+			display_order INTEGER NOT NULL DEFAULT 0,
+			updated_by INTEGER
+		);
+
+		INSERT INTO payment_methods (code, name, display_order) VALUES
+		('credit_card', 'Kartu Kredit', 1),
+		('va', 'Virtual Account', 2),
+		('transfer', 'Transfer Bank', 3),
+		('qris', 'QRIS', 4),
+		('store_credit', 'Saldo Kredit', 5);
+	`)
+	require.NoError(t, err, "Gagal membuat tabel payment_methods")
+}
+
+// TestPaymentMethodHandler menguji pengelolaan metode pembayaran oleh admin dan daftar metode untuk customer.
+func TestPaymentMethodHandler(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	ctx := utils.WithUser(context.Background(), &entity.User{ID: 1, Role: entity.RoleAdmin})
+	handler := &PaymentMethodHandler{DB: db, Ctx: &ctx}
+
+	// Urutan tampil bisa diubah; biaya persen disimpan sebagai basis poin
+	err := handler.UpdatePaymentMethod(entity.PaymentMethod{Code: entity.MethodQRIS, Name: "QRIS (semua e-wallet)", FeeRate: 70, MaxAmount: entity.NewMoney(10000000), DisplayOrder: 0})
+	require.NoError(t, err)
+	require.NoError(t, handler.SetPaymentMethodEnabled(entity.MethodTransfer, false))
+
+	methods, err := handler.GetPaymentMethods()
+	require.NoError(t, err)
+	require.Len(t, methods, 5)
+	assert.Equal(t, entity.MethodQRIS, methods[0].Code)
+	assert.Equal(t, "QRIS (semua e-wallet)", methods[0].Name)
+	assert.Equal(t, int64(70), methods[0].FeeRate)
+	assert.Equal(t, entity.NewMoney(10000000), methods[0].MaxAmount)
+
+	available, err := handler.GetAvailablePaymentMethods()
+	require.NoError(t, err)
+	require.Len(t, available, 4, "metode nonaktif tidak ditawarkan ke customer")
+	for _, method := range available {
+		assert.NotEqual(t, entity.MethodTransfer, method.Code)
+	}
+
+	// Input tidak valid ditolak
+	assert.Error(t, handler.UpdatePaymentMethod(entity.PaymentMethod{Code: entity.MethodVA, Name: ""}))
+	assert.Error(t, handler.UpdatePaymentMethod(entity.PaymentMethod{Code: entity.MethodVA, Name: "VA", FeeRate: 10001}))
+	assert.Error(t, handler.UpdatePaymentMethod(entity.PaymentMethod{Code: entity.MethodVA, Name: "VA", MinAmount: entity.NewMoney(5000), MaxAmount: entity.NewMoney(1000)}))
+	assert.Error(t, handler.UpdatePaymentMethod(entity.PaymentMethod{Code: "cash", Name: "Tunai"}))
+	assert.Error(t, handler.SetPaymentMethodEnabled("cash", true))
+}
+
+// TestCreatePayment_PaymentMethodRules menguji metode nonaktif, batas nominal, dan biaya metode saat membayar.
+func TestCreatePayment_PaymentMethodRules(t *testing.T) {
+	db := SetupTestCreditDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		UPDATE payment_methods SET enabled = 0 WHERE code = 'transfer';
+		UPDATE payment_methods SET min_amount = 20000, max_amount = 60000 WHERE code = 'va';
+		UPDATE payment_methods SET fee_flat = 2500, fee_percent = 1.50 WHERE code = 'credit_card';
+		UPDATE payment_methods SET fee_percent = 0.70 WHERE code = 'qris';
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: gateway.NewSimulator(gateway.ModeApprove)}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+
+	// Metode nonaktif dan nominal di luar batas ditolak sebelum gateway dihubungi
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(30000), entity.MethodTransfer)
+	assert.ErrorContains(t, err, "tidak tersedia")
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(10000), entity.MethodVA)
+	assert.ErrorContains(t, err, "minimal Rp 20.000,00")
+	err = handler.CreatePayment(billingHandler, billing, entity.NewMoney(70000), entity.MethodVA)
+	assert.ErrorContains(t, err, "maksimal Rp 60.000,00")
+
+	var charges int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_charges").Scan(&charges))
+	assert.Equal(t, 0, charges)
+
+	// Biaya ditagih di atas nominal, billing hanya menerima nominal pembayarannya
+	cardCtx := utils.WithUser(ctx, &entity.User{ID: 1, Customer: entity.Customer{ID: 1}})
+	card, err := (&CardHandler{DB: db, Ctx: &cardCtx, Gateway: handler.Gateway}).SaveCard(entity.CardDetails{Number: "4242424242424242", ExpMonth: 12, ExpYear: time.Now().Year() + 2, CVV: "123"})
+	require.NoError(t, err)
+	require.NoError(t, handler.CreateCardPayment(billingHandler, billing, entity.NewMoney(40000), card.ID, OverpaymentReject))
+
+	var fee, paid entity.Money
+	require.NoError(t, db.QueryRow("SELECT fee FROM payment_charges WHERE method = 'credit_card'").Scan(&fee))
+	require.NoError(t, db.QueryRow("SELECT SUM(amount) FROM payments WHERE billing_id = 1").Scan(&paid))
+	assert.Equal(t, entity.NewMoney(3100), fee, "2500 + 1,5% x 40000")
+	assert.Equal(t, entity.NewMoney(40000), paid)
+
+	// QR dibuat untuk nominal ditambah biaya
+	sim := gateway.NewSimulator(gateway.ModeApprove)
+	handler.Gateway = sim
+	charge, err := handler.CreateQRISPayment(billingHandler, billing, entity.NewMoney(50000), OverpaymentReject)
+	assert.True(t, err == nil || errors.Is(err, ErrPaymentPending))
+	assert.Equal(t, entity.NewMoney(350), charge.Fee)
+	assert.Equal(t, entity.NewMoney(50350), charge.Total())
+	payload, err := qris.Parse(charge.QRPayload)
+	require.NoError(t, err)
+	assert.Equal(t, charge.Total(), payload.Amount)
+
+	// Saldo kredit nonaktif tidak bisa dipakai membayar
+	_, err = db.Exec("UPDATE payment_methods SET enabled = 0 WHERE code = 'store_credit'")
+	require.NoError(t, err)
+	err = (&CreditHandler{DB: db, Ctx: &ctx}).ApplyStoreCredit(billingHandler, billing, entity.NewMoney(1000))
+	assert.ErrorContains(t, err, "tidak tersedia")
+}
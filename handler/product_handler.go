@@ -62,13 +62,14 @@ func (p *ProductHandler) CreateProduct(product entity.Product) error {
 	}
 
 	// Query SQL untuk memasukkan data produk baru ke tabel products
+	// (tax_class kosong disimpan NULL agar produk mengikuti kelas pajak kategorinya)
 	query := `
-		INSERT INTO products (name, stock, description, category_id, price, created_by)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO products (name, stock, description, category_id, price, weight, tax_class, price_includes_tax, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	// Jalankan query dengan parameter dari input product dan ID user sebagai created_by
-	_, err := p.DB.Exec(query, product.Name, product.Stock, product.Description, product.CategoryID, product.Price, user.ID)
+	_, err := p.DB.Exec(query, product.Name, product.Stock, product.Description, product.CategoryID, product.Price, product.Weight, nullString(product.TaxClass), product.PriceIncludesTax, user.ID)
 	if err != nil {
 		// Jika terjadi error saat eksekusi query insert, return error dengan pesan generik
 		return fmt.Errorf("Terjadi kesalahan ketika membuat produk")
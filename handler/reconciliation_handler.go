@@ -0,0 +1,405 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReconciliationHandler mengimpor mutasi rekening bank dan mencocokkannya dengan billing yang masih terbuka (khusus admin)
+type ReconciliationHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// StatementImportResult adalah ringkasan satu kali impor file statement
+type StatementImportResult struct {
+	Lines      int // seluruh mutasi di file
+	Debits     int // mutasi debit / nol yang dilewati
+	Duplicates int // mutasi yang sudah pernah diimpor
+	Matched    int // cocok dengan yakin, payment dibuat otomatis
+	Review     int // masuk antrean review admin
+}
+
+// statementMatch adalah hasil pencocokan satu mutasi kredit ke billing
+type statementMatch struct {
+	billingID     int
+	billingNumber string
+	method        entity.Method
+	confident     bool
+	note          string
+}
+
+var (
+	// documentNumberPattern mencari nomor billing / order di referensi dan keterangan transfer
+	documentNumberPattern = regexp.MustCompile(`\b(BIL|ORD)-\d{6}-\d{3,}\b`)
+	// vaNumberPattern mencari kandidat nomor virtual account
+	vaNumberPattern = regexp.MustCompile(`\b\d{16}\b`)
+)
+
+// ImportStatement menyimpan mutasi kredit dari file statement lalu mencocokkannya ke billing terbuka.
+// Mutasi yang cocok dengan yakin (nomor billing / order / VA disebut, atau nominal sama persis dengan sisa tagihan
+// satu-satunya billing) langsung dibuatkan payment; sisanya masuk antrean review. Mutasi yang sudah pernah diimpor dilewati.
+func (r *ReconciliationHandler) ImportStatement(billingHandler *BillingHandler, source string, lines []entity.StatementLine) (StatementImportResult, error) {
+	result := StatementImportResult{Lines: len(lines)}
+
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return result, fmt.Errorf("Please Login!")
+	}
+
+	paymentHandler := PaymentHandler{DB: r.DB, Ctx: r.Ctx}
+	for _, line := range lines {
+		if line.Amount <= 0 {
+			result.Debits++
+			continue
+		}
+
+		fingerprint := statementFingerprint(line)
+		var exists int
+		err := r.DB.QueryRow("SELECT COUNT(*) FROM bank_statement_lines WHERE fingerprint = ?", fingerprint).Scan(&exists)
+		if err != nil {
+			return result, fmt.Errorf("Terjadi kesalahan mengambil mutasi: %s", err)
+		}
+		if exists > 0 {
+			result.Duplicates++
+			continue
+		}
+
+		match, err := matchStatementLine(r.DB, line)
+		if err != nil {
+			return result, err
+		}
+
+		res, err := r.DB.Exec(`
+			INSERT INTO bank_statement_lines (source, fingerprint, booked_at, amount, reference, description, status, billing_id, note, created_by)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, source, fingerprint, line.Date, line.Amount, nullString(line.Reference), nullString(line.Description),
+			string(entity.StatementReview), nullInt(match.billingID), nullString(match.note), user.ID)
+		if err != nil {
+			return result, fmt.Errorf("Gagal menyimpan mutasi: %s", err)
+		}
+		lineID, err := res.LastInsertId()
+		if err != nil {
+			return result, err
+		}
+
+		if !match.confident {
+			result.Review++
+			continue
+		}
+
+		charge, err := paymentHandler.recordReceivedPayment(billingHandler, entity.PaymentCharge{
+			BillingID:     match.billingID,
+			BillingNumber: match.billingNumber,
+			ChargeRef:     "STMT-" + strconv.FormatInt(lineID, 10),
+			Amount:        line.Amount,
+			Method:        match.method,
+			Message:       "Mutasi " + source,
+			CreatedBy:     user.ID,
+		})
+		if err != nil {
+			// Payment gagal dibuat: biarkan admin memutuskan lewat antrean review
+			r.DB.Exec("UPDATE bank_statement_lines SET note = ? WHERE id = ?", "Gagal membuat payment: "+err.Error(), lineID)
+			result.Review++
+			continue
+		}
+
+		_, err = r.DB.Exec(
+			"UPDATE bank_statement_lines SET status = ?, payment_id = ? WHERE id = ?",
+			string(entity.StatementMatched), nullInt(charge.PaymentID), lineID,
+		)
+		if err != nil {
+			return result, fmt.Errorf("Gagal memperbarui mutasi: %s", err)
+		}
+		result.Matched++
+	}
+
+	return result, nil
+}
+
+// GetReviewQueue mengambil mutasi yang menunggu keputusan admin, urut dari yang terlama
+func (r *ReconciliationHandler) GetReviewQueue() ([]entity.BankStatementLine, error) {
+	if _, ok := utils.GetUser(*r.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	return getStatementLines(r.DB, "WHERE sl.status = ? ORDER BY sl.booked_at ASC, sl.id ASC", string(entity.StatementReview))
+}
+
+// ResolveStatementLine mencocokkan mutasi di antrean review ke billing pilihan admin dan membuat payment-nya.
+// Bagian yang melebihi sisa tagihan billing menjadi saldo kredit customer.
+func (r *ReconciliationHandler) ResolveStatementLine(billingHandler *BillingHandler, lineID int, billNumber string) (entity.BankStatementLine, error) {
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return entity.BankStatementLine{}, fmt.Errorf("Please Login!")
+	}
+
+	line, err := r.getReviewLine(lineID)
+	if err != nil {
+		return line, err
+	}
+
+	err = r.DB.QueryRow("SELECT id, number_display FROM billings WHERE number_display = ?", strings.TrimSpace(billNumber)).Scan(&line.BillingID, &line.BillingNumber)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return line, errors.New("Billing tidak ditemukan")
+		}
+		return line, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	paymentHandler := PaymentHandler{DB: r.DB, Ctx: r.Ctx}
+	charge, err := paymentHandler.recordReceivedPayment(billingHandler, entity.PaymentCharge{
+		BillingID:     line.BillingID,
+		BillingNumber: line.BillingNumber,
+		ChargeRef:     "STMT-" + strconv.Itoa(line.ID),
+		Amount:        line.Amount,
+		Method:        entity.MethodTransfer,
+		Message:       "Mutasi " + line.Source,
+		CreatedBy:     user.ID,
+	})
+	if err != nil {
+		return line, err
+	}
+
+	line.Status, line.PaymentID, line.ResolvedBy = entity.StatementResolved, charge.PaymentID, user.ID
+	_, err = r.DB.Exec(
+		"UPDATE bank_statement_lines SET status = ?, billing_id = ?, payment_id = ?, resolved_by = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(line.Status), line.BillingID, nullInt(line.PaymentID), user.ID, line.ID,
+	)
+	if err != nil {
+		return line, fmt.Errorf("Gagal memperbarui mutasi: %s", err)
+	}
+
+	return line, nil
+}
+
+// IgnoreStatementLine menandai mutasi di antrean review sebagai bukan pembayaran customer (misal bunga bank, salah transfer)
+func (r *ReconciliationHandler) IgnoreStatementLine(lineID int, note string) error {
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return errors.New("Alasan wajib diisi")
+	}
+	if _, err := r.getReviewLine(lineID); err != nil {
+		return err
+	}
+
+	_, err := r.DB.Exec(
+		"UPDATE bank_statement_lines SET status = ?, note = ?, resolved_by = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(entity.StatementIgnored), note, user.ID, lineID,
+	)
+	if err != nil {
+		return fmt.Errorf("Gagal memperbarui mutasi: %s", err)
+	}
+
+	return nil
+}
+
+// getReviewLine mengambil satu mutasi yang masih berada di antrean review
+func (r *ReconciliationHandler) getReviewLine(lineID int) (entity.BankStatementLine, error) {
+	lines, err := getStatementLines(r.DB, "WHERE sl.id = ?", lineID)
+	if err != nil {
+		return entity.BankStatementLine{}, err
+	}
+	if len(lines) == 0 {
+		return entity.BankStatementLine{}, errors.New("Mutasi tidak ditemukan")
+	}
+	if lines[0].Status != entity.StatementReview {
+		return lines[0], fmt.Errorf("Mutasi sudah berstatus %s", lines[0].Status)
+	}
+
+	return lines[0], nil
+}
+
+// matchStatementLine mencari billing untuk satu mutasi kredit. Nomor billing, nomor order, atau nomor VA
+// di referensi / keterangan lebih diutamakan; jika tidak ada, dicari billing terbuka dengan sisa tagihan yang sama persis.
+func matchStatementLine(q queryer, line entity.StatementLine) (statementMatch, error) {
+	text := strings.ToUpper(line.Reference + " " + line.Description)
+
+	// Kumpulkan billing yang disebut di referensi / keterangan
+	type candidate struct {
+		id     int
+		number string
+		status entity.StatusBilling
+		method entity.Method
+	}
+	var candidates []candidate
+	seen := map[int]bool{}
+	add := func(query string, arg interface{}, method entity.Method) error {
+		var c candidate
+		err := q.QueryRow(query, arg).Scan(&c.id, &c.number, &c.status)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Terjadi kesalahan mencocokkan mutasi: %s", err)
+		}
+		if !seen[c.id] {
+			seen[c.id] = true
+			c.method = method
+			candidates = append(candidates, c)
+		}
+		return nil
+	}
+
+	for _, number := range documentNumberPattern.FindAllString(text, -1) {
+		query := "SELECT id, number_display, status FROM billings WHERE number_display = ?"
+		if strings.HasPrefix(number, "ORD") {
+			// Order bisa punya beberapa billing; utamakan billing yang masih terbuka lalu yang terbaru
+			query = `
+				SELECT b.id, b.number_display, b.status
+				FROM billings b
+				JOIN orders o ON o.id = b.order_id
+				WHERE o.number_display = ?
+				ORDER BY CASE WHEN b.status IN ('unpaid', 'lesspaid') THEN 0 ELSE 1 END, b.id DESC
+				LIMIT 1
+			`
+		}
+		if err := add(query, number, entity.MethodTransfer); err != nil {
+			return statementMatch{}, err
+		}
+	}
+	for _, number := range vaNumberPattern.FindAllString(text, -1) {
+		if !entity.ValidVANumber(number) {
+			continue
+		}
+		query := "SELECT b.id, b.number_display, b.status FROM virtual_accounts va JOIN billings b ON b.id = va.billing_id WHERE va.va_number = ?"
+		if err := add(query, number, entity.MethodVA); err != nil {
+			return statementMatch{}, err
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		c := candidates[0]
+		match := statementMatch{billingID: c.id, billingNumber: c.number, method: c.method}
+		if c.status != entity.StatusUnpaid && c.status != entity.StatusLesspaid {
+			match.note = fmt.Sprintf("%s berstatus %s", c.number, c.status)
+			return match, nil
+		}
+
+		remaining, err := billingRemaining(q, c.id)
+		if err != nil {
+			return match, err
+		}
+		if line.Amount > remaining {
+			match.note = fmt.Sprintf("Nominal melebihi sisa tagihan %s (%s)", c.number, remaining.Rupiah())
+			return match, nil
+		}
+
+		match.confident, match.note = true, "Nomor dokumen cocok"
+		return match, nil
+	case len(candidates) > 1:
+		var numbers []string
+		for _, c := range candidates {
+			numbers = append(numbers, c.number)
+		}
+		return statementMatch{note: "Menyebut beberapa billing: " + strings.Join(numbers, ", ")}, nil
+	}
+
+	// Tanpa nomor dokumen: cocokkan nominal dengan sisa tagihan billing terbuka
+	rows, err := q.Query(`
+		SELECT id, number_display
+		FROM billings b
+		WHERE status IN ('unpaid', 'lesspaid')
+			AND total
+				+ (SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0) FROM billing_adjustments WHERE billing_id = b.id)
+				- (SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = b.id)
+				+ (SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = b.id AND status <> 'failed') = CAST(? AS DECIMAL(12,2))
+		ORDER BY id ASC
+		LIMIT 2
+	`, line.Amount)
+	if err != nil {
+		return statementMatch{}, fmt.Errorf("Terjadi kesalahan mencocokkan mutasi: %s", err)
+	}
+	defer rows.Close()
+
+	var matches []statementMatch
+	for rows.Next() {
+		var match statementMatch
+		if err := rows.Scan(&match.billingID, &match.billingNumber); err != nil {
+			return statementMatch{}, err
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return statementMatch{}, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return statementMatch{note: "Tidak ada billing yang cocok"}, nil
+	case 1:
+		match := matches[0]
+		match.method, match.confident, match.note = entity.MethodTransfer, true, "Nominal sama dengan sisa tagihan"
+		return match, nil
+	}
+	return statementMatch{note: "Beberapa billing memiliki sisa tagihan yang sama"}, nil
+}
+
+// statementFingerprint membuat sidik unik mutasi dari tanggal, nominal, referensi, dan keterangan
+func statementFingerprint(line entity.StatementLine) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		line.Date.Format("2006-01-02"),
+		line.Amount.String(),
+		strings.TrimSpace(line.Reference),
+		strings.TrimSpace(line.Description),
+	}, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// getStatementLines mengambil mutasi statement beserta nomor billing-nya; filter berisi WHERE dan ORDER BY
+func getStatementLines(q queryer, filter string, args ...interface{}) ([]entity.BankStatementLine, error) {
+	rows, err := q.Query(`
+		SELECT sl.id, sl.source, sl.fingerprint, sl.booked_at, sl.amount, IFNULL(sl.reference, ''), IFNULL(sl.description, ''),
+			sl.status, IFNULL(sl.billing_id, 0), IFNULL(b.number_display, ''), IFNULL(sl.payment_id, 0), IFNULL(sl.note, ''),
+			sl.created_at, sl.created_by, IFNULL(sl.resolved_by, 0)
+		FROM bank_statement_lines sl
+		LEFT JOIN billings b ON b.id = sl.billing_id
+		`+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil mutasi: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []entity.BankStatementLine
+	for rows.Next() {
+		var line entity.BankStatementLine
+		err := rows.Scan(
+			&line.ID,
+			&line.Source,
+			&line.Fingerprint,
+			&line.Date,
+			&line.Amount,
+			&line.Reference,
+			&line.Description,
+			&line.Status,
+			&line.BillingID,
+			&line.BillingNumber,
+			&line.PaymentID,
+			&line.Note,
+			&line.CreatedAt,
+			&line.CreatedBy,
+			&line.ResolvedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestReconciliationDB memakai schema virtual account ditambah tabel bank_statement_lines.
+// Nomor billing dan order diubah ke format asli agar bisa dikenali dari keterangan transfer.
+func SetupTestReconciliationDB(t *testing.T) *sql.DB {
+	db := SetupTestVirtualAccountDB(t)
+
+	_, err := db.Exec(`
+		ALTER TABLE orders ADD COLUMN number_display TEXT;
+		UPDATE orders SET number_display = 'ORD-202610-00' || id;
+		UPDATE billings SET number_display = 'BIL-202610-00' || id;
+
+		CREATE TABLE bank_statement_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			fingerprint TEXT NOT NULL UNIQUE,
+			booked_at DATE NOT NULL,
+			amount NUMERIC NOT NULL CHECK (amount > 0),
+			reference TEXT,
+			description TEXT,
+			status TEXT NOT NULL DEFAULT 'review' CHECK (status IN ('matched', 'review', 'resolved', 'ignored')),
+			billing_id INTEGER,
+			payment_id INTEGER,
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL,
+			resolved_at DATETIME,
+			resolved_by INTEGER
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema rekonsiliasi")
+
+	return db
+}
+
+// TestImportStatement menguji pencocokan otomatis lewat nomor dokumen dan nominal, antrean review, dan impor ganda.
+func TestImportStatement(t *testing.T) {
+	db := SetupTestReconciliationDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReconciliationHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	date := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	lines := []entity.StatementLine{
+		// Menyebut nomor billing: dibayar sebagian
+		{Date: date, Amount: entity.NewMoney(40000), Reference: "TRF001", Description: "PEMBAYARAN BIL-202610-001"},
+		// Tanpa nomor dokumen, nominal sama dengan sisa tagihan BIL-202610-002 saja
+		{Date: date, Amount: entity.NewMoney(50000), Reference: "TRF002", Description: "TRANSFER DARI BUDI"},
+		// Tidak cocok dengan billing mana pun
+		{Date: date, Amount: entity.NewMoney(12345), Reference: "TRF003", Description: "BUNGA"},
+		// Debit dilewati
+		{Date: date, Amount: entity.NewMoney(-5000), Reference: "ADM", Description: "BIAYA ADMIN"},
+	}
+
+	result, err := handler.ImportStatement(billingHandler, "mutasi.csv", lines)
+	require.NoError(t, err)
+	assert.Equal(t, StatementImportResult{Lines: 4, Debits: 1, Matched: 2, Review: 1}, result)
+
+	var paid entity.Money
+	require.NoError(t, db.QueryRow("SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = 1").Scan(&paid))
+	assert.Equal(t, entity.NewMoney(40000), paid)
+	require.NoError(t, db.QueryRow("SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = 2").Scan(&paid))
+	assert.Equal(t, entity.NewMoney(50000), paid)
+
+	queue, err := handler.GetReviewQueue()
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, "TRF003", queue[0].Reference)
+	assert.Equal(t, 0, queue[0].BillingID)
+
+	// File yang sama diimpor ulang tidak membuat payment baru
+	result, err = handler.ImportStatement(billingHandler, "mutasi.csv", lines)
+	require.NoError(t, err)
+	assert.Equal(t, StatementImportResult{Lines: 4, Debits: 1, Duplicates: 3}, result)
+}
+
+// TestImportStatement_Review menguji mutasi ambigu yang harus diputuskan admin.
+func TestImportStatement_Review(t *testing.T) {
+	db := SetupTestReconciliationDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReconciliationHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	_, err := db.Exec("UPDATE billings SET total = 100000 WHERE id = 2")
+	require.NoError(t, err)
+
+	date := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	lines := []entity.StatementLine{
+		// Dua billing memiliki sisa tagihan yang sama
+		{Date: date, Amount: entity.NewMoney(100000), Reference: "TRF010", Description: "TRANSFER"},
+		// Melebihi sisa tagihan billing yang disebut
+		{Date: date, Amount: entity.NewMoney(150000), Reference: "TRF011", Description: "ORD-202610-001"},
+		// Menyebut dua billing sekaligus
+		{Date: date, Amount: entity.NewMoney(20000), Reference: "TRF012", Description: "BIL-202610-001 BIL-202610-002"},
+	}
+
+	result, err := handler.ImportStatement(billingHandler, "mutasi.csv", lines)
+	require.NoError(t, err)
+	assert.Equal(t, StatementImportResult{Lines: 3, Review: 3}, result)
+
+	queue, err := handler.GetReviewQueue()
+	require.NoError(t, err)
+	require.Len(t, queue, 3)
+	assert.Equal(t, "BIL-202610-001", queue[1].BillingNumber, "billing yang disebut tetap disarankan")
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payments").Scan(&count))
+	assert.Equal(t, 0, count)
+}
+
+// TestResolveStatementLine menguji pencocokan manual dan pengabaian mutasi dari antrean review.
+func TestResolveStatementLine(t *testing.T) {
+	db := SetupTestReconciliationDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReconciliationHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	date := time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC)
+	_, err := handler.ImportStatement(billingHandler, "mutasi.sta", []entity.StatementLine{
+		{Date: date, Amount: entity.NewMoney(30000), Reference: "TRF020", Description: "TRANSFER ANI"},
+		{Date: date, Amount: entity.NewMoney(1000), Reference: "INT", Description: "BUNGA JASA GIRO"},
+	})
+	require.NoError(t, err)
+
+	queue, err := handler.GetReviewQueue()
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+
+	line, err := handler.ResolveStatementLine(billingHandler, queue[0].ID, "BIL-202610-001")
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatementResolved, line.Status)
+	assert.NotZero(t, line.PaymentID)
+
+	var paid entity.Money
+	require.NoError(t, db.QueryRow("SELECT amount FROM payments WHERE id = ?", line.PaymentID).Scan(&paid))
+	assert.Equal(t, entity.NewMoney(30000), paid)
+
+	// Mutasi yang sudah diputuskan tidak bisa diputuskan ulang
+	_, err = handler.ResolveStatementLine(billingHandler, queue[0].ID, "BIL-202610-001")
+	assert.Error(t, err)
+	_, err = handler.ResolveStatementLine(billingHandler, queue[1].ID, "BIL-404")
+	assert.Error(t, err)
+
+	assert.Error(t, handler.IgnoreStatementLine(queue[1].ID, " "))
+	require.NoError(t, handler.IgnoreStatementLine(queue[1].ID, "Bunga bank"))
+
+	queue, err = handler.GetReviewQueue()
+	require.NoError(t, err)
+	assert.Empty(t, queue)
+}
@@ -0,0 +1,504 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+	"strings"
+)
+
+// RefundHandler menangani pengembalian dana atas pembayaran billing, baik manual maupun dari retur.
+// Gateway dipakai untuk mengembalikan dana pembayaran yang masuk lewat payment gateway; nil berarti gateway.Default().
+type RefundHandler struct {
+	DB      *sql.DB
+	Ctx     *context.Context
+	Gateway gateway.PaymentGateway
+}
+
+// CreateRefund mengembalikan sebagian atau seluruh dana dari satu pembayaran (khusus admin).
+// Refund dicatat dan di-commit lebih dulu; pembayaran hasil charge baru direfund di gateway setelahnya.
+func (r *RefundHandler) CreateRefund(paymentID int, amount entity.Money, reason string) (entity.Refund, error) {
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return entity.Refund{}, fmt.Errorf("Please Login!")
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entity.Refund{}, err
+	}
+
+	refund, err := createRefundTx(tx, user.ID, paymentID, 0, amount, reason)
+	if err != nil {
+		tx.Rollback()
+		return refund, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return refund, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	refunds, err := settleRefunds(*r.Ctx, gatewayOrDefault(r.Gateway), r.DB, []entity.Refund{refund})
+	return refunds[0], err
+}
+
+// GetRefundablePayments mengambil pembayaran sebuah billing beserta jumlah yang sudah direfund.
+// Billing yang sudah dibatalkan ditolak karena dananya sudah dipindahkan ke saldo kredit.
+func (r *RefundHandler) GetRefundablePayments(billNumber string) ([]entity.Payment, error) {
+	if _, ok := utils.GetUser(*r.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	var status entity.StatusBilling
+	err := r.DB.QueryRow("SELECT status FROM billings WHERE number_display = ?", billNumber).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Billing tidak ditemukan")
+		}
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil billing: %w", err)
+	}
+	if status == entity.StatusCancelled {
+		return nil, errBillingCancelledRefund
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT p.id, p.billing_id, p.date, p.amount, p.method,
+			(SELECT IFNULL(SUM(rf.amount), 0) FROM refunds rf WHERE rf.payment_id = p.id AND rf.status <> 'failed')
+		FROM payments p
+		JOIN billings b ON b.id = p.billing_id
+		WHERE b.number_display = ?
+		ORDER BY p.id ASC
+	`, billNumber)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil pembayaran: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []entity.Payment
+	for rows.Next() {
+		var pmt entity.Payment
+		err := rows.Scan(&pmt.ID, &pmt.BillingID, &pmt.Date, &pmt.Amount, &pmt.Method, &pmt.Refunded)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, pmt)
+	}
+
+	return payments, rows.Err()
+}
+
+// GetRefundsByBillingID mengambil refund sebuah billing yang tidak gagal (pending dan completed)
+func (r *RefundHandler) GetRefundsByBillingID(billingID int) ([]entity.Refund, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, billing_id, payment_id, IFNULL(return_id, 0), amount, reason, status,
+			IFNULL(charge_ref, ''), IFNULL(gateway_ref, ''), IFNULL(message, ''), created_at, created_by
+		FROM refunds
+		WHERE billing_id = ? AND status <> 'failed'
+		ORDER BY id ASC
+	`, billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil refund: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []entity.Refund
+	for rows.Next() {
+		var refund entity.Refund
+		err := rows.Scan(
+			&refund.ID,
+			&refund.BillingID,
+			&refund.PaymentID,
+			&refund.ReturnID,
+			&refund.Amount,
+			&refund.Reason,
+			&refund.Status,
+			&refund.ChargeRef,
+			&refund.GatewayRef,
+			&refund.Message,
+			&refund.CreatedAt,
+			&refund.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// errBillingCancelledRefund menandakan refund diminta atas billing yang sudah dibatalkan
+var errBillingCancelledRefund = errors.New("Billing sudah dibatalkan, dana pembayarannya sudah dipindahkan ke saldo kredit")
+
+// createRefundTx mencatat refund atas satu pembayaran lalu menyesuaikan status billing.
+// Jumlah refund tidak boleh melebihi sisa dana pembayaran yang belum direfund maupun dipindahkan ke saldo kredit,
+// dan billing yang sudah dibatalkan tidak bisa direfund.
+// Pembayaran yang berasal dari charge payment gateway dicatat pending; gateway baru dipanggil oleh settleRefunds
+// setelah transaksi di-commit, sehingga transaksi database tidak tertahan menunggu gateway.
+func createRefundTx(tx *sql.Tx, userID int, paymentID int, returnID int, amount entity.Money, reason string) (entity.Refund, error) {
+	refund := entity.Refund{PaymentID: paymentID, ReturnID: returnID, Amount: amount, Reason: strings.TrimSpace(reason), CreatedBy: userID}
+
+	if amount <= 0 {
+		return refund, errors.New("Jumlah refund harus lebih dari 0")
+	}
+	if refund.Reason == "" {
+		return refund, errors.New("Alasan refund wajib diisi")
+	}
+
+	err := tx.QueryRow("SELECT billing_id FROM payments WHERE id = ?", paymentID).Scan(&refund.BillingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return refund, errors.New("Pembayaran tidak ditemukan")
+		}
+		return refund, fmt.Errorf("Terjadi kesalahan mengambil pembayaran: %s", err)
+	}
+
+	// Kunci billing sebelum menjumlahkan refund, sehingga dua refund bersamaan atas pembayaran yang sama
+	// tidak sama-sama lolos pengecekan sisa dana
+	if err := lockBillingTx(tx, refund.BillingID); err != nil {
+		return refund, err
+	}
+
+	var status entity.StatusBilling
+	err = tx.QueryRow("SELECT status FROM billings WHERE id = ?", refund.BillingID).Scan(&status)
+	if err != nil {
+		return refund, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+	if status == entity.StatusCancelled {
+		return refund, errBillingCancelledRefund
+	}
+
+	// Ambil pembayaran beserta total yang sudah direfund (refund gagal tidak dihitung) dan yang sudah
+	// dipindahkan ke saldo kredit saat billing kedaluwarsa
+	var paid, refunded, credited entity.Money
+	err = tx.QueryRow(`
+		SELECT amount,
+			(SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE payment_id = payments.id AND status <> 'failed'),
+			(SELECT IFNULL(SUM(amount), 0) FROM customer_credits WHERE payment_id = payments.id AND type = ?)
+		FROM payments
+		WHERE id = ?
+	`, string(entity.CreditFromExpired), paymentID).Scan(&paid, &refunded, &credited)
+	if err != nil {
+		return refund, fmt.Errorf("Terjadi kesalahan mengambil pembayaran: %s", err)
+	}
+
+	remaining := paid - refunded - credited
+	if amount > remaining {
+		return refund, fmt.Errorf("Jumlah refund melebihi sisa pembayaran (%s)", remaining)
+	}
+
+	// Pembayaran lama dan saldo kredit tidak punya charge, cukup dicatat di database
+	err = tx.QueryRow("SELECT charge_ref FROM payment_charges WHERE payment_id = ?", paymentID).Scan(&refund.ChargeRef)
+	if err != nil && err != sql.ErrNoRows {
+		return refund, fmt.Errorf("Terjadi kesalahan mengambil charge pembayaran: %s", err)
+	}
+	refund.Status = entity.RefundCompleted
+	if refund.ChargeRef != "" {
+		refund.Status = entity.RefundPending
+	}
+
+	res, err := tx.Exec(
+		"INSERT INTO refunds (billing_id, payment_id, return_id, amount, reason, status, charge_ref, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		refund.BillingID, paymentID, nullInt(returnID), amount, refund.Reason, string(refund.Status), nullString(refund.ChargeRef), userID,
+	)
+	if err != nil {
+		return refund, errors.New("Terjadi kesalahan menyimpan refund")
+	}
+
+	refundID, err := res.LastInsertId()
+	if err != nil {
+		return refund, err
+	}
+	refund.ID = int(refundID)
+
+	if err := updateBillingAfterRefundTx(tx, refund.BillingID); err != nil {
+		return refund, err
+	}
+
+	return refund, nil
+}
+
+// settleRefunds meneruskan refund pending ke payment gateway setelah transaksi pencatatannya di-commit.
+// Setiap refund dikirim dengan referensi tetap sehingga gateway tidak memprosesnya dua kali jika diulang.
+// Refund yang diterima gateway menjadi completed beserta ID refund gateway; yang ditolak menjadi failed
+// dan status billing dihitung ulang tanpa refund tersebut. Refund yang gagal dikirim tetap pending dan
+// diulang oleh worker lewat SyncPendingRefunds. Semua refund tetap diproses meski ada yang gagal;
+// seluruh error dikembalikan sekaligus.
+func settleRefunds(ctx context.Context, gw gateway.PaymentGateway, db *sql.DB, refunds []entity.Refund) ([]entity.Refund, error) {
+	var errs []error
+	for i, refund := range refunds {
+		if refund.Status != entity.RefundPending {
+			continue
+		}
+
+		result, err := gw.Refund(ctx, refund.ChargeRef, refundReference(refund.ID), refund.Amount)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Refund %d belum diproses payment gateway dan akan dicoba ulang: %w", refund.ID, err))
+			continue
+		}
+
+		refunds[i], err = applyRefundResult(db, refund, result)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return refunds, errors.Join(errs...)
+}
+
+// SyncPendingRefunds mengecek ulang refund yang masih pending ke payment gateway, misal karena proses sebelumnya
+// terhenti atau gateway tidak bisa dihubungi. Refund yang belum pernah diterima gateway dikirim ulang dengan
+// referensi yang sama. Mengembalikan jumlah refund yang selesai diproses; error satu refund tidak menghentikan
+// refund lainnya.
+func (r *RefundHandler) SyncPendingRefunds() (int, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, billing_id, payment_id, amount, charge_ref, created_by
+		FROM refunds
+		WHERE status = 'pending' AND charge_ref IS NOT NULL
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil refund pending: %w", err)
+	}
+
+	var refunds []entity.Refund
+	for rows.Next() {
+		refund := entity.Refund{Status: entity.RefundPending}
+		err := rows.Scan(&refund.ID, &refund.BillingID, &refund.PaymentID, &refund.Amount, &refund.ChargeRef, &refund.CreatedBy)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		refunds = append(refunds, refund)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	gw := gatewayOrDefault(r.Gateway)
+	var errs []error
+	changed := 0
+	for _, refund := range refunds {
+		reference := refundReference(refund.ID)
+		result, err := gw.RefundStatus(*r.Ctx, reference)
+		if errors.Is(err, gateway.ErrRefundNotFound) {
+			result, err = gw.Refund(*r.Ctx, refund.ChargeRef, reference, refund.Amount)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Gagal mengecek refund %d: %w", refund.ID, err))
+			continue
+		}
+
+		settled, err := applyRefundResult(r.DB, refund, result)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if settled.Status != entity.RefundPending {
+			changed++
+		}
+	}
+
+	return changed, errors.Join(errs...)
+}
+
+// refundReference adalah referensi refund yang dikirim ke gateway sebagai kunci idempotensi
+func refundReference(refundID int) string {
+	return fmt.Sprintf("RF-%d", refundID)
+}
+
+// applyRefundResult menyimpan hasil refund dari gateway. Hanya refund yang masih pending yang diubah,
+// sehingga hasil yang sama dari settleRefunds dan worker tidak diproses dua kali.
+// Penolakan gateway dikembalikan sebagai error setelah refund ditandai failed.
+func applyRefundResult(db *sql.DB, refund entity.Refund, result gateway.Refund) (entity.Refund, error) {
+	switch result.Status {
+	case entity.RefundCompleted:
+		_, err := db.Exec(
+			"UPDATE refunds SET status = ?, gateway_ref = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'pending'",
+			string(entity.RefundCompleted), result.ID, refund.ID,
+		)
+		if err != nil {
+			return refund, fmt.Errorf("Gagal memperbarui refund %d: %s", refund.ID, err)
+		}
+		refund.Status, refund.GatewayRef = entity.RefundCompleted, result.ID
+	case entity.RefundFailed:
+		if err := failRefund(db, refund, result.Message); err != nil {
+			return refund, err
+		}
+		refund.Status, refund.Message = entity.RefundFailed, result.Message
+		return refund, fmt.Errorf("Refund ditolak payment gateway: %s", result.Message)
+	}
+
+	return refund, nil
+}
+
+// failRefund menandai refund pending sebagai failed lalu menghitung ulang status billing dalam satu transaksi.
+// Jika billing keburu dibatalkan karena lewat jatuh tempo, dana refund yang gagal tidak ikut dipindahkan saat itu,
+// sehingga dana tersebut dipindahkan ke saldo kredit customer di sini.
+func failRefund(db *sql.DB, refund entity.Refund, message string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := lockBillingTx(tx, refund.BillingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	res, err := tx.Exec(
+		"UPDATE refunds SET status = ?, message = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'pending'",
+		string(entity.RefundFailed), nullString(message), refund.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Gagal memperbarui refund %d: %s", refund.ID, err)
+	}
+
+	var status entity.StatusBilling
+	var customerID int
+	err = tx.QueryRow(`
+		SELECT b.status, o.customer_id
+		FROM billings b
+		JOIN orders o ON o.id = b.order_id
+		WHERE b.id = ?
+	`, refund.BillingID).Scan(&status, &customerID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 && status == entity.StatusCancelled {
+		credit := entity.CustomerCredit{
+			CustomerID: customerID,
+			BillingID:  refund.BillingID,
+			PaymentID:  refund.PaymentID,
+			Type:       entity.CreditFromExpired,
+			Amount:     refund.Amount,
+			Note:       "Refund gagal atas billing yang sudah dibatalkan",
+		}
+		if err := recordCreditTx(tx, refund.CreatedBy, credit); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := updateBillingAfterRefundTx(tx, refund.BillingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return nil
+}
+
+// refundReturnTx mencatat refund dana retur yang disetujui, dimulai dari pembayaran terakhir billing.
+// Refund pending diteruskan ke gateway oleh settleRefunds setelah transaksi di-commit.
+func refundReturnTx(tx *sql.Tx, userID int, returnID int, billingID int, amount entity.Money, reason string) ([]entity.Refund, error) {
+	rows, err := tx.Query(`
+		SELECT id, amount
+			- (SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE payment_id = payments.id AND status <> 'failed')
+			- (SELECT IFNULL(SUM(amount), 0) FROM customer_credits WHERE payment_id = payments.id AND type = ?)
+		FROM payments
+		WHERE billing_id = ?
+		ORDER BY id DESC
+	`, string(entity.CreditFromExpired), billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil pembayaran: %w", err)
+	}
+
+	type refundable struct {
+		paymentID int
+		remaining entity.Money
+	}
+	var payments []refundable
+	for rows.Next() {
+		var p refundable
+		if err := rows.Scan(&p.paymentID, &p.remaining); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Bagi jumlah refund ke pembayaran yang masih punya sisa dana
+	var refunds []entity.Refund
+	left := amount
+	for _, p := range payments {
+		if left <= 0 {
+			break
+		}
+		if p.remaining <= 0 {
+			continue
+		}
+
+		portion := left.Min(p.remaining)
+		refund, err := createRefundTx(tx, userID, p.paymentID, returnID, portion, reason)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+		left -= portion
+	}
+
+	if left > 0 {
+		return nil, fmt.Errorf("Dana pembayaran tidak cukup untuk refund %s", amount)
+	}
+
+	return refunds, nil
+}
+
+// updateBillingAfterRefundTx menghitung ulang status billing dari pembayaran dikurangi refund yang tidak gagal:
+// refunded jika seluruh dana dikembalikan, lesspaid jika sisa dana kurang dari total setelah credit note / debit note, selain itu paid.
+// Hanya billing berstatus lesspaid, paid, atau refunded yang diubah; billing yang sudah dibatalkan tetap cancelled.
+func updateBillingAfterRefundTx(tx *sql.Tx, billingID int) error {
+	var total, paid, refunded entity.Money
+	err := tx.QueryRow(`
+		SELECT total,
+			(SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = billings.id),
+			(SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = billings.id AND status <> 'failed')
+		FROM billings
+		WHERE id = ?
+	`, billingID).Scan(&total, &paid, &refunded)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	adjusted, err := billingAdjustmentTotal(tx, billingID)
+	if err != nil {
+		return err
+	}
+	total += adjusted
+
+	net := paid - refunded
+	status := entity.StatusPaid
+	switch {
+	case net <= 0:
+		status = entity.StatusRefunded
+	case net < total:
+		status = entity.StatusLesspaid
+	}
+
+	_, err = tx.Exec(
+		"UPDATE billings SET status = ? WHERE id = ? AND status IN (?, ?, ?)",
+		string(status), billingID, string(entity.StatusLesspaid), string(entity.StatusPaid), string(entity.StatusRefunded),
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mengubah status billing")
+	}
+
+	return nil
+}
@@ -0,0 +1,456 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestRefundDB membuat database in-memory SQLite berisi billing lunas dengan dua pembayaran.
+func SetupTestRefundDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	// Satu koneksi agar semua query melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE customers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL
+		);
+
+		INSERT INTO customers (name) VALUES ('John Doe');
+
+		CREATE TABLE user_customers (
+			user_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL
+		);
+
+		INSERT INTO user_customers (user_id, customer_id) VALUES (1, 1);
+
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT,
+			customer_id INTEGER
+		);
+
+		INSERT INTO orders (number_display, customer_id) VALUES ('ORD-001', 1);
+
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			number_display TEXT,
+			total REAL DEFAULT 0,
+			status TEXT DEFAULT 'unpaid'
+		);
+
+		INSERT INTO billings (order_id, number_display, total, status) VALUES (1, 'BIL-001', 300000, 'paid');
+
+		CREATE TABLE payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			date DATETIME DEFAULT CURRENT_TIMESTAMP,
+			amount REAL NOT NULL DEFAULT 0,
+			method TEXT NOT NULL
+		);
+
+		INSERT INTO payments (billing_id, amount, method) VALUES (1, 200000, 'transfer'), (1, 100000, 'va');
+
+		CREATE TABLE billing_adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT NOT NULL UNIQUE,
+			billing_id INTEGER NOT NULL,
+			type TEXT NOT NULL CHECK (type IN ('credit', 'debit')),
+			amount NUMERIC NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			payment_id INTEGER NOT NULL,
+			return_id INTEGER,
+			amount REAL NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed',
+			charge_ref TEXT,
+			gateway_ref TEXT,
+			message TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE payment_charges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			charge_ref TEXT NOT NULL UNIQUE,
+			payment_id INTEGER
+		);
+
+		CREATE TABLE customer_credits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			billing_id INTEGER,
+			payment_id INTEGER,
+			type TEXT NOT NULL,
+			amount NUMERIC NOT NULL CHECK (amount <> 0),
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema refund")
+
+	return db
+}
+
+// TestCreateRefund menguji refund sebagian, refund penuh, dan perubahan status billing.
+func TestCreateRefund(t *testing.T) {
+	db := SetupTestRefundDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &RefundHandler{DB: db, Ctx: &ctx}
+
+	billingStatus := func() string {
+		var status string
+		require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+		return status
+	}
+
+	// Validasi input
+	_, err := handler.CreateRefund(1, entity.NewMoney(0), "Salah transfer")
+	assert.Error(t, err)
+	_, err = handler.CreateRefund(1, entity.NewMoney(1000), "")
+	assert.Error(t, err)
+	_, err = handler.CreateRefund(99, entity.NewMoney(1000), "Salah transfer")
+	assert.Error(t, err)
+
+	// Refund sebagian membuat billing kembali lesspaid
+	refund, err := handler.CreateRefund(2, entity.NewMoney(40000), "Diskon susulan")
+	require.NoError(t, err)
+	assert.Equal(t, 1, refund.BillingID)
+	assert.Equal(t, string(entity.StatusLesspaid), billingStatus())
+
+	// Refund tidak boleh melebihi sisa pembayaran
+	_, err = handler.CreateRefund(2, entity.NewMoney(60001), "Salah transfer")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "melebihi")
+
+	// Seluruh dana dikembalikan
+	_, err = handler.CreateRefund(2, entity.NewMoney(60000), "Pembatalan")
+	require.NoError(t, err)
+	_, err = handler.CreateRefund(1, entity.NewMoney(200000), "Pembatalan")
+	require.NoError(t, err)
+	assert.Equal(t, string(entity.StatusRefunded), billingStatus())
+
+	payments, err := handler.GetRefundablePayments("BIL-001")
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+	assert.Equal(t, entity.NewMoney(100000), payments[1].Refunded)
+}
+
+// TestCreateRefund_AfterDebitNote memastikan status billing setelah refund dihitung dari total yang sudah ditambah debit note.
+func TestCreateRefund_AfterDebitNote(t *testing.T) {
+	db := SetupTestRefundDB(t)
+	defer db.Close()
+
+	// Debit note 30.000 dan pembayaran ketiga melunasi total 330.000
+	_, err := db.Exec(`
+		INSERT INTO billing_adjustments (number_display, billing_id, type, amount, reason, created_by) VALUES ('DN-202506-001', 1, 'debit', 30000, 'Ongkir susulan', 1);
+		INSERT INTO payments (billing_id, amount, method) VALUES (1, 30000, 'transfer');
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &RefundHandler{DB: db, Ctx: &ctx}
+
+	_, err = handler.CreateRefund(3, entity.NewMoney(5000), "Kelebihan ongkir")
+	require.NoError(t, err)
+
+	var status string
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	assert.Equal(t, string(entity.StatusLesspaid), status)
+}
+
+// refundRecorder membungkus gateway simulator dan mencatat setiap refund yang diteruskan ke gateway.
+// Jika db diisi, status refund terakhir di database ikut dicatat saat gateway dipanggil.
+type refundRecorder struct {
+	gateway.PaymentGateway
+	db       *sql.DB
+	refunds  []gateway.Refund
+	statuses []string
+}
+
+func (r *refundRecorder) Refund(ctx context.Context, chargeID string, reference string, amount entity.Money) (gateway.Refund, error) {
+	if r.db != nil {
+		// Dengan satu koneksi, query ini hanya bisa berjalan jika transaksi refund sudah di-commit
+		var status string
+		if err := r.db.QueryRow("SELECT status FROM refunds ORDER BY id DESC LIMIT 1").Scan(&status); err != nil {
+			return gateway.Refund{}, err
+		}
+		r.statuses = append(r.statuses, status)
+	}
+
+	refund, err := r.PaymentGateway.Refund(ctx, chargeID, reference, amount)
+	if err == nil && refund.Status == entity.RefundCompleted {
+		r.refunds = append(r.refunds, refund)
+	}
+	return refund, err
+}
+
+// TestCreateRefund_Gateway menguji refund pembayaran hasil charge gateway: refund dicatat pending dan di-commit
+// sebelum gateway dipanggil, lalu menjadi completed atau failed sesuai hasil gateway.
+func TestCreateRefund_Gateway(t *testing.T) {
+	db := SetupTestRefundDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO payment_charges (billing_id, charge_ref, payment_id) VALUES
+		(1, 'SIM-A-1750000000000-1', 1),
+		(1, 'SIM-X-1750000000000-2', 2);
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	recorder := &refundRecorder{PaymentGateway: gateway.NewSimulator(gateway.ModeApprove), db: db}
+	handler := &RefundHandler{DB: db, Ctx: &ctx, Gateway: recorder}
+
+	type refundRow struct {
+		status, chargeRef, gatewayRef, message string
+	}
+	getRefund := func(id int) refundRow {
+		var row refundRow
+		require.NoError(t, db.QueryRow(
+			"SELECT status, IFNULL(charge_ref, ''), IFNULL(gateway_ref, ''), IFNULL(message, '') FROM refunds WHERE id = ?", id,
+		).Scan(&row.status, &row.chargeRef, &row.gatewayRef, &row.message))
+		return row
+	}
+	billingStatus := func() string {
+		var status string
+		require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+		return status
+	}
+
+	// Charge approved direfund di gateway setelah refund pending di-commit
+	refund, err := handler.CreateRefund(1, entity.NewMoney(50000), "Barang kurang")
+	require.NoError(t, err)
+	require.Len(t, recorder.refunds, 1)
+	assert.Equal(t, []string{string(entity.RefundPending)}, recorder.statuses)
+	assert.Equal(t, "SIM-A-1750000000000-1", recorder.refunds[0].ChargeID)
+	assert.Equal(t, entity.NewMoney(50000), recorder.refunds[0].Amount)
+
+	assert.Equal(t, entity.RefundCompleted, refund.Status)
+	assert.Equal(t, recorder.refunds[0].ID, refund.GatewayRef)
+	row := getRefund(refund.ID)
+	assert.Equal(t, string(entity.RefundCompleted), row.status)
+	assert.Equal(t, "SIM-A-1750000000000-1", row.chargeRef)
+	assert.Equal(t, recorder.refunds[0].ID, row.gatewayRef)
+	assert.Equal(t, string(entity.StatusLesspaid), billingStatus())
+
+	// Refund yang ditolak gateway tetap tercatat sebagai failed dan tidak mengurangi dana billing
+	_, err = db.Exec("UPDATE billings SET total = 250000, status = 'paid' WHERE id = 1")
+	require.NoError(t, err)
+	failed, err := handler.CreateRefund(2, entity.NewMoney(10000), "Barang kurang")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ditolak payment gateway")
+	assert.Equal(t, entity.RefundFailed, failed.Status)
+
+	row = getRefund(failed.ID)
+	assert.Equal(t, string(entity.RefundFailed), row.status)
+	assert.NotEmpty(t, row.message)
+	assert.Empty(t, row.gatewayRef)
+	assert.Equal(t, string(entity.StatusPaid), billingStatus())
+
+	// Refund gagal tidak dihitung sebagai dana yang sudah dikembalikan
+	payments, err := handler.GetRefundablePayments("BIL-001")
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+	assert.Equal(t, entity.Money(0), payments[1].Refunded)
+
+	refunds, err := handler.GetRefundsByBillingID(1)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, refund.ID, refunds[0].ID)
+
+	remaining, err := billingRemaining(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, entity.Money(0), remaining)
+
+	// Billing yang dibatalkan selama refund masih pending tidak hidup kembali saat refund ditolak gateway,
+	// dan dana refund yang gagal dipindahkan ke saldo kredit customer
+	res, err := db.Exec(`
+		INSERT INTO refunds (billing_id, payment_id, amount, reason, status, charge_ref, created_by)
+		VALUES (1, 2, 5000, 'Barang kurang', 'pending', 'SIM-X-1750000000000-2', 1)
+	`)
+	require.NoError(t, err)
+	pendingID, err := res.LastInsertId()
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE billings SET status = 'cancelled' WHERE id = 1")
+	require.NoError(t, err)
+
+	pending := entity.Refund{ID: int(pendingID), BillingID: 1, PaymentID: 2, Amount: entity.NewMoney(5000), CreatedBy: 1}
+	require.NoError(t, failRefund(db, pending, "declined"))
+	assert.Equal(t, string(entity.RefundFailed), getRefund(int(pendingID)).status)
+	assert.Equal(t, string(entity.StatusCancelled), billingStatus())
+
+	var creditPaymentID int
+	var credited entity.Money
+	err = db.QueryRow("SELECT payment_id, amount FROM customer_credits WHERE type = 'expired_billing'").Scan(&creditPaymentID, &credited)
+	require.NoError(t, err)
+	assert.Equal(t, 2, creditPaymentID)
+	assert.Equal(t, entity.NewMoney(5000), credited)
+
+	// Menandai ulang refund yang sudah failed tidak menambah saldo kredit lagi
+	require.NoError(t, failRefund(db, pending, "declined"))
+	var credits int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM customer_credits").Scan(&credits))
+	assert.Equal(t, 1, credits)
+
+	// Billing yang sudah dibatalkan tidak bisa direfund lagi
+	_, err = handler.CreateRefund(1, entity.NewMoney(1000), "Refund ulang")
+	assert.EqualError(t, err, errBillingCancelledRefund.Error())
+	_, err = handler.GetRefundablePayments("BIL-001")
+	assert.EqualError(t, err, errBillingCancelledRefund.Error())
+}
+
+// flakyRefundGateway membungkus gateway simulator; refund atas failCharge gagal dikirim seolah gateway tidak bisa dihubungi.
+// Setiap pemanggilan Refund dan RefundStatus dicatat beserta reference-nya.
+type flakyRefundGateway struct {
+	gateway.PaymentGateway
+	failCharge string
+	refunds    []string
+	statuses   []string
+}
+
+func (f *flakyRefundGateway) Refund(ctx context.Context, chargeID string, reference string, amount entity.Money) (gateway.Refund, error) {
+	f.refunds = append(f.refunds, reference)
+	if chargeID == f.failCharge {
+		return gateway.Refund{}, errors.New("koneksi ke payment gateway terputus")
+	}
+	return f.PaymentGateway.Refund(ctx, chargeID, reference, amount)
+}
+
+func (f *flakyRefundGateway) RefundStatus(ctx context.Context, reference string) (gateway.Refund, error) {
+	f.statuses = append(f.statuses, reference)
+	return f.PaymentGateway.RefundStatus(ctx, reference)
+}
+
+// TestSettleRefunds_GatewayUnavailable memastikan refund yang gagal dikirim ke gateway tetap pending tanpa menghentikan
+// refund lainnya, lalu diselesaikan worker lewat SyncPendingRefunds tanpa diproses dua kali oleh gateway.
+func TestSettleRefunds_GatewayUnavailable(t *testing.T) {
+	db := SetupTestRefundDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO payment_charges (billing_id, charge_ref, payment_id) VALUES
+		(1, 'SIM-A-1750000000000-1', 1),
+		(1, 'SIM-A-1750000000000-2', 2);
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	gw := &flakyRefundGateway{PaymentGateway: gateway.NewSimulator(gateway.ModeApprove), failCharge: "SIM-A-1750000000000-1"}
+	handler := &RefundHandler{DB: db, Ctx: &ctx, Gateway: gw}
+
+	refundStatus := func(id int) (string, string) {
+		var status, gatewayRef string
+		require.NoError(t, db.QueryRow("SELECT status, IFNULL(gateway_ref, '') FROM refunds WHERE id = ?", id).Scan(&status, &gatewayRef))
+		return status, gatewayRef
+	}
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	first, err := createRefundTx(tx, 1, 1, 0, entity.NewMoney(10000), "Barang kurang")
+	require.NoError(t, err)
+	second, err := createRefundTx(tx, 1, 2, 0, entity.NewMoney(5000), "Barang kurang")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// Refund pertama gagal dikirim, refund kedua tetap diproses
+	refunds, err := settleRefunds(ctx, gw, db, []entity.Refund{first, second})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "akan dicoba ulang")
+	assert.Equal(t, entity.RefundPending, refunds[0].Status)
+	assert.Equal(t, entity.RefundCompleted, refunds[1].Status)
+
+	status, _ := refundStatus(first.ID)
+	assert.Equal(t, string(entity.RefundPending), status)
+	status, secondRef := refundStatus(second.ID)
+	assert.Equal(t, string(entity.RefundCompleted), status)
+
+	// Gateway masih tidak bisa dihubungi: refund tetap pending
+	settled, err := handler.SyncPendingRefunds()
+	require.Error(t, err)
+	assert.Equal(t, 0, settled)
+
+	// Gateway pulih: worker mengirim ulang refund dengan reference yang sama
+	gw.failCharge = ""
+	settled, err = handler.SyncPendingRefunds()
+	require.NoError(t, err)
+	assert.Equal(t, 1, settled)
+
+	status, firstRef := refundStatus(first.ID)
+	assert.Equal(t, string(entity.RefundCompleted), status)
+	assert.Equal(t, "SIM-R-"+refundReference(first.ID), firstRef)
+
+	// Refund yang sudah diterima gateway tidak dikirim ulang; worker cukup mengambil statusnya
+	_, err = db.Exec("UPDATE refunds SET status = 'pending', gateway_ref = NULL WHERE id = ?", second.ID)
+	require.NoError(t, err)
+	calls := len(gw.refunds)
+	settled, err = handler.SyncPendingRefunds()
+	require.NoError(t, err)
+	assert.Equal(t, 1, settled)
+	assert.Len(t, gw.refunds, calls)
+	assert.Contains(t, gw.statuses, refundReference(second.ID))
+
+	status, ref := refundStatus(second.ID)
+	assert.Equal(t, string(entity.RefundCompleted), status)
+	assert.Equal(t, secondRef, ref)
+
+	// Tidak ada lagi refund pending
+	settled, err = handler.SyncPendingRefunds()
+	require.NoError(t, err)
+	assert.Equal(t, 0, settled)
+}
+
+// TestRevenueDetails_WithRefund memastikan refund tercatat sebagai pendapatan negatif.
+func TestRevenueDetails_WithRefund(t *testing.T) {
+	db := SetupTestRefundDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &RefundHandler{DB: db, Ctx: &ctx}
+
+	_, err := handler.CreateRefund(1, entity.NewMoney(50000), "Barang kurang")
+	require.NoError(t, err)
+
+	report := &ReportHandler{DB: db, Ctx: &ctx}
+	details, err := report.GetRevenueDetails()
+	require.NoError(t, err)
+	require.Len(t, details, 3)
+
+	var net entity.Money
+	var refundRows int
+	for _, d := range details {
+		net += d.Amount
+		if d.Method == "refund" {
+			refundRows++
+			assert.Equal(t, entity.NewMoney(-50000), d.Amount)
+		}
+	}
+	assert.Equal(t, 1, refundRows)
+	assert.Equal(t, entity.NewMoney(250000), net)
+}
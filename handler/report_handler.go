@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"pairproject/entity"
 )
 
 // ReportHandler bertugas untuk mengambil laporan terkait produk, tagihan, dan pendapatan dari database.
@@ -25,8 +26,9 @@ type UnpaidBill struct {
 	BillNumber   string  // Nomor tagihan yang ditampilkan
 	OrderNumber  string  // Nomor pesanan yang terkait dengan tagihan
 	CustomerName string  // Nama pelanggan yang berhubungan dengan tagihan
-	Tax          float64 // Pajak yang dikenakan pada tagihan
-	Total        float64 // Total jumlah tagihan
+	Tax          entity.Money // Pajak yang dikenakan pada tagihan
+	Total        entity.Money // Total jumlah tagihan
+	Adjustment   entity.Money // Total credit note (negatif) dan debit note (positif)
 	Status       string  // Status tagihan, harus 'unpaid'
 	CreatedAt    string  // Waktu pembuatan tagihan
 }
@@ -35,20 +37,20 @@ type UnpaidBill struct {
 type RevenueDetail struct {
 	BillNumber   string  // Nomor tagihan
 	PaymentDate  string  // Tanggal pembayaran dilakukan
-	Amount       float64 // Jumlah pembayaran
-	Method       string  // Metode pembayaran (contoh: cash, credit card, transfer)
+	Amount       entity.Money // Jumlah pembayaran (negatif untuk refund)
+	Method       string  // Metode pembayaran (contoh: cash, credit card, transfer) atau refund
 	CustomerName string  // Nama pelanggan yang membayar
 	OrderNumber  string  // Nomor pesanan terkait pembayaran
 }
 
 // GetMostSoldProducts mengambil 5 produk dengan total penjualan terbanyak dari database.
 func (r *ReportHandler) GetMostSoldProducts() ([]MostSoldProduct, error) {
+	// Nama produk diambil dari snapshot order_details, bukan dari tabel products
 	query := `
 	SELECT
-		p.id, p.name, SUM(od.qty) AS total_sold
-	FROM products p
-	JOIN order_details od ON p.id = od.product_id
-	GROUP BY p.id, p.name
+		od.product_id, MAX(od.product_name) AS name, SUM(od.qty) AS total_sold
+	FROM order_details od
+	GROUP BY od.product_id
 	ORDER BY total_sold DESC
 	LIMIT 5
 	`
@@ -88,6 +90,8 @@ func (h *ReportHandler) GetUnpaidBills() ([]UnpaidBill, error) {
 		c.name AS customer_name,
 		b.tax,
 		b.total,
+		(SELECT IFNULL(SUM(CASE WHEN ba.type = 'debit' THEN ba.amount ELSE -ba.amount END), 0)
+			FROM billing_adjustments ba WHERE ba.billing_id = b.id) AS adjustment,
 		b.status,
 		b.created_at
 	FROM billings b
@@ -117,6 +121,7 @@ func (h *ReportHandler) GetUnpaidBills() ([]UnpaidBill, error) {
 			&bill.CustomerName,
 			&bill.Tax,
 			&bill.Total,
+			&bill.Adjustment,
 			&bill.Status,
 			&bill.CreatedAt,
 		); err != nil {
@@ -132,6 +137,7 @@ func (h *ReportHandler) GetUnpaidBills() ([]UnpaidBill, error) {
 
 // GetRevenueDetails mengambil data detail pendapatan berupa pembayaran beserta info terkait pelanggan dan pesanan.
 func (r *ReportHandler) GetRevenueDetails() ([]RevenueDetail, error) {
+	// Refund (kecuali yang ditolak gateway) digabungkan sebagai baris bernilai negatif dengan method 'refund'
 	query := `
 	SELECT
 		b.number_display AS bill_number,
@@ -145,7 +151,21 @@ func (r *ReportHandler) GetRevenueDetails() ([]RevenueDetail, error) {
 	JOIN orders o ON b.order_id = o.id
 	JOIN user_customers uc ON o.customer_id = uc.customer_id
 	JOIN customers c ON uc.customer_id = c.id
-	ORDER BY p.date DESC
+	UNION ALL
+	SELECT
+		b.number_display AS bill_number,
+		r.created_at AS payment_date,
+		-r.amount,
+		'refund',
+		c.name AS customer_name,
+		o.number_display AS order_number
+	FROM refunds r
+	JOIN billings b ON r.billing_id = b.id
+	JOIN orders o ON b.order_id = o.id
+	JOIN user_customers uc ON o.customer_id = uc.customer_id
+	JOIN customers c ON uc.customer_id = c.id
+	WHERE r.status <> 'failed'
+	ORDER BY payment_date DESC
 	`
 
 	// Eksekusi query untuk mengambil data pembayaran
@@ -183,4 +203,59 @@ func (r *ReportHandler) GetRevenueDetails() ([]RevenueDetail, error) {
 
 	// Kembalikan data revenue dan nil error jika sukses
 	return results, nil
-}
\ No newline at end of file
+}
+
+// AdjustmentReport merepresentasikan satu credit note / debit note untuk laporan penyesuaian billing.
+type AdjustmentReport struct {
+	NumberDisplay string       // Nomor credit note / debit note
+	BillNumber    string       // Nomor tagihan yang disesuaikan
+	CustomerName  string       // Nama pelanggan pemilik tagihan
+	Type          string       // credit atau debit
+	Amount        entity.Money // Nominal bertanda: negatif untuk credit note
+	Reason        string       // Alasan penyesuaian
+	CreatedAt     string       // Waktu penyesuaian dibuat
+}
+
+// GetAdjustmentReport mengambil seluruh credit note dan debit note, terbaru di atas.
+func (r *ReportHandler) GetAdjustmentReport() ([]AdjustmentReport, error) {
+	query := `
+	SELECT
+		ba.number_display,
+		b.number_display AS bill_number,
+		c.name AS customer_name,
+		ba.type,
+		CASE WHEN ba.type = 'debit' THEN ba.amount ELSE -ba.amount END AS amount,
+		ba.reason,
+		ba.created_at
+	FROM billing_adjustments ba
+	JOIN billings b ON ba.billing_id = b.id
+	JOIN orders o ON b.order_id = o.id
+	JOIN customers c ON o.customer_id = c.id
+	ORDER BY ba.id DESC
+	`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	var results []AdjustmentReport
+	for rows.Next() {
+		var adjustment AdjustmentReport
+		if err := rows.Scan(
+			&adjustment.NumberDisplay,
+			&adjustment.BillNumber,
+			&adjustment.CustomerName,
+			&adjustment.Type,
+			&adjustment.Amount,
+			&adjustment.Reason,
+			&adjustment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan adjustment: %w", err)
+		}
+		results = append(results, adjustment)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+)
+
+// reserveStockTx menahan stok setiap produk dalam order untuk billing yang baru dibuat.
+// Stok langsung dikurangi agar tidak bisa dibeli customer lain selama billing belum lunas.
+func reserveStockTx(tx *sql.Tx, billingID int, orderID int) ([]entity.StockReservation, error) {
+	rows, err := tx.Query(`
+		SELECT product_id, product_name, SUM(qty)
+		FROM order_details
+		WHERE order_id = ?
+		GROUP BY product_id, product_name
+		ORDER BY product_id ASC
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil detail order: %w", err)
+	}
+
+	type orderLine struct {
+		productID int
+		name      string
+		qty       int
+	}
+	var lines []orderLine
+	for rows.Next() {
+		var line orderLine
+		if err := rows.Scan(&line.productID, &line.name, &line.qty); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var reservations []entity.StockReservation
+	for _, line := range lines {
+		// Kurangi stok hanya jika masih mencukupi
+		res, err := tx.Exec("UPDATE products SET stock = stock - ? WHERE id = ? AND stock >= ?", line.qty, line.productID, line.qty)
+		if err != nil {
+			return nil, errors.New("Terjadi kesalahan menahan stok produk")
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return nil, fmt.Errorf("Stok %s tidak mencukupi", line.name)
+		}
+
+		res, err = tx.Exec(
+			"INSERT INTO stock_reservations (billing_id, product_id, qty, status) VALUES (?, ?, ?, ?)",
+			billingID, line.productID, line.qty, string(entity.ReservationReserved),
+		)
+		if err != nil {
+			return nil, errors.New("Terjadi kesalahan menyimpan reservasi stok")
+		}
+
+		reservationID, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, entity.StockReservation{
+			ID:        int(reservationID),
+			BillingID: billingID,
+			ProductID: line.productID,
+			Qty:       line.qty,
+			Status:    entity.ReservationReserved,
+		})
+	}
+
+	return reservations, nil
+}
+
+// commitReservationsTx menandai stok yang ditahan billing sebagai terjual saat billing lunas
+func commitReservationsTx(tx *sql.Tx, billingID int) error {
+	_, err := tx.Exec(
+		"UPDATE stock_reservations SET status = ? WHERE billing_id = ? AND status = ?",
+		string(entity.ReservationCommitted), billingID, string(entity.ReservationReserved),
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mengubah reservasi stok")
+	}
+	return nil
+}
+
+// releaseReservationsTx mengembalikan stok yang masih ditahan billing, misalnya saat billing kedaluwarsa
+func releaseReservationsTx(tx *sql.Tx, billingID int) error {
+	rows, err := tx.Query(
+		"SELECT id, product_id, qty FROM stock_reservations WHERE billing_id = ? AND status = ?",
+		billingID, string(entity.ReservationReserved),
+	)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan mengambil reservasi stok: %w", err)
+	}
+
+	var reservations []entity.StockReservation
+	for rows.Next() {
+		var reservation entity.StockReservation
+		if err := rows.Scan(&reservation.ID, &reservation.ProductID, &reservation.Qty); err != nil {
+			rows.Close()
+			return err
+		}
+		reservations = append(reservations, reservation)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		_, err := tx.Exec("UPDATE products SET stock = stock + ? WHERE id = ?", reservation.Qty, reservation.ProductID)
+		if err != nil {
+			return errors.New("Terjadi kesalahan mengembalikan stok produk")
+		}
+
+		_, err = tx.Exec("UPDATE stock_reservations SET status = ? WHERE id = ?", string(entity.ReservationReleased), reservation.ID)
+		if err != nil {
+			return errors.New("Terjadi kesalahan mengubah reservasi stok")
+		}
+	}
+
+	return nil
+}
+
+// availableStockTx mengunci baris produk lalu menghitung stok yang masih bisa dibeli: stok produk dikurangi qty
+// order processing yang belum menahan stok lewat billing. Checkout lain untuk produk yang sama menunggu sampai
+// transaksi selesai, sehingga unit terakhir tidak terjual ke dua order sekaligus. Stok tidak dikurangi di sini
+// karena pengurangan tetap dilakukan reserveStockTx saat billing dibuat.
+func availableStockTx(tx *sql.Tx, productID int) (int, error) {
+	// UPDATE tanpa perubahan dipakai sebagai kunci baris, lihat lockBillingTx
+	if _, err := tx.Exec("UPDATE products SET stock = stock WHERE id = ?", productID); err != nil {
+		return 0, fmt.Errorf("Gagal mengunci produk: %s", err)
+	}
+
+	var available int
+	err := tx.QueryRow(`
+		SELECT p.stock - IFNULL((
+			SELECT SUM(od.qty)
+			FROM order_details od
+			JOIN orders o ON o.id = od.order_id
+			WHERE od.product_id = p.id AND o.status = ?
+				AND NOT EXISTS (
+					SELECT 1 FROM stock_reservations sr
+					JOIN billings b ON b.id = sr.billing_id
+					WHERE b.order_id = o.id AND sr.status IN (?, ?)
+				)
+		), 0)
+		FROM products p
+		WHERE p.id = ?
+	`, string(entity.StatusProcessing), string(entity.ReservationReserved), string(entity.ReservationCommitted), productID).Scan(&available)
+	if err != nil {
+		return 0, fmt.Errorf("Terjadi kesalahan menghitung stok tersedia: %w", err)
+	}
+	return available, nil
+}
@@ -0,0 +1,466 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+	"strings"
+	"time"
+)
+
+// ReturnHandler menangani pengajuan retur (RMA) customer sampai barang diterima kembali di gudang.
+// Gateway dipakai untuk refund dana retur; nil berarti gateway.Default().
+type ReturnHandler struct {
+	DB      *sql.DB
+	Ctx     *context.Context
+	Gateway gateway.PaymentGateway
+}
+
+// RequestReturn membuat pengajuan retur untuk baris-baris order yang sudah completed.
+// Nilai refund dihitung dari harga snapshot order ditambah porsi pajak per baris yang ditagihkan di billing.
+func (r *ReturnHandler) RequestReturn(orderNumber string, reason string, lines []entity.ReturnLine) (entity.Return, error) {
+	var ret entity.Return
+
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return ret, fmt.Errorf("Please Login!")
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ret, errors.New("Alasan retur wajib diisi")
+	}
+	if len(lines) == 0 {
+		return ret, errors.New("Pilih minimal satu produk yang ingin diretur")
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return ret, err
+	}
+
+	// Order harus milik customer dan sudah completed
+	var orderTotal entity.Money
+	var status entity.StatusOrder
+	err = tx.QueryRow(
+		"SELECT id, total, status FROM orders WHERE number_display = ? AND customer_id = ?",
+		orderNumber, user.Customer.ID,
+	).Scan(&ret.OrderID, &orderTotal, &status)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return ret, errors.New("Order tidak ditemukan")
+		}
+		return ret, fmt.Errorf("Terjadi kesalahan mengambil order: %s", err)
+	}
+	if status != entity.StatusCompleted {
+		tx.Rollback()
+		return ret, errors.New("Retur hanya bisa diajukan untuk order yang sudah completed")
+	}
+
+	// Refund diajukan terhadap billing lunas milik order (lesspaid jika sebagian dana sudah direfund)
+	var billingTax entity.Money
+	err = tx.QueryRow(
+		"SELECT id, tax FROM billings WHERE order_id = ? AND status IN ('paid', 'lesspaid') ORDER BY id DESC LIMIT 1",
+		ret.OrderID,
+	).Scan(&ret.BillingID, &billingTax)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return ret, errors.New("Billing lunas untuk order ini tidak ditemukan")
+		}
+		return ret, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+
+	// Rincian pajak per baris billing; billing lama tanpa rincian memakai rasio pajak billing
+	taxLines, err := getBillingTaxLines(tx, ret.BillingID)
+	if err != nil {
+		tx.Rollback()
+		return ret, err
+	}
+	lineTax := make(map[int]entity.BillingTaxLine)
+	for _, tl := range taxLines {
+		lineTax[tl.OrderDetailID] = tl
+	}
+
+	// Validasi setiap baris terhadap qty order dikurangi qty yang sudah pernah diretur
+	var subtotal, taxShare entity.Money
+	for _, line := range lines {
+		if line.Qty <= 0 {
+			tx.Rollback()
+			return ret, errors.New("Qty retur harus lebih dari 0")
+		}
+
+		var item entity.ReturnItem
+		var orderedQty, returnedQty int
+		err = tx.QueryRow(`
+			SELECT od.id, od.product_id, od.product_name, od.price, od.qty,
+				(SELECT IFNULL(SUM(ri.qty), 0)
+					FROM return_items ri
+					JOIN returns rt ON rt.id = ri.return_id
+					WHERE ri.order_detail_id = od.id AND rt.status != 'rejected')
+			FROM order_details od
+			WHERE od.id = ? AND od.order_id = ?
+		`, line.OrderDetailID, ret.OrderID).Scan(&item.OrderDetailID, &item.ProductID, &item.ProductName, &item.Price, &orderedQty, &returnedQty)
+		if err != nil {
+			tx.Rollback()
+			if err == sql.ErrNoRows {
+				return ret, fmt.Errorf("Baris order dengan id %d tidak ditemukan", line.OrderDetailID)
+			}
+			return ret, fmt.Errorf("Terjadi kesalahan mengambil detail order: %s", err)
+		}
+
+		if line.Qty > orderedQty-returnedQty {
+			tx.Rollback()
+			return ret, fmt.Errorf("Qty retur %s melebihi sisa yang bisa diretur (%d)", item.ProductName, orderedQty-returnedQty)
+		}
+
+		item.Qty = line.Qty
+		item.Condition = entity.ConditionPending
+		subtotal += item.Price.Mul(item.Qty)
+		ret.Items = append(ret.Items, item)
+
+		// Harga inclusive sudah mengandung pajak, harga exclusive mendapat porsi pajak baris sesuai qty
+		if tl, ok := lineTax[item.OrderDetailID]; ok && !tl.PriceIncludesTax {
+			taxShare += tl.TaxAmount.MulDiv(entity.Money(item.Qty), entity.Money(orderedQty))
+		}
+	}
+
+	// Tanpa rincian pajak, porsi pajak mengikuti rasio pajak yang ditagihkan pada billing
+	if len(taxLines) == 0 {
+		taxShare = subtotal.MulDiv(billingTax, orderTotal)
+	}
+	ret.RefundAmount = subtotal + taxShare
+
+	ret.NumberDisplay = r.GenerateReturnNumber(tx)
+	res, err := tx.Exec(`
+		INSERT INTO returns (number_display, order_id, billing_id, customer_id, status, reason, refund_amount, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, ret.NumberDisplay, ret.OrderID, ret.BillingID, user.Customer.ID, string(entity.ReturnRequested), reason, ret.RefundAmount, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return ret, errors.New("Terjadi kesalahan menyimpan retur")
+	}
+
+	returnID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return ret, err
+	}
+
+	for i, item := range ret.Items {
+		res, err := tx.Exec(`
+			INSERT INTO return_items (return_id, order_detail_id, product_id, qty, price, item_condition)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, returnID, item.OrderDetailID, item.ProductID, item.Qty, item.Price, string(item.Condition))
+		if err != nil {
+			tx.Rollback()
+			return ret, errors.New("Terjadi kesalahan menyimpan item retur")
+		}
+
+		itemID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return ret, err
+		}
+		ret.Items[i].ID = int(itemID)
+		ret.Items[i].ReturnID = int(returnID)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ret, fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	ret.ID = int(returnID)
+	ret.OrderNumber = orderNumber
+	ret.CustomerID = user.Customer.ID
+	ret.Status = entity.ReturnRequested
+	ret.Reason = reason
+	ret.CreatedBy = user.ID
+	return ret, nil
+}
+
+// GetReturns mengambil daftar retur untuk admin, bisa difilter berdasarkan status (kosong = semua)
+func (r *ReturnHandler) GetReturns(status entity.StatusReturn) ([]entity.Return, error) {
+	if _, ok := utils.GetUser(*r.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	var conditions []string
+	var args []interface{}
+	if status != "" {
+		conditions = append(conditions, "rt.status = ?")
+		args = append(args, string(status))
+	}
+
+	return r.queryReturns(conditions, args)
+}
+
+// GetMyReturns mengambil semua retur milik customer yang sedang login
+func (r *ReturnHandler) GetMyReturns() ([]entity.Return, error) {
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	return r.queryReturns([]string{"rt.customer_id = ?"}, []interface{}{user.Customer.ID})
+}
+
+// GetReturnByNumber mengambil satu retur beserta item-itemnya berdasarkan nomor retur
+func (r *ReturnHandler) GetReturnByNumber(numberDisplay string) (entity.Return, error) {
+	if _, ok := utils.GetUser(*r.Ctx); !ok {
+		return entity.Return{}, fmt.Errorf("Please Login!")
+	}
+
+	returns, err := r.queryReturns([]string{"rt.number_display = ?"}, []interface{}{numberDisplay})
+	if err != nil {
+		return entity.Return{}, err
+	}
+	if len(returns) == 0 {
+		return entity.Return{}, errors.New("Retur tidak ditemukan")
+	}
+
+	return returns[0], nil
+}
+
+// ReviewReturn menyetujui atau menolak pengajuan retur (khusus admin).
+// Refund retur yang disetujui dicatat dalam transaksi yang sama, lalu diteruskan ke payment gateway setelah commit.
+func (r *ReturnHandler) ReviewReturn(numberDisplay string, approve bool, note string) error {
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Hanya retur yang masih requested yang bisa direview
+	var returnID, billingID int
+	var refundAmount entity.Money
+	err = tx.QueryRow(
+		"SELECT id, billing_id, refund_amount FROM returns WHERE number_display = ? AND status = ?",
+		numberDisplay, string(entity.ReturnRequested),
+	).Scan(&returnID, &billingID, &refundAmount)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("Retur tidak ditemukan atau sudah diproses")
+		}
+		return fmt.Errorf("Terjadi kesalahan mengambil retur: %s", err)
+	}
+
+	status := entity.ReturnRejected
+	if approve {
+		status = entity.ReturnApproved
+	}
+
+	_, err = tx.Exec(
+		"UPDATE returns SET status = ?, admin_note = ?, updated_by = ? WHERE id = ?",
+		string(status), nullString(strings.TrimSpace(note)), user.ID, returnID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Terjadi kesalahan mengubah status retur: %s", err)
+	}
+
+	// Catat refund dana retur yang disetujui
+	var refunds []entity.Refund
+	if approve && refundAmount > 0 {
+		refunds, err = refundReturnTx(tx, user.ID, returnID, billingID, refundAmount, "Retur "+numberDisplay)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	// Refund pembayaran hasil charge diteruskan ke gateway setelah retur tercatat
+	_, err = settleRefunds(*r.Ctx, gatewayOrDefault(r.Gateway), r.DB, refunds)
+	return err
+}
+
+// ReceiveReturn mencatat barang retur yang sudah diterima gudang.
+// Item dengan kondisi restock dikembalikan ke stok produk, item damaged dihapusbukukan.
+// Item yang tidak ada di conditions dianggap layak jual (restock).
+func (r *ReturnHandler) ReceiveReturn(numberDisplay string, conditions map[int]entity.ItemCondition) error {
+	user, ok := utils.GetUser(*r.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	ret, err := r.GetReturnByNumber(numberDisplay)
+	if err != nil {
+		return err
+	}
+	if ret.Status != entity.ReturnApproved {
+		return errors.New("Hanya retur yang sudah disetujui yang bisa diterima")
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range ret.Items {
+		condition, ok := conditions[item.ID]
+		if !ok {
+			condition = entity.ConditionRestock
+		}
+		if condition != entity.ConditionRestock && condition != entity.ConditionDamaged {
+			tx.Rollback()
+			return fmt.Errorf("Kondisi barang tidak valid: %s", condition)
+		}
+
+		_, err = tx.Exec("UPDATE return_items SET item_condition = ? WHERE id = ?", string(condition), item.ID)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("Terjadi kesalahan mengubah kondisi barang retur")
+		}
+
+		// Barang layak jual masuk kembali ke stok
+		if condition == entity.ConditionRestock {
+			_, err = tx.Exec("UPDATE products SET stock = stock + ?, updated_by = ? WHERE id = ?", item.Qty, user.ID, item.ProductID)
+			if err != nil {
+				tx.Rollback()
+				return errors.New("Terjadi kesalahan mengembalikan stok produk")
+			}
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE returns SET status = ?, updated_by = ? WHERE id = ? AND status = ?",
+		string(entity.ReturnReceived), user.ID, ret.ID, string(entity.ReturnApproved),
+	)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Terjadi kesalahan mengubah status retur")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return nil
+}
+
+// GenerateReturnNumber menghasilkan nomor retur berdasarkan bulan & urutan terakhir
+// Contoh: RMA-202506-001
+func (r *ReturnHandler) GenerateReturnNumber(tx *sql.Tx) string {
+	currentYearMonth := time.Now().Format("200601") // Format YYYYMM
+	var lastNumber int
+
+	query := `
+		SELECT
+			COALESCE(
+				CAST(SUBSTR(number_display, 14, 3) AS UNSIGNED),
+				0
+			) AS last_number
+		FROM returns
+		WHERE SUBSTR(number_display, 5, 6) = ?
+		ORDER BY last_number DESC
+		LIMIT 1
+	`
+	err := tx.QueryRow(query, currentYearMonth).Scan(&lastNumber)
+	if err != nil {
+		lastNumber = 0 // Jika tidak ada data, mulai dari 0
+	}
+
+	return fmt.Sprintf("RMA-%s-%03d", currentYearMonth, lastNumber+1)
+}
+
+// queryReturns mengambil retur sesuai kondisi yang diberikan beserta item-itemnya
+func (r *ReturnHandler) queryReturns(conditions []string, args []interface{}) ([]entity.Return, error) {
+	query := `
+		SELECT rt.id, rt.number_display, rt.order_id, o.number_display, rt.billing_id, rt.customer_id,
+			rt.status, rt.reason, IFNULL(rt.admin_note, ''), rt.refund_amount, rt.created_at, rt.created_by
+		FROM returns rt
+		JOIN orders o ON o.id = rt.order_id
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY rt.id DESC"
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil retur: %w", err)
+	}
+
+	var returns []entity.Return
+	for rows.Next() {
+		var ret entity.Return
+		err := rows.Scan(
+			&ret.ID,
+			&ret.NumberDisplay,
+			&ret.OrderID,
+			&ret.OrderNumber,
+			&ret.BillingID,
+			&ret.CustomerID,
+			&ret.Status,
+			&ret.Reason,
+			&ret.AdminNote,
+			&ret.RefundAmount,
+			&ret.CreatedAt,
+			&ret.CreatedBy,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		returns = append(returns, ret)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ambil item retur setelah rows ditutup agar koneksi tidak dipakai bersamaan
+	for i := range returns {
+		items, err := r.getReturnItems(returns[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		returns[i].Items = items
+	}
+
+	return returns, nil
+}
+
+// getReturnItems mengambil item-item sebuah retur
+func (r *ReturnHandler) getReturnItems(returnID int) ([]entity.ReturnItem, error) {
+	rows, err := r.DB.Query(`
+		SELECT ri.id, ri.return_id, ri.order_detail_id, ri.product_id, od.product_name, ri.qty, ri.price, ri.item_condition
+		FROM return_items ri
+		JOIN order_details od ON od.id = ri.order_detail_id
+		WHERE ri.return_id = ?
+		ORDER BY ri.id ASC
+	`, returnID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil item retur: %w", err)
+	}
+	defer rows.Close()
+
+	var items []entity.ReturnItem
+	for rows.Next() {
+		var item entity.ReturnItem
+		err := rows.Scan(&item.ID, &item.ReturnID, &item.OrderDetailID, &item.ProductID, &item.ProductName, &item.Qty, &item.Price, &item.Condition)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestReturnDB membuat database in-memory SQLite berisi order completed dengan billing lunas.
+func SetupTestReturnDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	// Satu koneksi agar semua query melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			stock INTEGER DEFAULT 0 NOT NULL,
+			price REAL DEFAULT 0 NOT NULL,
+			updated_by INTEGER
+		);
+
+		INSERT INTO products (name, stock, price) VALUES
+		('Adjustable Dumbbell 20kg', 5, 100000.00),
+		('Treadmill Compact X100', 2, 50000.00);
+
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT,
+			customer_id INTEGER,
+			status TEXT DEFAULT 'processing',
+			total REAL DEFAULT 0
+		);
+
+		INSERT INTO orders (number_display, customer_id, status, total) VALUES
+		('ORD-001', 1, 'completed', 250000),
+		('ORD-002', 1, 'processing', 100000);
+
+		CREATE TABLE order_details (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			product_id INTEGER,
+			qty INTEGER,
+			product_name TEXT NOT NULL,
+			price REAL DEFAULT 0 NOT NULL
+		);
+
+		INSERT INTO order_details (order_id, product_id, qty, product_name, price) VALUES
+		(1, 1, 2, 'Adjustable Dumbbell 20kg', 100000),
+		(1, 2, 1, 'Treadmill Compact X100', 50000),
+		(2, 1, 1, 'Adjustable Dumbbell 20kg', 100000);
+
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			tax REAL DEFAULT 0,
+			total REAL DEFAULT 0,
+			status TEXT DEFAULT 'unpaid'
+		);
+
+		INSERT INTO billings (order_id, tax, total, status) VALUES (1, 25000, 275000, 'paid');
+
+		CREATE TABLE billing_tax_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			order_detail_id INTEGER NOT NULL,
+			tax_class TEXT NOT NULL,
+			rate REAL NOT NULL,
+			price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+			taxable_amount REAL NOT NULL DEFAULT 0,
+			tax_amount REAL NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			amount REAL NOT NULL DEFAULT 0
+		);
+
+		INSERT INTO payments (billing_id, amount) VALUES (1, 200000), (1, 75000);
+
+		CREATE TABLE billing_adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT NOT NULL UNIQUE,
+			billing_id INTEGER NOT NULL,
+			type TEXT NOT NULL CHECK (type IN ('credit', 'debit')),
+			amount NUMERIC NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			payment_id INTEGER NOT NULL,
+			return_id INTEGER,
+			amount REAL NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed',
+			charge_ref TEXT,
+			gateway_ref TEXT,
+			message TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE payment_charges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			charge_ref TEXT NOT NULL UNIQUE,
+			payment_id INTEGER
+		);
+
+		CREATE TABLE returns (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT UNIQUE,
+			order_id INTEGER NOT NULL,
+			billing_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'requested',
+			reason TEXT NOT NULL,
+			admin_note TEXT,
+			refund_amount REAL NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL,
+			updated_by INTEGER
+		);
+
+		CREATE TABLE return_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			return_id INTEGER NOT NULL,
+			order_detail_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL CHECK (qty > 0),
+			price REAL NOT NULL DEFAULT 0,
+			item_condition TEXT NOT NULL DEFAULT 'pending'
+		);
+
+		CREATE TABLE customer_credits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			billing_id INTEGER,
+			payment_id INTEGER,
+			type TEXT NOT NULL,
+			amount NUMERIC NOT NULL CHECK (amount <> 0),
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema retur")
+
+	return db
+}
+
+// TestRequestReturn menguji pengajuan retur beserta validasi status order dan qty.
+func TestRequestReturn(t *testing.T) {
+	db := SetupTestReturnDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReturnHandler{DB: db, Ctx: &ctx}
+
+	// Order yang belum completed tidak bisa diretur
+	_, err := handler.RequestReturn("ORD-002", "Rusak", []entity.ReturnLine{{OrderDetailID: 3, Qty: 1}})
+	assert.Error(t, err)
+
+	// Alasan wajib diisi
+	_, err = handler.RequestReturn("ORD-001", "", []entity.ReturnLine{{OrderDetailID: 1, Qty: 1}})
+	assert.Error(t, err)
+
+	ret, err := handler.RequestReturn("ORD-001", "Barang cacat", []entity.ReturnLine{{OrderDetailID: 1, Qty: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, entity.ReturnRequested, ret.Status)
+	assert.Equal(t, 1, ret.BillingID)
+	assert.Equal(t, entity.NewMoney(110000), ret.RefundAmount, "100000 + pajak 10%")
+
+	// Sisa qty yang bisa diretur tinggal 1
+	_, err = handler.RequestReturn("ORD-001", "Salah ukuran", []entity.ReturnLine{{OrderDetailID: 1, Qty: 2}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "melebihi")
+
+	// Baris milik order lain ditolak
+	_, err = handler.RequestReturn("ORD-001", "Salah kirim", []entity.ReturnLine{{OrderDetailID: 3, Qty: 1}})
+	assert.Error(t, err)
+}
+
+// TestRequestReturn_TaxLines memastikan porsi pajak refund mengikuti rincian pajak per baris billing.
+func TestRequestReturn_TaxLines(t *testing.T) {
+	db := SetupTestReturnDB(t)
+	defer db.Close()
+
+	// Dumbbell exclusive 10%, treadmill harga sudah termasuk pajak
+	_, err := db.Exec(`
+		INSERT INTO billing_tax_lines (billing_id, order_detail_id, tax_class, rate, price_includes_tax, taxable_amount, tax_amount) VALUES
+		(1, 1, 'standard', 10.00, 0, 200000, 20000),
+		(1, 2, 'standard', 10.00, 1, 45454.55, 4545.45)
+	`)
+	require.NoError(t, err)
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReturnHandler{DB: db, Ctx: &ctx}
+
+	ret, err := handler.RequestReturn("ORD-001", "Tidak sesuai", []entity.ReturnLine{
+		{OrderDetailID: 1, Qty: 1},
+		{OrderDetailID: 2, Qty: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(160000), ret.RefundAmount, "100000 + pajak 10000 + 50000 inclusive")
+}
+
+// TestReturnWorkflow menguji alur approve, penerimaan barang, dan pengembalian stok.
+func TestReturnWorkflow(t *testing.T) {
+	db := SetupTestReturnDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReturnHandler{DB: db, Ctx: &ctx}
+
+	ret, err := handler.RequestReturn("ORD-001", "Tidak sesuai", []entity.ReturnLine{
+		{OrderDetailID: 1, Qty: 2},
+		{OrderDetailID: 2, Qty: 1},
+	})
+	require.NoError(t, err)
+
+	// Barang belum bisa diterima sebelum retur disetujui
+	assert.Error(t, handler.ReceiveReturn(ret.NumberDisplay, nil))
+
+	require.NoError(t, handler.ReviewReturn(ret.NumberDisplay, true, "Disetujui"))
+	// Retur yang sudah diproses tidak bisa direview ulang
+	assert.Error(t, handler.ReviewReturn(ret.NumberDisplay, false, ""))
+
+	// Treadmill rusak, dumbbell dikembalikan ke stok
+	conditions := map[int]entity.ItemCondition{ret.Items[1].ID: entity.ConditionDamaged}
+	require.NoError(t, handler.ReceiveReturn(ret.NumberDisplay, conditions))
+
+	var dumbbellStock, treadmillStock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = 1").Scan(&dumbbellStock))
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = 2").Scan(&treadmillStock))
+	assert.Equal(t, 7, dumbbellStock)
+	assert.Equal(t, 2, treadmillStock)
+
+	// Seluruh nilai order diretur sehingga billing menjadi refunded
+	var refunded float64
+	var billingStatus string
+	require.NoError(t, db.QueryRow("SELECT SUM(amount) FROM refunds WHERE return_id = ?", ret.ID).Scan(&refunded))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&billingStatus))
+	assert.Equal(t, float64(275000), refunded)
+	assert.Equal(t, string(entity.StatusRefunded), billingStatus)
+
+	got, err := handler.GetReturnByNumber(ret.NumberDisplay)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ReturnReceived, got.Status)
+	require.Len(t, got.Items, 2)
+	assert.Equal(t, entity.ConditionRestock, got.Items[0].Condition)
+	assert.Equal(t, entity.ConditionDamaged, got.Items[1].Condition)
+}
+
+// TestReturnRejected memastikan qty dari retur yang ditolak bisa diajukan kembali.
+func TestReturnRejected(t *testing.T) {
+	db := SetupTestReturnDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ReturnHandler{DB: db, Ctx: &ctx}
+
+	ret, err := handler.RequestReturn("ORD-001", "Berubah pikiran", []entity.ReturnLine{{OrderDetailID: 2, Qty: 1}})
+	require.NoError(t, err)
+	require.NoError(t, handler.ReviewReturn(ret.NumberDisplay, false, "Di luar masa retur"))
+
+	_, err = handler.RequestReturn("ORD-001", "Barang cacat", []entity.ReturnLine{{OrderDetailID: 2, Qty: 1}})
+	assert.NoError(t, err)
+
+	returns, err := handler.GetMyReturns()
+	require.NoError(t, err)
+	assert.Len(t, returns, 2)
+}
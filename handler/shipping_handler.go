@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+)
+
+// ShippingHandler menangani tarif pengiriman yang dikonfigurasi admin dan perhitungan ongkos kirim
+type ShippingHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// queryer adalah kumpulan method yang dimiliki *sql.DB maupun *sql.Tx,
+// sehingga query yang sama bisa dijalankan di dalam atau di luar transaksi
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// CreateRate menambahkan tarif pengiriman baru (khusus admin)
+func (s *ShippingHandler) CreateRate(rate entity.ShippingRate) error {
+	user, ok := utils.GetUser(*s.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	// Validasi tipe tarif dan field yang dibutuhkan sesuai tipenya
+	switch rate.Type {
+	case entity.RateFlat:
+	case entity.RateWeight:
+		if rate.MaxWeight > 0 && rate.MaxWeight < rate.MinWeight {
+			return errors.New("Berat maksimal harus lebih besar dari berat minimal")
+		}
+	case entity.RateZone:
+		if strings.TrimSpace(rate.Zone) == "" {
+			return errors.New("Zona wajib diisi untuk tarif zona")
+		}
+	default:
+		return fmt.Errorf("Tipe tarif tidak valid: %s", rate.Type)
+	}
+
+	if rate.Fee < 0 {
+		return errors.New("Ongkos kirim tidak boleh negatif")
+	}
+
+	_, err := s.DB.Exec(`
+		INSERT INTO shipping_rates (name, type, zone, min_weight, max_weight, fee, is_active, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, TRUE, ?)
+	`,
+		strings.TrimSpace(rate.Name),
+		string(rate.Type),
+		nullString(strings.ToLower(strings.TrimSpace(rate.Zone))),
+		nullInt(rate.MinWeight),
+		nullInt(rate.MaxWeight),
+		rate.Fee,
+		user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan ketika membuat tarif pengiriman")
+	}
+
+	return nil
+}
+
+// GetRates mengambil semua tarif pengiriman (aktif maupun tidak)
+func (s *ShippingHandler) GetRates() ([]entity.ShippingRate, error) {
+	return getShippingRates(s.DB, false)
+}
+
+// SetRateActive mengaktifkan atau menonaktifkan tarif pengiriman
+func (s *ShippingHandler) SetRateActive(id int, active bool) error {
+	user, ok := utils.GetUser(*s.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	res, err := s.DB.Exec("UPDATE shipping_rates SET is_active = ?, updated_by = ? WHERE id = ?", active, user.ID, id)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan mengubah tarif pengiriman: %s", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("Tarif pengiriman tidak ditemukan")
+	}
+
+	return nil
+}
+
+// CalculateFee menghitung ongkos kirim berdasarkan zona alamat dan total berat (gram)
+func (s *ShippingHandler) CalculateFee(zone string, weight int) (entity.ShippingRate, error) {
+	return calculateShippingFee(s.DB, zone, weight)
+}
+
+// calculateShippingFee memilih tarif aktif yang paling spesifik:
+// tarif zona yang cocok lebih diutamakan, lalu tarif berat, terakhir tarif flat.
+func calculateShippingFee(q queryer, zone string, weight int) (entity.ShippingRate, error) {
+	rates, err := getShippingRates(q, true)
+	if err != nil {
+		return entity.ShippingRate{}, err
+	}
+
+	zone = strings.ToLower(strings.TrimSpace(zone))
+	priority := map[entity.ShippingRateType]int{entity.RateZone: 3, entity.RateWeight: 2, entity.RateFlat: 1}
+
+	var selected entity.ShippingRate
+	for _, rate := range rates {
+		// Tarif zona hanya berlaku untuk zona yang sama
+		if rate.Type == entity.RateZone && rate.Zone != zone {
+			continue
+		}
+
+		// Tarif zona dan berat harus mencakup total berat jika batas berat diisi
+		if rate.Type != entity.RateFlat {
+			if rate.MinWeight > 0 && weight < rate.MinWeight {
+				continue
+			}
+			if rate.MaxWeight > 0 && weight > rate.MaxWeight {
+				continue
+			}
+		}
+
+		if priority[rate.Type] > priority[selected.Type] {
+			selected = rate
+		}
+	}
+
+	if selected.ID == 0 {
+		return selected, errors.New("Tidak ada tarif pengiriman yang berlaku untuk alamat ini")
+	}
+
+	return selected, nil
+}
+
+// getShippingRates mengambil daftar tarif pengiriman, bisa difilter hanya yang aktif
+func getShippingRates(q queryer, activeOnly bool) ([]entity.ShippingRate, error) {
+	query := `
+		SELECT id, name, type, IFNULL(zone, ''), IFNULL(min_weight, 0), IFNULL(max_weight, 0), fee, is_active
+		FROM shipping_rates
+	`
+	if activeOnly {
+		query += " WHERE is_active = TRUE"
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil tarif pengiriman: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []entity.ShippingRate
+	for rows.Next() {
+		var rate entity.ShippingRate
+		err := rows.Scan(&rate.ID, &rate.Name, &rate.Type, &rate.Zone, &rate.MinWeight, &rate.MaxWeight, &rate.Fee, &rate.IsActive)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// nullString mengubah string kosong menjadi NULL saat disimpan ke database
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt mengubah angka 0 menjadi NULL saat disimpan ke database
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestShippingDB membuat database in-memory SQLite berisi tabel tarif pengiriman.
+func SetupTestShippingDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	_, err = db.Exec(`
+		CREATE TABLE shipping_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL CHECK (type IN ('flat', 'weight', 'zone')),
+			zone TEXT,
+			min_weight INTEGER,
+			max_weight INTEGER,
+			fee REAL NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT 1,
+			created_by INTEGER NOT NULL,
+			updated_by INTEGER
+		);
+
+		INSERT INTO shipping_rates (name, type, zone, min_weight, max_weight, fee, created_by) VALUES
+		('Flat Nasional', 'flat', NULL, NULL, NULL, 50000, 1),
+		('Ringan s/d 5kg', 'weight', NULL, NULL, 5000, 20000, 1),
+		('Berat 5-20kg', 'weight', NULL, 5001, 20000, 45000, 1),
+		('Jabodetabek', 'zone', 'jabodetabek', NULL, NULL, 15000, 1);
+	`)
+	require.NoError(t, err, "Gagal membuat schema shipping")
+
+	return db
+}
+
+// TestCalculateFee menguji prioritas tarif: zona, lalu berat, lalu flat.
+func TestCalculateFee(t *testing.T) {
+	db := SetupTestShippingDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ShippingHandler{DB: db, Ctx: &ctx}
+
+	// Zona yang punya tarif khusus
+	rate, err := handler.CalculateFee("Jabodetabek", 30000)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(15000), rate.Fee)
+
+	// Zona lain memakai tarif berat sesuai bracket
+	rate, err = handler.CalculateFee("jawa", 3000)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(20000), rate.Fee)
+
+	rate, err = handler.CalculateFee("jawa", 8000)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(45000), rate.Fee)
+
+	// Di luar bracket berat jatuh ke tarif flat
+	rate, err = handler.CalculateFee("jawa", 25000)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(50000), rate.Fee)
+
+	// Tarif flat dinonaktifkan, tidak ada tarif yang berlaku
+	require.NoError(t, handler.SetRateActive(1, false))
+	_, err = handler.CalculateFee("jawa", 25000)
+	assert.Error(t, err)
+}
+
+// TestCreateRate_Invalid menguji validasi input tarif pengiriman.
+func TestCreateRate_Invalid(t *testing.T) {
+	db := SetupTestShippingDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &ShippingHandler{DB: db, Ctx: &ctx}
+
+	assert.Error(t, handler.CreateRate(entity.ShippingRate{Name: "Tanpa zona", Type: entity.RateZone, Fee: entity.NewMoney(10000)}))
+	assert.Error(t, handler.CreateRate(entity.ShippingRate{Name: "Tipe salah", Type: "express", Fee: entity.NewMoney(10000)}))
+	assert.NoError(t, handler.CreateRate(entity.ShippingRate{Name: "Sumatera", Type: entity.RateZone, Zone: "Sumatera", Fee: entity.NewMoney(60000)}))
+
+	rate, err := handler.CalculateFee("sumatera", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(60000), rate.Fee)
+}
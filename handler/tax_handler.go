@@ -0,0 +1,374 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+	"time"
+)
+
+// TaxHandler mengelola kelas pajak, tarif berlaku per tanggal, dan penetapan kelas pajak ke kategori/produk
+type TaxHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// CreateTaxClass menambahkan kelas pajak baru (khusus admin). Tarif ditambahkan terpisah lewat AddTaxRate.
+func (t *TaxHandler) CreateTaxClass(code string, name string) error {
+	user, ok := utils.GetUser(*t.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	code = strings.ToLower(strings.TrimSpace(code))
+	name = strings.TrimSpace(name)
+	if code == "" || name == "" {
+		return errors.New("Kode dan nama kelas pajak wajib diisi")
+	}
+
+	_, err := t.DB.Exec("INSERT INTO tax_classes (code, name, created_by) VALUES (?, ?, ?)", code, name, user.ID)
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan membuat kelas pajak (kode mungkin sudah dipakai)")
+	}
+
+	return nil
+}
+
+// GetTaxClasses mengambil semua kelas pajak beserta tarif yang berlaku hari ini
+func (t *TaxHandler) GetTaxClasses() ([]entity.TaxClass, error) {
+	if _, ok := utils.GetUser(*t.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	rows, err := t.DB.Query("SELECT id, code, name FROM tax_classes ORDER BY id ASC")
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil kelas pajak: %w", err)
+	}
+
+	var classes []entity.TaxClass
+	for rows.Next() {
+		var class entity.TaxClass
+		if err := rows.Scan(&class.ID, &class.Code, &class.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Lengkapi dengan tarif yang berlaku hari ini
+	today := time.Now()
+	for i := range classes {
+		rate, err := resolveTaxRate(t.DB, classes[i].Code, today)
+		if err == nil {
+			classes[i].Rate = rate
+			classes[i].HasRate = true
+		}
+	}
+
+	return classes, nil
+}
+
+// GetTaxRates mengambil riwayat tarif sebuah kelas pajak (terbaru lebih dulu)
+func (t *TaxHandler) GetTaxRates(code string) ([]entity.TaxRate, error) {
+	if _, ok := utils.GetUser(*t.Ctx); !ok {
+		return nil, fmt.Errorf("Please Login!")
+	}
+
+	rows, err := t.DB.Query(`
+		SELECT id, tax_class, rate, effective_from, effective_to, created_by
+		FROM tax_rates
+		WHERE tax_class = ?
+		ORDER BY effective_from DESC
+	`, code)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil tarif pajak: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []entity.TaxRate
+	for rows.Next() {
+		var rate entity.TaxRate
+		var percent float64
+		var effectiveTo sql.NullTime
+		err := rows.Scan(&rate.ID, &rate.TaxClass, &percent, &rate.EffectiveFrom, &effectiveTo, &rate.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		rate.Rate = percentToBasisPoints(percent)
+		rate.EffectiveTo = effectiveTo.Time
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// AddTaxRate menambahkan tarif baru untuk kelas pajak yang berlaku mulai tanggal tertentu (khusus admin).
+// Tarif dalam basis poin (1000 = 10%). Tarif sebelumnya otomatis ditutup sehari sebelum tarif baru berlaku.
+func (t *TaxHandler) AddTaxRate(code string, rate int64, effectiveFrom time.Time) error {
+	user, ok := utils.GetUser(*t.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	if rate < 0 || rate > 10000 {
+		return errors.New("Tarif pajak harus antara 0% sampai 100%")
+	}
+	from := effectiveFrom.Format("2006-01-02")
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Kelas pajak harus terdaftar
+	var classID int
+	err = tx.QueryRow("SELECT id FROM tax_classes WHERE code = ?", code).Scan(&classID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Kelas pajak %s tidak ditemukan", code)
+		}
+		return fmt.Errorf("Terjadi kesalahan mengambil kelas pajak: %s", err)
+	}
+
+	// Tarif baru harus berlaku setelah tarif terakhir agar riwayat tidak tumpang tindih
+	var lastFrom sql.NullString
+	err = tx.QueryRow("SELECT MAX(effective_from) FROM tax_rates WHERE tax_class = ?", code).Scan(&lastFrom)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Terjadi kesalahan mengambil tarif pajak: %s", err)
+	}
+	// Driver bisa mengembalikan tanggal saja atau tanggal beserta jam; bandingkan bagian tanggalnya
+	last := lastFrom.String
+	if len(last) > 10 {
+		last = last[:10]
+	}
+	if lastFrom.Valid && from <= last {
+		tx.Rollback()
+		return fmt.Errorf("Tanggal berlaku harus setelah tarif terakhir (%s)", last)
+	}
+
+	// Tutup tarif yang masih berlaku sehari sebelum tarif baru
+	closeDate := effectiveFrom.AddDate(0, 0, -1).Format("2006-01-02")
+	_, err = tx.Exec("UPDATE tax_rates SET effective_to = ? WHERE tax_class = ? AND effective_to IS NULL", closeDate, code)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Terjadi kesalahan menutup tarif pajak sebelumnya")
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO tax_rates (tax_class, rate, effective_from, created_by) VALUES (?, ?, ?, ?)",
+		code, basisPointsToPercent(rate), from, user.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Terjadi kesalahan menyimpan tarif pajak")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Terjadi kesalahan saat commit transaksi: %v", err)
+	}
+
+	return nil
+}
+
+// SetCategoryTaxClass menetapkan kelas pajak default untuk semua produk dalam kategori
+func (t *TaxHandler) SetCategoryTaxClass(categoryID int, code string) error {
+	user, ok := utils.GetUser(*t.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	if err := t.checkTaxClass(code); err != nil {
+		return err
+	}
+
+	res, err := t.DB.Exec("UPDATE categories SET tax_class = ?, updated_by = ? WHERE id = ?", code, user.ID, categoryID)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mengubah kelas pajak kategori")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errors.New("Kategori tidak ditemukan")
+	}
+
+	return nil
+}
+
+// SetProductTax menetapkan kelas pajak produk dan apakah harganya sudah termasuk pajak.
+// Kode kosong berarti produk kembali mengikuti kelas pajak kategorinya.
+func (t *TaxHandler) SetProductTax(productID int, code string, priceIncludesTax bool) error {
+	user, ok := utils.GetUser(*t.Ctx)
+	if !ok {
+		return fmt.Errorf("Please Login!")
+	}
+
+	if code != "" {
+		if err := t.checkTaxClass(code); err != nil {
+			return err
+		}
+	}
+
+	res, err := t.DB.Exec(
+		"UPDATE products SET tax_class = ?, price_includes_tax = ?, updated_by = ? WHERE id = ?",
+		nullString(code), priceIncludesTax, user.ID, productID,
+	)
+	if err != nil {
+		return errors.New("Terjadi kesalahan mengubah pajak produk")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errors.New("Produk tidak ditemukan")
+	}
+
+	return nil
+}
+
+// checkTaxClass memastikan kode kelas pajak terdaftar
+func (t *TaxHandler) checkTaxClass(code string) error {
+	var id int
+	err := t.DB.QueryRow("SELECT id FROM tax_classes WHERE code = ?", code).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Kelas pajak %s tidak ditemukan", code)
+		}
+		return fmt.Errorf("Terjadi kesalahan mengambil kelas pajak: %s", err)
+	}
+	return nil
+}
+
+// resolveTaxRate mencari tarif kelas pajak (basis poin) yang berlaku pada tanggal tertentu
+func resolveTaxRate(q queryer, code string, date time.Time) (int64, error) {
+	day := date.Format("2006-01-02")
+
+	var percent float64
+	err := q.QueryRow(`
+		SELECT rate
+		FROM tax_rates
+		WHERE tax_class = ? AND effective_from <= ? AND (effective_to IS NULL OR effective_to >= ?)
+		ORDER BY effective_from DESC
+		LIMIT 1
+	`, code, day, day).Scan(&percent)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("Tarif pajak %s untuk tanggal %s belum diatur", code, day)
+		}
+		return 0, fmt.Errorf("Terjadi kesalahan mengambil tarif pajak: %s", err)
+	}
+
+	return percentToBasisPoints(percent), nil
+}
+
+// computeTaxLines menghitung pajak setiap baris order berdasarkan snapshot kelas pajak dan tarif pada tanggal tagihan.
+// Harga inclusive dipecah menjadi dasar pengenaan pajak dan pajaknya, harga exclusive dikenai tarif di atas harga.
+func computeTaxLines(q queryer, orderID int, date time.Time) ([]entity.BillingTaxLine, error) {
+	rows, err := q.Query(`
+		SELECT id, product_name, qty * price, tax_class, price_includes_tax
+		FROM order_details
+		WHERE order_id = ?
+		ORDER BY id ASC
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil detail order: %w", err)
+	}
+
+	var lines []entity.BillingTaxLine
+	var gross []entity.Money
+	for rows.Next() {
+		var line entity.BillingTaxLine
+		var amount entity.Money
+		err := rows.Scan(&line.OrderDetailID, &line.ProductName, &amount, &line.TaxClass, &line.PriceIncludesTax)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, line)
+		gross = append(gross, amount)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Tarif dicari setelah rows ditutup, satu kali per kelas pajak
+	rates := map[string]int64{}
+	for i := range lines {
+		rate, ok := rates[lines[i].TaxClass]
+		if !ok {
+			rate, err = resolveTaxRate(q, lines[i].TaxClass, date)
+			if err != nil {
+				return nil, err
+			}
+			rates[lines[i].TaxClass] = rate
+		}
+
+		lines[i].Rate = rate
+		if lines[i].PriceIncludesTax {
+			lines[i].TaxAmount = gross[i].MulDiv(entity.Money(rate), entity.Money(10000+rate))
+			lines[i].TaxableAmount = gross[i] - lines[i].TaxAmount
+		} else {
+			lines[i].TaxAmount = gross[i].ApplyRate(rate)
+			lines[i].TaxableAmount = gross[i]
+		}
+	}
+
+	return lines, nil
+}
+
+// getBillingTaxLines mengambil rincian pajak per baris yang tersimpan pada billing
+func getBillingTaxLines(q queryer, billingID int) ([]entity.BillingTaxLine, error) {
+	rows, err := q.Query(`
+		SELECT btl.id, btl.billing_id, btl.order_detail_id, od.product_name, btl.tax_class, btl.rate,
+			btl.price_includes_tax, btl.taxable_amount, btl.tax_amount
+		FROM billing_tax_lines btl
+		JOIN order_details od ON od.id = btl.order_detail_id
+		WHERE btl.billing_id = ?
+		ORDER BY btl.id ASC
+	`, billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil rincian pajak: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []entity.BillingTaxLine
+	for rows.Next() {
+		var line entity.BillingTaxLine
+		var percent float64
+		err := rows.Scan(
+			&line.ID,
+			&line.BillingID,
+			&line.OrderDetailID,
+			&line.ProductName,
+			&line.TaxClass,
+			&percent,
+			&line.PriceIncludesTax,
+			&line.TaxableAmount,
+			&line.TaxAmount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		line.Rate = percentToBasisPoints(percent)
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+// percentToBasisPoints mengubah tarif persen di database (DECIMAL(5,2)) menjadi basis poin
+func percentToBasisPoints(percent float64) int64 {
+	return int64(math.Round(percent * 100))
+}
+
+// basisPointsToPercent mengubah basis poin menjadi string persen dua desimal untuk disimpan ke database
+func basisPointsToPercent(rate int64) string {
+	return fmt.Sprintf("%d.%02d", rate/100, rate%100)
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestTaxDB membuat database in-memory SQLite berisi kelas pajak, tarif, kategori, dan produk.
+func SetupTestTaxDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+
+	// Satu koneksi agar semua query melihat database in-memory yang sama
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE tax_classes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			created_by INTEGER NOT NULL
+		);
+
+		INSERT INTO tax_classes (code, name, created_by) VALUES
+		('standard', 'PPN Standar', 1),
+		('reduced', 'PPN Tarif Khusus', 1),
+		('exempt', 'Bebas PPN', 1);
+
+		CREATE TABLE tax_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tax_class TEXT NOT NULL,
+			rate NUMERIC NOT NULL,
+			effective_from DATE NOT NULL,
+			effective_to DATE,
+			created_by INTEGER NOT NULL,
+			UNIQUE (tax_class, effective_from)
+		);
+
+		INSERT INTO tax_rates (tax_class, rate, effective_from, created_by) VALUES
+		('standard', 10.00, '2020-01-01', 1),
+		('reduced', 5.00, '2020-01-01', 1),
+		('exempt', 0.00, '2020-01-01', 1);
+
+		CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tax_class TEXT NOT NULL DEFAULT 'standard',
+			updated_by INTEGER
+		);
+
+		INSERT INTO categories (name, tax_class) VALUES
+		('Fitness Equipment', 'standard'),
+		('Nutrition & Supplements', 'reduced');
+
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			category_id INTEGER NOT NULL,
+			stock INTEGER NOT NULL DEFAULT 10,
+			price NUMERIC NOT NULL DEFAULT 0,
+			tax_class TEXT,
+			price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+			weight INTEGER NOT NULL DEFAULT 0,
+			updated_by INTEGER
+		);
+
+		INSERT INTO products (name, category_id, price, tax_class, price_includes_tax) VALUES
+		('Adjustable Dumbbell 20kg', 1, 100000, NULL, 0),
+		('Whey Protein 1kg', 2, 100000, NULL, 0),
+		('Yoga Mat Premium', 1, 110000, NULL, 1);
+
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT,
+			customer_id INTEGER,
+			date DATETIME DEFAULT CURRENT_TIMESTAMP,
+			total NUMERIC DEFAULT 0,
+			created_by INTEGER
+		);
+
+		CREATE TABLE order_details (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER,
+			product_id INTEGER,
+			qty INTEGER,
+			product_name TEXT NOT NULL,
+			price NUMERIC DEFAULT 0 NOT NULL,
+			tax_class TEXT DEFAULT 'standard' NOT NULL,
+			price_includes_tax BOOLEAN DEFAULT 0 NOT NULL,
+			weight INTEGER DEFAULT 0 NOT NULL,
+			created_by INTEGER
+		);
+
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			number_display TEXT,
+			issue_date TIMESTAMP,
+			due_date TIMESTAMP,
+			tax NUMERIC DEFAULT 0 NOT NULL,
+			shipping_fee NUMERIC DEFAULT 0 NOT NULL,
+			total NUMERIC DEFAULT 0 NOT NULL,
+			status TEXT DEFAULT 'unpaid',
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE stock_reservations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			qty INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'reserved'
+		);
+
+		CREATE TABLE billing_tax_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			order_detail_id INTEGER NOT NULL,
+			tax_class TEXT NOT NULL,
+			rate NUMERIC NOT NULL,
+			price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+			taxable_amount NUMERIC NOT NULL DEFAULT 0,
+			tax_amount NUMERIC NOT NULL DEFAULT 0
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema pajak")
+
+	return db
+}
+
+// TestGenerateBill_MixedTaxClasses menguji pajak per baris untuk kelas pajak berbeda dan harga inclusive.
+func TestGenerateBill_MixedTaxClasses(t *testing.T) {
+	db := SetupTestTaxDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	orderHandler := &OrderHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	order, err := orderHandler.CreateOrder([]entity.OrderProduct{
+		{ProductId: 1, Qty: 2}, // standar 10%, 200000 + pajak 20000
+		{ProductId: 2, Qty: 1}, // ikut kategori (reduced 5%), 100000 + pajak 5000
+		{ProductId: 3, Qty: 1}, // inclusive 10%, 110000 = 100000 + pajak 10000
+	})
+	require.NoError(t, err)
+
+	// Kelas pajak produk tanpa tax_class diwarisi dari kategori
+	require.Len(t, order.Details, 3)
+	assert.Equal(t, "standard", order.Details[0].TaxClass)
+	assert.Equal(t, "reduced", order.Details[1].TaxClass)
+	assert.True(t, order.Details[2].PriceIncludesTax)
+
+	order.ShippingFee = entity.NewMoney(15000)
+	billing, err := billingHandler.GenerateBill(order)
+	require.NoError(t, err)
+
+	assert.Equal(t, entity.NewMoney(35000), billing.Tax)
+	assert.Equal(t, entity.NewMoney(450000), billing.Total, "400000 dasar pajak + 35000 pajak + 15000 ongkir")
+
+	// Rincian pajak tersimpan per baris
+	lines, err := getBillingTaxLines(db, billing.ID)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	assert.Equal(t, int64(500), lines[1].Rate)
+	assert.Equal(t, entity.NewMoney(5000), lines[1].TaxAmount)
+	assert.True(t, lines[2].PriceIncludesTax)
+	assert.Equal(t, entity.NewMoney(100000), lines[2].TaxableAmount)
+	assert.Equal(t, entity.NewMoney(10000), lines[2].TaxAmount)
+}
+
+// TestAddTaxRate menguji tarif berlaku per tanggal beserta penutupan tarif sebelumnya.
+func TestAddTaxRate(t *testing.T) {
+	db := SetupTestTaxDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &TaxHandler{DB: db, Ctx: &ctx}
+
+	today := time.Now()
+	nextMonth := today.AddDate(0, 1, 0)
+
+	// Validasi tarif dan kelas pajak
+	assert.Error(t, handler.AddTaxRate("standard", 10001, nextMonth))
+	assert.Error(t, handler.AddTaxRate("luxury", 2000, nextMonth))
+	// Tanggal berlaku tidak boleh sebelum tarif terakhir
+	assert.Error(t, handler.AddTaxRate("standard", 1200, time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local)))
+
+	require.NoError(t, handler.AddTaxRate("standard", 1200, nextMonth))
+
+	// Tarif lama masih berlaku sampai sehari sebelum tarif baru
+	rate, err := resolveTaxRate(db, "standard", today)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), rate)
+
+	rate, err = resolveTaxRate(db, "standard", nextMonth.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), rate)
+
+	rate, err = resolveTaxRate(db, "standard", nextMonth)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1200), rate)
+
+	rates, err := handler.GetTaxRates("standard")
+	require.NoError(t, err)
+	require.Len(t, rates, 2)
+	assert.True(t, rates[0].EffectiveTo.IsZero())
+	assert.Equal(t, nextMonth.AddDate(0, 0, -1).Format("2006-01-02"), rates[1].EffectiveTo.Format("2006-01-02"))
+
+	// Tanggal sebelum tarif pertama tidak punya tarif
+	_, err = resolveTaxRate(db, "standard", time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local))
+	assert.Error(t, err)
+
+	// Tanggal tarif terakhir yang lebih pendek dari format YYYY-MM-DD tidak membuat panic
+	_, err = db.Exec("UPDATE tax_rates SET effective_from = '2020' WHERE tax_class = 'exempt'")
+	require.NoError(t, err)
+	assert.NotPanics(t, func() {
+		assert.NoError(t, handler.AddTaxRate("exempt", 0, nextMonth))
+	})
+}
+
+// TestSetProductTax menguji penetapan kelas pajak produk dan kategori.
+func TestSetProductTax(t *testing.T) {
+	db := SetupTestTaxDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &TaxHandler{DB: db, Ctx: &ctx}
+
+	assert.Error(t, handler.SetProductTax(1, "luxury", false))
+	assert.Error(t, handler.SetProductTax(99, "exempt", false))
+	require.NoError(t, handler.SetProductTax(1, "exempt", true))
+	require.NoError(t, handler.SetCategoryTaxClass(2, "exempt"))
+
+	var productClass sql.NullString
+	var inclusive bool
+	require.NoError(t, db.QueryRow("SELECT tax_class, price_includes_tax FROM products WHERE id = 1").Scan(&productClass, &inclusive))
+	assert.Equal(t, "exempt", productClass.String)
+	assert.True(t, inclusive)
+
+	// Kode kosong mengembalikan produk ke kelas pajak kategori
+	require.NoError(t, handler.SetProductTax(1, "", false))
+	require.NoError(t, db.QueryRow("SELECT tax_class FROM products WHERE id = 1").Scan(&productClass))
+	assert.False(t, productClass.Valid)
+
+	classes, err := handler.GetTaxClasses()
+	require.NoError(t, err)
+	require.Len(t, classes, 3)
+	assert.Equal(t, int64(1000), classes[0].Rate)
+	assert.True(t, classes[2].HasRate)
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/utils"
+	"strings"
+)
+
+// VirtualAccountHandler menerbitkan nomor virtual account per billing dan mencocokkan transfer VA yang masuk
+type VirtualAccountHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+// IssueVirtualAccount menerbitkan nomor VA untuk billing di bank tertentu.
+// Jika billing sudah punya VA di bank tersebut, nomor yang sama dikembalikan. Selain admin hanya untuk billing milik sendiri.
+func (v *VirtualAccountHandler) IssueVirtualAccount(billNumber string, bankCode string) (entity.VirtualAccount, error) {
+	va := entity.VirtualAccount{BillingNumber: billNumber, Bank: strings.ToLower(strings.TrimSpace(bankCode))}
+
+	user, ok := utils.GetUser(*v.Ctx)
+	if !ok {
+		return va, fmt.Errorf("Please Login!")
+	}
+
+	bank, ok := entity.FindVABank(va.Bank)
+	if !ok {
+		return va, fmt.Errorf("Bank %s tidak mendukung virtual account", bankCode)
+	}
+
+	query := `
+		SELECT b.id, b.status, o.customer_id
+		FROM billings b
+		JOIN orders o ON o.id = b.order_id
+		WHERE b.number_display = ?
+	`
+	args := []interface{}{billNumber}
+	if user.Role != entity.RoleAdmin {
+		query += " AND o.customer_id = ?"
+		args = append(args, user.Customer.ID)
+	}
+
+	var status entity.StatusBilling
+	err := v.DB.QueryRow(query, args...).Scan(&va.BillingID, &status, &va.CustomerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return va, errors.New("Billing tidak ditemukan")
+		}
+		return va, fmt.Errorf("Terjadi kesalahan mengambil billing: %s", err)
+	}
+	if status != entity.StatusUnpaid && status != entity.StatusLesspaid {
+		return va, fmt.Errorf("Billing berstatus %s tidak membutuhkan virtual account", status)
+	}
+
+	// VA yang sudah terbit dipakai ulang agar customer tidak menerima nomor berbeda
+	vas, err := getVirtualAccounts(v.DB, va.BillingID)
+	if err != nil {
+		return va, err
+	}
+	for _, existing := range vas {
+		if existing.Bank == bank.Code {
+			return existing, nil
+		}
+	}
+
+	va.Number = entity.NewVANumber(bank.Prefix, va.CustomerID, va.BillingID)
+	res, err := v.DB.Exec(
+		"INSERT INTO virtual_accounts (billing_id, customer_id, bank, va_number, created_by) VALUES (?, ?, ?, ?, ?)",
+		va.BillingID, va.CustomerID, bank.Code, va.Number, user.ID,
+	)
+	if err != nil {
+		return va, fmt.Errorf("Gagal menerbitkan virtual account %s: %s", va.Number, err)
+	}
+
+	vaID, err := res.LastInsertId()
+	if err != nil {
+		return va, err
+	}
+	va.ID = int(vaID)
+	va.CreatedBy = user.ID
+
+	return va, nil
+}
+
+// ReceiveVAPayment mencatat transfer yang masuk ke nomor VA (notifikasi bank) sebagai payment billing pemilik VA.
+// Dana sudah diterima bank, sehingga charge langsung approved: kelebihan dari sisa tagihan masuk saldo kredit.
+// Referensi bank dipakai sebagai charge_ref sehingga notifikasi ganda ditolak dengan ErrPaymentDuplicate.
+func (v *VirtualAccountHandler) ReceiveVAPayment(billingHandler *BillingHandler, vaNumber string, amount entity.Money, reference string) (entity.PaymentCharge, error) {
+	var charge entity.PaymentCharge
+
+	user, ok := utils.GetUser(*v.Ctx)
+	if !ok {
+		return charge, fmt.Errorf("Please Login!")
+	}
+
+	vaNumber = strings.TrimSpace(vaNumber)
+	reference = strings.TrimSpace(reference)
+	if !entity.ValidVANumber(vaNumber) {
+		return charge, fmt.Errorf("Nomor virtual account %s tidak valid", vaNumber)
+	}
+	if amount <= 0 {
+		return charge, errors.New("Nominal transfer harus lebih dari 0")
+	}
+	if reference == "" {
+		return charge, errors.New("Referensi transfer wajib diisi")
+	}
+
+	var bank string
+	err := v.DB.QueryRow(`
+		SELECT va.billing_id, b.number_display, va.bank
+		FROM virtual_accounts va
+		JOIN billings b ON b.id = va.billing_id
+		WHERE va.va_number = ?
+	`, vaNumber).Scan(&charge.BillingID, &charge.BillingNumber, &bank)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return charge, fmt.Errorf("Virtual account %s belum diterbitkan", vaNumber)
+		}
+		return charge, fmt.Errorf("Terjadi kesalahan mengambil virtual account: %s", err)
+	}
+
+	// Referensi bank unik per bank, diberi awalan kode bank agar tidak bentrok dengan ID gateway
+	charge.ChargeRef = "VA-" + strings.ToUpper(bank) + "-" + reference
+	charge.Message = "Transfer VA " + vaNumber
+	charge.Amount = amount
+	charge.Method = entity.MethodVA
+	charge.CreatedBy = user.ID
+
+	paymentHandler := PaymentHandler{DB: v.DB, Ctx: v.Ctx}
+	return paymentHandler.recordReceivedPayment(billingHandler, charge)
+}
+
+// getVirtualAccounts mengambil nomor VA sebuah billing urut sesuai waktu terbit
+func getVirtualAccounts(q queryer, billingID int) ([]entity.VirtualAccount, error) {
+	rows, err := q.Query(`
+		SELECT va.id, va.billing_id, b.number_display, va.customer_id, va.bank, va.va_number, va.created_at, va.created_by
+		FROM virtual_accounts va
+		JOIN billings b ON b.id = va.billing_id
+		WHERE va.billing_id = ?
+		ORDER BY va.id ASC
+	`, billingID)
+	if err != nil {
+		return nil, fmt.Errorf("Terjadi kesalahan mengambil virtual account: %w", err)
+	}
+	defer rows.Close()
+
+	var vas []entity.VirtualAccount
+	for rows.Next() {
+		var va entity.VirtualAccount
+		err := rows.Scan(&va.ID, &va.BillingID, &va.BillingNumber, &va.CustomerID, &va.Bank, &va.Number, &va.CreatedAt, &va.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		vas = append(vas, va)
+	}
+
+	return vas, rows.Err()
+}
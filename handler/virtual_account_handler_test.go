@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	"pairproject/entity"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestVirtualAccountDB memakai schema saldo kredit (dua billing unpaid milik customer 1 dan 2)
+// ditambah tabel virtual_accounts.
+func SetupTestVirtualAccountDB(t *testing.T) *sql.DB {
+	db := SetupTestCreditDB(t)
+
+	_, err := db.Exec(`
+		CREATE TABLE virtual_accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL,
+			bank TEXT NOT NULL,
+			va_number TEXT NOT NULL UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL,
+			UNIQUE (billing_id, bank)
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema virtual account")
+
+	return db
+}
+
+// TestIssueVirtualAccount menguji penerbitan nomor VA, pemakaian ulang nomor, dan pembatasan billing.
+func TestIssueVirtualAccount(t *testing.T) {
+	db := SetupTestVirtualAccountDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &VirtualAccountHandler{DB: db, Ctx: &ctx}
+
+	va, err := handler.IssueVirtualAccount("BIL-001", "BCA")
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewVANumber("39358", 1, 1), va.Number)
+	assert.True(t, entity.ValidVANumber(va.Number))
+
+	// Penerbitan ulang di bank yang sama mengembalikan nomor yang sama
+	again, err := handler.IssueVirtualAccount("BIL-001", "bca")
+	require.NoError(t, err)
+	assert.Equal(t, va.ID, again.ID)
+
+	// Bank lain menghasilkan nomor lain untuk billing yang sama
+	bni, err := handler.IssueVirtualAccount("BIL-001", "bni")
+	require.NoError(t, err)
+	assert.NotEqual(t, va.Number, bni.Number)
+
+	// Bank tidak dikenal dan billing milik customer lain ditolak
+	_, err = handler.IssueVirtualAccount("BIL-001", "xyz")
+	assert.Error(t, err)
+	_, err = handler.IssueVirtualAccount("BIL-002", "bca")
+	assert.Error(t, err)
+
+	// Billing lunas tidak membutuhkan VA
+	_, err = db.Exec("UPDATE billings SET status = 'paid' WHERE id = 1")
+	require.NoError(t, err)
+	_, err = handler.IssueVirtualAccount("BIL-001", "bri")
+	assert.Error(t, err)
+}
+
+// TestReceiveVAPayment menguji pencocokan transfer VA ke billing, notifikasi ganda, dan kelebihan transfer.
+func TestReceiveVAPayment(t *testing.T) {
+	db := SetupTestVirtualAccountDB(t)
+	defer db.Close()
+
+	ctx := utils.NewTestContextWithUser()
+	handler := &VirtualAccountHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	va, err := handler.IssueVirtualAccount("BIL-001", "mandiri")
+	require.NoError(t, err)
+
+	// Nomor dengan check digit salah atau belum diterbitkan ditolak
+	wrong := []byte(va.Number)
+	wrong[len(wrong)-1] = '0' + (wrong[len(wrong)-1]-'0'+1)%10
+	_, err = handler.ReceiveVAPayment(billingHandler, string(wrong), entity.NewMoney(1000), "TRX-0")
+	assert.Error(t, err)
+	_, err = handler.ReceiveVAPayment(billingHandler, entity.NewVANumber("89608", 1, 2), entity.NewMoney(1000), "TRX-0")
+	assert.Error(t, err)
+
+	charge, err := handler.ReceiveVAPayment(billingHandler, va.Number, entity.NewMoney(60000), "TRX-1")
+	require.NoError(t, err)
+	assert.Equal(t, "BIL-001", charge.BillingNumber)
+	assert.Equal(t, entity.ChargeApproved, charge.Status)
+	assert.NotZero(t, charge.PaymentID)
+
+	// Notifikasi ganda dengan referensi yang sama tidak dicatat dua kali
+	_, err = handler.ReceiveVAPayment(billingHandler, va.Number, entity.NewMoney(60000), "TRX-1")
+	assert.ErrorIs(t, err, ErrPaymentDuplicate)
+
+	// Pencatatan payment gagal: charge ikut dibatalkan sehingga referensi yang sama bisa dicoba lagi
+	_, err = db.Exec("ALTER TABLE payments RENAME TO payments_offline")
+	require.NoError(t, err)
+	_, err = handler.ReceiveVAPayment(billingHandler, va.Number, entity.NewMoney(50000), "TRX-2")
+	require.Error(t, err)
+	_, err = db.Exec("ALTER TABLE payments_offline RENAME TO payments")
+	require.NoError(t, err)
+
+	var pending int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payment_charges WHERE charge_ref = 'TRX-2'").Scan(&pending))
+	assert.Zero(t, pending)
+
+	// Transfer melebihi sisa tagihan: billing lunas dan kelebihannya menjadi saldo kredit
+	_, err = handler.ReceiveVAPayment(billingHandler, va.Number, entity.NewMoney(50000), "TRX-2")
+	require.NoError(t, err)
+
+	var paid entity.Money
+	var status, method string
+	require.NoError(t, db.QueryRow("SELECT SUM(amount), MIN(method) FROM payments WHERE billing_id = 1").Scan(&paid, &method))
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	assert.Equal(t, entity.NewMoney(100000), paid)
+	assert.Equal(t, string(entity.MethodVA), method)
+	assert.Equal(t, string(entity.StatusPaid), status)
+
+	balance, err := (&CreditHandler{DB: db, Ctx: &ctx}).GetMyCreditBalance()
+	require.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(10000), balance)
+}
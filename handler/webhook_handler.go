@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pairproject/entity"
+	"pairproject/gateway"
+)
+
+// WebhookHandler memproses event perubahan status charge yang dikirim payment gateway.
+// Dipanggil oleh endpoint webhook sehingga tidak membutuhkan user login.
+type WebhookHandler struct {
+	DB  *sql.DB
+	Ctx *context.Context
+}
+
+var (
+	// ErrWebhookDuplicate dikembalikan jika event dengan ID yang sama sudah pernah selesai diproses
+	ErrWebhookDuplicate = errors.New("Event webhook sudah pernah diproses")
+	// ErrChargeNotFound dikembalikan jika event merujuk charge yang tidak dikenal
+	ErrChargeNotFound = errors.New("Charge tidak ditemukan")
+)
+
+// HandleEvent mencatat event webhook lalu menerapkannya ke charge terkait: charge approved dicatat sebagai payment
+// dan status billing serta order diperbarui. Event yang sudah processed ditolak dengan ErrWebhookDuplicate,
+// sedangkan event yang sebelumnya failed diproses ulang saat dikirim kembali oleh gateway.
+func (wh *WebhookHandler) HandleEvent(billingHandler *BillingHandler, event gateway.Event, payload []byte) (entity.WebhookEvent, error) {
+	if err := event.Validate(); err != nil {
+		return entity.WebhookEvent{}, err
+	}
+
+	record, err := wh.receiveEvent(event, payload)
+	if err != nil {
+		return record, err
+	}
+
+	if err := wh.applyEvent(billingHandler, event); err != nil {
+		record.Status, record.Error = entity.WebhookFailed, err.Error()
+		if len(record.Error) > 255 {
+			record.Error = record.Error[:255]
+		}
+		_, updateErr := wh.DB.Exec("UPDATE webhook_events SET status = ?, error = ? WHERE id = ?", string(record.Status), record.Error, record.ID)
+		if updateErr != nil {
+			return record, fmt.Errorf("Gagal memperbarui event webhook: %s", updateErr)
+		}
+		return record, err
+	}
+
+	record.Status, record.Error = entity.WebhookProcessed, ""
+	_, err = wh.DB.Exec(
+		"UPDATE webhook_events SET status = ?, error = NULL, processed_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(record.Status), record.ID,
+	)
+	if err != nil {
+		return record, fmt.Errorf("Gagal memperbarui event webhook: %s", err)
+	}
+
+	return record, nil
+}
+
+// receiveEvent menyimpan event baru, atau menambah jumlah percobaan untuk event yang sebelumnya gagal
+func (wh *WebhookHandler) receiveEvent(event gateway.Event, payload []byte) (entity.WebhookEvent, error) {
+	record := entity.WebhookEvent{EventID: event.ID, Type: event.Type, ChargeRef: event.ChargeID, Payload: string(payload)}
+
+	var errorText sql.NullString
+	err := wh.DB.QueryRow(
+		"SELECT id, status, error, attempts FROM webhook_events WHERE event_id = ?", event.ID,
+	).Scan(&record.ID, &record.Status, &errorText, &record.Attempts)
+	switch {
+	case err == sql.ErrNoRows:
+		record.Status, record.Attempts = entity.WebhookReceived, 1
+		res, err := wh.DB.Exec(
+			"INSERT INTO webhook_events (event_id, type, charge_ref, payload, status) VALUES (?, ?, ?, ?, ?)",
+			record.EventID, record.Type, record.ChargeRef, record.Payload, string(record.Status),
+		)
+		if err != nil {
+			return record, fmt.Errorf("Gagal menyimpan event webhook %s: %s", event.ID, err)
+		}
+		eventID, err := res.LastInsertId()
+		if err != nil {
+			return record, err
+		}
+		record.ID = int(eventID)
+		return record, nil
+	case err != nil:
+		return record, fmt.Errorf("Terjadi kesalahan mengambil event webhook: %s", err)
+	}
+
+	record.Error = errorText.String
+	if record.Status == entity.WebhookProcessed {
+		return record, ErrWebhookDuplicate
+	}
+
+	record.Status = entity.WebhookReceived
+	record.Attempts++
+	_, err = wh.DB.Exec("UPDATE webhook_events SET status = ?, attempts = ? WHERE id = ?", string(record.Status), record.Attempts, record.ID)
+	if err != nil {
+		return record, fmt.Errorf("Gagal memperbarui event webhook: %s", err)
+	}
+
+	return record, nil
+}
+
+// applyEvent menerapkan status dari event ke charge. Charge yang sudah tidak pending (misal sudah dikonfirmasi worker)
+// dibiarkan, sehingga event yang datang terlambat tidak mencatat payment dua kali.
+func (wh *WebhookHandler) applyEvent(billingHandler *BillingHandler, event gateway.Event) error {
+	paymentHandler := PaymentHandler{DB: wh.DB, Ctx: wh.Ctx}
+	charges, err := paymentHandler.getCharges("WHERE pc.charge_ref = ?", event.ChargeID)
+	if err != nil {
+		return err
+	}
+	if len(charges) == 0 {
+		return ErrChargeNotFound
+	}
+
+	charge := charges[0]
+	// Gateway menagih nominal billing ditambah biaya metode
+	if event.Amount != charge.Total() {
+		return fmt.Errorf("Nominal event %s tidak sama dengan charge %s", event.Amount.Rupiah(), charge.Total().Rupiah())
+	}
+	if charge.Status != entity.ChargePending {
+		return nil
+	}
+
+	err = paymentHandler.resolveCharge(billingHandler, charge, gateway.Charge{ID: charge.ChargeRef, Status: event.Status, Message: event.Message})
+	var declined *PaymentDeclinedError
+	if errors.Is(err, ErrPaymentPending) || errors.As(err, &declined) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/gateway"
+	"pairproject/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupTestWebhookDB memakai schema saldo kredit ditambah tabel webhook_events.
+func SetupTestWebhookDB(t *testing.T) *sql.DB {
+	db := SetupTestCreditDB(t)
+
+	_, err := db.Exec(`
+		CREATE TABLE webhook_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL UNIQUE,
+			type TEXT NOT NULL,
+			charge_ref TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'received',
+			error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 1,
+			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			processed_at DATETIME
+		);
+	`)
+	require.NoError(t, err, "Gagal membuat schema webhook")
+
+	return db
+}
+
+// pendingCharge membuat charge pending lewat simulator mode delay dan mengembalikan charge tersebut
+func pendingCharge(t *testing.T, db *sql.DB, amount entity.Money) entity.PaymentCharge {
+	ctx := utils.NewTestContextWithUser()
+	sim := gateway.NewSimulator(gateway.ModeDelay)
+	sim.Delay = time.Hour
+	paymentHandler := &PaymentHandler{DB: db, Ctx: &ctx, Gateway: sim}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	billing := entity.Billing{ID: 1, NumberDisplay: "BIL-001", DueDate: time.Now().Add(time.Hour)}
+	require.ErrorIs(t, paymentHandler.CreatePayment(billingHandler, billing, amount, entity.MethodVA), ErrPaymentPending)
+
+	charges, err := paymentHandler.getCharges("WHERE pc.status = 'pending'")
+	require.NoError(t, err)
+	require.Len(t, charges, 1)
+	return charges[0]
+}
+
+// TestHandleEvent menguji event approved yang mencatat payment, lalu pengiriman ulang event yang sama.
+func TestHandleEvent(t *testing.T) {
+	db := SetupTestWebhookDB(t)
+	defer db.Close()
+
+	charge := pendingCharge(t, db, entity.NewMoney(100000))
+
+	// Webhook berjalan tanpa user login
+	ctx := context.Background()
+	handler := &WebhookHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	event := gateway.Event{ID: "evt_1", Type: "charge.approved", ChargeID: charge.ChargeRef, Status: entity.ChargeApproved, Amount: charge.Amount}
+	record, err := handler.HandleEvent(billingHandler, event, []byte(`{"id":"evt_1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, entity.WebhookProcessed, record.Status)
+
+	var status string
+	var paid entity.Money
+	require.NoError(t, db.QueryRow("SELECT status FROM billings WHERE id = 1").Scan(&status))
+	require.NoError(t, db.QueryRow("SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = 1").Scan(&paid))
+	assert.Equal(t, string(entity.StatusPaid), status)
+	assert.Equal(t, entity.NewMoney(100000), paid)
+
+	// Event yang sama dikirim ulang tidak mencatat payment kedua
+	_, err = handler.HandleEvent(billingHandler, event, []byte(`{"id":"evt_1"}`))
+	assert.ErrorIs(t, err, ErrWebhookDuplicate)
+
+	// Event lain untuk charge yang sudah approved diabaikan
+	event.ID = "evt_2"
+	_, err = handler.HandleEvent(billingHandler, event, []byte(`{"id":"evt_2"}`))
+	require.NoError(t, err)
+
+	var payments int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM payments").Scan(&payments))
+	assert.Equal(t, 1, payments)
+}
+
+// TestHandleEvent_Failed menguji event yang gagal diproses lalu berhasil saat dikirim ulang.
+func TestHandleEvent_Failed(t *testing.T) {
+	db := SetupTestWebhookDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	handler := &WebhookHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	// Charge belum tercatat di sistem
+	event := gateway.Event{ID: "evt_9", Type: "charge.declined", ChargeID: "SIM-D-1-1", Status: entity.ChargeDeclined, Amount: entity.NewMoney(40000), Message: "Saldo tidak cukup"}
+	record, err := handler.HandleEvent(billingHandler, event, []byte(`{}`))
+	assert.ErrorIs(t, err, ErrChargeNotFound)
+	assert.Equal(t, entity.WebhookFailed, record.Status)
+
+	// Nominal event tidak sama dengan charge
+	charge := pendingCharge(t, db, entity.NewMoney(40000))
+	event.ChargeID, event.Amount = charge.ChargeRef, entity.NewMoney(1000)
+	_, err = handler.HandleEvent(billingHandler, event, []byte(`{}`))
+	assert.Error(t, err)
+
+	// Pengiriman ulang yang benar diproses dan jumlah percobaan tercatat
+	event.Amount = charge.Amount
+	record, err = handler.HandleEvent(billingHandler, event, []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, 3, record.Attempts)
+
+	var chargeStatus, message string
+	require.NoError(t, db.QueryRow("SELECT status, message FROM payment_charges WHERE id = ?", charge.ID).Scan(&chargeStatus, &message))
+	assert.Equal(t, string(entity.ChargeDeclined), chargeStatus)
+	assert.Equal(t, "Saldo tidak cukup", message)
+
+	// Event tanpa status yang dikenal ditolak sebelum disimpan
+	event.ID, event.Status = "evt_10", "unknown"
+	_, err = handler.HandleEvent(billingHandler, event, []byte(`{}`))
+	assert.Error(t, err)
+}
+
+// TestHandleEvent_WithFee menguji event untuk charge berbiaya yang membawa nominal billing ditambah biaya metode.
+func TestHandleEvent_WithFee(t *testing.T) {
+	db := SetupTestWebhookDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("UPDATE payment_methods SET fee_flat = 4000 WHERE code = 'va'")
+	require.NoError(t, err)
+	charge := pendingCharge(t, db, entity.NewMoney(100000))
+	assert.Equal(t, entity.NewMoney(4000), charge.Fee)
+
+	ctx := context.Background()
+	handler := &WebhookHandler{DB: db, Ctx: &ctx}
+	billingHandler := &BillingHandler{DB: db, Ctx: &ctx}
+
+	// Nominal tanpa biaya tidak sama dengan yang ditagih gateway
+	event := gateway.Event{ID: "evt_fee", Type: "charge.approved", ChargeID: charge.ChargeRef, Status: entity.ChargeApproved, Amount: charge.Amount}
+	_, err = handler.HandleEvent(billingHandler, event, []byte(`{}`))
+	assert.Error(t, err)
+
+	event.Amount = entity.NewMoney(104000)
+	_, err = handler.HandleEvent(billingHandler, event, []byte(`{}`))
+	require.NoError(t, err)
+
+	// Billing hanya menerima nominal pembayarannya
+	var paid entity.Money
+	require.NoError(t, db.QueryRow("SELECT SUM(amount) FROM payments WHERE billing_id = 1").Scan(&paid))
+	assert.Equal(t, entity.NewMoney(100000), paid)
+}
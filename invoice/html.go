@@ -0,0 +1,131 @@
+package invoice
+
+import (
+	"encoding/base64"
+	"html/template"
+	"io"
+	"pairproject/entity"
+	"pairproject/qris"
+)
+
+// htmlTemplate adalah tata letak invoice HTML; nilai otomatis di-escape oleh html/template
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"rupiah":     func(m entity.Money) string { return m.Rupiah() },
+	"neg":        func(m entity.Money) entity.Money { return -m },
+	"label":      lineLabel,
+	"adjustment": adjustmentLabel,
+	"payment":    paymentLabel,
+}).Parse(`<!DOCTYPE html>
+<html lang="id">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.Inv.Billing.NumberDisplay}}</title>
+<style>
+	body { font-family: Arial, sans-serif; font-size: 14px; color: #222; max-width: 760px; margin: 24px auto; }
+	header { border-bottom: 2px solid #222; margin-bottom: 16px; }
+	h1 { margin: 0; font-size: 22px; }
+	h2 { letter-spacing: 2px; }
+	table { width: 100%; border-collapse: collapse; margin-bottom: 16px; }
+	th, td { padding: 6px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+	.num { text-align: right; }
+	.summary td { border: none; }
+	.total td { font-weight: bold; border-top: 2px solid #222; }
+</style>
+</head>
+<body>
+<header>
+	<h1>{{.Store.Name}}</h1>
+	{{with .Store.Address}}<div>{{.}}</div>{{end}}
+	{{with .Store.Phone}}<div>{{.}}</div>{{end}}
+	{{with .Store.Email}}<div>{{.}}</div>{{end}}
+</header>
+
+<h2>{{.Title}}</h2>
+<table class="summary">
+	<tr><td>No. Billing</td><td>{{.Inv.Billing.NumberDisplay}}</td><td>Customer</td><td>{{.Inv.Customer.Name}}</td></tr>
+	<tr><td>No. Order</td><td>{{.Inv.OrderNumber}}</td><td>Kirim ke</td><td>{{.Inv.ShippingAddress}}</td></tr>
+	<tr><td>Tanggal</td><td>{{.Inv.Billing.IssueDate.Format "2006-01-02 15:04"}}</td><td>Jatuh Tempo</td><td>{{.Due}}</td></tr>
+	<tr><td>Status</td><td>{{.Inv.Billing.Status}}</td><td></td><td></td></tr>
+</table>
+
+<table>
+	<tr><th>Produk</th><th class="num">Qty</th><th class="num">Harga</th><th class="num">Jumlah</th></tr>
+	{{range .Inv.Lines}}
+	<tr><td>{{label .}}</td><td class="num">{{.Qty}}</td><td class="num">{{rupiah .Price}}</td><td class="num">{{rupiah .Total}}</td></tr>
+	{{end}}
+</table>
+
+<table class="summary">
+	<tr><td class="num">Subtotal</td><td class="num">{{rupiah .Inv.Subtotal}}</td></tr>
+	{{range .Taxes}}
+	<tr><td class="num">{{.Label}}</td><td class="num">{{rupiah .Amount}}</td></tr>
+	{{end}}
+	<tr><td class="num">Ongkos Kirim</td><td class="num">{{rupiah .Inv.Billing.ShippingFee}}</td></tr>
+	<tr class="total"><td class="num">Total</td><td class="num">{{rupiah .Inv.Billing.Total}}</td></tr>
+	{{if .Inv.Billing.Adjustments}}
+	{{range .Inv.Billing.Adjustments}}
+	<tr><td class="num">{{adjustment .}}</td><td class="num">{{rupiah .Signed}}</td></tr>
+	{{end}}
+	<tr class="total"><td class="num">Total Setelah Penyesuaian</td><td class="num">{{rupiah .Inv.Billing.AdjustedTotal}}</td></tr>
+	{{end}}
+</table>
+
+{{if or .Inv.Billing.Payments .Inv.Billing.Refunds}}
+<table>
+	<tr><th>Tanggal</th><th>Metode</th><th class="num">Jumlah</th></tr>
+	{{range .Inv.Billing.Payments}}
+	<tr><td>{{.Date.Format "2006-01-02 15:04"}}</td><td>{{payment .}}</td><td class="num">{{rupiah .Amount}}</td></tr>
+	{{end}}
+	{{range .Inv.Billing.Refunds}}
+	<tr><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td><td>refund</td><td class="num">{{rupiah (neg .Amount)}}</td></tr>
+	{{end}}
+</table>
+{{end}}
+
+<table class="summary">
+	<tr><td class="num">Total Dibayar</td><td class="num">{{rupiah .NetPaid}}</td></tr>
+	<tr class="total"><td class="num">Sisa Tagihan</td><td class="num">{{rupiah .Inv.BalanceDue}}</td></tr>
+</table>
+
+{{if .VirtualAccounts}}
+<table>
+	<tr><th>Bayar melalui Virtual Account</th><th>Nomor VA</th></tr>
+	{{range .VirtualAccounts}}
+	<tr><td>{{.Bank}}</td><td>{{.Number}}</td></tr>
+	{{end}}
+</table>
+{{end}}
+
+{{if .QRIS}}
+<div>
+	<h3>Bayar dengan QRIS</h3>
+	<img src="{{.QRIS}}" alt="QRIS {{.Inv.Billing.NumberDisplay}}" width="200" height="200">
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML menulis invoice sebagai dokumen HTML yang siap dibuka atau dicetak dari browser.
+// QR pembayaran disisipkan sebagai gambar PNG data URI sehingga file tetap mandiri.
+func RenderHTML(w io.Writer, store Store, inv entity.Invoice) error {
+	var qrImage template.URL
+	if payload := qrisPayload(store, inv); payload != "" {
+		png, err := qris.PNG(payload, qris.DefaultPNGSize)
+		if err != nil {
+			return err
+		}
+		qrImage = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Store           Store
+		Inv             entity.Invoice
+		Title           string
+		Due             string
+		Taxes           []taxRow
+		NetPaid         entity.Money
+		VirtualAccounts []vaRow
+		QRIS            template.URL
+	}{store, inv, Title(inv), dueLabel(inv), taxSummary(inv), inv.Paid - inv.Refunded, vaRows(inv), qrImage})
+}
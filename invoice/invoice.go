@@ -0,0 +1,210 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"pairproject/entity"
+	"pairproject/qris"
+	"path/filepath"
+	"strings"
+)
+
+// Format adalah jenis dokumen hasil render
+type Format string
+
+const (
+	FormatText Format = "txt"
+	FormatHTML Format = "html"
+	FormatPDF  Format = "pdf"
+)
+
+// Store berisi identitas toko yang dicetak di kepala dokumen
+type Store struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	QRIS    qris.Merchant // identitas merchant QRIS; kosong berarti invoice tanpa QR pembayaran
+}
+
+// DefaultStore membaca identitas toko dari environment (STORE_NAME, STORE_ADDRESS, STORE_PHONE, STORE_EMAIL)
+// dan merchant QRIS dari qris.DefaultMerchant
+func DefaultStore() Store {
+	store := Store{
+		Name:    os.Getenv("STORE_NAME"),
+		Address: os.Getenv("STORE_ADDRESS"),
+		Phone:   os.Getenv("STORE_PHONE"),
+		Email:   os.Getenv("STORE_EMAIL"),
+		QRIS:    qris.DefaultMerchant(),
+	}
+	if store.Name == "" {
+		store.Name = "Pair Project Sport Store"
+	}
+
+	return store
+}
+
+// Title mengembalikan judul dokumen: kuitansi jika billing sudah lunas, selain itu invoice
+func Title(inv entity.Invoice) string {
+	if inv.IsReceipt() {
+		return "KUITANSI"
+	}
+	return "INVOICE"
+}
+
+// FormatFromPath menentukan format dokumen berdasarkan ekstensi file
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt":
+		return FormatText, nil
+	case ".html", ".htm":
+		return FormatHTML, nil
+	case ".pdf":
+		return FormatPDF, nil
+	}
+
+	return "", fmt.Errorf("Format file tidak didukung, gunakan .txt, .html, atau .pdf")
+}
+
+// Render menulis dokumen invoice ke w dalam format yang diminta
+func Render(w io.Writer, format Format, store Store, inv entity.Invoice) error {
+	switch format {
+	case FormatText:
+		return RenderText(w, store, inv)
+	case FormatHTML:
+		return RenderHTML(w, store, inv)
+	case FormatPDF:
+		return RenderPDF(w, store, inv)
+	}
+
+	return fmt.Errorf("Format %q tidak didukung", format)
+}
+
+// Export menyimpan dokumen invoice ke path; format mengikuti ekstensi file
+func Export(path string, store Store, inv entity.Invoice) error {
+	return exportFile(path, func(w io.Writer, format Format) error {
+		return Render(w, format, store, inv)
+	})
+}
+
+// exportFile membuat file di path lalu merender dokumen dengan format sesuai ekstensinya
+func exportFile(path string, render func(w io.Writer, format Format) error) error {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Gagal membuat file: %w", err)
+	}
+
+	// File setengah jadi dihapus agar tidak tertinggal dokumen rusak
+	if err := render(file, format); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return file.Close()
+}
+
+// lineLabel menampilkan nama produk, ditandai jika harganya sudah termasuk pajak
+func lineLabel(line entity.OrderDetail) string {
+	if line.PriceIncludesTax {
+		return line.ProductName + " (termasuk pajak)"
+	}
+	return line.ProductName
+}
+
+// adjustmentLabel menampilkan nomor dan alasan credit note / debit note
+func adjustmentLabel(adjustment entity.BillingAdjustment) string {
+	kind := "Credit Note"
+	if adjustment.Type == entity.AdjustmentDebit {
+		kind = "Debit Note"
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, adjustment.NumberDisplay, truncate(adjustment.Reason, 24))
+}
+
+// paymentLabel menampilkan metode pembayaran, ditambah kartu tersamar untuk pembayaran kartu kredit
+func paymentLabel(payment entity.Payment) string {
+	if payment.Card != "" {
+		return fmt.Sprintf("%s %s", payment.Method, payment.Card)
+	}
+	return string(payment.Method)
+}
+
+// vaRow adalah satu nomor virtual account yang bisa dipakai membayar sisa tagihan
+type vaRow struct {
+	Bank   string
+	Number string
+}
+
+// vaRows mengembalikan nomor virtual account billing, kosong jika tidak ada sisa tagihan
+func vaRows(inv entity.Invoice) []vaRow {
+	if inv.BalanceDue <= 0 {
+		return nil
+	}
+
+	var rows []vaRow
+	for _, va := range inv.Billing.VirtualAccounts {
+		name := va.Bank
+		if bank, ok := entity.FindVABank(va.Bank); ok {
+			name = bank.Name
+		}
+		rows = append(rows, vaRow{Bank: name, Number: va.Number})
+	}
+	return rows
+}
+
+// qrisPayload membuat payload QRIS untuk membayar sisa tagihan; kosong jika tidak ada sisa tagihan
+// atau merchant QRIS toko belum diatur
+func qrisPayload(store Store, inv entity.Invoice) string {
+	if inv.BalanceDue <= 0 || store.QRIS.Validate() != nil {
+		return ""
+	}
+
+	payload, err := qris.Generate(store.QRIS, inv.Billing.NumberDisplay, inv.BalanceDue)
+	if err != nil {
+		return ""
+	}
+	return payload
+}
+
+// dueLabel menampilkan jatuh tempo, kosong untuk data lama tanpa due_date
+func dueLabel(inv entity.Invoice) string {
+	if inv.Billing.DueDate.IsZero() {
+		return "-"
+	}
+	return inv.Billing.DueDate.Format("2006-01-02 15:04")
+}
+
+// taxRow adalah ringkasan pajak per kelas dan tarif
+type taxRow struct {
+	Label  string
+	Amount entity.Money
+}
+
+// taxSummary menjumlahkan rincian pajak billing per kelas pajak dan tarif, urut sesuai kemunculan.
+// Billing lama tanpa rincian pajak ditampilkan sebagai satu baris pajak.
+func taxSummary(inv entity.Invoice) []taxRow {
+	if len(inv.Billing.TaxLines) == 0 {
+		return []taxRow{{Label: "Pajak", Amount: inv.Billing.Tax}}
+	}
+
+	var rows []taxRow
+	index := map[string]int{}
+	for _, line := range inv.Billing.TaxLines {
+		label := fmt.Sprintf("Pajak %s %s%%", line.TaxClass, percent(line.Rate))
+		i, ok := index[label]
+		if !ok {
+			i = len(rows)
+			index[label] = i
+			rows = append(rows, taxRow{Label: label})
+		}
+		rows[i].Amount += line.TaxAmount
+	}
+
+	return rows
+}
@@ -0,0 +1,187 @@
+package invoice
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/qris"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleInvoice membuat invoice lesspaid dengan dua baris produk, pajak per baris, satu pembayaran, dan satu refund
+func sampleInvoice() entity.Invoice {
+	issued := time.Date(2025, 6, 5, 10, 0, 0, 0, time.Local)
+
+	return entity.Invoice{
+		Billing: entity.Billing{
+			NumberDisplay: "BIL-202506-001",
+			IssueDate:     issued,
+			DueDate:       issued.Add(24 * time.Hour),
+			Tax:           entity.NewMoney(30000),
+			ShippingFee:   entity.NewMoney(15000),
+			Total:         entity.NewMoney(345000),
+			Status:        entity.StatusLesspaid,
+			TaxLines: []entity.BillingTaxLine{
+				{TaxClass: "standard", Rate: 1000, TaxAmount: entity.NewMoney(20000)},
+				{TaxClass: "standard", Rate: 1000, PriceIncludesTax: true, TaxAmount: entity.NewMoney(10000)},
+			},
+			Payments: []entity.Payment{{Date: issued, Amount: entity.NewMoney(200000), Method: entity.MethodVA}},
+			Refunds:  []entity.Refund{{CreatedAt: issued, Amount: entity.NewMoney(5000)}},
+		},
+		OrderNumber:     "ORD-202506-001",
+		ShippingAddress: "Jl. Merdeka 1, Jakarta",
+		Customer:        entity.Customer{Name: "Budi <Santoso>"},
+		Lines: []entity.OrderDetail{
+			{ProductName: "Adjustable Dumbbell 20kg", Qty: 2, Price: entity.NewMoney(100000), Total: entity.NewMoney(200000)},
+			{ProductName: "Yoga Mat Premium", Qty: 1, Price: entity.NewMoney(110000), Total: entity.NewMoney(110000), PriceIncludesTax: true},
+		},
+		Subtotal:   entity.NewMoney(300000),
+		Paid:       entity.NewMoney(200000),
+		Refunded:   entity.NewMoney(5000),
+		BalanceDue: entity.NewMoney(150000),
+	}
+}
+
+// TestRenderText memastikan dokumen teks memuat kepala toko, baris produk, pajak, pembayaran, dan sisa tagihan.
+func TestRenderText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, RenderText(&buf, Store{Name: "Toko Uji", Phone: "021-123"}, sampleInvoice()))
+
+	out := buf.String()
+	assert.Contains(t, out, "Toko Uji")
+	assert.Contains(t, out, "021-123")
+	assert.Contains(t, out, "INVOICE")
+	assert.Contains(t, out, "BIL-202506-001")
+	assert.Contains(t, out, "Yoga Mat Premium (termasuk pajak)")
+	assert.Contains(t, out, "Pajak standard 10%")
+	assert.Contains(t, out, "Rp 30.000,00", "pajak digabung per kelas dan tarif")
+	assert.Contains(t, out, "-Rp 5.000,00")
+	assert.Contains(t, out, "Rp 195.000,00", "total dibayar bersih setelah refund")
+	assert.Contains(t, out, "Rp 150.000,00")
+	assert.NotContains(t, out, "Total Setelah Penyesuaian")
+	assert.NotContains(t, out, "Virtual Account")
+}
+
+// TestRenderText_VirtualAccount memastikan nomor VA hanya dicetak selama masih ada sisa tagihan.
+func TestRenderText_VirtualAccount(t *testing.T) {
+	inv := sampleInvoice()
+	inv.Billing.VirtualAccounts = []entity.VirtualAccount{{Bank: "bca", Number: "3935800001000011"}}
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderText(&buf, Store{Name: "Toko Uji"}, inv))
+	assert.Contains(t, buf.String(), "Bayar melalui Virtual Account")
+	assert.Contains(t, buf.String(), "BCA        3935800001000011")
+
+	inv.BalanceDue = 0
+	buf.Reset()
+	require.NoError(t, RenderHTML(&buf, Store{Name: "Toko Uji"}, inv))
+	assert.NotContains(t, buf.String(), "3935800001000011")
+}
+
+// TestRender_QRIS memastikan QR pembayaran dicetak di semua format selama masih ada sisa tagihan.
+func TestRender_QRIS(t *testing.T) {
+	inv := sampleInvoice()
+	store := Store{Name: "Toko Uji", QRIS: qris.DefaultMerchant()}
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderText(&buf, store, inv))
+	assert.Contains(t, buf.String(), "Bayar dengan QRIS")
+	assert.Contains(t, buf.String(), "█")
+
+	buf.Reset()
+	require.NoError(t, RenderHTML(&buf, store, inv))
+	assert.Contains(t, buf.String(), `src="data:image/png;base64,`)
+
+	buf.Reset()
+	require.NoError(t, RenderPDF(&buf, store, inv))
+	withQR := buf.Len()
+	buf.Reset()
+	require.NoError(t, RenderPDF(&buf, Store{Name: "Toko Uji"}, inv))
+	assert.Greater(t, withQR, buf.Len(), "PDF dengan QR memuat gambar tambahan")
+
+	// Kuitansi lunas tidak memuat QR
+	inv.BalanceDue = 0
+	buf.Reset()
+	require.NoError(t, RenderText(&buf, store, inv))
+	assert.NotContains(t, buf.String(), "QRIS")
+}
+
+// TestRenderText_Adjustments memastikan credit note ditampilkan beserta total setelah penyesuaian.
+func TestRenderText_Adjustments(t *testing.T) {
+	inv := sampleInvoice()
+	inv.Billing.Adjustments = []entity.BillingAdjustment{
+		{NumberDisplay: "CN-202506-001", Type: entity.AdjustmentCredit, Amount: entity.NewMoney(45000), Reason: "Diskon goodwill"},
+	}
+	inv.BalanceDue = entity.NewMoney(105000)
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderText(&buf, Store{Name: "Toko Uji"}, inv))
+
+	out := buf.String()
+	assert.Contains(t, out, "Credit Note CN-202506-001 (Diskon goodwill)")
+	assert.Contains(t, out, "-Rp 45.000,00")
+	assert.Contains(t, out, "Total Setelah Penyesuaian")
+	assert.Contains(t, out, "Rp 300.000,00")
+	assert.Contains(t, out, "Rp 105.000,00")
+}
+
+// TestRenderText_Card memastikan kuitansi pembayaran kartu hanya menampilkan brand dan 4 digit terakhir.
+func TestRenderText_Card(t *testing.T) {
+	inv := sampleInvoice()
+	inv.Billing.Payments[0].Method = entity.MethodCredit
+	inv.Billing.Payments[0].Card = "VISA **** 4242"
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderText(&buf, Store{Name: "Toko Uji"}, inv))
+	assert.Contains(t, buf.String(), "credit_card VISA **** 4242")
+
+	buf.Reset()
+	require.NoError(t, RenderHTML(&buf, Store{Name: "Toko Uji"}, inv))
+	assert.Contains(t, buf.String(), "credit_card VISA **** 4242")
+}
+
+// TestRenderHTML memastikan HTML memuat data invoice dan meng-escape input customer.
+func TestRenderHTML(t *testing.T) {
+	inv := sampleInvoice()
+	inv.BalanceDue = 0
+	inv.Billing.Status = entity.StatusPaid
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderHTML(&buf, Store{Name: "Toko Uji"}, inv))
+
+	out := buf.String()
+	assert.Contains(t, out, "<title>KUITANSI BIL-202506-001</title>")
+	assert.Contains(t, out, "Budi &lt;Santoso&gt;")
+	assert.NotContains(t, out, "Budi <Santoso>")
+	assert.Contains(t, out, "Rp 345.000,00")
+}
+
+// TestExport menguji pemilihan format berdasarkan ekstensi file, termasuk PDF.
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	store := Store{Name: "Toko Uji"}
+
+	path := filepath.Join(dir, "invoice.pdf")
+	require.NoError(t, Export(path, store, sampleInvoice()))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("%PDF-")))
+
+	path = filepath.Join(dir, "invoice.html")
+	require.NoError(t, Export(path, store, sampleInvoice()))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("<!DOCTYPE html>")))
+
+	// Ekstensi lain ditolak tanpa membuat file
+	path = filepath.Join(dir, "invoice.docx")
+	assert.Error(t, Export(path, store, sampleInvoice()))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,147 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"pairproject/entity"
+	"pairproject/qris"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// RenderPDF menulis invoice sebagai dokumen PDF ukuran A4
+func RenderPDF(w io.Writer, store Store, inv entity.Invoice) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	// Font bawaan PDF memakai cp1252, teks UTF-8 diterjemahkan lebih dulu
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Kepala dokumen: identitas toko
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(store.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, info := range []string{store.Address, store.Phone, store.Email} {
+		if info != "" {
+			pdf.CellFormat(0, 5, tr(info), "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Line(15, pdf.GetY()+2, 195, pdf.GetY()+2)
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, Title(inv), "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	// Identitas billing dan customer
+	pdf.SetFont("Helvetica", "", 10)
+	info := [][2]string{
+		{"No. Billing", inv.Billing.NumberDisplay},
+		{"No. Order", inv.OrderNumber},
+		{"Tanggal", inv.Billing.IssueDate.Format("2006-01-02 15:04")},
+		{"Jatuh Tempo", dueLabel(inv)},
+		{"Status", string(inv.Billing.Status)},
+		{"Customer", inv.Customer.Name},
+	}
+	if inv.ShippingAddress != "" {
+		info = append(info, [2]string{"Kirim ke", inv.ShippingAddress})
+	}
+	for _, row := range info {
+		pdf.CellFormat(30, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, ": "+tr(row[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	// Tabel baris produk
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(90, 7, "Produk", "B", 0, "L", false, 0, "")
+	pdf.CellFormat(15, 7, "Qty", "B", 0, "R", false, 0, "")
+	pdf.CellFormat(37.5, 7, "Harga", "B", 0, "R", false, 0, "")
+	pdf.CellFormat(37.5, 7, "Jumlah", "B", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range inv.Lines {
+		pdf.CellFormat(90, 7, tr(lineLabel(line)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(15, 7, fmt.Sprint(line.Qty), "", 0, "R", false, 0, "")
+		pdf.CellFormat(37.5, 7, line.Price.Rupiah(), "", 0, "R", false, 0, "")
+		pdf.CellFormat(37.5, 7, line.Total.Rupiah(), "", 1, "R", false, 0, "")
+	}
+	pdf.Line(15, pdf.GetY(), 195, pdf.GetY())
+	pdf.Ln(2)
+
+	// Ringkasan nominal
+	amountRow := func(label string, m entity.Money, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(142.5, 6, label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(37.5, 6, m.Rupiah(), "", 1, "R", false, 0, "")
+	}
+	amountRow("Subtotal", inv.Subtotal, false)
+	for _, row := range taxSummary(inv) {
+		amountRow(row.Label, row.Amount, false)
+	}
+	amountRow("Ongkos Kirim", inv.Billing.ShippingFee, false)
+	amountRow("Total", inv.Billing.Total, true)
+	if len(inv.Billing.Adjustments) > 0 {
+		for _, adjustment := range inv.Billing.Adjustments {
+			amountRow(tr(adjustmentLabel(adjustment)), adjustment.Signed(), false)
+		}
+		amountRow("Total Setelah Penyesuaian", inv.Billing.AdjustedTotal(), true)
+	}
+	pdf.Ln(4)
+
+	// Pembayaran yang sudah diterima
+	if len(inv.Billing.Payments) > 0 || len(inv.Billing.Refunds) > 0 {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 7, "Pembayaran diterima", "B", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, payment := range inv.Billing.Payments {
+			pdf.CellFormat(45, 6, payment.Date.Format("2006-01-02 15:04"), "", 0, "L", false, 0, "")
+			pdf.CellFormat(97.5, 6, paymentLabel(payment), "", 0, "L", false, 0, "")
+			pdf.CellFormat(37.5, 6, payment.Amount.Rupiah(), "", 1, "R", false, 0, "")
+		}
+		for _, refund := range inv.Billing.Refunds {
+			pdf.CellFormat(45, 6, refund.CreatedAt.Format("2006-01-02 15:04"), "", 0, "L", false, 0, "")
+			pdf.CellFormat(97.5, 6, "refund", "", 0, "L", false, 0, "")
+			pdf.CellFormat(37.5, 6, (-refund.Amount).Rupiah(), "", 1, "R", false, 0, "")
+		}
+		pdf.Ln(2)
+	}
+
+	amountRow("Total Dibayar", inv.Paid-inv.Refunded, false)
+	amountRow("Sisa Tagihan", inv.BalanceDue, true)
+
+	// Nomor virtual account untuk membayar sisa tagihan
+	if vas := vaRows(inv); len(vas) > 0 {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 7, "Bayar melalui Virtual Account", "B", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, va := range vas {
+			pdf.CellFormat(45, 6, tr(va.Bank), "", 0, "L", false, 0, "")
+			pdf.CellFormat(0, 6, va.Number, "", 1, "L", false, 0, "")
+		}
+	}
+
+	// QR pembayaran sisa tagihan sebagai gambar PNG
+	if payload := qrisPayload(store, inv); payload != "" {
+		png, err := qris.PNG(payload, qris.DefaultPNGSize)
+		if err != nil {
+			return err
+		}
+		options := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader("qris", options, bytes.NewReader(png))
+
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 7, "Bayar dengan QRIS", "B", 1, "L", false, 0, "")
+		pdf.Ln(2)
+		pdf.ImageOptions("qris", pdf.GetX(), pdf.GetY(), 45, 45, true, options, 0, "")
+	}
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,206 @@
+package invoice
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"pairproject/entity"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ReceiptTitle adalah judul dokumen kuitansi satu pembayaran
+const ReceiptTitle = "KUITANSI PEMBAYARAN"
+
+// receiptRow adalah satu baris identitas kuitansi
+type receiptRow struct {
+	Label string
+	Value string
+}
+
+// receiptInfo mengembalikan identitas pembayaran yang dicetak di kuitansi
+func receiptInfo(receipt entity.PaymentReceipt) []receiptRow {
+	return []receiptRow{
+		{"No. Kuitansi", receipt.Number},
+		{"Tanggal Bayar", receipt.Payment.Date.Format("2006-01-02 15:04")},
+		{"No. Billing", receipt.BillingNumber},
+		{"No. Order", receipt.OrderNumber},
+		{"Customer", receipt.Customer.Name},
+		{"Metode", paymentLabel(receipt.Payment)},
+	}
+}
+
+// receiptAmount adalah satu baris ringkasan nominal kuitansi
+type receiptAmount struct {
+	Label  string
+	Amount entity.Money
+}
+
+// receiptAmounts mengembalikan posisi tagihan tepat setelah pembayaran
+func receiptAmounts(receipt entity.PaymentReceipt) []receiptAmount {
+	return []receiptAmount{
+		{Label: "Total Tagihan", Amount: receipt.BillingTotal},
+		{Label: "Total Dibayar s.d. Pembayaran Ini", Amount: receipt.PaidToDate},
+		{Label: "Sisa Tagihan", Amount: receipt.BalanceAfter},
+	}
+}
+
+// RenderReceipt menulis kuitansi satu pembayaran ke w dalam format yang diminta
+func RenderReceipt(w io.Writer, format Format, store Store, receipt entity.PaymentReceipt) error {
+	switch format {
+	case FormatText:
+		return RenderReceiptText(w, store, receipt)
+	case FormatHTML:
+		return RenderReceiptHTML(w, store, receipt)
+	case FormatPDF:
+		return RenderReceiptPDF(w, store, receipt)
+	}
+
+	return fmt.Errorf("Format %q tidak didukung", format)
+}
+
+// ExportReceipt menyimpan kuitansi satu pembayaran ke path; format mengikuti ekstensi file
+func ExportReceipt(path string, store Store, receipt entity.PaymentReceipt) error {
+	return exportFile(path, func(w io.Writer, format Format) error {
+		return RenderReceipt(w, format, store, receipt)
+	})
+}
+
+// RenderReceiptText menulis kuitansi sebagai teks polos dengan lebar yang sama seperti invoice teks
+func RenderReceiptText(w io.Writer, store Store, receipt entity.PaymentReceipt) error {
+	bw := bufio.NewWriter(w)
+	rule := strings.Repeat("=", textWidth)
+	thin := strings.Repeat("-", textWidth)
+
+	fmt.Fprintln(bw, rule)
+	fmt.Fprintln(bw, center(store.Name))
+	for _, info := range []string{store.Address, store.Phone, store.Email} {
+		if info != "" {
+			fmt.Fprintln(bw, center(info))
+		}
+	}
+	fmt.Fprintln(bw, rule)
+	fmt.Fprintln(bw, center(ReceiptTitle))
+	fmt.Fprintln(bw)
+
+	for _, row := range receiptInfo(receipt) {
+		fmt.Fprintf(bw, "%-14s: %s\n", row.Label, row.Value)
+	}
+	fmt.Fprintln(bw, thin)
+	fmt.Fprintf(bw, "%*s %15s\n", textWidth-16, "Jumlah Dibayar", receipt.Payment.Amount.Rupiah())
+	fmt.Fprintln(bw, thin)
+	for _, row := range receiptAmounts(receipt) {
+		fmt.Fprintf(bw, "%*s %15s\n", textWidth-16, row.Label, row.Amount.Rupiah())
+	}
+	fmt.Fprintln(bw, rule)
+
+	return bw.Flush()
+}
+
+// receiptTemplate adalah tata letak kuitansi HTML, memakai gaya yang sama dengan invoice HTML
+var receiptTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"rupiah": func(m entity.Money) string { return m.Rupiah() },
+}).Parse(`<!DOCTYPE html>
+<html lang="id">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.Receipt.Number}}</title>
+<style>
+	body { font-family: Arial, sans-serif; font-size: 14px; color: #222; max-width: 760px; margin: 24px auto; }
+	header { border-bottom: 2px solid #222; margin-bottom: 16px; }
+	h1 { margin: 0; font-size: 22px; }
+	h2 { letter-spacing: 2px; }
+	table { width: 100%; border-collapse: collapse; margin-bottom: 16px; }
+	th, td { padding: 6px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+	.num { text-align: right; }
+	.summary td { border: none; }
+	.total td { font-weight: bold; border-top: 2px solid #222; }
+</style>
+</head>
+<body>
+<header>
+	<h1>{{.Store.Name}}</h1>
+	{{with .Store.Address}}<div>{{.}}</div>{{end}}
+	{{with .Store.Phone}}<div>{{.}}</div>{{end}}
+	{{with .Store.Email}}<div>{{.}}</div>{{end}}
+</header>
+
+<h2>{{.Title}}</h2>
+<table class="summary">
+	{{range .Info}}
+	<tr><td>{{.Label}}</td><td>{{.Value}}</td></tr>
+	{{end}}
+</table>
+
+<table class="summary">
+	<tr class="total"><td class="num">Jumlah Dibayar</td><td class="num">{{rupiah .Receipt.Payment.Amount}}</td></tr>
+	{{range .Amounts}}
+	<tr><td class="num">{{.Label}}</td><td class="num">{{rupiah .Amount}}</td></tr>
+	{{end}}
+</table>
+</body>
+</html>
+`))
+
+// RenderReceiptHTML menulis kuitansi sebagai dokumen HTML yang siap dibuka atau dicetak dari browser
+func RenderReceiptHTML(w io.Writer, store Store, receipt entity.PaymentReceipt) error {
+	return receiptTemplate.Execute(w, struct {
+		Store   Store
+		Receipt entity.PaymentReceipt
+		Title   string
+		Info    []receiptRow
+		Amounts []receiptAmount
+	}{store, receipt, ReceiptTitle, receiptInfo(receipt), receiptAmounts(receipt)})
+}
+
+// RenderReceiptPDF menulis kuitansi sebagai dokumen PDF ukuran A5
+func RenderReceiptPDF(w io.Writer, store Store, receipt entity.PaymentReceipt) error {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetMargins(12, 12, 12)
+	pdf.AddPage()
+
+	// Font bawaan PDF memakai cp1252, teks UTF-8 diterjemahkan lebih dulu
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 7, tr(store.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, info := range []string{store.Address, store.Phone, store.Email} {
+		if info != "" {
+			pdf.CellFormat(0, 5, tr(info), "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Line(12, pdf.GetY()+2, 136, pdf.GetY()+2)
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, ReceiptTitle, "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, row := range receiptInfo(receipt) {
+		pdf.CellFormat(30, 6, row.Label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, ": "+tr(row.Value), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	amountRow := func(label string, m entity.Money, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(89, 6, label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 6, m.Rupiah(), "", 1, "R", false, 0, "")
+	}
+	amountRow("Jumlah Dibayar", receipt.Payment.Amount, true)
+	pdf.Line(12, pdf.GetY()+1, 136, pdf.GetY()+1)
+	pdf.Ln(2)
+	for _, row := range receiptAmounts(receipt) {
+		amountRow(row.Label, row.Amount, false)
+	}
+
+	return pdf.Output(w)
+}
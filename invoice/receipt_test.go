@@ -0,0 +1,80 @@
+package invoice
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleReceipt membuat kuitansi pembayaran kartu kedua atas billing yang belum lunas
+func sampleReceipt() entity.PaymentReceipt {
+	return entity.PaymentReceipt{
+		Number: "RCP-202506-001-0007",
+		Payment: entity.Payment{
+			ID:     7,
+			Date:   time.Date(2025, 6, 6, 9, 30, 0, 0, time.Local),
+			Amount: entity.NewMoney(100000),
+			Method: entity.MethodCredit,
+			Card:   "VISA **** 4242",
+		},
+		BillingNumber: "BIL-202506-001",
+		OrderNumber:   "ORD-202506-001",
+		Customer:      entity.Customer{Name: "Budi <Santoso>"},
+		BillingTotal:  entity.NewMoney(345000),
+		PaidToDate:    entity.NewMoney(300000),
+		BalanceAfter:  entity.NewMoney(45000),
+	}
+}
+
+// TestRenderReceiptText memastikan kuitansi memuat identitas pembayaran dan posisi tagihan setelahnya.
+func TestRenderReceiptText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, RenderReceiptText(&buf, Store{Name: "Toko Uji"}, sampleReceipt()))
+
+	out := buf.String()
+	assert.Contains(t, out, ReceiptTitle)
+	assert.Contains(t, out, "RCP-202506-001-0007")
+	assert.Contains(t, out, "2025-06-06 09:30")
+	assert.Contains(t, out, "credit_card VISA **** 4242")
+	assert.Contains(t, out, "Rp 100.000,00")
+	assert.Contains(t, out, "Rp 300.000,00")
+	assert.Contains(t, out, "Rp 45.000,00")
+}
+
+// TestRenderReceiptHTML memastikan nilai dari database di-escape.
+func TestRenderReceiptHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, RenderReceiptHTML(&buf, Store{Name: "Toko Uji"}, sampleReceipt()))
+
+	out := buf.String()
+	assert.Contains(t, out, "Budi &lt;Santoso&gt;")
+	assert.Contains(t, out, "RCP-202506-001-0007")
+	assert.Contains(t, out, "Sisa Tagihan")
+}
+
+// TestExportReceipt menguji kuitansi tersimpan sesuai ekstensi file.
+func TestExportReceipt(t *testing.T) {
+	dir := t.TempDir()
+	store := Store{Name: "Toko Uji"}
+
+	path := filepath.Join(dir, "kuitansi.pdf")
+	require.NoError(t, ExportReceipt(path, store, sampleReceipt()))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("%PDF-")))
+
+	path = filepath.Join(dir, "kuitansi.txt")
+	require.NoError(t, ExportReceipt(path, store, sampleReceipt()))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ReceiptTitle)
+
+	assert.Error(t, ExportReceipt(filepath.Join(dir, "kuitansi.doc"), store, sampleReceipt()))
+}
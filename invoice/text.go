@@ -0,0 +1,131 @@
+package invoice
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"pairproject/entity"
+	"pairproject/qris"
+	"strings"
+)
+
+// textWidth adalah lebar baris dokumen teks
+const textWidth = 72
+
+// RenderText menulis invoice sebagai teks polos dengan lebar tetap, cocok untuk terminal atau printer struk
+func RenderText(w io.Writer, store Store, inv entity.Invoice) error {
+	bw := bufio.NewWriter(w)
+	rule := strings.Repeat("=", textWidth)
+	thin := strings.Repeat("-", textWidth)
+
+	// Kepala dokumen: identitas toko
+	fmt.Fprintln(bw, rule)
+	fmt.Fprintln(bw, center(store.Name))
+	for _, info := range []string{store.Address, store.Phone, store.Email} {
+		if info != "" {
+			fmt.Fprintln(bw, center(info))
+		}
+	}
+	fmt.Fprintln(bw, rule)
+	fmt.Fprintln(bw, center(Title(inv)))
+	fmt.Fprintln(bw)
+
+	// Identitas billing dan customer
+	fmt.Fprintf(bw, "%-14s: %s\n", "No. Billing", inv.Billing.NumberDisplay)
+	fmt.Fprintf(bw, "%-14s: %s\n", "No. Order", inv.OrderNumber)
+	fmt.Fprintf(bw, "%-14s: %s\n", "Tanggal", inv.Billing.IssueDate.Format("2006-01-02 15:04"))
+	fmt.Fprintf(bw, "%-14s: %s\n", "Jatuh Tempo", dueLabel(inv))
+	fmt.Fprintf(bw, "%-14s: %s\n", "Status", inv.Billing.Status)
+	fmt.Fprintf(bw, "%-14s: %s\n", "Customer", inv.Customer.Name)
+	if inv.ShippingAddress != "" {
+		fmt.Fprintf(bw, "%-14s: %s\n", "Kirim ke", inv.ShippingAddress)
+	}
+	fmt.Fprintln(bw, thin)
+
+	// Baris produk
+	fmt.Fprintf(bw, "%-34s %5s %15s %15s\n", "Produk", "Qty", "Harga", "Jumlah")
+	fmt.Fprintln(bw, thin)
+	for _, line := range inv.Lines {
+		fmt.Fprintf(bw, "%-34s %5d %15s %15s\n", truncate(lineLabel(line), 34), line.Qty, line.Price.Rupiah(), line.Total.Rupiah())
+	}
+	fmt.Fprintln(bw, thin)
+
+	// Ringkasan nominal
+	amountRow := func(label string, m entity.Money) {
+		fmt.Fprintf(bw, "%*s %15s\n", textWidth-16, label, m.Rupiah())
+	}
+	amountRow("Subtotal", inv.Subtotal)
+	for _, row := range taxSummary(inv) {
+		amountRow(row.Label, row.Amount)
+	}
+	amountRow("Ongkos Kirim", inv.Billing.ShippingFee)
+	amountRow("Total", inv.Billing.Total)
+	if len(inv.Billing.Adjustments) > 0 {
+		for _, adjustment := range inv.Billing.Adjustments {
+			amountRow(adjustmentLabel(adjustment), adjustment.Signed())
+		}
+		amountRow("Total Setelah Penyesuaian", inv.Billing.AdjustedTotal())
+	}
+
+	// Pembayaran yang sudah diterima
+	if len(inv.Billing.Payments) > 0 || len(inv.Billing.Refunds) > 0 {
+		fmt.Fprintln(bw, thin)
+		fmt.Fprintln(bw, "Pembayaran diterima:")
+		for _, payment := range inv.Billing.Payments {
+			fmt.Fprintf(bw, "  %-17s %-32s %19s\n", payment.Date.Format("2006-01-02 15:04"), paymentLabel(payment), payment.Amount.Rupiah())
+		}
+		for _, refund := range inv.Billing.Refunds {
+			fmt.Fprintf(bw, "  %-17s %-32s %19s\n", refund.CreatedAt.Format("2006-01-02 15:04"), "refund", (-refund.Amount).Rupiah())
+		}
+	}
+
+	fmt.Fprintln(bw, rule)
+	amountRow("Total Dibayar", inv.Paid-inv.Refunded)
+	amountRow("Sisa Tagihan", inv.BalanceDue)
+	fmt.Fprintln(bw, rule)
+
+	// Nomor virtual account untuk membayar sisa tagihan
+	if vas := vaRows(inv); len(vas) > 0 {
+		fmt.Fprintln(bw, "Bayar melalui Virtual Account:")
+		for _, va := range vas {
+			fmt.Fprintf(bw, "  %-10s %s\n", va.Bank, va.Number)
+		}
+		fmt.Fprintln(bw, rule)
+	}
+
+	// QR pembayaran sisa tagihan, dipindai dari aplikasi bank atau e-wallet
+	if payload := qrisPayload(store, inv); payload != "" {
+		code, err := qris.Terminal(payload)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(bw, "Bayar dengan QRIS, pindai QR berikut:")
+		fmt.Fprint(bw, code)
+		fmt.Fprintln(bw, rule)
+	}
+
+	return bw.Flush()
+}
+
+// center menengahkan teks di dalam lebar dokumen
+func center(s string) string {
+	if len(s) >= textWidth {
+		return s
+	}
+	return strings.Repeat(" ", (textWidth-len(s))/2) + s
+}
+
+// truncate memotong teks yang lebih panjang dari n karakter
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+// percent menampilkan tarif basis poin sebagai persen, contoh 1100 → "11" dan 1250 → "12.5"
+func percent(basisPoints int64) string {
+	s := fmt.Sprintf("%d.%02d", basisPoints/100, basisPoints%100)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
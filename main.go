@@ -2,22 +2,104 @@ package main
 
 import (
 	"context"
-	"pairproject/cli"    // Package untuk menangani command line interface (CLI)
-	"pairproject/config" // Package untuk konfigurasi aplikasi, termasuk inisialisasi database
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"pairproject/cli"     // Package untuk menangani command line interface (CLI)
+	"pairproject/config"  // Package untuk konfigurasi aplikasi, termasuk inisialisasi database
+	"pairproject/entity"  // Package untuk tipe data domain
+	"pairproject/webhook" // Package untuk endpoint callback payment gateway
+	"pairproject/worker"  // Package untuk tugas terjadwal di background
+	"syscall"
 )
 
 func main() {
+	// Mode webhook-send: `pairproject webhook-send <charge_ref> <nominal> [approved|declined|pending]`
+	// menandatangani event contoh lalu mengirimnya ke endpoint webhook, tanpa koneksi database
+	if len(os.Args) > 1 && os.Args[1] == "webhook-send" {
+		config.LoadEnv()
+		sendWebhook(os.Args[2:])
+		return
+	}
+
 	// Inisialisasi koneksi database menggunakan konfigurasi dari package config
 	db := config.InitDB()
 	// Pastikan koneksi database ditutup ketika aplikasi selesai dijalankan
 	defer db.Close()
 
 	// Buat context dasar untuk digunakan di seluruh aplikasi (misal untuk request scope)
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Mode worker: `pairproject worker` hanya menjalankan scheduler sampai dihentikan (Ctrl+C / SIGTERM)
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		w := worker.New(db)
+		w.Logger = log.New(os.Stdout, "[worker] ", log.LstdFlags)
+		w.Logger.Printf("berjalan setiap %s", w.Interval)
+		w.Run(ctx)
+		w.Logger.Println("berhenti")
+		return
+	}
+
+	// Mode webhook: `pairproject webhook` menjalankan endpoint callback payment gateway di WEBHOOK_ADDR (default :8080)
+	if len(os.Args) > 1 && os.Args[1] == "webhook" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		addr := os.Getenv("WEBHOOK_ADDR")
+		if addr == "" {
+			addr = ":8080"
+		}
+		server := &webhook.Server{DB: db, Secret: []byte(os.Getenv("WEBHOOK_SECRET")), Logger: log.New(os.Stdout, "[webhook] ", log.LstdFlags)}
+		server.Logger.Printf("menerima callback di %s%s", addr, webhook.Path)
+		if err := server.ListenAndServe(ctx, addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			server.Logger.Fatal(err)
+		}
+		server.Logger.Println("berhenti")
+		return
+	}
+
+	// Jalankan scheduler di background selama CLI berjalan agar billing kedaluwarsa tetap diproses
+	go worker.New(db).Run(ctx)
 
 	// Buat handler CLI dengan memasukkan koneksi db dan context
-	cli := cli.NewCLIHandler(db, ctx)
+	cli := cli.NewCLIHandler(db, context.Background())
 
 	// Jalankan menu utama CLI agar user bisa mulai berinteraksi dengan aplikasi
 	cli.Menu()
-}
\ No newline at end of file
+}
+
+// sendWebhook mengirim event contoh yang ditandatangani WEBHOOK_SECRET ke WEBHOOK_URL
+// (default http://localhost:8080/webhooks/payment) untuk menguji endpoint webhook secara lokal
+func sendWebhook(args []string) {
+	if len(args) < 2 {
+		log.Fatal("Penggunaan: webhook-send <charge_ref> <nominal> [approved|declined|pending]")
+	}
+
+	amount, err := entity.ParseMoney(args[1])
+	if err != nil {
+		log.Fatal("Nominal tidak valid: ", err)
+	}
+	status := entity.ChargeApproved
+	if len(args) > 2 {
+		status = entity.ChargeStatus(args[2])
+	}
+
+	url := os.Getenv("WEBHOOK_URL")
+	if url == "" {
+		url = "http://localhost:8080" + webhook.Path
+	}
+
+	event := webhook.SampleEvent(args[0], status, amount)
+	code, body, err := webhook.Send(context.Background(), url, []byte(os.Getenv("WEBHOOK_SECRET")), event)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s -> %d %s\n", event.ID, code, body)
+}
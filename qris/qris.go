@@ -0,0 +1,288 @@
+// Package qris membuat dan membaca payload QR pembayaran format EMVCo Merchant-Presented Mode
+// seperti yang dipakai QRIS, lalu merendernya sebagai QR di terminal atau gambar PNG.
+package qris
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"pairproject/entity"
+	"strconv"
+	"strings"
+)
+
+// Tag data object EMVCo yang dipakai payload
+const (
+	tagFormatIndicator = "00"
+	tagInitiation      = "01"
+	tagMerchantAccount = "26"
+	tagQRISNational    = "51"
+	tagCategoryCode    = "52"
+	tagCurrency        = "53"
+	tagAmount          = "54"
+	tagCountry         = "58"
+	tagMerchantName    = "59"
+	tagMerchantCity    = "60"
+	tagPostalCode      = "61"
+	tagAdditionalData  = "62"
+	tagCRC             = "63"
+
+	// Sub tag di dalam merchant account information (26 dan 51)
+	subGUID        = "00"
+	subMerchantPAN = "01"
+	subMerchantID  = "02"
+	subCriteria    = "03"
+
+	// Sub tag di dalam additional data (62)
+	subBillNumber = "01"
+	subReference  = "05"
+)
+
+const (
+	// QRISDomain adalah GUID nasional QRIS pada tag 51
+	QRISDomain = "ID.CO.QRIS.WWW"
+	// CurrencyIDR adalah kode numerik ISO 4217 untuk rupiah
+	CurrencyIDR = "360"
+	// initiationDynamic menandakan QR sekali pakai yang sudah berisi nominal (QR statis memakai "11")
+	initiationDynamic = "12"
+)
+
+var (
+	// ErrInvalidCRC dikembalikan jika checksum payload tidak cocok, misal QR rusak atau diubah
+	ErrInvalidCRC = errors.New("Checksum QR tidak valid")
+	// ErrMalformed dikembalikan jika payload bukan data object EMVCo yang valid
+	ErrMalformed = errors.New("Payload QR tidak valid")
+)
+
+// Merchant adalah identitas merchant yang dicetak di QR
+type Merchant struct {
+	Name         string // maksimal 25 karakter
+	City         string // maksimal 15 karakter
+	PostalCode   string
+	CategoryCode string // merchant category code (MCC) ISO 18245
+	Acquirer     string // GUID acquirer dalam format reverse domain, contoh ID.CO.BANK.WWW
+	PAN          string // merchant PAN dari acquirer
+	MerchantID   string // NMID (National Merchant ID) QRIS
+	Criteria     string // kriteria usaha: UMI, UKE, UME, atau UBE
+}
+
+// DefaultMerchant membaca identitas merchant dari environment (QRIS_MERCHANT_NAME, QRIS_MERCHANT_CITY,
+// QRIS_POSTAL_CODE, QRIS_MCC, QRIS_ACQUIRER, QRIS_MERCHANT_PAN, QRIS_MERCHANT_ID). Nilai kosong diisi
+// identitas merchant simulasi, nama merchant mengikuti STORE_NAME.
+func DefaultMerchant() Merchant {
+	merchant := Merchant{
+		Name:         env("QRIS_MERCHANT_NAME", env("STORE_NAME", "Pair Project Sport Store")),
+		City:         env("QRIS_MERCHANT_CITY", "JAKARTA"),
+		PostalCode:   env("QRIS_POSTAL_CODE", "10110"),
+		CategoryCode: env("QRIS_MCC", "5941"),
+		Acquirer:     env("QRIS_ACQUIRER", "ID.CO.PAIRPROJECT.WWW"),
+		PAN:          env("QRIS_MERCHANT_PAN", "9360000100000000017"),
+		MerchantID:   env("QRIS_MERCHANT_ID", "ID1026000000001"),
+		Criteria:     "UMI",
+	}
+
+	return merchant
+}
+
+// Validate memastikan identitas merchant cukup untuk dicetak di QR
+func (m Merchant) Validate() error {
+	switch {
+	case m.Name == "":
+		return errors.New("Nama merchant QRIS wajib diisi")
+	case m.City == "":
+		return errors.New("Kota merchant QRIS wajib diisi")
+	case m.MerchantID == "":
+		return errors.New("NMID merchant QRIS wajib diisi")
+	case len(m.CategoryCode) != 4 || !isDigits(m.CategoryCode):
+		return fmt.Errorf("MCC merchant QRIS harus 4 digit: %q", m.CategoryCode)
+	}
+	return nil
+}
+
+// Payload adalah isi QR pembayaran yang sudah dibaca
+type Payload struct {
+	Dynamic      bool // true jika QR sekali pakai dengan nominal
+	MerchantName string
+	MerchantCity string
+	MerchantID   string // NMID
+	CategoryCode string
+	Currency     string
+	Amount       entity.Money // 0 untuk QR statis
+	BillNumber   string       // nomor billing yang dibayar
+	Reference    string
+}
+
+// Generate membuat payload QR dinamis untuk membayar billNumber sebesar amount.
+// Payload diakhiri CRC16-CCITT sehingga aplikasi pembayaran menolak QR yang rusak.
+func Generate(merchant Merchant, billNumber string, amount entity.Money) (string, error) {
+	if err := merchant.Validate(); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", errors.New("Nominal QR harus lebih dari 0")
+	}
+	if billNumber == "" || len(billNumber) > 25 {
+		return "", fmt.Errorf("Nomor billing QR harus 1-25 karakter: %q", billNumber)
+	}
+
+	criteria := merchant.Criteria
+	if criteria == "" {
+		criteria = "UMI"
+	}
+
+	var b strings.Builder
+	b.WriteString(tlv(tagFormatIndicator, "01"))
+	b.WriteString(tlv(tagInitiation, initiationDynamic))
+	if merchant.Acquirer != "" {
+		account := tlv(subGUID, merchant.Acquirer)
+		if merchant.PAN != "" {
+			account += tlv(subMerchantPAN, merchant.PAN)
+		}
+		account += tlv(subMerchantID, merchant.MerchantID) + tlv(subCriteria, criteria)
+		b.WriteString(tlv(tagMerchantAccount, account))
+	}
+	b.WriteString(tlv(tagQRISNational, tlv(subGUID, QRISDomain)+tlv(subMerchantID, merchant.MerchantID)+tlv(subCriteria, criteria)))
+	b.WriteString(tlv(tagCategoryCode, merchant.CategoryCode))
+	b.WriteString(tlv(tagCurrency, CurrencyIDR))
+	b.WriteString(tlv(tagAmount, formatAmount(amount)))
+	b.WriteString(tlv(tagCountry, "ID"))
+	b.WriteString(tlv(tagMerchantName, limit(merchant.Name, 25)))
+	b.WriteString(tlv(tagMerchantCity, limit(strings.ToUpper(merchant.City), 15)))
+	if merchant.PostalCode != "" {
+		b.WriteString(tlv(tagPostalCode, merchant.PostalCode))
+	}
+	b.WriteString(tlv(tagAdditionalData, tlv(subBillNumber, billNumber)+tlv(subReference, billNumber)))
+
+	// CRC dihitung dari seluruh payload termasuk ID dan panjang tag 63
+	b.WriteString(tagCRC + "04")
+	payload := b.String()
+	return payload + fmt.Sprintf("%04X", CRC16(payload)), nil
+}
+
+// Parse membaca payload QR dan memverifikasi CRC-nya
+func Parse(payload string) (Payload, error) {
+	var result Payload
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != tagCRC+"04" {
+		return result, ErrMalformed
+	}
+	crc, err := strconv.ParseUint(payload[len(payload)-4:], 16, 16)
+	if err != nil {
+		return result, ErrMalformed
+	}
+	if uint16(crc) != CRC16(payload[:len(payload)-4]) {
+		return result, ErrInvalidCRC
+	}
+
+	objects, err := parseTLV(payload)
+	if err != nil {
+		return result, err
+	}
+	if objects[tagFormatIndicator] != "01" {
+		return result, ErrMalformed
+	}
+
+	result.Dynamic = objects[tagInitiation] == initiationDynamic
+	result.MerchantName = objects[tagMerchantName]
+	result.MerchantCity = objects[tagMerchantCity]
+	result.CategoryCode = objects[tagCategoryCode]
+	result.Currency = objects[tagCurrency]
+
+	if national, ok := objects[tagQRISNational]; ok {
+		sub, err := parseTLV(national)
+		if err != nil {
+			return result, err
+		}
+		result.MerchantID = sub[subMerchantID]
+	}
+	if additional, ok := objects[tagAdditionalData]; ok {
+		sub, err := parseTLV(additional)
+		if err != nil {
+			return result, err
+		}
+		result.BillNumber, result.Reference = sub[subBillNumber], sub[subReference]
+	}
+	if amount, ok := objects[tagAmount]; ok {
+		result.Amount, err = entity.ParseMoney(amount)
+		if err != nil {
+			return result, fmt.Errorf("%w: nominal %q", ErrMalformed, amount)
+		}
+	}
+	if result.Dynamic && result.Amount <= 0 {
+		return result, fmt.Errorf("%w: QR dinamis tanpa nominal", ErrMalformed)
+	}
+
+	return result, nil
+}
+
+// CRC16 menghitung CRC16-CCITT (polinomial 0x1021, nilai awal 0xFFFF) sesuai spesifikasi EMVCo
+func CRC16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// tlv menyusun satu data object: ID dua digit, panjang dua digit, lalu nilainya
+func tlv(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// parseTLV memecah rangkaian data object menjadi map ID ke nilai
+func parseTLV(data string) (map[string]string, error) {
+	objects := map[string]string{}
+	for len(data) > 0 {
+		if len(data) < 4 || !isDigits(data[:4]) {
+			return nil, ErrMalformed
+		}
+		id := data[:2]
+		length, _ := strconv.Atoi(data[2:4])
+		if len(data) < 4+length {
+			return nil, ErrMalformed
+		}
+		objects[id] = data[4 : 4+length]
+		data = data[4+length:]
+	}
+	return objects, nil
+}
+
+// formatAmount menulis nominal tanpa pemisah ribuan; sen hanya ditulis jika ada, contoh "150000" atau "150000.50"
+func formatAmount(amount entity.Money) string {
+	if amount%100 == 0 {
+		return strconv.FormatInt(int64(amount/100), 10)
+	}
+	return amount.String()
+}
+
+// limit memotong teks agar tidak melebihi panjang maksimal data object
+func limit(s string, n int) string {
+	if len(s) > n {
+		return strings.TrimSpace(s[:n])
+	}
+	return s
+}
+
+// isDigits bernilai true jika s hanya berisi angka
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// env membaca environment variable, atau fallback jika kosong
+func env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
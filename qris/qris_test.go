@@ -0,0 +1,95 @@
+package qris
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pairproject/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMerchant adalah identitas merchant tetap agar payload hasil test tidak bergantung environment
+var testMerchant = Merchant{
+	Name:         "Pair Project Sport Store",
+	City:         "Jakarta",
+	PostalCode:   "10110",
+	CategoryCode: "5941",
+	Acquirer:     "ID.CO.PAIRPROJECT.WWW",
+	PAN:          "9360000100000000017",
+	MerchantID:   "ID1026000000001",
+}
+
+// TestCRC16 memakai check value CRC-16/CCITT-FALSE untuk "123456789"
+func TestCRC16(t *testing.T) {
+	assert.Equal(t, uint16(0x29B1), CRC16("123456789"))
+}
+
+// TestGenerate menguji payload QR dinamis dan pembacaan ulangnya
+func TestGenerate(t *testing.T) {
+	payload, err := Generate(testMerchant, "BIL-202610-001", entity.NewMoney(150000))
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(payload, "000201010212"), "Format indicator dan QR dinamis")
+	assert.Contains(t, payload, "5406150000")
+	assert.Contains(t, payload, "5303360")
+	assert.Contains(t, payload, "5802ID")
+	assert.Contains(t, payload, "6007JAKARTA")
+	assert.Contains(t, payload, "0014ID.CO.QRIS.WWW")
+
+	parsed, err := Parse(payload)
+	require.NoError(t, err)
+	assert.True(t, parsed.Dynamic)
+	assert.Equal(t, entity.NewMoney(150000), parsed.Amount)
+	assert.Equal(t, "BIL-202610-001", parsed.BillNumber)
+	assert.Equal(t, "ID1026000000001", parsed.MerchantID)
+	assert.Equal(t, "Pair Project Sport Store", parsed.MerchantName)
+	assert.Equal(t, CurrencyIDR, parsed.Currency)
+
+	// Nominal dengan sen
+	payload, err = Generate(testMerchant, "BIL-202610-001", entity.Money(1234550))
+	require.NoError(t, err)
+	assert.Contains(t, payload, "540812345.50")
+
+	// Input yang tidak valid ditolak
+	_, err = Generate(testMerchant, "BIL-202610-001", 0)
+	assert.Error(t, err)
+	_, err = Generate(Merchant{Name: "Toko"}, "BIL-202610-001", entity.NewMoney(1000))
+	assert.Error(t, err)
+}
+
+// TestParse_Invalid menguji payload yang rusak atau diubah
+func TestParse_Invalid(t *testing.T) {
+	payload, err := Generate(testMerchant, "BIL-202610-001", entity.NewMoney(150000))
+	require.NoError(t, err)
+
+	// Nominal diubah tanpa memperbarui CRC
+	tampered := strings.Replace(payload, "5406150000", "5406100000", 1)
+	_, err = Parse(tampered)
+	assert.ErrorIs(t, err, ErrInvalidCRC)
+
+	_, err = Parse("bukan qr")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	// CRC benar namun panjang data object tidak sesuai isinya
+	broken := "0002010105AB6304"
+	_, err = Parse(broken + fmt.Sprintf("%04X", CRC16(broken)))
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+// TestRender menguji QR terminal dan gambar PNG
+func TestRender(t *testing.T) {
+	payload, err := Generate(testMerchant, "BIL-202610-001", entity.NewMoney(150000))
+	require.NoError(t, err)
+
+	text, err := Terminal(payload)
+	require.NoError(t, err)
+	assert.Contains(t, text, "█")
+
+	png, err := PNG(payload, 0)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(png, []byte("\x89PNG")))
+}
@@ -0,0 +1,32 @@
+package qris
+
+import (
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// DefaultPNGSize adalah lebar dan tinggi gambar QR dalam piksel
+const DefaultPNGSize = 256
+
+// Terminal merender payload sebagai QR dari karakter blok Unicode, dua baris modul per baris teks
+// agar cukup kecil untuk dipindai langsung dari layar terminal
+func Terminal(payload string) (string, error) {
+	code, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return "", fmt.Errorf("Gagal membuat QR: %w", err)
+	}
+	return code.ToSmallString(false), nil
+}
+
+// PNG merender payload sebagai gambar PNG berukuran size x size piksel
+func PNG(payload string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = DefaultPNGSize
+	}
+	png, err := qrcode.Encode(payload, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("Gagal membuat gambar QR: %w", err)
+	}
+	return png, nil
+}
@@ -0,0 +1,121 @@
+package statement
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"pairproject/entity"
+	"strings"
+)
+
+// csvColumns adalah nama kolom yang dikenali (huruf kecil) untuk setiap field mutasi
+var csvColumns = map[string][]string{
+	"date":        {"date", "tanggal", "tgl", "tanggal transaksi"},
+	"description": {"description", "keterangan", "berita", "remark"},
+	"reference":   {"reference", "referensi", "ref", "no. referensi"},
+	"amount":      {"amount", "nominal", "jumlah", "mutasi"},
+	"credit":      {"credit", "kredit", "cr"},
+	"debit":       {"debit", "db"},
+}
+
+// ParseCSV membaca statement CSV dengan baris header. Pemisah kolom koma atau titik koma dideteksi otomatis.
+// Nominal diambil dari kolom amount bertanda, atau dari pasangan kolom credit / debit.
+func ParseCSV(r io.Reader) ([]entity.StatementLine, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	reader := csv.NewReader(br)
+	header := string(first)
+	if i := strings.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Gagal membaca CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("File CSV kosong")
+	}
+
+	// Petakan nama field ke indeks kolom berdasarkan header
+	index := map[string]int{}
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for field, aliases := range csvColumns {
+			for _, alias := range aliases {
+				if name == alias {
+					index[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := index["date"]; !ok {
+		return nil, errors.New("Kolom tanggal tidak ditemukan di header CSV")
+	}
+	_, hasAmount := index["amount"]
+	_, hasCredit := index["credit"]
+	if !hasAmount && !hasCredit {
+		return nil, errors.New("Kolom nominal (amount atau credit) tidak ditemukan di header CSV")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var lines []entity.StatementLine
+	for n, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		date, err := parseDate(field(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("Baris %d: %w", n+2, err)
+		}
+
+		line := entity.StatementLine{Date: date, Reference: field(record, "reference"), Description: field(record, "description")}
+		if hasAmount {
+			line.Amount, err = parseAmount(field(record, "amount"))
+		} else {
+			line.Amount, err = creditDebit(field(record, "credit"), field(record, "debit"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Baris %d: nominal tidak valid: %w", n+2, err)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// creditDebit menggabungkan kolom kredit dan debit menjadi satu nominal bertanda
+func creditDebit(credit, debit string) (entity.Money, error) {
+	if credit != "" {
+		amount, err := parseAmount(credit)
+		if err != nil || amount != 0 {
+			return amount, err
+		}
+	}
+	if debit == "" {
+		return 0, nil
+	}
+
+	amount, err := parseAmount(debit)
+	return -amount, err
+}
@@ -0,0 +1,142 @@
+package statement
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"pairproject/entity"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseMT940 membaca statement SWIFT MT940. Setiap tag :61: menjadi satu mutasi,
+// dan tag :86: setelahnya menjadi keterangan mutasi tersebut.
+func ParseMT940(r io.Reader) ([]entity.StatementLine, error) {
+	// Kumpulkan tag beserta baris lanjutannya
+	type tag struct {
+		name  string
+		value string
+	}
+	var tags []tag
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case text == "-" || text == "-}" || strings.TrimSpace(text) == "":
+			continue
+		case strings.HasPrefix(text, ":"):
+			end := strings.Index(text[1:], ":")
+			if end < 0 {
+				return nil, fmt.Errorf("Tag MT940 tidak valid: %q", text)
+			}
+			tags = append(tags, tag{name: text[1 : end+1], value: text[end+2:]})
+		case len(tags) > 0:
+			tags[len(tags)-1].value += "\n" + text
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var lines []entity.StatementLine
+	for _, t := range tags {
+		switch t.name {
+		case "61":
+			line, err := parseMT940Line(t.value)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		case "86":
+			if len(lines) > 0 {
+				lines[len(lines)-1].Description = strings.Join(strings.Fields(t.value), " ")
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("File MT940 tidak berisi tag apa pun")
+	}
+
+	return lines, nil
+}
+
+// parseMT940Line membaca isi tag :61:, contoh "2506050605C1500000,00NTRFBIL-202506-001//TRX123".
+// Format: tanggal valuta YYMMDD, tanggal buku MMDD (opsional), tanda C/D/RC/RD, kode dana (opsional),
+// nominal dengan koma desimal, kode transaksi 4 karakter, referensi customer, lalu //referensi bank (opsional).
+func parseMT940Line(value string) (entity.StatementLine, error) {
+	var line entity.StatementLine
+	first := strings.SplitN(value, "\n", 2)[0]
+	invalid := fmt.Errorf("Mutasi MT940 tidak valid: %q", first)
+
+	if len(first) < 6 {
+		return line, invalid
+	}
+	date, err := time.ParseInLocation("060102", first[:6], time.Local)
+	if err != nil {
+		return line, invalid
+	}
+	line.Date = date
+	rest := first[6:]
+
+	// Tanggal buku opsional (MMDD)
+	if len(rest) >= 4 && isDigits(rest[:4]) {
+		rest = rest[4:]
+	}
+
+	// Tanda debit / kredit, termasuk pembalikan (RC / RD)
+	sign := entity.Money(1)
+	switch {
+	case strings.HasPrefix(rest, "RC"):
+		sign, rest = -1, rest[2:]
+	case strings.HasPrefix(rest, "RD"):
+		rest = rest[2:]
+	case strings.HasPrefix(rest, "C"):
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "D"):
+		sign, rest = -1, rest[1:]
+	default:
+		return line, invalid
+	}
+
+	// Kode dana opsional berupa satu huruf sebelum nominal
+	if rest != "" && unicode.IsLetter(rune(rest[0])) {
+		rest = rest[1:]
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) && r != ',' })
+	if end <= 0 {
+		return line, invalid
+	}
+	amount, err := entity.ParseMoney(strings.TrimSuffix(strings.Replace(rest[:end], ",", ".", 1), "."))
+	if err != nil {
+		return line, invalid
+	}
+	line.Amount = sign * amount
+	rest = rest[end:]
+
+	// Kode transaksi (N/F + 3 karakter), lalu referensi customer dan referensi bank
+	if len(rest) < 4 {
+		return line, invalid
+	}
+	rest = rest[4:]
+	customerRef, bankRef, _ := strings.Cut(rest, "//")
+	line.Reference = strings.TrimSpace(customerRef)
+	if line.Reference == "" || line.Reference == "NONREF" {
+		line.Reference = strings.TrimSpace(bankRef)
+	}
+
+	return line, nil
+}
+
+// isDigits bernilai true jika s hanya berisi angka
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
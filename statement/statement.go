@@ -0,0 +1,76 @@
+package statement
+
+import (
+	"fmt"
+	"os"
+	"pairproject/entity"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParseFile membaca file statement bank; format mengikuti ekstensi (.csv untuk CSV, .sta / .mt940 / .940 untuk MT940)
+func ParseFile(path string) ([]entity.StatementLine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Gagal membuka file statement: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ParseCSV(file)
+	case ".sta", ".mt940", ".940":
+		return ParseMT940(file)
+	}
+
+	return nil, fmt.Errorf("Format file statement tidak didukung, gunakan .csv atau .sta/.mt940")
+}
+
+// parseAmount membaca nominal dengan pemisah ribuan / desimal gaya Indonesia maupun internasional,
+// contoh "1.250.000,50", "1,250,000.50", "Rp 150000", dan "-75000"
+func parseAmount(s string) (entity.Money, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "Rp"), "IDR")
+	s = strings.ReplaceAll(s, " ", "")
+
+	lastComma, lastDot := strings.LastIndex(s, ","), strings.LastIndex(s, ".")
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		// Pemisah yang muncul terakhir adalah desimal
+		if lastComma > lastDot {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastComma >= 0:
+		// Koma tunggal diikuti 1-2 digit adalah desimal, selain itu pemisah ribuan
+		if strings.Count(s, ",") == 1 && len(s)-lastComma-1 <= 2 {
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastDot >= 0:
+		// Titik lebih dari satu, atau diikuti tepat 3 digit, adalah pemisah ribuan
+		if strings.Count(s, ".") > 1 || len(s)-lastDot-1 == 3 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	return entity.ParseMoney(strings.TrimSuffix(s, "."))
+}
+
+// dateLayouts adalah format tanggal yang umum di file statement bank
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "02-01-2006", "2006/01/02", "02/01/06", "2006-01-02 15:04:05"}
+
+// parseDate membaca tanggal mutasi dengan salah satu format di dateLayouts
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Tanggal %q tidak dikenali", s)
+}
@@ -0,0 +1,118 @@
+package statement
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseAmount menguji nominal dengan berbagai pemisah ribuan dan desimal.
+func TestParseAmount(t *testing.T) {
+	cases := map[string]entity.Money{
+		"150000":        entity.NewMoney(150000),
+		"1.250.000,50":  125000050,
+		"1,250,000.50":  125000050,
+		"Rp 75.000":     entity.NewMoney(75000),
+		"-75000":        entity.NewMoney(-75000),
+		"1,000":         entity.NewMoney(1000),
+		"12,5":          1250,
+		"IDR 99.000,00": entity.NewMoney(99000),
+	}
+	for input, expected := range cases {
+		amount, err := parseAmount(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, amount, input)
+	}
+
+	_, err := parseAmount("abc")
+	assert.Error(t, err)
+}
+
+// TestParseCSV menguji CSV dengan pemisah titik koma dan kolom kredit / debit terpisah.
+func TestParseCSV(t *testing.T) {
+	input := "Tanggal;Keterangan;Referensi;Debit;Kredit\n" +
+		"05/06/2025;TRSF E-BANKING BIL-202506-001 BUDI;TRX001;;1.500.000,00\n" +
+		"05/06/2025;BIAYA ADM;ADM01;6.500,00;\n" +
+		";;;;\n"
+
+	lines, err := ParseCSV(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, time.Date(2025, 6, 5, 0, 0, 0, 0, time.Local), lines[0].Date)
+	assert.Equal(t, entity.NewMoney(1500000), lines[0].Amount)
+	assert.Equal(t, "TRX001", lines[0].Reference)
+	assert.Contains(t, lines[0].Description, "BIL-202506-001")
+	assert.Equal(t, entity.NewMoney(-6500), lines[1].Amount)
+
+	// Kolom nominal bertanda dengan pemisah koma
+	lines, err = ParseCSV(strings.NewReader("date,description,reference,amount\n2025-06-06,Transfer,REF9,250000\n"))
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.Equal(t, entity.NewMoney(250000), lines[0].Amount)
+
+	_, err = ParseCSV(strings.NewReader("description,amount\nTransfer,1000\n"))
+	assert.Error(t, err, "tanpa kolom tanggal")
+	_, err = ParseCSV(strings.NewReader("date,amount\n31/31/2025,1000\n"))
+	assert.Error(t, err, "tanggal tidak valid")
+}
+
+// TestParseMT940 menguji mutasi kredit, debit, referensi bank, dan keterangan multi-baris.
+func TestParseMT940(t *testing.T) {
+	input := `:20:STMT20250605
+:25:1234567890
+:28C:00001/001
+:60F:C250604IDR10000000,00
+:61:2506050605C1500000,00NTRFBIL-202506-001//TRX123
+:86:TRSF E-BANKING CR BUDI SANTOSO
+ORD-202506-001
+:61:250605D6500,NCHGNONREF//ADM01
+:86:BIAYA ADMINISTRASI
+:61:250606RC200000,NTRFNONREF//REV01
+:62F:C250606IDR11293500,00
+-`
+
+	lines, err := ParseMT940(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, time.Date(2025, 6, 5, 0, 0, 0, 0, time.Local), lines[0].Date)
+	assert.Equal(t, entity.NewMoney(1500000), lines[0].Amount)
+	assert.Equal(t, "BIL-202506-001", lines[0].Reference)
+	assert.Equal(t, "TRSF E-BANKING CR BUDI SANTOSO ORD-202506-001", lines[0].Description)
+
+	assert.Equal(t, entity.NewMoney(-6500), lines[1].Amount)
+	assert.Equal(t, "ADM01", lines[1].Reference)
+	assert.Equal(t, entity.NewMoney(-200000), lines[2].Amount, "pembalikan kredit")
+
+	_, err = ParseMT940(strings.NewReader(":61:2506X\n"))
+	assert.Error(t, err)
+}
+
+// TestParseFile menguji pemilihan parser berdasarkan ekstensi file.
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "mutasi.csv")
+	require.NoError(t, os.WriteFile(path, []byte("date,amount\n2025-06-05,1000\n"), 0o644))
+	lines, err := ParseFile(path)
+	require.NoError(t, err)
+	assert.Len(t, lines, 1)
+
+	path = filepath.Join(dir, "mutasi.sta")
+	require.NoError(t, os.WriteFile(path, []byte(":61:250605C1000,NTRFREF1\n"), 0o644))
+	lines, err = ParseFile(path)
+	require.NoError(t, err)
+	assert.Len(t, lines, 1)
+
+	path = filepath.Join(dir, "mutasi.xlsx")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	_, err = ParseFile(path)
+	assert.Error(t, err)
+}
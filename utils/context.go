@@ -2,7 +2,11 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"pairproject/entity"
+	"time"
 )
 
 // ContextKey adalah tipe kustom untuk key yang digunakan di context agar menghindari bentrok dengan key lain.
@@ -29,4 +33,29 @@ func GetUser(ctx context.Context) (*entity.User, bool) {
 // Mengembalikan context baru tanpa data user.
 func ClearUser(ctx context.Context) context.Context {
 	return context.WithValue(ctx, userKey, nil)
-}
\ No newline at end of file
+}
+
+// idempotencyKey adalah key untuk menyimpan idempotency key permintaan yang sedang diproses pada context.
+const idempotencyKey ContextKey = "idempotency_key"
+
+// WithIdempotencyKey menyisipkan idempotency key ke dalam context.
+// Permintaan yang diulang dengan key yang sama mengembalikan hasil permintaan pertama tanpa menulis data lagi.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey, key)
+}
+
+// GetIdempotencyKey mengambil idempotency key dari context.
+// Jika key tidak ada atau kosong, mengembalikan string kosong dan false.
+func GetIdempotencyKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey).(string)
+	return key, ok && key != ""
+}
+
+// NewIdempotencyKey membuat idempotency key acak untuk satu aksi user
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -101,16 +101,88 @@ END;
 
 ---
 
+-- Tabel customer_addresses
+CREATE TABLE customer_addresses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    recipient_name TEXT NOT NULL,
+    phone_number TEXT NOT NULL,
+    address TEXT NOT NULL,
+    city TEXT NOT NULL,
+    postal_code TEXT NOT NULL,
+    zone TEXT NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id)
+);
+
+---
+
+-- Tabel shipping_rates
+CREATE TABLE shipping_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('flat', 'weight', 'zone')),
+    zone TEXT,
+    min_weight INTEGER,
+    max_weight INTEGER,
+    fee NUMERIC NOT NULL DEFAULT 0 CHECK (fee >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel tax_classes
+CREATE TABLE tax_classes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+-- Tabel tax_rates
+CREATE TABLE tax_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tax_class TEXT NOT NULL,
+    rate NUMERIC NOT NULL CHECK (rate >= 0 AND rate <= 100),
+    effective_from DATE NOT NULL,
+    effective_to DATE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (tax_class) REFERENCES tax_classes(code),
+    UNIQUE (tax_class, effective_from)
+);
+
 -- Tabel categories
 CREATE TABLE categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL UNIQUE,
+    tax_class TEXT NOT NULL DEFAULT 'standard',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     created_by INTEGER NOT NULL,
     updated_by INTEGER,
     FOREIGN KEY (created_by) REFERENCES users(id),
-    FOREIGN KEY (updated_by) REFERENCES users(id)
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (tax_class) REFERENCES tax_classes(code)
 );
 
 -- Trigger untuk updated_at di tabel categories
@@ -131,6 +203,9 @@ CREATE TABLE products (
     description TEXT,
     category_id INTEGER NOT NULL,
     price NUMERIC DEFAULT 0 NOT NULL,
+    tax_class TEXT,
+    price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+    weight INTEGER NOT NULL DEFAULT 0 CHECK (weight >= 0),
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     created_by INTEGER NOT NULL,
@@ -162,13 +237,18 @@ CREATE TABLE orders (
     date TEXT NOT NULL,
     status TEXT NOT NULL CHECK (status IN ('processing', 'completed', 'cancel')) DEFAULT 'processing',
     total NUMERIC NOT NULL DEFAULT 0 CHECK (total >= 0),
+    shipping_address_id INTEGER,
+    shipping_address TEXT,
+    shipping_zone TEXT,
+    shipping_fee NUMERIC NOT NULL DEFAULT 0 CHECK (shipping_fee >= 0),
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     created_by INTEGER NOT NULL,
     updated_by INTEGER,
     FOREIGN KEY (created_by) REFERENCES users(id),
     FOREIGN KEY (updated_by) REFERENCES users(id),
-    FOREIGN KEY (customer_id) REFERENCES customers(id)
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (shipping_address_id) REFERENCES customer_addresses(id)
 );
 
 -- Trigger untuk updated_at di tabel orders
@@ -191,6 +271,11 @@ CREATE TABLE order_details (
     order_id INTEGER NOT NULL,
     product_id INTEGER NOT NULL,
     qty INTEGER NOT NULL DEFAULT 1,
+    product_name TEXT NOT NULL,
+    price NUMERIC NOT NULL DEFAULT 0,
+    tax_class TEXT NOT NULL DEFAULT 'standard',
+    price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+    weight INTEGER NOT NULL DEFAULT 0 CHECK (weight >= 0),
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     created_by INTEGER NOT NULL,
@@ -219,6 +304,7 @@ CREATE TABLE billings (
     issue_date DATETIME DEFAULT CURRENT_TIMESTAMP,
     due_date DATETIME,
     tax NUMERIC NOT NULL DEFAULT 0 CHECK (tax >= 0),
+    shipping_fee NUMERIC NOT NULL DEFAULT 0 CHECK (shipping_fee >= 0),
     total NUMERIC NOT NULL DEFAULT 0 CHECK (total >= 0),
     status TEXT NOT NULL CHECK (status IN ('unpaid', 'lesspaid', 'paid', 'cancelled', 'refunded')) DEFAULT 'unpaid',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -230,6 +316,9 @@ CREATE TABLE billings (
     FOREIGN KEY (order_id) REFERENCES orders(id)
 );
 
+-- Maksimal satu billing aktif (unpaid/lesspaid) per order
+CREATE UNIQUE INDEX uq_billings_active_order ON billings (order_id) WHERE status IN ('unpaid', 'lesspaid');
+
 -- Trigger untuk updated_at di tabel billings
 CREATE TRIGGER update_billings_updated_at
 AFTER UPDATE ON billings
@@ -243,20 +332,44 @@ CREATE INDEX idx_billings_status ON billings (status);
 
 ---
 
+-- Tabel payment_methods
+CREATE TABLE payment_methods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    fee_flat NUMERIC NOT NULL DEFAULT 0 CHECK (fee_flat >= 0),
+    fee_percent NUMERIC NOT NULL DEFAULT 0 CHECK (fee_percent >= 0 AND fee_percent <= 100),
+    min_amount NUMERIC NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+    max_amount NUMERIC NOT NULL DEFAULT 0 CHECK (max_amount >= 0),
+    display_order INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+CREATE INDEX idx_payment_methods_enabled_display_order ON payment_methods (enabled, display_order);
+
+---
+
 -- Tabel payments
 CREATE TABLE payments (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     billing_id INTEGER NOT NULL,
     date DATETIME DEFAULT CURRENT_TIMESTAMP,
     amount NUMERIC NOT NULL DEFAULT 0 CHECK (amount >= 0),
-    method TEXT NOT NULL CHECK (method IN ('credit_card', 'va', 'transfer')),
+    method TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     created_by INTEGER NOT NULL,
     updated_by INTEGER,
     FOREIGN KEY (created_by) REFERENCES users(id),
     FOREIGN KEY (updated_by) REFERENCES users(id),
-    FOREIGN KEY (billing_id) REFERENCES billings(id)
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (method) REFERENCES payment_methods(code)
 );
 
 -- Trigger untuk updated_at di tabel payments
@@ -272,6 +385,349 @@ CREATE INDEX idx_payments_method ON payments (method);
 
 ---
 
+-- Tabel cart_items
+CREATE TABLE cart_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    qty INTEGER NOT NULL DEFAULT 1 CHECK (qty > 0),
+    price NUMERIC NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE (customer_id, product_id)
+);
+
+---
+
+-- Tabel shipments
+CREATE TABLE shipments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL UNIQUE,
+    status TEXT NOT NULL CHECK (status IN ('picking', 'packing', 'shipped', 'delivered')) DEFAULT 'picking',
+    courier TEXT,
+    tracking_number TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+-- Tabel shipment_events
+CREATE TABLE shipment_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    shipment_id INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('picking', 'packing', 'shipped', 'delivered')),
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (shipment_id) REFERENCES shipments(id)
+);
+
+---
+
+-- Tabel returns
+CREATE TABLE returns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    number_display TEXT UNIQUE,
+    order_id INTEGER NOT NULL,
+    billing_id INTEGER NOT NULL,
+    customer_id INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('requested', 'approved', 'rejected', 'received')) DEFAULT 'requested',
+    reason TEXT NOT NULL,
+    admin_note TEXT,
+    refund_amount NUMERIC NOT NULL DEFAULT 0 CHECK (refund_amount >= 0),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    updated_by INTEGER,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (updated_by) REFERENCES users(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id)
+);
+
+-- Tabel return_items
+CREATE TABLE return_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    return_id INTEGER NOT NULL,
+    order_detail_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    price NUMERIC NOT NULL DEFAULT 0,
+    item_condition TEXT NOT NULL CHECK (item_condition IN ('pending', 'restock', 'damaged')) DEFAULT 'pending',
+    FOREIGN KEY (return_id) REFERENCES returns(id),
+    FOREIGN KEY (order_detail_id) REFERENCES order_details(id),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+---
+
+-- Tabel refunds
+CREATE TABLE refunds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    payment_id INTEGER NOT NULL,
+    return_id INTEGER,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'completed', 'failed')) DEFAULT 'completed',
+    charge_ref TEXT,
+    gateway_ref TEXT,
+    message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (return_id) REFERENCES returns(id)
+);
+
+---
+
+-- Tabel billing_tax_lines
+CREATE TABLE billing_tax_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    order_detail_id INTEGER NOT NULL,
+    tax_class TEXT NOT NULL,
+    rate NUMERIC NOT NULL,
+    price_includes_tax BOOLEAN NOT NULL DEFAULT 0,
+    taxable_amount NUMERIC NOT NULL DEFAULT 0,
+    tax_amount NUMERIC NOT NULL DEFAULT 0,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (order_detail_id) REFERENCES order_details(id)
+);
+
+---
+
+-- Tabel stock_reservations
+CREATE TABLE stock_reservations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    status TEXT NOT NULL DEFAULT 'reserved' CHECK (status IN ('reserved', 'committed', 'released')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+---
+
+-- Tabel billing_audits
+CREATE TABLE billing_audits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('superseded', 'expired')),
+    replaced_by INTEGER,
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    FOREIGN KEY (replaced_by) REFERENCES billings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel billing_installments
+CREATE TABLE billing_installments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    seq INTEGER NOT NULL CHECK (seq > 0),
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    paid_amount NUMERIC NOT NULL DEFAULT 0 CHECK (paid_amount >= 0),
+    due_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'partial', 'paid')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    UNIQUE (billing_id, seq)
+);
+
+-- Tabel payment_allocations
+CREATE TABLE payment_allocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_id INTEGER NOT NULL,
+    installment_id INTEGER NOT NULL,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (installment_id) REFERENCES billing_installments(id)
+);
+
+---
+
+-- Tabel customer_credits
+CREATE TABLE customer_credits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL,
+    billing_id INTEGER,
+    payment_id INTEGER,
+    type TEXT NOT NULL CHECK (type IN ('overpayment', 'applied', 'credit_note', 'expired_billing')),
+    amount NUMERIC NOT NULL CHECK (amount <> 0),
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel billing_adjustments
+CREATE TABLE billing_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    number_display TEXT NOT NULL UNIQUE,
+    billing_id INTEGER NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('credit', 'debit')),
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel card_tokens
+CREATE TABLE card_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    brand TEXT NOT NULL CHECK (brand IN ('visa', 'mastercard', 'amex', 'jcb')),
+    last4 TEXT NOT NULL,
+    exp_month INTEGER NOT NULL CHECK (exp_month BETWEEN 1 AND 12),
+    exp_year INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel payment_charges
+CREATE TABLE payment_charges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    charge_ref TEXT NOT NULL UNIQUE,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    fee NUMERIC NOT NULL DEFAULT 0 CHECK (fee >= 0),
+    method TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'declined')),
+    message TEXT,
+    card_token_id INTEGER,
+    qr_payload TEXT,
+    payment_id INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (card_token_id) REFERENCES card_tokens(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (method) REFERENCES payment_methods(code),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel virtual_accounts
+CREATE TABLE virtual_accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    billing_id INTEGER NOT NULL,
+    customer_id INTEGER NOT NULL,
+    bank TEXT NOT NULL,
+    va_number TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    UNIQUE (billing_id, bank),
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel bank_statement_lines
+CREATE TABLE bank_statement_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,
+    fingerprint TEXT NOT NULL UNIQUE,
+    booked_at DATE NOT NULL,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    reference TEXT,
+    description TEXT,
+    status TEXT NOT NULL DEFAULT 'review' CHECK (status IN ('matched', 'review', 'resolved', 'ignored')),
+    billing_id INTEGER,
+    payment_id INTEGER,
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER NOT NULL,
+    resolved_at DATETIME,
+    resolved_by INTEGER,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id)
+);
+
+---
+
+-- Tabel webhook_events
+CREATE TABLE webhook_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id TEXT NOT NULL UNIQUE,
+    type TEXT NOT NULL,
+    charge_ref TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'received' CHECK (status IN ('received', 'processed', 'failed')),
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    processed_at DATETIME
+);
+
+---
+
+-- Tabel idempotency_keys
+CREATE TABLE idempotency_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    idem_key TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    operation TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    resource_id INTEGER,
+    response TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE (user_id, operation, idem_key)
+);
+
+---
+
 -- Triggers Pengganti Stored Procedures
 
 -- Trigger pengganti trg_order_details_after_insert
@@ -280,9 +736,8 @@ AFTER INSERT ON order_details
 FOR EACH ROW
 BEGIN
     UPDATE orders
-    SET total = (SELECT IFNULL(SUM(od.qty * p.price), 0)
+    SET total = (SELECT IFNULL(SUM(od.qty * od.price), 0)
                  FROM order_details od
-                 JOIN products p ON od.product_id = p.id
                  WHERE od.order_id = NEW.order_id)
     WHERE id = NEW.order_id;
 END;
@@ -295,18 +750,16 @@ BEGIN
     -- Recalculate for old order ID if it was changed
     -- Only execute if OLD.order_id is different from NEW.order_id
     UPDATE orders
-    SET total = (SELECT IFNULL(SUM(od.qty * p.price), 0)
+    SET total = (SELECT IFNULL(SUM(od.qty * od.price), 0)
                  FROM order_details od
-                 JOIN products p ON od.product_id = p.id
                  WHERE od.order_id = OLD.order_id)
     WHERE id = OLD.order_id AND OLD.order_id != NEW.order_id; -- Hanya update jika order_id berubah
 
     -- Recalculate for new order ID
     -- This update always happens as it applies to the current new state of the order_detail
     UPDATE orders
-    SET total = (SELECT IFNULL(SUM(od.qty * p.price), 0)
+    SET total = (SELECT IFNULL(SUM(od.qty * od.price), 0)
                  FROM order_details od
-                 JOIN products p ON od.product_id = p.id
                  WHERE od.order_id = NEW.order_id)
     WHERE id = NEW.order_id;
 END;
@@ -317,9 +770,8 @@ AFTER DELETE ON order_details
 FOR EACH ROW
 BEGIN
     UPDATE orders
-    SET total = (SELECT IFNULL(SUM(od.qty * p.price), 0)
+    SET total = (SELECT IFNULL(SUM(od.qty * od.price), 0)
                  FROM order_details od
-                 JOIN products p ON od.product_id = p.id
                  WHERE od.order_id = OLD.order_id)
     WHERE id = OLD.order_id;
 END;
@@ -329,14 +781,17 @@ CREATE TRIGGER trg_payment_before_insert
 BEFORE INSERT ON payments
 FOR EACH ROW
 BEGIN
-    -- Hitung total pembayaran saat ini + jumlah pembayaran baru
-    -- Bandingkan dengan total tagihan dari tabel billings
+    -- Hitung dana bersih saat ini (pembayaran dikurangi refund) + jumlah pembayaran baru
+    -- Bandingkan dengan total tagihan setelah credit note / debit note
     SELECT
         CASE
             WHEN (
                 (SELECT IFNULL(SUM(amount), 0) FROM payments WHERE billing_id = NEW.billing_id)
+                - (SELECT IFNULL(SUM(amount), 0) FROM refunds WHERE billing_id = NEW.billing_id AND status <> 'failed')
                 + NEW.amount
             ) > (SELECT total FROM billings WHERE id = NEW.billing_id)
+                + (SELECT IFNULL(SUM(CASE WHEN type = 'debit' THEN amount ELSE -amount END), 0)
+                   FROM billing_adjustments WHERE billing_id = NEW.billing_id)
             THEN RAISE(ABORT, 'Total payment exceeds billing total')
         END;
 END;
@@ -364,11 +819,23 @@ VALUES
 (4, 1),
 (5, 2);
 
-INSERT INTO categories (name, created_by, updated_by)
+INSERT INTO tax_classes (code, name, created_by)
+VALUES
+('standard', 'PPN Standar', 1),
+('reduced', 'PPN Tarif Khusus', 1),
+('exempt', 'Bebas PPN', 1);
+
+INSERT INTO tax_rates (tax_class, rate, effective_from, created_by)
 VALUES
-('Fitness Equipment', 2, 2),
-('Outdoor Gear', 2, 2),
-('Nutrition & Supplements', 3, 3);
+('standard', 10.00, '2020-01-01', 1),
+('reduced', 5.00, '2020-01-01', 1),
+('exempt', 0.00, '2020-01-01', 1);
+
+INSERT INTO categories (name, tax_class, created_by, updated_by)
+VALUES
+('Fitness Equipment', 'standard', 2, 2),
+('Outdoor Gear', 'standard', 2, 2),
+('Nutrition & Supplements', 'reduced', 3, 3);
 
 INSERT INTO products (name, stock, description, category_id, price, created_by, updated_by)
 VALUES
@@ -385,16 +852,16 @@ VALUES
 (2, 'ORD-202506-002', '2025-06-01', 'processing', 630000.00, 3, 3);
 
 -- Order 1: Dumbbell + Whey Protein
-INSERT INTO order_details (order_id, product_id, qty, created_by, updated_by)
+INSERT INTO order_details (order_id, product_id, qty, product_name, price, created_by, updated_by)
 VALUES
-(1, 1, 1, 2, 2),   -- Adjustable Dumbbell
-(1, 5, 1, 2, 2);   -- Whey Protein
+(1, 1, 1, 'Adjustable Dumbbell 20kg', 1200000.00, 2, 2),   -- Adjustable Dumbbell
+(1, 5, 1, 'Whey Protein 1kg', 450000.00, 2, 2);   -- Whey Protein
 
 -- Order 2: Camping Stove + Electrolyte Drink
-INSERT INTO order_details (order_id, product_id, qty, created_by, updated_by)
+INSERT INTO order_details (order_id, product_id, qty, product_name, price, created_by, updated_by)
 VALUES
-(2, 4, 1, 3, 3),   -- Camping Stove
-(2, 6, 1, 3, 3);   -- Electrolyte Drink
+(2, 4, 1, 'Camping Stove Mini', 320000.00, 3, 3),   -- Camping Stove
+(2, 6, 1, 'Electrolyte Drink Pack (12x)', 180000.00, 3, 3);   -- Electrolyte Drink
 
 -- Billing for Order 1
 INSERT INTO billings (order_id, number_display, tax, total, status, created_by, updated_by)
@@ -406,6 +873,15 @@ INSERT INTO billings (order_id, number_display, tax, total, status, created_by,
 VALUES
 (2, 'BIL-202506-002', 30000.00, 630000.00, 'unpaid', 3, 3);
 
+-- Metode pembayaran
+INSERT INTO payment_methods (code, name, display_order, created_by)
+VALUES
+('credit_card', 'Kartu Kredit', 1, 1),
+('va', 'Virtual Account', 2, 1),
+('transfer', 'Transfer Bank', 3, 1),
+('qris', 'QRIS', 4, 1),
+('store_credit', 'Saldo Kredit', 5, 1);
+
 -- Payment for Billing 1
 INSERT INTO payments (billing_id, date, amount, method, created_by, updated_by)
 VALUES
@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"pairproject/gateway"
+	"pairproject/handler"
+	"time"
+)
+
+// Path adalah endpoint penerima webhook payment gateway
+const Path = "/webhooks/payment"
+
+// maxBodySize membatasi ukuran payload webhook
+const maxBodySize = 1 << 20
+
+// Server menerima callback payment gateway, memverifikasi tanda tangan HMAC, lalu meneruskan event
+// ke handler.WebhookHandler. Event dengan ID yang sama hanya diproses sekali.
+type Server struct {
+	DB        *sql.DB
+	Secret    []byte           // secret bersama dengan gateway, lihat gateway.SignWebhook
+	Tolerance time.Duration    // 0 berarti gateway.DefaultTolerance
+	Now       func() time.Time // nil berarti time.Now
+	Logger    *log.Logger      // nil berarti tidak mencetak log
+}
+
+// response adalah body balasan endpoint webhook
+type response struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ServeHTTP menangani satu callback. Gateway mengirim ulang event selama balasan bukan 2xx,
+// sehingga hanya kegagalan sementara (5xx) dan charge yang belum dikenal (404) yang layak dicoba ulang.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.reply(w, http.StatusMethodNotAllowed, response{Status: "error", Error: "Method tidak diizinkan"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		s.reply(w, http.StatusRequestEntityTooLarge, response{Status: "error", Error: "Payload terlalu besar"})
+		return
+	}
+
+	// Tanda tangan diverifikasi terhadap body mentah sebelum JSON dibaca
+	err = gateway.VerifyWebhook(s.Secret, r.Header.Get(gateway.SignatureHeader), body, s.now(), s.Tolerance)
+	if err != nil {
+		s.logf("tanda tangan ditolak: %v", err)
+		s.reply(w, http.StatusUnauthorized, response{Status: "error", Error: err.Error()})
+		return
+	}
+
+	var event gateway.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		s.reply(w, http.StatusBadRequest, response{Status: "error", Error: "Payload bukan event yang valid"})
+		return
+	}
+	if err := event.Validate(); err != nil {
+		s.reply(w, http.StatusBadRequest, response{Status: "error", Error: err.Error()})
+		return
+	}
+
+	// Webhook berjalan tanpa user login sehingga context tidak berisi user
+	ctx := context.Background()
+	billingHandler := handler.BillingHandler{DB: s.DB, Ctx: &ctx}
+	webhookHandler := handler.WebhookHandler{DB: s.DB, Ctx: &ctx}
+
+	_, err = webhookHandler.HandleEvent(&billingHandler, event, body)
+	switch {
+	case errors.Is(err, handler.ErrWebhookDuplicate):
+		s.logf("event %s sudah pernah diproses", event.ID)
+		s.reply(w, http.StatusOK, response{Status: "duplicate"})
+	case errors.Is(err, handler.ErrChargeNotFound):
+		s.logf("event %s: charge %s tidak ditemukan", event.ID, event.ChargeID)
+		s.reply(w, http.StatusNotFound, response{Status: "error", Error: err.Error()})
+	case err != nil:
+		s.logf("event %s gagal diproses: %v", event.ID, err)
+		s.reply(w, http.StatusInternalServerError, response{Status: "error", Error: err.Error()})
+	default:
+		s.logf("event %s (%s) untuk charge %s diproses", event.ID, event.Status, event.ChargeID)
+		s.reply(w, http.StatusOK, response{Status: "processed"})
+	}
+}
+
+// ListenAndServe menjalankan endpoint webhook di addr sampai ctx dibatalkan
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if len(s.Secret) == 0 {
+		return errors.New("WEBHOOK_SECRET belum diatur")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// reply menulis balasan JSON
+func (s *Server) reply(w http.ResponseWriter, status int, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// now mengembalikan waktu saat ini, bisa diganti saat testing
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// logf mencetak log jika Logger diisi
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pairproject/entity"
+	"pairproject/gateway"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite" // SQLite driver tanpa CGO (untuk testing)
+)
+
+// setupTestWebhookDB membuat tabel yang dibaca endpoint sebelum charge diproses.
+// Pencatatan payment dari event diuji di handler/webhook_handler_test.go.
+func setupTestWebhookDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err, "Gagal membuka database in-memory")
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE billings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			number_display TEXT NOT NULL
+		);
+
+		CREATE TABLE card_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			brand TEXT NOT NULL,
+			last4 TEXT NOT NULL
+		);
+
+		CREATE TABLE payment_charges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			billing_id INTEGER NOT NULL,
+			charge_ref TEXT NOT NULL UNIQUE,
+			amount NUMERIC NOT NULL,
+			fee NUMERIC NOT NULL DEFAULT 0,
+			method TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			message TEXT,
+			card_token_id INTEGER,
+			qr_payload TEXT,
+			payment_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER NOT NULL
+		);
+
+		CREATE TABLE webhook_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL UNIQUE,
+			type TEXT NOT NULL,
+			charge_ref TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'received',
+			error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 1,
+			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			processed_at DATETIME
+		);
+
+		INSERT INTO webhook_events (event_id, type, charge_ref, payload, status) VALUES ('evt_done', 'charge.approved', 'SIM-A-1-1', '{}', 'processed');
+	`)
+	require.NoError(t, err, "Gagal membuat schema webhook")
+
+	return db
+}
+
+// TestServer menguji verifikasi tanda tangan, validasi payload, dan idempotensi endpoint webhook.
+func TestServer(t *testing.T) {
+	db := setupTestWebhookDB(t)
+	defer db.Close()
+
+	secret := []byte("rahasia")
+	server := httptest.NewServer(&Server{DB: db, Secret: secret})
+	defer server.Close()
+
+	ctx := context.Background()
+	event := SampleEvent("SIM-A-1-1", entity.ChargeApproved, entity.NewMoney(50000))
+
+	// Event yang sudah processed dibalas 200 tanpa diproses ulang
+	event.ID = "evt_done"
+	status, body, err := Send(ctx, server.URL, secret, event)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "duplicate")
+
+	// Charge yang belum dikenal dibalas 404 agar gateway mengirim ulang
+	event = SampleEvent("SIM-A-1-2", entity.ChargeApproved, entity.NewMoney(50000))
+	status, _, err = Send(ctx, server.URL, secret, event)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	var eventStatus string
+	require.NoError(t, db.QueryRow("SELECT status FROM webhook_events WHERE event_id = ?", event.ID).Scan(&eventStatus))
+	assert.Equal(t, string(entity.WebhookFailed), eventStatus)
+
+	// Secret berbeda ditolak
+	status, _, err = Send(ctx, server.URL, []byte("palsu"), event)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Tanda tangan lama (replay) ditolak
+	body = `{"id":"evt_old"}`
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(gateway.SignatureHeader, gateway.SignWebhook(secret, time.Now().Add(-time.Hour), []byte(body)))
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// Tanda tangan valid namun payload tidak lengkap
+	req, err = http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(gateway.SignatureHeader, gateway.SignWebhook(secret, time.Now(), []byte(body)))
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// Hanya POST yang diterima
+	res, err = http.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"pairproject/entity"
+	"pairproject/gateway"
+	"strings"
+	"time"
+)
+
+// SampleEvent membuat event contoh seperti yang dikirim gateway untuk charge chargeRef
+func SampleEvent(chargeRef string, status entity.ChargeStatus, amount entity.Money) gateway.Event {
+	now := time.Now()
+	event := gateway.Event{
+		ID:        fmt.Sprintf("evt_local_%d", now.UnixNano()),
+		Type:      "charge." + string(status),
+		ChargeID:  chargeRef,
+		Status:    status,
+		Amount:    amount,
+		CreatedAt: now,
+	}
+	if status == entity.ChargeDeclined {
+		event.Message = "Transaksi ditolak oleh bank penerbit"
+	}
+	return event
+}
+
+// Send menandatangani event dengan secret lalu mengirimnya ke url seperti yang dilakukan gateway.
+// Dipakai untuk menguji endpoint webhook secara lokal; mengembalikan status HTTP dan body balasan.
+func Send(ctx context.Context, url string, secret []byte, event gateway.Event) (int, string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(gateway.SignatureHeader, gateway.SignWebhook(secret, time.Now(), body))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("Gagal mengirim webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	reply, err := io.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	if err != nil {
+		return res.StatusCode, "", err
+	}
+
+	return res.StatusCode, strings.TrimSpace(string(reply)), nil
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"pairproject/gateway"
+	"pairproject/handler"
+	"time"
+)
+
+// DefaultInterval adalah jeda default antar pengecekan billing kedaluwarsa
+const DefaultInterval = time.Minute
+
+// Worker menjalankan tugas terjadwal di background: mengonfirmasi charge dan refund pending ke payment gateway
+// dan membatalkan billing yang melewati due_date
+type Worker struct {
+	DB       *sql.DB
+	Interval time.Duration
+	Logger   *log.Logger            // nil berarti tidak mencetak log (misal saat berjalan di dalam proses CLI)
+	Gateway  gateway.PaymentGateway // nil berarti gateway.Default()
+}
+
+// New membuat worker dengan interval default
+func New(db *sql.DB) *Worker {
+	return &Worker{DB: db, Interval: DefaultInterval}
+}
+
+// Run menjalankan pengecekan sekali di awal lalu berulang setiap Interval sampai ctx dibatalkan
+func (w *Worker) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce membatalkan billing yang sudah kedaluwarsa per waktu now dan melepas stok yang ditahannya.
+// Charge pending dikonfirmasi lebih dulu agar pembayaran yang sudah diterima gateway tidak ikut kedaluwarsa,
+// lalu refund pending yang belum selesai diproses gateway dicek atau dikirim ulang.
+func (w *Worker) RunOnce(now time.Time) (int, error) {
+	// Worker berjalan tanpa user login sehingga context tidak berisi user
+	ctx := context.Background()
+	billingHandler := handler.BillingHandler{DB: w.DB, Ctx: &ctx}
+
+	paymentHandler := handler.PaymentHandler{DB: w.DB, Ctx: &ctx, Gateway: w.Gateway}
+	confirmed, err := paymentHandler.SyncPendingCharges(&billingHandler)
+	if err != nil {
+		w.logf("gagal mengonfirmasi charge pending: %v", err)
+	} else if confirmed > 0 {
+		w.logf("%d charge pending selesai diproses gateway", confirmed)
+	}
+
+	refundHandler := handler.RefundHandler{DB: w.DB, Ctx: &ctx, Gateway: w.Gateway}
+	settled, err := refundHandler.SyncPendingRefunds()
+	if err != nil {
+		w.logf("gagal memproses refund pending: %v", err)
+	}
+	if settled > 0 {
+		w.logf("%d refund pending selesai diproses gateway", settled)
+	}
+
+	expired, err := billingHandler.ExpireOverdueBillings(now)
+	if err != nil {
+		w.logf("gagal memproses billing kedaluwarsa: %v", err)
+		return expired, err
+	}
+
+	if expired > 0 {
+		w.logf("%d billing kedaluwarsa dibatalkan", expired)
+	}
+	return expired, nil
+}
+
+// logf mencetak log jika Logger diisi
+func (w *Worker) logf(format string, args ...interface{}) {
+	if w.Logger != nil {
+		w.Logger.Printf(format, args...)
+	}
+}